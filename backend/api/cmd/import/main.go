@@ -0,0 +1,102 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/lib/pq"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/importer"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/tracing"
+)
+
+func main() {
+	entity := flag.String("entity", "", "Entity to import: customers, employees or payments")
+	filePath := flag.String("file", "", "Path to the csv file")
+	dryRun := flag.Bool("dry-run", false, "Validate and report without saving anything")
+	onDuplicate := flag.String("on-duplicate", importer.Skip, "What to do with rows whose email already exists: skip or upsert")
+	reportPath := flag.String("report", "", "Write the full json report to this path")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], config.PostgresSection)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *entity == "" || *filePath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := logger.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cmp.Or(cfg.Tracing.ServiceName, "soul-connection-import"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// NOTE: Interrupting the import cancels its transaction, nothing is saved
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	database.QueryTimeout = cfg.Timeouts.Query
+
+	db, err := database.Open(ctx, cfg.Postgres.ConnectionString())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	file, err := os.Open(*filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	encrypted, err := customers.NewEncryption(cfg.Encryption.Keys, cfg.Encryption.IndexKey, cfg.Encryption.Fields)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := importer.Importer{DB: db, Encryption: encrypted}.Import(ctx, *entity, file, importer.Options{DryRun: *dryRun, OnDuplicate: *onDuplicate})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *reportPath != "" {
+		content, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*reportPath, content, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, row := range report.Rows {
+		for _, fieldError := range row.Errors {
+			slog.Error("invalid row", "line", row.Line, "field", fieldError.Field, "message", fieldError.Message)
+		}
+	}
+	summary := fmt.Sprintf("%d rows: %d inserted, %d updated, %d skipped, %d failed", report.Total, report.Inserted, report.Updated, report.Skipped, report.Failed)
+	if report.DryRun {
+		summary += " (dry run, nothing was saved)"
+	}
+	slog.Info(summary)
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/lib/pq"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/tracing"
+)
+
+func main() {
+	batchSize := flag.Int("batch-size", 100, "Customers updated in each transaction")
+	dryRun := flag.Bool("dry-run", false, "Count the customers to seal again without saving anything")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], config.PostgresSection)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *batchSize < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := logger.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cmp.Or(cfg.Tracing.ServiceName, "soul-connection-reencrypt"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// NOTE: The columns left out of ENCRYPTED_FIELDS are written back in plaintext
+	encrypted, err := customers.NewEncryption(cfg.Encryption.Keys, cfg.Encryption.IndexKey, cfg.Encryption.Fields)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// NOTE: Interrupting only rolls back the batch in progress, running again resumes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	database.QueryTimeout = cfg.Timeouts.Query
+
+	db, err := database.Open(ctx, cfg.Postgres.ConnectionString())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	result, err := customers.CustomersDB{DB: db, Encryption: encrypted}.Reencrypt(ctx, *batchSize, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	message := "Customers sealed again"
+	if *dryRun {
+		message = "Customers to seal again (dry run, nothing was saved)"
+	}
+	slog.Info(message, "customers", result.Customers, "updated", result.Updated, "key", encrypted.Keyring.Current())
+}
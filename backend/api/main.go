@@ -1,71 +1,135 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
 
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/config"
 	"soul-connection.com/api/src/database"
 	"soul-connection.com/api/src/endpoints"
 	filestorage "soul-connection.com/api/src/file-storage"
+	"soul-connection.com/api/src/health"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/metrics"
 	"soul-connection.com/api/src/middleware"
-	"soul-connection.com/api/src/parser"
 	"soul-connection.com/api/src/server"
+	"soul-connection.com/api/src/tracing"
 )
 
 func main() {
-	params, err := parser.ParseArgs()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], config.PostgresSection, config.MongoSection)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if _, err := logger.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Configuration loaded", "config", cfg)
 
-	err = godotenv.Load(*params.EnvPath)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cmp.Or(cfg.Tracing.ServiceName, "soul-connection-api"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer shutdownTracing(context.Background())
+
+	database.QueryTimeout = cfg.Timeouts.Query
+	middleware.ManagerWorks = cfg.Upstream.ManagerWorks
+	filestorage.OperationTimeout = cfg.Timeouts.Storage
+
+	// NOTE: The databases may still be starting, connections are retried
+	// until the startup timeout instead of failing on the first attempt
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Startup)
+	defer cancel()
 
-	database, err := database.Open(database.ConnectionString())
+	var db *sql.DB
+	err = lib.Retry(ctx, "postgres", func(ctx context.Context) (err error) {
+		db, err = database.Open(ctx, cfg.Postgres.ConnectionString())
+		return err
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer database.Close()
+	defer db.Close()
+	metrics.RegisterDB(db, "postgres")
 
-	mongoClient, ctx, err := filestorage.Open(filestorage.ConnectionString())
+	var mongoClient *mongo.Client
+	err = lib.Retry(ctx, "mongo", func(ctx context.Context) (err error) {
+		mongoClient, err = filestorage.Open(ctx, cfg.Mongo.ConnectionString())
+		return err
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer mongoClient.Disconnect(ctx)
+	defer mongoClient.Disconnect(context.Background())
 	fileStorage := mongoClient.Database("soul-connection-files")
 
-	router, err := endpoints.CreateRouter(database, fileStorage)
+	probe := &health.Probe{Checks: map[string]func(context.Context) error{
+		"postgres": db.PingContext,
+		"mongo": func(ctx context.Context) error {
+			return mongoClient.Ping(ctx, nil)
+		},
+	}}
+
+	// NOTE: Changes go through Postgres so the streams of every instance
+	// receive the writes made on any of them
+	bus, err := changes.Listen(db, cfg.Postgres.ConnectionString())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router, err := endpoints.CreateRouter(cfg, db, fileStorage, bus, probe)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	purger, err := endpoints.NewPurger(cfg, db, fileStorage)
 	if err != nil {
 		log.Fatal(err)
 	}
+	purgeCtx, stopPurging := context.WithCancel(context.Background())
+	go purger.Run(purgeCtx, cfg.Retention.Interval)
 
-	corsRouter := mux.NewRouter()
-	corsRouter.Use(middleware.CorsMiddleware)
-	corsRouter.PathPrefix("/").Handler(router)
+	rootRouter := mux.NewRouter()
+	rootRouter.Use(middleware.Cors(middleware.CorsOptions{
+		Origins:          cfg.AllowedOrigins(),
+		Methods:          endpoints.Methods(router),
+		AllowCredentials: cfg.Cors.AllowCredentials,
+		MaxAge:           cfg.Cors.MaxAge,
+	}))
+	rootRouter.PathPrefix("/").Handler(router)
 
 	apiServer := &http.Server{
-		Addr:    fmt.Sprintf(":%d", *params.Port),
-		Handler: corsRouter,
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: rootRouter,
 	}
+	apiServer.RegisterOnShutdown(bus.Close)
+	apiServer.RegisterOnShutdown(stopPurging)
 
 	running := make(chan struct{})
 	go server.Start(apiServer, running)
-	defer server.Stop(apiServer)
+	defer server.Stop(apiServer, probe.Drain, cfg.Timeouts.PreStop, cfg.Timeouts.Drain)
 	<-running
-	initalLog(apiServer, corsRouter)
+	initalLog(apiServer, rootRouter, cfg.Log.Format)
 }
 
-func initalLog(server *http.Server, router *mux.Router) {
-	fmt.Println(`
+func initalLog(server *http.Server, router *mux.Router, logFormat string) {
+	// NOTE: The banner would break the JSON lines of production logs
+	if logFormat == logger.Pretty {
+		fmt.Println(`
    _____  __________ .___
   /  _  \ \______   \|   |
  /  /_\  \ |     ___/|   |
@@ -73,8 +137,9 @@ func initalLog(server *http.Server, router *mux.Router) {
 \____|__  /|____|    |___|
         \/
         `)
+	}
 
-	lib.ServerLog("INFO", fmt.Sprintf("Server is available at %s", server.Addr))
+	slog.Info("Server is available", "address", server.Addr)
 	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
 		pathTemplate, err := route.GetPathTemplate()
 		if err != nil {
@@ -87,7 +152,7 @@ func initalLog(server *http.Server, router *mux.Router) {
 		if err != nil {
 			methods = []string{"ANY"}
 		}
-		lib.ServerLog("INFO", fmt.Sprintf("%-39s %s", pathTemplate, strings.Join(methods, ", ")))
+		slog.Debug("route", "path", pathTemplate, "methods", strings.Join(methods, ", "))
 		return nil
 	})
 }
@@ -0,0 +1,172 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/validation"
+)
+
+const (
+	BadRequestCode       = "bad_request"
+	ValidationFailedCode = "validation_failed"
+	UnauthorizedCode     = "unauthorized"
+	ForbiddenCode        = "forbidden"
+	NotFoundCode         = "not_found"
+	ConflictCode         = "conflict"
+	TooManyRequestsCode  = "too_many_requests"
+	InvalidReferenceCode = "invalid_reference"
+	InternalCode         = "internal_error"
+	TimeoutCode          = "timeout"
+	CancelledCode        = "cancelled"
+
+	RequestIdHeader = "X-Request-ID"
+)
+
+// NOTE: SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	queryCanceled       = "57014"
+)
+
+// StatusClientClosedRequest is sent when the client went away before the
+// response was ready, nobody reads it but it tells the logs apart.
+const StatusClientClosedRequest = 499
+
+// Error is the body of every failed request, wrapped in an Envelope.
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestId string `json:"request_id,omitempty"`
+	// cause is logged but never sent to the client
+	cause error
+}
+
+// Envelope is sent as {"error": Error}.
+type Envelope struct {
+	Error Error `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, BadRequestCode, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, ForbiddenCode, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, NotFoundCode, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, UnauthorizedCode, message)
+}
+
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, TooManyRequestsCode, message)
+}
+
+// From maps err to the Error sent to the client. Missing rows become a 404,
+// unique violations a 409, foreign key violations a 422, exceeded deadlines a
+// 504 and anything else is an opaque 500 so database internals never reach
+// the client.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		return &Error{Status: http.StatusBadRequest, Code: ValidationFailedCode, Message: "Invalid request body", Details: errs, cause: err}
+	}
+
+	if errors.Is(err, database.ErrNoFields) {
+		return &Error{Status: http.StatusBadRequest, Code: BadRequestCode, Message: "No fields to update", cause: err}
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Status: http.StatusNotFound, Code: NotFoundCode, Message: "Resource not found", cause: err}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return timeout(err)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return &Error{Status: StatusClientClosedRequest, Code: CancelledCode, Message: "Request cancelled", cause: err}
+	}
+
+	var sqlErr interface{ SQLState() string }
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.SQLState() {
+		case queryCanceled:
+			// NOTE: lib/pq reports a query cancelled by its context this way
+			return timeout(err)
+		case uniqueViolation:
+			return &Error{Status: http.StatusConflict, Code: ConflictCode, Message: "Resource already exists", cause: err}
+		case foreignKeyViolation:
+			return &Error{Status: http.StatusUnprocessableEntity, Code: InvalidReferenceCode, Message: "Referenced resource does not exist", cause: err}
+		}
+	}
+
+	return &Error{Status: http.StatusInternalServerError, Code: InternalCode, Message: "Internal server error", cause: err}
+}
+
+func timeout(err error) *Error {
+	return &Error{Status: http.StatusGatewayTimeout, Code: TimeoutCode, Message: "Request timed out", cause: err}
+}
+
+// Write maps err with From and sends it as JSON, tagged with the id of req.
+func Write(res http.ResponseWriter, req *http.Request, err error) {
+	apiErr := *From(err)
+	apiErr.RequestId = RequestId(req)
+
+	log := logger.FromContext(context.Background())
+	if req != nil {
+		log = logger.FromContext(req.Context())
+	}
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Error("request failed", "status", apiErr.Status, "error", err)
+	} else if apiErr.cause != nil {
+		log.Warn("request failed", "status", apiErr.Status, "error", err)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(apiErr.Status)
+	if err := json.NewEncoder(res).Encode(Envelope{Error: apiErr}); err != nil {
+		log.Error("could not write error response", "error", err)
+	}
+}
+
+func RequestId(req *http.Request) string {
+	if req == nil {
+		return ""
+	}
+	return req.Header.Get(RequestIdHeader)
+}
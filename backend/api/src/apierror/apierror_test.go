@@ -0,0 +1,88 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lib/pq"
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/validation"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"Api Error", NotFound("Could not find image"), http.StatusNotFound, NotFoundCode},
+		{"Validation", validation.Errors{{Field: "Rating", Message: "must be at most 5"}}, http.StatusBadRequest, ValidationFailedCode},
+		{"No Fields", database.ErrNoFields, http.StatusBadRequest, BadRequestCode},
+		{"No Rows", fmt.Errorf("find customer: %w", sql.ErrNoRows), http.StatusNotFound, NotFoundCode},
+		{"Unique Violation", &pq.Error{Code: "23505"}, http.StatusConflict, ConflictCode},
+		{"Foreign Key Violation", &pq.Error{Code: "23503"}, http.StatusUnprocessableEntity, InvalidReferenceCode},
+		{"Other Database Error", &pq.Error{Code: "08006"}, http.StatusInternalServerError, InternalCode},
+		{"Query Canceled", &pq.Error{Code: "57014"}, http.StatusGatewayTimeout, TimeoutCode},
+		{"Deadline Exceeded", fmt.Errorf("upload: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, TimeoutCode},
+		{"Client Gone", context.Canceled, StatusClientClosedRequest, CancelledCode},
+		{"Unknown", errors.New("connection refused"), http.StatusInternalServerError, InternalCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := From(tt.err)
+			if apiErr.Status != tt.status || apiErr.Code != tt.code {
+				t.Errorf("Expected %d %s, got %d %s", tt.status, tt.code, apiErr.Status, apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/customers", nil)
+	req.Header.Set(RequestIdHeader, "abc-123")
+	rr := httptest.NewRecorder()
+
+	Write(rr, req, errors.New("pq: password authentication failed"))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a json response, got %q", ct)
+	}
+
+	var body map[string]map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := map[string]any{"code": InternalCode, "message": "Internal server error", "request_id": "abc-123"}
+	if fmt.Sprint(body["error"]) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, body["error"])
+	}
+}
+
+func TestWriteDetails(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest(http.MethodPost, "/api/tips", nil), validation.Errors{{Field: "Tip", Message: "is required"}})
+
+	var body struct {
+		Error struct {
+			Code    string
+			Details []validation.FieldError
+		}
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if rr.Code != http.StatusBadRequest || body.Error.Code != ValidationFailedCode || len(body.Error.Details) != 1 || body.Error.Details[0].Field != "Tip" {
+		t.Errorf("Unexpected response %d %+v", rr.Code, body)
+	}
+}
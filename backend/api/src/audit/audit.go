@@ -0,0 +1,326 @@
+// Package audit keeps the append-only record of every write made through
+// the api: who made it, to which row, and what it changed.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/metrics"
+	"soul-connection.com/api/src/middleware"
+)
+
+type Action string
+
+const (
+	Created  Action = "created"
+	Updated  Action = "updated"
+	Deleted  Action = "deleted"
+	Imported Action = "imported"
+	Restored Action = "restored"
+	// Purged rows were deleted long enough ago to be removed for good
+	Purged Action = "purged"
+	// Erased customers had their personal data anonymized on request
+	Erased Action = "erased"
+)
+
+var actions = []Action{Created, Updated, Deleted, Imported, Restored, Purged, Erased}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Entry is a row of audit_log. Actor_Id is the upstream id of the employee
+// who made the request, nil when it was anonymous, and Entity_Id is nil for
+// writes to many rows at once like imports.
+type Entry struct {
+	Id          int       `json:"id" db:"id"`
+	Actor_Id    *int      `json:"actor_id" db:"actor_id"`
+	Actor_Email *string   `json:"actor_email" db:"actor_email"`
+	Entity      string    `json:"entity" db:"entity"`
+	Entity_Id   *int      `json:"entity_id" db:"entity_id"`
+	Action      Action    `json:"action" db:"action"`
+	Request_Id  string    `json:"request_id" db:"request_id"`
+	Diff        Diff      `json:"diff" db:"diff"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AddEntry holds the columns of an Entry written by the api, the database
+// sets the others.
+type AddEntry struct {
+	Actor_Id    *int    `db:"actor_id"`
+	Actor_Email *string `db:"actor_email"`
+	Entity      string  `db:"entity"`
+	Entity_Id   *int    `db:"entity_id"`
+	Action      Action  `db:"action"`
+	Request_Id  string  `db:"request_id"`
+	Diff        Diff    `db:"diff"`
+}
+
+// Filter narrows the entries listed, its nil fields match any entry. The
+// entries are listed newest first, Before_Id pages through older ones.
+type Filter struct {
+	Entity    *string
+	Entity_Id *int
+	Actor_Id  *int
+	Action    *Action
+	From      *time.Time
+	To        *time.Time
+	Before_Id *int
+	Limit     int
+}
+
+// EntriesRepository is the storage behind Log. It can only add and read
+// entries, the log is never pruned nor changed. The diffs hold no personal
+// data, see Personal, so erasing a customer leaves them as they are.
+type EntriesRepository interface {
+	Add(context.Context, *AddEntry) (*Entry, error)
+	Find(context.Context, Filter) ([]Entry, error)
+}
+
+// Log records the writes of the models. A nil Log records nothing, models
+// built without one are not audited.
+type Log struct {
+	// DB runs the transactions of Write, without it the writes and their
+	// entries are made on their own
+	DB      *sql.DB
+	Entries EntriesRepository
+}
+
+// Write runs write in a transaction, along with the entries it records with
+// the context it is given: the write is rolled back unless all of them were
+// recorded.
+func (l *Log) Write(ctx context.Context, write func(context.Context) error) error {
+	if l == nil || l.DB == nil {
+		return write(ctx)
+	}
+	tx, err := database.Begin(ctx, l.DB)
+	if err != nil {
+		return err
+	}
+	if err := write(database.WithTx(ctx, tx.Tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Record appends what the request of ctx did to the row id of entity, 0 for
+// writes to many rows. Before and after are the row as its routes answer it,
+// nil when it did not exist. It is called within Write, so that the write
+// fails with the error when it cannot be recorded.
+func (l *Log) Record(ctx context.Context, entity changes.Entity, action Action, id int, before any, after any) error {
+	if l == nil {
+		return nil
+	}
+	diff, err := NewDiff(before, after)
+	if err == nil {
+		entry := AddEntry{Entity: string(entity), Action: action, Request_Id: middleware.RequestIdFrom(ctx), Diff: diff}
+		if id != 0 {
+			entry.Entity_Id = &id
+		}
+		if user, ok := middleware.UserFrom(ctx); ok {
+			entry.Actor_Id = &user.Id
+			entry.Actor_Email = &user.Email
+		}
+		_, err = l.Entries.Add(ctx, &entry)
+	}
+	if err != nil {
+		metrics.AuditFailures.Inc()
+		logger.FromContext(ctx).Error("Could not record audit entry", "entity", entity, "id", id, "action", action, "error", err)
+		return fmt.Errorf("could not record the %s of %s %d: %w", action, entity, id, err)
+	}
+	return nil
+}
+
+// GetEntries lists the entries matching the query, newest first, for
+// managers only: entity, entity_id, actor_id, action, from and to as
+// YYYY-MM-DD, before_id and limit.
+func (l *Log) GetEntries(res http.ResponseWriter, req *http.Request) {
+	if err := middleware.RequireManager(req, "Only managers can read the audit log"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	filter, err := filterOf(req.URL.Query())
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	entries, err := l.Entries.Find(req.Context(), filter)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(lib.Map(entries, Entry.Response)); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func filterOf(query url.Values) (Filter, error) {
+	filter := Filter{Limit: defaultLimit}
+	if entity := query.Get("entity"); entity != "" {
+		if !slices.Contains(changes.Entities, changes.Entity(entity)) {
+			return filter, apierror.BadRequest(fmt.Sprintf("Unknown entity %q", entity))
+		}
+		filter.Entity = &entity
+	}
+	if action := Action(query.Get("action")); action != "" {
+		if !slices.Contains(actions, action) {
+			return filter, apierror.BadRequest(fmt.Sprintf("Unknown action %q", action))
+		}
+		filter.Action = &action
+	}
+	for name, field := range map[string]**int{"entity_id": &filter.Entity_Id, "actor_id": &filter.Actor_Id, "before_id": &filter.Before_Id} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, apierror.BadRequest(fmt.Sprintf("Invalid %s, expected a number", name))
+		}
+		*field = &id
+	}
+	for name, field := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return filter, apierror.BadRequest("Invalid date format, expected YYYY-MM-DD")
+		}
+		if name == "to" {
+			// NOTE: The last day is included, up to its last second
+			day = day.AddDate(0, 0, 1)
+		}
+		*field = &day
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return filter, apierror.BadRequest(fmt.Sprintf("Invalid limit, expected a number from 1 to %d", maxLimit))
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// FieldChange is the value of a field before and after a write, the side
+// where the row did not exist is left out.
+type FieldChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Diff holds the fields a write changed, by JSON name.
+type Diff map[string]FieldChange
+
+// Personal is implemented by the rows holding personal data, their diffs
+// tell which of PersonalFields changed but never to what. Their changes leave
+// them out as well, see changes.New.
+type Personal interface {
+	PersonalFields() []string
+}
+
+// Redacted stands for the values of the personal fields in a diff.
+var Redacted = json.RawMessage(`"redacted"`)
+
+// NewDiff compares the JSON of before and after field by field, either can
+// be nil for rows created or deleted. The values of their personal fields
+// are replaced with Redacted, see Personal.
+func NewDiff(before any, after any) (Diff, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diff := Diff{}
+	for name, value := range beforeFields {
+		if other, ok := afterFields[name]; !ok || !bytes.Equal(value, other) {
+			diff[name] = FieldChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = FieldChange{After: value}
+		}
+	}
+	for _, row := range []any{before, after} {
+		personal, ok := row.(Personal)
+		if !ok {
+			continue
+		}
+		for _, name := range personal.PersonalFields() {
+			change, ok := diff[name]
+			if !ok {
+				continue
+			}
+			if change.Before != nil {
+				change.Before = Redacted
+			}
+			if change.After != nil {
+				change.After = Redacted
+			}
+			diff[name] = change
+		}
+	}
+	return diff, nil
+}
+
+func jsonFields(row any) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if row == nil {
+		return fields, nil
+	}
+	content, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, fmt.Errorf("audit: %T is not a JSON object: %w", row, err)
+	}
+	return fields, nil
+}
+
+// Value stores the diff as a JSON string, Postgres casts it to jsonb.
+func (d Diff) Value() (driver.Value, error) {
+	content, err := json.Marshal(d)
+	return string(content), err
+}
+
+func (d *Diff) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, d)
+	case string:
+		return json.Unmarshal([]byte(src), d)
+	case nil:
+		*d = Diff{}
+		return nil
+	}
+	return errors.New("audit: diff is not JSON")
+}
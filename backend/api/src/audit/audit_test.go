@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/middleware"
+)
+
+type MockEntriesDB struct {
+	Added  []AddEntry
+	Filter Filter
+	Err    error
+}
+
+func (m *MockEntriesDB) Add(ctx context.Context, entry *AddEntry) (*Entry, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.Added = append(m.Added, *entry)
+	return &Entry{Id: len(m.Added)}, nil
+}
+
+func (m *MockEntriesDB) Find(ctx context.Context, filter Filter) ([]Entry, error) {
+	m.Filter = filter
+	return []Entry{{Id: 2, Entity: "tips", Action: Deleted, Diff: Diff{}}}, nil
+}
+
+type row struct {
+	Title string `json:"title"`
+	Tip   string `json:"tip"`
+}
+
+type personalRow struct {
+	Tip          string `json:"tip"`
+	Phone_Number string `json:"phone_number"`
+}
+
+func (personalRow) PersonalFields() []string {
+	return []string{"phone_number"}
+}
+
+func TestNewDiff(t *testing.T) {
+	t.Run("Created", func(t *testing.T) {
+		diff, err := NewDiff(nil, row{Title: "Listen", Tip: "Ask"})
+		if err != nil || len(diff) != 2 || string(diff["title"].After) != `"Listen"` || diff["title"].Before != nil {
+			t.Errorf("Expected every field after, got %v (%v)", diff, err)
+		}
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		diff, err := NewDiff(row{Title: "Listen", Tip: "Ask"}, row{Title: "Listen", Tip: "Smile"})
+		if err != nil || len(diff) != 1 || string(diff["tip"].Before) != `"Ask"` || string(diff["tip"].After) != `"Smile"` {
+			t.Errorf("Expected only the tip, got %v (%v)", diff, err)
+		}
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		diff, err := NewDiff(row{Title: "Listen"}, nil)
+		if err != nil || len(diff) != 2 || diff["title"].After != nil {
+			t.Errorf("Expected every field before, got %v (%v)", diff, err)
+		}
+	})
+
+	t.Run("Personal", func(t *testing.T) {
+		diff, err := NewDiff(personalRow{Tip: "Ask", Phone_Number: "0600000000"}, personalRow{Tip: "Smile", Phone_Number: "0611111111"})
+		if err != nil || string(diff["tip"].After) != `"Smile"` {
+			t.Fatalf("Expected the other fields as they are, got %v (%v)", diff, err)
+		}
+		if change := diff["phone_number"]; string(change.Before) != string(Redacted) || string(change.After) != string(Redacted) {
+			t.Errorf("Expected the phone number to be redacted, got %s %s", change.Before, change.After)
+		}
+		diff, _ = NewDiff(nil, personalRow{Phone_Number: "0600000000"})
+		if change := diff["phone_number"]; change.Before != nil || string(change.After) != string(Redacted) {
+			t.Errorf("Expected only the side that exists, got %s %s", change.Before, change.After)
+		}
+	})
+
+	t.Run("Not An Object", func(t *testing.T) {
+		if _, err := NewDiff([]int{1}, nil); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("Actor And Request", func(t *testing.T) {
+		entries := &MockEntriesDB{}
+		log := &Log{Entries: entries}
+		ctx := middleware.WithUser(context.Background(), &middleware.User{Id: 50, Email: "coach@soul-connection.fr"})
+
+		log.Record(ctx, changes.Tips, Updated, 3, row{Tip: "Ask"}, row{Tip: "Smile"})
+		if len(entries.Added) != 1 {
+			t.Fatalf("Expected an entry, got %d", len(entries.Added))
+		}
+		entry := entries.Added[0]
+		if *entry.Actor_Id != 50 || *entry.Actor_Email != "coach@soul-connection.fr" || *entry.Entity_Id != 3 || entry.Entity != "tips" || len(entry.Diff) != 1 {
+			t.Errorf("Expected tip 3 updated by 50, got %+v", entry)
+		}
+	})
+
+	t.Run("Anonymous Import", func(t *testing.T) {
+		entries := &MockEntriesDB{}
+		(&Log{Entries: entries}).Record(context.Background(), changes.Customers, Imported, 0, nil, map[string]int{"inserted": 2})
+		if entry := entries.Added[0]; entry.Actor_Id != nil || entry.Entity_Id != nil {
+			t.Errorf("Expected no actor nor row, got %+v", entry)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		err := (&Log{Entries: &MockEntriesDB{Err: errors.New("down")}}).Record(context.Background(), changes.Tips, Deleted, 1, row{}, nil)
+		if err == nil || !strings.Contains(err.Error(), "down") {
+			t.Errorf("Expected the error of the entries, got %v", err)
+		}
+	})
+
+	t.Run("Nil Log", func(t *testing.T) {
+		var log *Log
+		if err := log.Record(context.Background(), changes.Tips, Deleted, 1, row{}, nil); err != nil {
+			t.Errorf("Expected nothing to be recorded, got %v", err)
+		}
+	})
+}
+
+func TestGetEntries(t *testing.T) {
+	serve := func(user *middleware.User, query string) (*httptest.ResponseRecorder, *MockEntriesDB) {
+		entries := &MockEntriesDB{}
+		req := httptest.NewRequest(http.MethodGet, "/api/audit"+query, nil)
+		if user != nil {
+			req = req.WithContext(middleware.WithUser(req.Context(), user))
+		}
+		res := httptest.NewRecorder()
+		(&Log{Entries: entries}).GetEntries(res, req)
+		return res, entries
+	}
+	manager := &middleware.User{Id: 90, Work: "Manager"}
+
+	t.Run("Filters", func(t *testing.T) {
+		res, entries := serve(manager, "?entity=customers&entity_id=4&actor_id=50&action=updated&from=2024-05-01&to=2024-05-31&before_id=80&limit=10")
+		if res.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d %s", res.Code, res.Body.String())
+		}
+		filter := entries.Filter
+		if *filter.Entity != "customers" || *filter.Entity_Id != 4 || *filter.Actor_Id != 50 || *filter.Action != Updated || *filter.Before_Id != 80 || filter.Limit != 10 {
+			t.Errorf("Expected every filter, got %+v", filter)
+		}
+		if filter.To.Format("2006-01-02") != "2024-06-01" {
+			t.Errorf("Expected the last day to be included, got %v", filter.To)
+		}
+		var body []EntryResponse
+		if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil || len(body) != 1 || body[0].Id != 2 {
+			t.Errorf("Expected entry 2, got %s", res.Body.String())
+		}
+	})
+
+	t.Run("Default Limit", func(t *testing.T) {
+		if _, entries := serve(manager, ""); entries.Filter.Limit != defaultLimit || entries.Filter.Entity != nil {
+			t.Errorf("Expected no filter and the default limit, got %+v", entries.Filter)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if res, _ := serve(nil, ""); res.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 without a user, got %d", res.Code)
+		}
+		if res, _ := serve(&middleware.User{Work: middleware.CoachWork}, ""); res.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for a coach, got %d", res.Code)
+		}
+		for _, query := range []string{"?entity=secrets", "?action=read", "?actor_id=me", "?from=yesterday", "?limit=0", "?limit=5000"} {
+			if res, _ := serve(manager, query); res.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", query, res.Code)
+			}
+		}
+	})
+}
+
+func TestDiffValue(t *testing.T) {
+	value, err := Diff{"tip": {Before: json.RawMessage(`"Ask"`)}}.Value()
+	if err != nil || value != `{"tip":{"before":"Ask"}}` {
+		t.Errorf("Expected the diff as JSON, got %v (%v)", value, err)
+	}
+	var diff Diff
+	if err := diff.Scan([]byte(value.(string))); err != nil || !strings.Contains(string(diff["tip"].Before), "Ask") {
+		t.Errorf("Expected the diff back, got %v (%v)", diff, err)
+	}
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"soul-connection.com/api/src/database"
+)
+
+// EntriesDB stores the entries in audit_log, where a trigger refuses any
+// UPDATE, DELETE or TRUNCATE.
+type EntriesDB struct {
+	DB *sql.DB
+}
+
+func repository(q database.Queryer) database.Repository[Entry] {
+	return database.Repository[Entry]{DB: q, Table: "audit_log"}
+}
+
+func (db EntriesDB) Add(ctx context.Context, entry *AddEntry) (*Entry, error) {
+	return repository(db.DB).Add(ctx, entry)
+}
+
+func (db EntriesDB) Find(ctx context.Context, filter Filter) ([]Entry, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.Entity != nil {
+		where("entity = $%d", *filter.Entity)
+	}
+	if filter.Entity_Id != nil {
+		where("entity_id = $%d", *filter.Entity_Id)
+	}
+	if filter.Actor_Id != nil {
+		where("actor_id = $%d", *filter.Actor_Id)
+	}
+	if filter.Action != nil {
+		where("action = $%d", string(*filter.Action))
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+	if filter.Before_Id != nil {
+		where("id < $%d", *filter.Before_Id)
+	}
+	if len(conditions) == 0 {
+		conditions = append(conditions, "1 = 1")
+	}
+	args = append(args, filter.Limit)
+	clause := fmt.Sprintf("%s ORDER BY id DESC LIMIT $%d", strings.Join(conditions, " AND "), len(args))
+
+	return repository(db.DB).FindWhere(ctx, clause, args...)
+}
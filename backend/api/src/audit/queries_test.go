@@ -0,0 +1,128 @@
+package audit_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/database"
+)
+
+func setupTestDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := `
+    CREATE TABLE IF NOT EXISTS "audit_log" (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        actor_id INT,
+        actor_email VARCHAR(255),
+        entity VARCHAR(50) NOT NULL,
+        entity_id INT,
+        action VARCHAR(20) NOT NULL,
+        request_id VARCHAR(255) NOT NULL DEFAULT '',
+        diff TEXT NOT NULL DEFAULT '{}',
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    );
+	`
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestEntriesDB(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	entriesDB := audit.EntriesDB{DB: db}
+
+	for _, entry := range []audit.AddEntry{
+		{Actor_Id: ptr(50), Entity: "customers", Entity_Id: ptr(4), Action: audit.Created, Diff: audit.Diff{}},
+		{Actor_Id: ptr(50), Entity: "customers", Entity_Id: ptr(4), Action: audit.Updated, Request_Id: "abc", Diff: audit.Diff{"name": {Before: []byte(`"Ada"`), After: []byte(`"Grace"`)}}},
+		{Actor_Id: ptr(60), Entity: "tips", Entity_Id: ptr(1), Action: audit.Deleted, Diff: audit.Diff{}},
+	} {
+		if _, err := entriesDB.Add(ctx, &entry); err != nil {
+			t.Fatalf("Failed to add entry: %v", err)
+		}
+	}
+
+	t.Run("Newest First", func(t *testing.T) {
+		entries, err := entriesDB.Find(ctx, audit.Filter{Limit: 2})
+		if err != nil || len(entries) != 2 || entries[0].Id != 3 || entries[1].Id != 2 {
+			t.Fatalf("Expected entries 3 and 2, got %+v (%v)", entries, err)
+		}
+		if string(entries[1].Diff["name"].After) != `"Grace"` || entries[1].Request_Id != "abc" {
+			t.Errorf("Expected the diff of entry 2, got %+v", entries[1])
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		entries, err := entriesDB.Find(ctx, audit.Filter{Entity: ptr("customers"), Actor_Id: ptr(50), Before_Id: ptr(2), Limit: 10})
+		if err != nil || len(entries) != 1 || entries[0].Action != audit.Created {
+			t.Errorf("Expected the creation of customer 4, got %+v (%v)", entries, err)
+		}
+		entries, err = entriesDB.Find(ctx, audit.Filter{Action: ptr(audit.Deleted), From: ptr(time.Now().Add(time.Hour)), Limit: 10})
+		if err != nil || len(entries) != 0 {
+			t.Errorf("Expected no entry, got %+v (%v)", entries, err)
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	// NOTE: Every connection of :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE tip (id INTEGER PRIMARY KEY AUTOINCREMENT, tip TEXT NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create the table: %v", err)
+	}
+	ctx := context.Background()
+	log := &audit.Log{DB: db, Entries: audit.EntriesDB{DB: db}}
+	write := func(ctx context.Context) error {
+		if _, err := database.Conn(ctx, db).ExecContext(ctx, `INSERT INTO tip (tip) VALUES ('Listen')`); err != nil {
+			return err
+		}
+		return log.Record(ctx, changes.Tips, audit.Created, 1, nil, map[string]string{"tip": "Listen"})
+	}
+	count := func(table string) (n int) {
+		db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n)
+		return n
+	}
+
+	if err := log.Write(ctx, write); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if count("tip") != 1 || count("audit_log") != 1 {
+		t.Errorf("Expected the write and its entry, got %d tips and %d entries", count("tip"), count("audit_log"))
+	}
+
+	if _, err := db.Exec(`DROP TABLE audit_log`); err != nil {
+		t.Fatalf("Failed to drop the table: %v", err)
+	}
+	if err := log.Write(ctx, write); err == nil {
+		t.Errorf("Expected the write to fail without its entry")
+	}
+	if count("tip") != 1 {
+		t.Errorf("Expected the write to be rolled back, got %d tips", count("tip"))
+	}
+}
@@ -0,0 +1,30 @@
+package audit
+
+import "time"
+
+// EntryResponse is the JSON of an Entry.
+type EntryResponse struct {
+	Id          int       `json:"id"`
+	Actor_Id    *int      `json:"actor_id"`
+	Actor_Email *string   `json:"actor_email"`
+	Entity      string    `json:"entity"`
+	Entity_Id   *int      `json:"entity_id"`
+	Action      Action    `json:"action"`
+	Request_Id  string    `json:"request_id"`
+	Diff        Diff      `json:"diff"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (e Entry) Response() EntryResponse {
+	return EntryResponse{
+		Id:          e.Id,
+		Actor_Id:    e.Actor_Id,
+		Actor_Email: e.Actor_Email,
+		Entity:      e.Entity,
+		Entity_Id:   e.Entity_Id,
+		Action:      e.Action,
+		Request_Id:  e.Request_Id,
+		Diff:        e.Diff,
+		CreatedAt:   e.CreatedAt,
+	}
+}
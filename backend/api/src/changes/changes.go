@@ -0,0 +1,199 @@
+// Package changes carries the rows created, updated and deleted through the
+// api to the clients following them, see Bus.
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"soul-connection.com/api/src/logger"
+)
+
+// Entity names the table of a change, like the base path of its routes.
+type Entity string
+
+const (
+	Employees  Entity = "employees"
+	Customers  Entity = "customers"
+	Events     Entity = "events"
+	Payments   Entity = "payments"
+	Encounters Entity = "encounters"
+	Clothes    Entity = "clothes"
+	Tips       Entity = "tips"
+)
+
+// Entities lists every entity changes are published for.
+var Entities = []Entity{Employees, Customers, Events, Payments, Encounters, Clothes, Tips}
+
+type Action string
+
+const (
+	Created Action = "created"
+	Updated Action = "updated"
+	Deleted Action = "deleted"
+)
+
+// Change is published once a row has been written. Data is the row as its
+// routes answer it without its personal fields, missing for deletions and for
+// rows too big to be carried by Postgres, clients fetch those again.
+type Change struct {
+	Entity Entity `json:"entity"`
+	Action Action `json:"action"`
+	Id     int    `json:"id"`
+	// CustomerId is the customer the row belongs to, or the customer itself,
+	// coaches only receive the changes of their own customers
+	CustomerId *int `json:"customer_id,omitempty"`
+	// EmployeeId is the coach of a changed customer once it is written
+	EmployeeId *int            `json:"employee_id,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	At         time.Time       `json:"at"`
+}
+
+// personal is audit.Personal, the rows holding personal data. Changes go
+// through Postgres notifications and every stream, they never carry it.
+type personal interface {
+	PersonalFields() []string
+}
+
+// New describes the change of the row id of entity, row is encoded as Data
+// unless it was deleted.
+func New(entity Entity, action Action, id int, row any) Change {
+	change := Change{Entity: entity, Action: action, Id: id, At: time.Now().UTC()}
+	if row != nil && action != Deleted {
+		// NOTE: Rows are the response types of the routes, they always encode
+		change.Data, _ = json.Marshal(row)
+		if p, ok := row.(personal); ok {
+			change.Data = without(change.Data, p.PersonalFields())
+		}
+	}
+	return change
+}
+
+// without removes fields from the JSON object data.
+func without(data json.RawMessage, fields []string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil
+	}
+	for _, field := range fields {
+		delete(object, field)
+	}
+	data, _ = json.Marshal(object)
+	return data
+}
+
+// ForCustomer marks the change as one of the rows of the customer id.
+func (c Change) ForCustomer(id int) Change {
+	c.CustomerId = &id
+	return c
+}
+
+// WithCoach records the coach of a changed customer.
+func (c Change) WithCoach(employeeId *int) Change {
+	c.EmployeeId = employeeId
+	return c
+}
+
+// subscriberBuffer is how many changes a subscriber may lag behind before it
+// is dropped, the stream of a client that stopped reading must not block the
+// others.
+const subscriberBuffer = 64
+
+// Bus fans the published changes out to its subscribers. Made with NewBus it
+// only reaches the subscribers of the same process, made with Listen it goes
+// through Postgres and reaches those of every instance of the api.
+//
+// A nil Bus drops every change, models built without one publish nothing.
+type Bus struct {
+	// notify sends a change to every instance, nil delivers it in process
+	notify func(ctx context.Context, payload []byte) error
+	stop   func() error
+
+	mu          sync.Mutex
+	subscribers map[chan Change]struct{}
+	closed      bool
+}
+
+// NewBus delivers the changes to the subscribers of this process.
+func NewBus() *Bus {
+	return &Bus{subscribers: map[chan Change]struct{}{}}
+}
+
+// Publish sends change to every subscriber. It is called once the write it
+// describes succeeded and never fails the request, a change that could not
+// be sent is logged.
+func (b *Bus) Publish(ctx context.Context, change Change) {
+	if b == nil {
+		return
+	}
+	if b.notify == nil {
+		b.deliver(change)
+		return
+	}
+	if err := b.notify(ctx, encode(change)); err != nil {
+		logger.FromContext(ctx).Error("Could not publish change", "entity", change.Entity, "id", change.Id, "error", err)
+	}
+}
+
+// Subscribe answers the changes published from now on. The channel is
+// closed by unsubscribe, when the bus closes and when the subscriber falls
+// too far behind, the caller should then start over.
+func (b *Bus) Subscribe() (changes <-chan Change, unsubscribe func()) {
+	ch := make(chan Change, subscriberBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drop(ch)
+	}
+}
+
+// Close ends every subscription and stops listening to Postgres, it is meant
+// to run when the server shuts down so streams do not hold it.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for ch := range b.subscribers {
+		b.drop(ch)
+	}
+	b.mu.Unlock()
+
+	if b.stop != nil {
+		if err := b.stop(); err != nil {
+			slog.Error("Could not stop listening to changes", "error", err)
+		}
+	}
+}
+
+func (b *Bus) deliver(change Change) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- change:
+		default:
+			b.drop(ch)
+		}
+	}
+}
+
+// drop must be called with mu held.
+func (b *Bus) drop(ch chan Change) {
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
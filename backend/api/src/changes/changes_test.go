@@ -0,0 +1,115 @@
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type personalRow struct {
+	Id      int    `json:"id"`
+	Email   string `json:"email"`
+	Address string `json:"address"`
+}
+
+func (personalRow) PersonalFields() []string {
+	return []string{"email", "address"}
+}
+
+func TestBus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Fan Out", func(t *testing.T) {
+		bus := NewBus()
+		first, unsubscribeFirst := bus.Subscribe()
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := bus.Subscribe()
+		defer unsubscribeSecond()
+
+		bus.Publish(ctx, New(Tips, Created, 3, map[string]string{"title": "Listen"}))
+		for _, received := range []<-chan Change{first, second} {
+			change := <-received
+			if change.Entity != Tips || change.Action != Created || change.Id != 3 || string(change.Data) != `{"title":"Listen"}` {
+				t.Errorf("Expected tip 3 created, got %+v", change)
+			}
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		bus := NewBus()
+		received, unsubscribe := bus.Subscribe()
+		unsubscribe()
+		unsubscribe()
+		bus.Publish(ctx, New(Tips, Deleted, 3, nil))
+		if _, ok := <-received; ok {
+			t.Error("Expected no change once unsubscribed")
+		}
+	})
+
+	t.Run("Slow Subscriber", func(t *testing.T) {
+		bus := NewBus()
+		slow, unsubscribeSlow := bus.Subscribe()
+		defer unsubscribeSlow()
+		for i := range subscriberBuffer + 1 {
+			bus.Publish(ctx, New(Tips, Updated, i, nil))
+		}
+		count := 0
+		for range slow {
+			count++
+		}
+		if count != subscriberBuffer {
+			t.Errorf("Expected the %d buffered changes before being dropped, got %d", subscriberBuffer, count)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		bus := NewBus()
+		received, _ := bus.Subscribe()
+		bus.Close()
+		if _, ok := <-received; ok {
+			t.Error("Expected subscriptions to end with the bus")
+		}
+		late, _ := bus.Subscribe()
+		if _, ok := <-late; ok {
+			t.Error("Expected a closed bus to refuse subscribers")
+		}
+	})
+
+	t.Run("Nil Bus", func(t *testing.T) {
+		var bus *Bus
+		bus.Publish(ctx, New(Tips, Created, 1, nil))
+	})
+
+	t.Run("Personal Fields Left Out", func(t *testing.T) {
+		change := New(Customers, Updated, 4, personalRow{Id: 4, Email: "ada@test.com", Address: "1 rue de Paris"})
+		if string(change.Data) != `{"id":4}` {
+			t.Errorf("Expected the personal fields to be left out, got %s", change.Data)
+		}
+	})
+
+	t.Run("Deletions Have No Data", func(t *testing.T) {
+		change := New(Payments, Deleted, 1, map[string]int{"id": 1}).ForCustomer(4)
+		if change.Data != nil || *change.CustomerId != 4 {
+			t.Errorf("Expected a deletion of customer 4 without data, got %+v", change)
+		}
+	})
+}
+
+func TestEncode(t *testing.T) {
+	small := New(Customers, Updated, 1, map[string]string{"name": "Ada"})
+	var decoded Change
+	if err := json.Unmarshal(encode(small), &decoded); err != nil || string(decoded.Data) != `{"name":"Ada"}` {
+		t.Errorf("Expected the row to be kept, got %s (%v)", decoded.Data, err)
+	}
+
+	big := New(Customers, Updated, 1, map[string]string{"description": strings.Repeat("a", maxPayload)})
+	payload := encode(big)
+	if len(payload) > maxPayload {
+		t.Fatalf("Expected at most %d bytes, got %d", maxPayload, len(payload))
+	}
+	decoded = Change{}
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.Data != nil || decoded.Id != 1 {
+		t.Errorf("Expected the change of customer 1 without its row, got %+v (%v)", decoded, err)
+	}
+}
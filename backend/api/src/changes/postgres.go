@@ -0,0 +1,100 @@
+package changes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"soul-connection.com/api/src/database"
+)
+
+// channel is the Postgres channel every instance notifies and listens to.
+const channel = "api_changes"
+
+// maxPayload keeps notifications under the 8000 bytes Postgres accepts.
+const maxPayload = 7900
+
+// pingInterval is how often an idle listener checks its connection.
+const pingInterval = 90 * time.Second
+
+// Listen makes a bus whose changes are sent with NOTIFY on db and received
+// with LISTEN on a connection of its own to connectionString, so subscribers
+// of one instance receive the changes published by any other.
+func Listen(db *sql.DB, connectionString string) (*Bus, error) {
+	listener := pq.NewListener(connectionString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Change listener connection failed", "event", event, "error", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	bus := NewBus()
+	bus.notify = func(ctx context.Context, payload []byte) error {
+		ctx, cancel := database.WithQueryTimeout(ctx)
+		defer cancel()
+		_, err := db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, string(payload))
+		return err
+	}
+	done := make(chan struct{})
+	bus.stop = func() error {
+		err := listener.Close()
+		<-done
+		return err
+	}
+	go bus.listen(listener, done)
+	return bus, nil
+}
+
+func (b *Bus) listen(listener *pq.Listener, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			// NOTE: nil follows a reconnection, the changes sent while the
+			// connection was down are lost so every subscriber starts over
+			if notification == nil {
+				slog.Warn("Change listener reconnected, dropping subscribers")
+				b.reset()
+				continue
+			}
+			var change Change
+			if err := json.Unmarshal([]byte(notification.Extra), &change); err != nil {
+				slog.Error("Could not decode change", "error", err)
+				continue
+			}
+			b.deliver(change)
+		case <-time.After(pingInterval):
+			go listener.Ping()
+		}
+	}
+}
+
+// reset ends every subscription without closing the bus.
+func (b *Bus) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		b.drop(ch)
+	}
+}
+
+// encode answers the payload of change, without its row when it is too big
+// for a notification.
+func encode(change Change) []byte {
+	payload, _ := json.Marshal(change)
+	if len(payload) > maxPayload {
+		change.Data = nil
+		payload, _ = json.Marshal(change)
+	}
+	return payload
+}
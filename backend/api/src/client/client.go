@@ -0,0 +1,357 @@
+// Package client calls the api from Go with the same types the handlers use.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/graph"
+	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/openapi"
+)
+
+// Error is a failed request, decoded from the error envelope of the api.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    json.RawMessage
+	RequestId  string
+}
+
+func (e *Error) Error() string {
+	if e.RequestId != "" {
+		return fmt.Sprintf("api: %d %s: %s (request %s)", e.StatusCode, e.Code, e.Message, e.RequestId)
+	}
+	return fmt.Sprintf("api: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// TokenSource answers the bearer token sent with every request. It is asked
+// once and asked again when the api answers 401, the token may have expired.
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken always answers token.
+func StaticToken(token string) TokenSource {
+	return func(ctx context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// UpstreamLogin logs in to the upstream api, whose tokens the api accepts.
+func UpstreamLogin(credentials lib.LoginCredentials) TokenSource {
+	return func(ctx context.Context) (string, error) {
+		return lib.Auth(ctx, credentials)
+	}
+}
+
+type Option func(*Client)
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithTokenSource(tokens TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+func WithToken(token string) Option {
+	return WithTokenSource(StaticToken(token))
+}
+
+// Client calls the api found at a base url like http://localhost:8000, one
+// field per group of routes.
+type Client struct {
+	Employees  *EmployeesService
+	Customers  *CustomersService
+	Events     *EventsService
+	Payments   *PaymentsService
+	Encounters *EncountersService
+	Clothes    *ClothesService
+	Tips       *TipsService
+	Imports    *ImportsService
+
+	baseURL    string
+	httpClient *http.Client
+	tokens     TokenSource
+
+	mu    sync.Mutex
+	token string
+}
+
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), httpClient: http.DefaultClient}
+	for _, option := range options {
+		option(c)
+	}
+	c.Employees = &EmployeesService{resource[employees.EmployeeResponse, employees.AddEmployee, employees.UpdateEmployee]{c, "/api/v1/employees"}}
+	c.Customers = &CustomersService{resource[customers.CustomerResponse, customers.AddCustomer, customers.UpdateCustomer]{c, "/api/v1/customers"}}
+	c.Events = &EventsService{resource[events.EventResponse, events.AddEvent, events.UpdateEvent]{c, "/api/v1/events"}}
+	c.Payments = &PaymentsService{resource[payments.PaymentResponse, payments.AddPayment, payments.UpdatePayment]{c, "/api/v1/payments"}}
+	c.Encounters = &EncountersService{resource[encounters.EncounterResponse, encounters.AddEncounter, encounters.UpdateEncounter]{c, "/api/v1/encounters"}}
+	c.Clothes = &ClothesService{resource[clothes.ClotheResponse, clothes.AddClothe, clothes.UpdateClothe]{c, "/api/v1/clothes"}}
+	c.Tips = &TipsService{resource[tips.TipResponse, tips.AddTip, tips.UpdateTip]{c, "/api/v1/tips"}}
+	c.Imports = &ImportsService{c}
+	return c
+}
+
+// Ready is nil when the api and its databases are up.
+func (c *Client) Ready(ctx context.Context) error {
+	return c.json(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+}
+
+// OpenAPI fetches the document describing every route.
+func (c *Client) OpenAPI(ctx context.Context) (*openapi.Document, error) {
+	var doc openapi.Document
+	if err := c.json(ctx, http.MethodGet, "/api/openapi.json", nil, nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// GraphQLError is a field a GraphQL query could not read, Code is the code
+// of the REST error it maps to, like forbidden.
+type GraphQLError struct {
+	Message string
+	Path    []any
+	Code    string
+}
+
+// GraphQLErrors are returned along with whatever data could be read.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = fmt.Sprintf("%v: %s", err.Path, err.Message)
+	}
+	return "graphql: " + strings.Join(messages, ", ")
+}
+
+// GraphQL runs query with variables and decodes its data in out, the fields
+// that failed are reported as GraphQLErrors.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	var response struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string `json:"message"`
+			Path       []any  `json:"path"`
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := c.json(ctx, http.MethodPost, "/api/graphql", nil, graph.Request{Query: query, Variables: variables}, &response); err != nil {
+		return err
+	}
+	if out != nil && len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(response.Errors) == 0 {
+		return nil
+	}
+	errs := make(GraphQLErrors, len(response.Errors))
+	for i, err := range response.Errors {
+		errs[i] = GraphQLError{Message: err.Message, Path: err.Path, Code: err.Extensions.Code}
+	}
+	return errs
+}
+
+// Stream follows the changes the user may read and calls fn with each of
+// them, entities keeps only those. It returns once ctx is done, fn fails or
+// the api ends the stream, nil then: callers usually follow it again after
+// fetching what they show anew.
+func (c *Client) Stream(ctx context.Context, fn func(changes.Change) error, entities ...changes.Entity) error {
+	query := url.Values{}
+	if len(entities) > 0 {
+		names := make([]string, len(entities))
+		for i, entity := range entities {
+			names[i] = string(entity)
+		}
+		query.Set("entities", strings.Join(names, ","))
+	}
+	res, err := c.do(ctx, request{method: http.MethodGet, path: "/api/stream", query: query, accept: "text/event-stream"})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var change changes.Change
+		if err := json.Unmarshal([]byte(data), &change); err != nil {
+			return err
+		}
+		if err := fn(change); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// request is kept whole so it can be sent again with a new token.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+	accept      string
+}
+
+// do sends r and answers the response of a successful request, failures are
+// turned into an *Error.
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	res, err := c.send(ctx, r, false)
+	if err == nil && res.StatusCode == http.StatusUnauthorized && c.tokens != nil {
+		res.Body.Close()
+		res, err = c.send(ctx, r, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+	return res, nil
+}
+
+func (c *Client) send(ctx context.Context, r request, refresh bool) (*http.Response, error) {
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if r.accept != "" {
+		req.Header.Set("Accept", r.accept)
+	}
+	if c.tokens != nil {
+		token, err := c.bearer(ctx, refresh)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) bearer(ctx context.Context, refresh bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && !refresh {
+		return c.token, nil
+	}
+	token, err := c.tokens(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	return token, nil
+}
+
+func decodeError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode, RequestId: res.Header.Get(apierror.RequestIdHeader)}
+	var envelope struct {
+		Error struct {
+			Code      string          `json:"code"`
+			Message   string          `json:"message"`
+			Details   json.RawMessage `json:"details"`
+			RequestId string          `json:"request_id"`
+		} `json:"error"`
+	}
+	content, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if json.Unmarshal(content, &envelope) != nil || envelope.Error.Code == "" {
+		// NOTE: Proxies and the upstream api do not answer envelopes
+		apiErr.Code = strings.ToLower(strings.ReplaceAll(http.StatusText(res.StatusCode), " ", "_"))
+		apiErr.Message = strings.TrimSpace(string(content))
+		return apiErr
+	}
+	apiErr.Code = envelope.Error.Code
+	apiErr.Message = envelope.Error.Message
+	apiErr.Details = envelope.Error.Details
+	if envelope.Error.RequestId != "" {
+		apiErr.RequestId = envelope.Error.RequestId
+	}
+	return apiErr
+}
+
+// json sends in as the JSON body, if any, and decodes the response in out.
+func (c *Client) json(ctx context.Context, method string, path string, query url.Values, in any, out any) error {
+	r := request{method: method, path: path, query: query}
+	if in != nil {
+		body, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		r.body = body
+		r.contentType = "application/json"
+	}
+	res, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// get decodes the JSON answered by a GET of path.
+func get[T any](ctx context.Context, c *Client, path string, query url.Values) (T, error) {
+	var out T
+	err := c.json(ctx, http.MethodGet, path, query, nil, &out)
+	return out, err
+}
+
+// raw answers the raw body of the response, images and documents.
+func (c *Client) raw(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	res, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
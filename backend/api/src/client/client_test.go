@@ -0,0 +1,412 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/client"
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/endpoints"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/health"
+)
+
+// MockCustomersDB only implements what the tests call, the embedded
+// interface panics on anything else.
+type MockCustomersDB struct {
+	customers.CustomersRepository
+	Customers   []customers.Customer
+	Files       map[primitive.ObjectID][]byte
+	withDeleted bool
+}
+
+func (m *MockCustomersDB) FindAll(ctx context.Context) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if m.withDeleted || customer.Deleted_At == nil {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockCustomersDB) FindByID(ctx context.Context, id int) (*customers.Customer, error) {
+	for _, customer := range m.Customers {
+		if customer.Id == id && (m.withDeleted || customer.Deleted_At == nil) {
+			return &customer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCustomersDB) IncludingDeleted() customers.CustomersRepository {
+	return &MockCustomersDB{Customers: m.Customers, Files: m.Files, withDeleted: true}
+}
+
+func (m *MockCustomersDB) Delete(ctx context.Context, id int) error {
+	for i := range m.Customers {
+		if m.Customers[i].Id == id && m.Customers[i].Deleted_At == nil {
+			now := time.Now()
+			m.Customers[i].Deleted_At = &now
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (m *MockCustomersDB) Restore(ctx context.Context, id int) (*customers.Customer, error) {
+	for i := range m.Customers {
+		if m.Customers[i].Id == id && m.Customers[i].Deleted_At != nil {
+			m.Customers[i].Deleted_At = nil
+			return &m.Customers[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCustomersDB) FindByEmployeeID(ctx context.Context, id int) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if customer.Employee_Id != nil && *customer.Employee_Id == id {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockCustomersDB) FindByPhoneNumber(ctx context.Context, phoneNumber string) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if customer.Phone_Number == phoneNumber && (m.withDeleted || customer.Deleted_At == nil) {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockCustomersDB) Add(ctx context.Context, customer *customers.AddCustomer) (*customers.Customer, error) {
+	added := customers.Customer{Id: len(m.Customers) + 1, Email: customer.Email, Name: customer.Name, Surname: customer.Surname, Employee_Id: customer.Employee_Id}
+	m.Customers = append(m.Customers, added)
+	return &added, nil
+}
+
+func (m *MockCustomersDB) GetFile(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	content, ok := m.Files[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return content, nil
+}
+
+type MockPaymentsDB struct {
+	payments.PaymentsRepository
+	Payments []payments.Payment
+}
+
+func (m *MockPaymentsDB) Add(ctx context.Context, payment *payments.AddPayment) (*payments.Payment, error) {
+	added := payments.Payment{Id: len(m.Payments) + 1, Date: payment.Date, PaymentMethod: payment.PaymentMethod, Amount: payment.Amount, CustomerId: payment.CustomerId}
+	m.Payments = append(m.Payments, added)
+	return &added, nil
+}
+
+func (m *MockPaymentsDB) FindByCustomerID(ctx context.Context, id int) ([]payments.Payment, error) {
+	var result []payments.Payment
+	for _, payment := range m.Payments {
+		if payment.CustomerId == id {
+			result = append(result, payment)
+		}
+	}
+	return result, nil
+}
+
+func setupServer(t *testing.T, wrap func(http.Handler) http.Handler) (*MockCustomersDB, *httptest.Server) {
+	imageId := primitive.NewObjectID()
+	hex := imageId.Hex()
+	employeeId := 7
+	customersDB := &MockCustomersDB{
+		Customers: []customers.Customer{
+			{Id: 1, Email: "jane@example.com", Name: "Jane", Surname: "Doe", Image_Id: &hex, Employee_Id: &employeeId},
+			{Id: 2, Email: "john@example.com", Name: "John", Surname: "Doe", Phone_Number: "0612345678"},
+		},
+		Files: map[primitive.ObjectID][]byte{imageId: []byte("\x89PNG")},
+	}
+
+	// NOTE: The upstream api vouches for any token, for the routes behind auth,
+	// "coach" is the token of a coach and any other one that of a manager
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") == "Bearer coach" {
+			res.Write([]byte(`{"id": 2, "email": "coach@example.com", "work": "Coach"}`))
+			return
+		}
+		res.Write([]byte(`{"id": 1, "email": "manager@example.com", "work": "Manager"}`))
+	}))
+	t.Cleanup(upstream.Close)
+	cfg := &config.Config{}
+	cfg.Upstream.BaseUri = upstream.URL
+
+	bus := changes.NewBus()
+	t.Cleanup(bus.Close)
+	router, err := endpoints.NewRouter(cfg, endpoints.Models{
+		Customers: customers.CustomersModel{Customers: customersDB, Changes: bus},
+		Payments:  payments.PaymentModel{Payments: &MockPaymentsDB{}, Changes: bus},
+		Changes:   bus,
+	}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	var handler http.Handler = router
+	if wrap != nil {
+		handler = wrap(router)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return customersDB, server
+}
+
+func TestClient(t *testing.T) {
+	_, server := setupServer(t, nil)
+	api := client.New(server.URL)
+	ctx := context.Background()
+
+	t.Run("List", func(t *testing.T) {
+		all, err := api.Customers.List(ctx, client.CustomerFilter{})
+		if err != nil || len(all) != 2 {
+			t.Fatalf("Expected 2 customers, got %v, %v", all, err)
+		}
+		employeeId := 7
+		assigned, err := api.Customers.List(ctx, client.CustomerFilter{EmployeeId: &employeeId})
+		if err != nil || len(assigned) != 1 || assigned[0].Name != "Jane" || !assigned[0].Has_Image {
+			t.Fatalf("Expected the customer of employee 7, got %v, %v", assigned, err)
+		}
+		found, err := api.Customers.List(ctx, client.CustomerFilter{PhoneNumber: "0612345678"})
+		if err != nil || len(found) != 1 || found[0].Name != "John" {
+			t.Fatalf("Expected the customer with this phone number, got %v, %v", found, err)
+		}
+	})
+
+	t.Run("Create And Get", func(t *testing.T) {
+		added, err := api.Customers.Create(ctx, customers.AddCustomer{Email: "ada@example.com", Name: "Ada", Surname: "Lovelace"})
+		if err != nil {
+			t.Fatalf("Failed to create customer: %v", err)
+		}
+		got, err := api.Customers.Get(ctx, added.Id)
+		if err != nil || got.Email != "ada@example.com" {
+			t.Fatalf("Expected the added customer, got %v, %v", got, err)
+		}
+	})
+
+	t.Run("Payments", func(t *testing.T) {
+		added, err := api.Payments.Create(ctx, payments.AddPayment{Date: "2024-05-01", PaymentMethod: "PayPal", Amount: 1250, CustomerId: 1})
+		if err != nil || added.Amount != 1250 {
+			t.Fatalf("Expected the amount to round trip, got %v, %v", added, err)
+		}
+		customerId := 1
+		list, err := api.Payments.List(ctx, client.PaymentFilter{CustomerId: &customerId})
+		if err != nil || len(list) != 1 {
+			t.Fatalf("Expected the payment of customer 1, got %v, %v", list, err)
+		}
+	})
+
+	t.Run("Image", func(t *testing.T) {
+		image, err := api.Customers.Image(ctx, 1)
+		if err != nil || !bytes.Equal(image, []byte("\x89PNG")) {
+			t.Fatalf("Expected the image bytes, got %q, %v", image, err)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := api.Customers.Get(ctx, 404)
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != apierror.NotFoundCode || apiErr.RequestId == "" {
+			t.Fatalf("Expected a not found error with a request id, got %v", err)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := api.Customers.Create(ctx, customers.AddCustomer{Email: "not an email"})
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != apierror.ValidationFailedCode || !strings.Contains(string(apiErr.Details), `"email"`) {
+			t.Fatalf("Expected the invalid fields, got %v", err)
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	_, server := setupServer(t, nil)
+	api := client.New(server.URL, client.WithToken("token"))
+	ctx := context.Background()
+
+	if err := api.Customers.Delete(ctx, 2); err != nil {
+		t.Fatalf("Failed to delete customer: %v", err)
+	}
+	if live, err := api.Customers.List(ctx, client.CustomerFilter{}); err != nil || len(live) != 1 {
+		t.Fatalf("Expected the deleted customer to be left out, got %v, %v", live, err)
+	}
+	all, err := api.Customers.List(ctx, client.CustomerFilter{IncludeDeleted: true})
+	if err != nil || len(all) != 2 || all[1].Deleted_At == nil {
+		t.Fatalf("Expected the deleted customer with its deletion date, got %v, %v", all, err)
+	}
+
+	var apiErr *client.Error
+	if _, err := client.New(server.URL).Customers.Restore(ctx, 2); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 restoring a customer without a token, got %v", err)
+	}
+	if _, err := client.New(server.URL, client.WithToken("coach")).Customers.Restore(ctx, 2); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 restoring a customer as a coach, got %v", err)
+	}
+	restored, err := api.Customers.Restore(ctx, 2)
+	if err != nil || restored.Deleted_At != nil {
+		t.Fatalf("Expected the customer to be restored, got %v, %v", restored, err)
+	}
+	if _, err := api.Customers.Restore(ctx, 2); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring a customer that is not deleted, got %v", err)
+	}
+
+	_, err = client.New(server.URL).Customers.List(ctx, client.CustomerFilter{IncludeDeleted: true})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 including deleted customers without a token, got %v", err)
+	}
+}
+
+func TestPrivacy(t *testing.T) {
+	_, server := setupServer(t, nil)
+	ctx := context.Background()
+
+	var apiErr *client.Error
+	if _, err := client.New(server.URL).Customers.Export(ctx, 1); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 exporting a customer without a token, got %v", err)
+	}
+	if _, err := client.New(server.URL).Customers.Erase(ctx, 1); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 erasing a customer without a token, got %v", err)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	_, server := setupServer(t, nil)
+	api := client.New(server.URL, client.WithToken("token"))
+	ctx := context.Background()
+
+	t.Run("Data", func(t *testing.T) {
+		var data struct {
+			Customers []struct {
+				Name        string
+				Employee_Id *int
+			}
+		}
+		if err := api.GraphQL(ctx, `{ customers { name employee_id } }`, nil, &data); err != nil {
+			t.Fatalf("Failed to query customers: %v", err)
+		}
+		if len(data.Customers) != 2 || data.Customers[0].Name != "Jane" || *data.Customers[0].Employee_Id != 7 {
+			t.Fatalf("Expected the customers, got %+v", data)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		var data struct {
+			Customer *struct{ Name string }
+		}
+		err := api.GraphQL(ctx, `query ($id: Int!) { customer(id: $id) { name } }`, map[string]any{"id": 404}, &data)
+		var errs client.GraphQLErrors
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != apierror.NotFoundCode || data.Customer != nil {
+			t.Fatalf("Expected a not found field, got %v", err)
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	_, server := setupServer(t, nil)
+	api := client.New(server.URL, client.WithToken("token"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan changes.Change, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- api.Stream(ctx, func(change changes.Change) error {
+			select {
+			case received <- change:
+			default:
+			}
+			cancel()
+			return nil
+		}, changes.Customers)
+	}()
+
+	// NOTE: Customers are added until the stream, opened concurrently, sees one
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case change := <-received:
+			if change.Entity != changes.Customers || change.Action != changes.Created || !strings.Contains(string(change.Data), `"id"`) {
+				t.Errorf("Expected a customer created, got %+v", change)
+			}
+			if strings.Contains(string(change.Data), `"ada@example.com"`) {
+				t.Errorf("Expected the email of the customer to be left out, got %s", change.Data)
+			}
+			if err := <-done; err != nil {
+				t.Errorf("Expected the stream to end cleanly, got %v", err)
+			}
+			return
+		case <-ticker.C:
+			if _, err := api.Customers.Create(context.Background(), customers.AddCustomer{Email: "ada@example.com", Name: "Ada", Surname: "Lovelace"}); err != nil {
+				t.Fatalf("Failed to create customer: %v", err)
+			}
+		case <-timeout:
+			t.Fatal("Expected a change")
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	requireToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") != "Bearer fresh" {
+				apierror.Write(res, req, apierror.Unauthorized("Unauthorized"))
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+	_, server := setupServer(t, requireToken)
+	ctx := context.Background()
+
+	t.Run("Refresh", func(t *testing.T) {
+		tokens := []string{"expired", "fresh"}
+		calls := 0
+		api := client.New(server.URL, client.WithTokenSource(func(ctx context.Context) (string, error) {
+			calls++
+			return tokens[min(calls, len(tokens))-1], nil
+		}))
+		for range 2 {
+			if _, err := api.Customers.Get(ctx, 1); err != nil {
+				t.Fatalf("Expected the token to be refreshed, got %v", err)
+			}
+		}
+		if calls != 2 {
+			t.Fatalf("Expected the fresh token to be cached, got %d calls", calls)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		api := client.New(server.URL, client.WithToken("wrong"))
+		_, err := api.Customers.List(ctx, client.CustomerFilter{})
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != apierror.UnauthorizedCode {
+			t.Fatalf("Expected an unauthorized error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/privacy"
+	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/importer"
+)
+
+// resource holds the routes every entity has: T is the entity, A the body
+// adding one and U the body updating one.
+type resource[T any, A any, U any] struct {
+	c    *Client
+	path string
+}
+
+func (r resource[T, A, U]) list(ctx context.Context, path string) ([]T, error) {
+	return get[[]T](ctx, r.c, path, nil)
+}
+
+func (r resource[T, A, U]) one(ctx context.Context, method string, path string, in any) (*T, error) {
+	var item T
+	if err := r.c.json(ctx, method, path, nil, in, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r resource[T, A, U]) Get(ctx context.Context, id int) (*T, error) {
+	return r.one(ctx, http.MethodGet, r.item(id), nil)
+}
+
+func (r resource[T, A, U]) Create(ctx context.Context, add A) (*T, error) {
+	return r.one(ctx, http.MethodPost, r.path, add)
+}
+
+// Update only changes the fields of update that are not nil.
+func (r resource[T, A, U]) Update(ctx context.Context, id int, update U) (*T, error) {
+	return r.one(ctx, http.MethodPatch, r.item(id), update)
+}
+
+func (r resource[T, A, U]) Delete(ctx context.Context, id int) error {
+	return r.c.json(ctx, http.MethodDelete, r.item(id), nil, nil, nil)
+}
+
+// Export downloads every entity as format, csv or xlsx.
+func (r resource[T, A, U]) Export(ctx context.Context, format string) ([]byte, error) {
+	return r.c.raw(ctx, r.path, url.Values{"format": {format}})
+}
+
+func (r resource[T, A, U]) item(id int) string {
+	return fmt.Sprintf("%s/%d", r.path, id)
+}
+
+type EmployeesService struct {
+	resource[employees.EmployeeResponse, employees.AddEmployee, employees.UpdateEmployee]
+}
+
+func (s *EmployeesService) List(ctx context.Context) ([]employees.EmployeeResponse, error) {
+	return s.list(ctx, s.path)
+}
+
+func (s *EmployeesService) Image(ctx context.Context, id int) ([]byte, error) {
+	return s.c.raw(ctx, s.item(id)+"/image", nil)
+}
+
+// Restore brings back a deleted employee that was not purged yet.
+func (s *EmployeesService) Restore(ctx context.Context, id int) (*employees.EmployeeResponse, error) {
+	return s.one(ctx, http.MethodPost, s.item(id)+"/restore", nil)
+}
+
+// CustomerFilter narrows CustomersService.List, the zero value lists them all
+// but the deleted ones, which only managers may include.
+type CustomerFilter struct {
+	EmployeeId *int
+	// PhoneNumber keeps the customers with exactly this phone number
+	PhoneNumber    string
+	IncludeDeleted bool
+}
+
+type CustomersService struct {
+	resource[customers.CustomerResponse, customers.AddCustomer, customers.UpdateCustomer]
+}
+
+func (s *CustomersService) List(ctx context.Context, filter CustomerFilter) ([]customers.CustomerResponse, error) {
+	if filter.EmployeeId != nil {
+		return s.list(ctx, fmt.Sprintf("%s/employees/%d", s.path, *filter.EmployeeId))
+	}
+	query := url.Values{}
+	if filter.PhoneNumber != "" {
+		query.Set("phone_number", filter.PhoneNumber)
+	}
+	if filter.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	if len(query) > 0 {
+		return get[[]customers.CustomerResponse](ctx, s.c, s.path, query)
+	}
+	return s.list(ctx, s.path)
+}
+
+// Restore brings back a deleted customer that was not purged yet.
+func (s *CustomersService) Restore(ctx context.Context, id int) (*customers.CustomerResponse, error) {
+	return s.one(ctx, http.MethodPost, s.item(id)+"/restore", nil)
+}
+
+func (s *CustomersService) Image(ctx context.Context, id int) ([]byte, error) {
+	return s.c.raw(ctx, s.item(id)+"/image", nil)
+}
+
+func (s *CustomersService) Assign(ctx context.Context, id int, assignment customers.AssignCustomer) (*customers.CustomerResponse, error) {
+	return s.one(ctx, http.MethodPost, s.item(id)+"/assign", assignment)
+}
+
+func (s *CustomersService) Unassign(ctx context.Context, id int) (*customers.CustomerResponse, error) {
+	return s.one(ctx, http.MethodPost, s.item(id)+"/unassign", nil)
+}
+
+func (s *CustomersService) Assignments(ctx context.Context, id int) ([]customers.AssignmentResponse, error) {
+	return get[[]customers.AssignmentResponse](ctx, s.c, s.item(id)+"/assignments", nil)
+}
+
+func (s *CustomersService) Reassign(ctx context.Context, reassignment customers.ReassignCustomers) ([]customers.CustomerResponse, error) {
+	var list []customers.CustomerResponse
+	if err := s.c.json(ctx, http.MethodPost, s.path+"/reassign", nil, reassignment, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// StatementOptions bound the payments of a statement, From and To are
+// YYYY-MM-DD days and Store keeps a copy on the server.
+type StatementOptions struct {
+	From  string
+	To    string
+	Store bool
+}
+
+// Statement downloads the PDF statement of the payments of a customer.
+func (s *CustomersService) Statement(ctx context.Context, id int, options StatementOptions) ([]byte, error) {
+	query := url.Values{}
+	setQuery(query, "from", options.From)
+	setQuery(query, "to", options.To)
+	if options.Store {
+		query.Set("store", "true")
+	}
+	return s.c.raw(ctx, s.item(id)+"/statement", query)
+}
+
+// Export downloads the ZIP of everything held about a customer, managers
+// only, see privacy.Manifest for its manifest.json.
+func (s *CustomersService) Export(ctx context.Context, id int) ([]byte, error) {
+	return s.c.raw(ctx, s.item(id)+"/export", nil)
+}
+
+// Erase anonymizes a customer for good, managers only.
+func (s *CustomersService) Erase(ctx context.Context, id int) (*privacy.ErasureResponse, error) {
+	var erasure privacy.ErasureResponse
+	if err := s.c.json(ctx, http.MethodPost, s.item(id)+"/erase", nil, nil, &erasure); err != nil {
+		return nil, err
+	}
+	return &erasure, nil
+}
+
+type EventsService struct {
+	resource[events.EventResponse, events.AddEvent, events.UpdateEvent]
+}
+
+func (s *EventsService) List(ctx context.Context) ([]events.EventResponse, error) {
+	return s.list(ctx, s.path)
+}
+
+// PaymentFilter narrows PaymentsService.List, the zero value lists them all.
+type PaymentFilter struct {
+	CustomerId *int
+}
+
+type PaymentsService struct {
+	resource[payments.PaymentResponse, payments.AddPayment, payments.UpdatePayment]
+}
+
+func (s *PaymentsService) List(ctx context.Context, filter PaymentFilter) ([]payments.PaymentResponse, error) {
+	if filter.CustomerId != nil {
+		return s.list(ctx, fmt.Sprintf("%s/customer/%d", s.path, *filter.CustomerId))
+	}
+	return s.list(ctx, s.path)
+}
+
+// DocumentOptions pick the kind of document, receipt or invoice, and Store
+// keeps a copy on the server.
+type DocumentOptions struct {
+	Type  string
+	Store bool
+}
+
+// Document downloads the PDF receipt or invoice of a payment.
+func (s *PaymentsService) Document(ctx context.Context, id int, options DocumentOptions) ([]byte, error) {
+	query := url.Values{}
+	setQuery(query, "type", options.Type)
+	if options.Store {
+		query.Set("store", "true")
+	}
+	return s.c.raw(ctx, s.item(id)+"/document", query)
+}
+
+func (s *PaymentsService) RevenueByCustomer(ctx context.Context) ([]payments.CustomerRevenue, error) {
+	return get[[]payments.CustomerRevenue](ctx, s.c, s.path+"/revenue/customers", nil)
+}
+
+func (s *PaymentsService) RevenueForCustomer(ctx context.Context, customerId int) (*payments.CustomerRevenue, error) {
+	return get[*payments.CustomerRevenue](ctx, s.c, s.path+"/revenue/customers/"+strconv.Itoa(customerId), nil)
+}
+
+func (s *PaymentsService) RevenueByEmployee(ctx context.Context) ([]payments.EmployeeRevenue, error) {
+	return get[[]payments.EmployeeRevenue](ctx, s.c, s.path+"/revenue/employees", nil)
+}
+
+// RevenueByMonth sums the payments of each month from and to included,
+// written YYYY-MM, either may be empty.
+func (s *PaymentsService) RevenueByMonth(ctx context.Context, from string, to string) ([]payments.MonthlyRevenue, error) {
+	query := url.Values{}
+	setQuery(query, "from", from)
+	setQuery(query, "to", to)
+	return get[[]payments.MonthlyRevenue](ctx, s.c, s.path+"/revenue/months", query)
+}
+
+func (s *PaymentsService) RevenueByMethod(ctx context.Context) ([]payments.MethodRevenue, error) {
+	return get[[]payments.MethodRevenue](ctx, s.c, s.path+"/revenue/methods", nil)
+}
+
+// EncounterFilter narrows EncountersService.List, the zero value lists them all.
+type EncounterFilter struct {
+	CustomerId *int
+}
+
+type EncountersService struct {
+	resource[encounters.EncounterResponse, encounters.AddEncounter, encounters.UpdateEncounter]
+}
+
+func (s *EncountersService) List(ctx context.Context, filter EncounterFilter) ([]encounters.EncounterResponse, error) {
+	if filter.CustomerId != nil {
+		return s.list(ctx, fmt.Sprintf("%s/customer/%d", s.path, *filter.CustomerId))
+	}
+	return s.list(ctx, s.path)
+}
+
+// ClotheFilter narrows ClothesService.List, the zero value lists them all.
+type ClotheFilter struct {
+	CustomerId *int
+}
+
+type ClothesService struct {
+	resource[clothes.ClotheResponse, clothes.AddClothe, clothes.UpdateClothe]
+}
+
+func (s *ClothesService) List(ctx context.Context, filter ClotheFilter) ([]clothes.ClotheResponse, error) {
+	if filter.CustomerId != nil {
+		return s.list(ctx, fmt.Sprintf("%s/customer/%d", s.path, *filter.CustomerId))
+	}
+	return s.list(ctx, s.path)
+}
+
+func (s *ClothesService) Image(ctx context.Context, id int) ([]byte, error) {
+	return s.c.raw(ctx, s.item(id)+"/image", nil)
+}
+
+type TipsService struct {
+	resource[tips.TipResponse, tips.AddTip, tips.UpdateTip]
+}
+
+func (s *TipsService) List(ctx context.Context) ([]tips.TipResponse, error) {
+	return s.list(ctx, s.path)
+}
+
+type ImportsService struct {
+	c *Client
+}
+
+// Import sends a csv file of entity rows, like "customers", and answers the
+// report of what was, or with DryRun would be, saved.
+func (s *ImportsService) Import(ctx context.Context, entity string, file io.Reader, options importer.Options) (*importer.Report, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	setQuery(query, "on_duplicate", options.OnDuplicate)
+
+	res, err := s.c.do(ctx, request{method: http.MethodPost, path: "/api/v1/import/" + url.PathEscape(entity), query: query, body: content, contentType: "text/csv"})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var report importer.Report
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func setQuery(query url.Values, key string, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Config holds every setting of the api, the migration service and the import
+// command. Each field is read from the `env` variable of its tag and can be
+// overridden by the flag named after it, POSTGRES_HOST is -postgres-host.
+// Fields tagged `secret` are redacted whenever the config is printed.
+type Config struct {
+	Port        int64  `env:"PORT" default:"8000" validate:"min=1,max=65535" usage:"Port of the api"`
+	MetricsPort int64  `env:"METRICS_PORT" default:"9100" validate:"min=0,max=65535" usage:"Port of the /metrics endpoint of the migration service, 0 disables it"`
+	WebUrl      string `env:"WEB_URL" validate:"url" usage:"Origin of the web app, always allowed by CORS"`
+
+	Cors       Cors
+	Postgres   Postgres
+	Mongo      Mongo
+	Upstream   Upstream
+	Log        Log
+	Tracing    Tracing
+	Timeouts   Timeouts
+	Retention  Retention
+	Encryption Encryption
+	RateLimit  RateLimit
+}
+
+// Cors is the policy of cross-origin requests to the api, the web app at
+// WebUrl is always allowed on top of Origins.
+type Cors struct {
+	Origins          []string      `env:"CORS_ORIGINS" usage:"Other origins allowed, comma separated, like https://*.example.com or * for any"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"true" usage:"Whether browsers send cookies and Authorization headers, never allowed with *"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m" validate:"min=0" usage:"How long browsers cache a preflight response"`
+}
+
+// AllowedOrigins lists WebUrl and the other origins of the policy.
+func (c *Config) AllowedOrigins() []string {
+	var origins []string
+	if c.WebUrl != "" {
+		origins = append(origins, c.WebUrl)
+	}
+	return append(origins, c.Cors.Origins...)
+}
+
+type Postgres struct {
+	User     string `env:"POSTGRES_USER" required:"true" usage:"Postgres user"`
+	Password string `env:"POSTGRES_PASSWORD" required:"true" secret:"true" usage:"Postgres password"`
+	Name     string `env:"POSTGRES_DB" required:"true" usage:"Postgres database"`
+	Host     string `env:"POSTGRES_HOST" required:"true" usage:"Postgres host"`
+	Port     int64  `env:"POSTGRES_PORT" default:"5432" validate:"min=1,max=65535" usage:"Postgres port"`
+}
+
+func (p Postgres) ConnectionString() string {
+	u := url.URL{
+		Scheme:   "postgresql",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     net.JoinHostPort(p.Host, strconv.FormatInt(p.Port, 10)),
+		Path:     "/" + p.Name,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
+
+type Mongo struct {
+	User     string `env:"MONGO_USER" required:"true" usage:"MongoDB user"`
+	Password string `env:"MONGO_PASSWORD" required:"true" secret:"true" usage:"MongoDB password"`
+	Host     string `env:"MONGO_HOST" required:"true" usage:"MongoDB host"`
+	Port     int64  `env:"MONGO_PORT" default:"27017" validate:"min=1,max=65535" usage:"MongoDB port"`
+}
+
+func (m Mongo) ConnectionString() string {
+	u := url.URL{
+		Scheme:   "mongodb",
+		User:     url.UserPassword(m.User, m.Password),
+		Host:     net.JoinHostPort(m.Host, strconv.FormatInt(m.Port, 10)),
+		Path:     "/",
+		RawQuery: "authSource=admin",
+	}
+	return u.String()
+}
+
+// Upstream is the Soul Connection api the employees log in with and the
+// migration service copies its data from.
+type Upstream struct {
+	BaseUri  string `env:"API_BASE_URI" default:"https://soul-connection.fr" validate:"url" usage:"Base URI of the upstream api"`
+	ApiKey   string `env:"API_KEY" required:"true" secret:"true" usage:"Group key of the upstream api"`
+	Email    string `env:"API_EMAIL" required:"true" validate:"email" usage:"Email the migration service logs in with"`
+	Password string `env:"API_PASSWORD" required:"true" secret:"true" usage:"Password the migration service logs in with"`
+	// NOTE: Employees of any other work are held to what a coach may do
+	ManagerWorks []string `env:"MANAGER_WORKS" default:"Manager" usage:"Works of the upstream employees managing the agency, matched exactly, comma separated"`
+}
+
+type Log struct {
+	Format string `env:"LOG_FORMAT" default:"json" validate:"oneof=json|pretty" usage:"Log format"`
+	Level  string `env:"LOG_LEVEL" default:"info" validate:"oneof=debug|info|warn|error" usage:"Lowest level logged"`
+}
+
+type Tracing struct {
+	Exporter    string `env:"OTEL_TRACES_EXPORTER" default:"none" validate:"oneof=none|otlp|stdout" usage:"Where spans are exported"`
+	Endpoint    string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"url" usage:"Endpoint of the OTLP collector"`
+	ServiceName string `env:"OTEL_SERVICE_NAME" usage:"Service name of the spans, the name of the program by default"`
+}
+
+type Timeouts struct {
+	Request time.Duration `env:"REQUEST_TIMEOUT" default:"30s" validate:"min=0" usage:"Deadline of a request to the api, streams and exports are left without one"`
+	Query   time.Duration `env:"QUERY_TIMEOUT" default:"10s" validate:"min=0" usage:"Deadline of a single SQL query"`
+	Storage time.Duration `env:"STORAGE_TIMEOUT" default:"30s" validate:"min=0" usage:"Deadline of a single file storage operation"`
+	Drain   time.Duration `env:"DRAIN_TIMEOUT" default:"15s" validate:"min=0" usage:"Time given to requests in flight on shutdown"`
+	PreStop time.Duration `env:"PRESTOP_DELAY" default:"5s" validate:"min=0" usage:"Time the api keeps serving while not ready on shutdown, so the orchestrator stops routing to it first"`
+	Startup time.Duration `env:"STARTUP_TIMEOUT" default:"1m" validate:"min=0" usage:"Time given to the databases to come up"`
+}
+
+// Retention is how long the deleted employees and customers are kept before
+// the api removes them for good.
+type Retention struct {
+	Period   time.Duration `env:"RETENTION_PERIOD" default:"720h" validate:"min=0" usage:"How long deleted employees and customers are kept, 0 keeps them forever"`
+	Interval time.Duration `env:"RETENTION_INTERVAL" default:"1h" validate:"min=0" usage:"How often the rows kept long enough are purged, 0 never purges them"`
+}
+
+// Encryption seals the sensitive columns of the customers at rest, no keys
+// leave them in plaintext. The first key seals, the others only open what
+// they sealed until the reencrypt command moves it to the first.
+type Encryption struct {
+	Keys     []string `env:"ENCRYPTION_KEYS" secret:"true" usage:"AES-256 keys as id:base64, comma separated, the first one seals"`
+	IndexKey string   `env:"ENCRYPTION_INDEX_KEY" secret:"true" usage:"Key of the blind indexes in base64, at least 32 bytes, never rotated"`
+	Fields   []string `env:"ENCRYPTED_FIELDS" default:"phone_number,address,birth_date,description" usage:"Columns of the customers sealed, comma separated"`
+}
+
+// RateLimit throttles the clients of the api under /api, see
+// ratelimit.ParseRules, and locks accounts out after failed logins.
+type RateLimit struct {
+	Rules         []string      `env:"RATE_LIMITS" default:"default=300/1m" usage:"Limits as prefix=requests/period:key, key being ip, api_key or employee, comma separated, like default=300/1m,/api/v1/import=10/1m:employee"`
+	RedisUrl      string        `env:"RATE_LIMIT_REDIS_URL" secret:"true" usage:"redis://[user:password@]host:port[/db] shared by every instance, each one counts on its own when empty"`
+	PerAddress    string        `env:"RATE_LIMIT_PER_ADDRESS" default:"600/1m" usage:"Limit of every address under /api as requests/period, counted before the authentication so that forged tokens are throttled too"`
+	ForwardedFor  bool          `env:"RATE_LIMIT_FORWARDED_FOR" default:"false" usage:"Tell clients apart by the last address of X-Forwarded-For, only behind a proxy setting it"`
+	LoginAttempts int64         `env:"LOGIN_MAX_ATTEMPTS" default:"5" validate:"min=0" usage:"Failed logins locking an account, 0 never locks it"`
+	LoginWindow   time.Duration `env:"LOGIN_ATTEMPTS_WINDOW" default:"15m" validate:"min=0" usage:"Window the failed logins are counted in"`
+	Lockout       time.Duration `env:"LOGIN_LOCKOUT" default:"15m" validate:"min=0" usage:"How long a locked account is refused"`
+}
+
+// Section names of Config, as passed to Load.
+const (
+	PostgresSection = "Postgres"
+	MongoSection    = "Mongo"
+	UpstreamSection = "Upstream"
+)
@@ -0,0 +1,256 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"soul-connection.com/api/src/validation"
+)
+
+const redacted = "[REDACTED]"
+
+// setting is a single field of Config, found through its tags.
+type setting struct {
+	key      string
+	flag     string
+	usage    string
+	fallback string
+	section  string
+	secret   bool
+	required bool
+	value    reflect.Value
+}
+
+func (c *Config) settings() []setting {
+	var result []setting
+	collect(reflect.ValueOf(c).Elem(), "", &result)
+	return result
+}
+
+func collect(v reflect.Value, section string, result *[]setting) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("env")
+		if key == "" {
+			if field.Type.Kind() == reflect.Struct {
+				collect(v.Field(i), field.Name, result)
+			}
+			continue
+		}
+		*result = append(*result, setting{
+			key:      key,
+			flag:     strings.ReplaceAll(strings.ToLower(key), "_", "-"),
+			usage:    field.Tag.Get("usage"),
+			fallback: field.Tag.Get("default"),
+			section:  section,
+			secret:   field.Tag.Get("secret") == "true",
+			required: field.Tag.Get("required") == "true",
+			value:    v.Field(i),
+		})
+	}
+}
+
+// Load reads the Config from the flags of args, then the environment, then
+// the .env file given by -env-path if any, and falls back to the defaults.
+// Empty values count as unset. The required fields of sections, like
+// PostgresSection, must be set and every value must pass its `validate`
+// rules, all the invalid ones are reported at once.
+func Load(fs *flag.FlagSet, args []string, sections ...string) (*Config, error) {
+	var c Config
+	settings := c.settings()
+
+	envPath := fs.String("env-path", "", "Path to an optional .env file, the environment takes precedence over it")
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (%s)", s.usage, s.key)
+		if s.fallback != "" {
+			usage = fmt.Sprintf("%s (%s, default %s)", s.usage, s.key, s.fallback)
+		}
+		flags[s.flag] = fs.String(s.flag, "", usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	file := map[string]string{}
+	if *envPath != "" {
+		var err error
+		if file, err = godotenv.Read(*envPath); err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", *envPath, err)
+		}
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var errs validation.Errors
+	for _, s := range settings {
+		raw := s.fallback
+		if value := file[s.key]; value != "" {
+			raw = value
+		}
+		if value := os.Getenv(s.key); value != "" {
+			raw = value
+		}
+		if set[s.flag] {
+			raw = *flags[s.flag]
+		}
+		if message := s.parse(raw); message != "" {
+			errs = append(errs, validation.FieldError{Field: s.key, Message: message})
+		}
+	}
+	if len(errs) == 0 {
+		errs = c.validate(sections)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errs)
+	}
+	return &c, nil
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+func (s setting) parse(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	switch {
+	case s.value.Type() == durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return "must be a duration like 30s"
+		}
+		s.value.SetInt(int64(duration))
+	case s.value.Kind() == reflect.Int64:
+		number, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return "must be a whole number"
+		}
+		s.value.SetInt(number)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return "must be true or false"
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Slice:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		s.value.SetString(raw)
+	}
+	return ""
+}
+
+func (c *Config) validate(sections []string) validation.Errors {
+	required := map[string]bool{}
+	for _, section := range sections {
+		if _, ok := reflect.TypeFor[Config]().FieldByName(section); !ok {
+			panic(fmt.Sprintf("config: unknown section %q", section))
+		}
+		required[section] = true
+	}
+
+	// NOTE: validation reports the Go names of the fields, the variables are
+	// what users actually set
+	messages := map[string]string{}
+	value := reflect.ValueOf(c).Elem()
+	structs := []reflect.Value{value}
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Kind() == reflect.Struct {
+			structs = append(structs, value.Field(i))
+		}
+	}
+	for _, v := range structs {
+		var fieldErrs validation.Errors
+		if errors.As(validation.Struct(v.Interface()), &fieldErrs) {
+			for _, fieldErr := range fieldErrs {
+				field, _ := v.Type().FieldByName(fieldErr.Field)
+				messages[field.Tag.Get("env")] = fieldErr.Message
+			}
+		}
+	}
+
+	for _, origin := range c.Cors.Origins {
+		if message := checkOrigin(origin); message != "" {
+			messages["CORS_ORIGINS"] = fmt.Sprintf("%s, got %q", message, origin)
+			break
+		}
+	}
+	// NOTE: Browsers refuse credentials with a wildcard, reflecting any origin
+	// instead would let every site act on behalf of the logged in user
+	if c.Cors.AllowCredentials && slices.Contains(c.Cors.Origins, "*") {
+		messages["CORS_ALLOW_CREDENTIALS"] = "must be false when CORS_ORIGINS allows *"
+	}
+
+	var errs validation.Errors
+	for _, s := range c.settings() {
+		if s.required && required[s.section] && s.value.IsZero() {
+			errs = append(errs, validation.FieldError{Field: s.key, Message: "is required"})
+		} else if message, ok := messages[s.key]; ok {
+			errs = append(errs, validation.FieldError{Field: s.key, Message: message})
+		}
+	}
+	return errs
+}
+
+// checkOrigin accepts *, an origin like https://example.com or a pattern with
+// a single wildcard like https://*.example.com.
+func checkOrigin(origin string) string {
+	if origin == "*" {
+		return ""
+	}
+	if strings.Count(origin, "*") > 1 {
+		return "must have at most one * per origin"
+	}
+	u, err := url.Parse(strings.Replace(origin, "*", "wildcard", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+		return "must be origins like https://example.com"
+	}
+	return ""
+}
+
+func (s setting) display() string {
+	if s.secret && !s.value.IsZero() {
+		return redacted
+	}
+	if list, ok := s.value.Interface().([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// String lists the effective config as KEY=value lines, secrets redacted.
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, s := range c.settings() {
+		fmt.Fprintf(&b, "%s=%s\n", s.key, s.display())
+	}
+	return b.String()
+}
+
+// LogValue logs the effective config as a group keyed by variable, secrets
+// redacted.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, s := range c.settings() {
+		attrs = append(attrs, slog.String(s.key, s.display()))
+	}
+	return slog.GroupValue(attrs...)
+}
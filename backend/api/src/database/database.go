@@ -1,29 +1,126 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"os"
+	"errors"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-func Open(connectionString string) (*sql.DB, error) {
-	database, err := sql.Open("postgres", connectionString)
+// QueryTimeout bounds every single query on top of the deadline of the
+// context it runs with, zero disables it.
+var QueryTimeout = 10 * time.Second
+
+// Open connects to Postgres, every query made through the connection is
+// traced as a span of the context it runs with.
+func Open(ctx context.Context, connectionString string) (*sql.DB, error) {
+	database, err := otelsql.Open("postgres", connectionString,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 
 	if err != nil {
 		return nil, err
 	}
-	if err := database.Ping(); err != nil {
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
 		return nil, err
 	}
 	return database, nil
 }
 
-func ConnectionString() string {
-	postgres_user := os.Getenv("POSTGRES_USER")
-	postgres_password := os.Getenv("POSTGRES_PASSWORD")
-	postgres_db := os.Getenv("POSTGRES_DB")
-	postgres_host := os.Getenv("POSTGRES_HOST")
-	postgres_port := os.Getenv("POSTGRES_PORT")
+// WithQueryTimeout derives the context of a single query from ctx, cancel
+// must be called once its rows are read.
+func WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+// Queryer is implemented by both *sql.DB and *sql.Tx, queries written against
+// it can run on their own or as part of a bigger transaction.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// WithTx makes the queries run with the returned context part of tx: those of
+// a Repository on the *sql.DB, through Conn, and the transactions begun with
+// Begin.
+func WithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// Conn answers the transaction of ctx in place of a *sql.DB, q otherwise.
+func Conn(ctx context.Context, q Queryer) Queryer {
+	if _, ok := q.(*sql.DB); ok {
+		if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+			return tx
+		}
+	}
+	return q
+}
+
+// Tx is a transaction begun with Begin.
+type Tx struct {
+	*sql.Tx
+	joined bool
+}
+
+// Begin starts a transaction on db, or joins the one of ctx. A joined
+// transaction is committed or rolled back by whoever began it, its Commit and
+// Rollback do nothing.
+func Begin(ctx context.Context, db *sql.DB) (*Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return &Tx{Tx: tx, joined: true}, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+func (tx *Tx) Commit() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Rollback()
+}
 
-	return fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", postgres_user, postgres_password, postgres_host, postgres_port, postgres_db)
+// ErrNoFields is returned by patches that have nothing to update.
+var ErrNoFields = errors.New("no fields to update")
+
+// RequireRows turns a statement that matched no row into sql.ErrNoRows, it
+// wraps Exec calls: RequireRows(db.ExecContext(ctx, query, id)).
+func RequireRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository runs the common queries on Table for rows of type T. Columns come
+// from the `db` struct tags of T, fields without one are not stored, and are
+// always listed explicitly so adding a column to a table never breaks a scan.
+//
+// Add and Patch take any struct tagged the same way: Add inserts every tagged
+// field while Patch only updates the tagged pointer fields that are not nil.
+//
+// Every query runs with the context it is given, bounded by QueryTimeout, and
+// in its transaction when it carries one, see WithTx. Streams are only bounded
+// until their query answers, the rows are read at the pace of fn.
+type Repository[T any] struct {
+	DB    Queryer
+	Table string
+	// Scope, when set, is a condition every row read, patched or deleted must
+	// match, like "deleted_at IS NULL". It comes before the clauses given to
+	// FindWhere and StreamWhere, those joining conditions with OR must wrap
+	// them in parentheses.
+	Scope string
+}
+
+type field struct {
+	column string
+	index  []int
+}
+
+var fieldsCache sync.Map
+
+// fields lists the tagged fields of t, embedded structs included.
+func fields(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var result []field
+	for _, f := range reflect.VisibleFields(t) {
+		column := f.Tag.Get("db")
+		if column == "" || column == "-" || !f.IsExported() {
+			continue
+		}
+		result = append(result, field{column: column, index: f.Index})
+	}
+	fieldsCache.Store(t, result)
+	return result
+}
+
+func (r Repository[T]) columns() string {
+	var columns []string
+	for _, f := range fields(reflect.TypeFor[T]()) {
+		columns = append(columns, f.column)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Select is the start of every query of the repository, callers append their
+// own WHERE or ORDER BY clause to it.
+func (r Repository[T]) Select() string {
+	return fmt.Sprintf("SELECT %s FROM %s", r.columns(), r.Table)
+}
+
+// Returning is appended to INSERT and UPDATE statements to read back the row.
+func (r Repository[T]) Returning() string {
+	return "RETURNING " + r.columns()
+}
+
+// Scan reads the columns of Select or Returning into a new T, rows can be
+// either a *sql.Row or *sql.Rows.
+func (r Repository[T]) Scan(row interface{ Scan(...any) error }) (*T, error) {
+	var v T
+	value := reflect.ValueOf(&v).Elem()
+
+	var dest []any
+	for _, f := range fields(value.Type()) {
+		dest = append(dest, value.FieldByIndex(f.index).Addr().Interface())
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r Repository[T]) FindAll(ctx context.Context) ([]T, error) {
+	return collect(func(fn func(T) error) error {
+		return r.StreamAll(ctx, fn)
+	})
+}
+
+func (r Repository[T]) StreamAll(ctx context.Context, fn func(T) error) error {
+	query := r.Select()
+	if r.Scope != "" {
+		query += " WHERE " + r.Scope
+	}
+	return r.stream(ctx, fn, query)
+}
+
+func (r Repository[T]) FindByID(ctx context.Context, id int) (*T, error) {
+	return r.FindOneBy(ctx, "id", id)
+}
+
+// FindOneBy answers the first row where column equals value, or
+// sql.ErrNoRows when there is none.
+func (r Repository[T]) FindOneBy(ctx context.Context, column string, value any) (*T, error) {
+	query := fmt.Sprintf("%s WHERE %s LIMIT 1", r.Select(), r.where(column+" = $1"))
+
+	return r.QueryRow(ctx, query, value)
+}
+
+func (r Repository[T]) FindBy(ctx context.Context, column string, value any) ([]T, error) {
+	return r.FindWhere(ctx, column+" = $1", value)
+}
+
+// FindIn answers the rows where column is one of values, in one query
+// whatever their number. No values match no row.
+func (r Repository[T]) FindIn(ctx context.Context, column string, values []int) ([]T, error) {
+	if len(values) == 0 {
+		return []T{}, nil
+	}
+	placeholders := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, value := range values {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = value
+	}
+	return r.FindWhere(ctx, fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", ")), args...)
+}
+
+func (r Repository[T]) StreamBy(ctx context.Context, column string, value any, fn func(T) error) error {
+	return r.StreamWhere(ctx, fn, column+" = $1", value)
+}
+
+// FindWhere answers the rows matching clause, anything that can follow a
+// WHERE keyword like "customer_id = $1 ORDER BY created_at".
+func (r Repository[T]) FindWhere(ctx context.Context, clause string, args ...any) ([]T, error) {
+	return collect(func(fn func(T) error) error {
+		return r.StreamWhere(ctx, fn, clause, args...)
+	})
+}
+
+func (r Repository[T]) StreamWhere(ctx context.Context, fn func(T) error, clause string, args ...any) error {
+	query := fmt.Sprintf("%s WHERE %s", r.Select(), r.where(clause))
+
+	return r.stream(ctx, fn, query, args...)
+}
+
+func (r Repository[T]) Add(ctx context.Context, values any) (*T, error) {
+	v := reflect.Indirect(reflect.ValueOf(values))
+
+	var columns, placeholders []string
+	var args []any
+	for _, f := range fields(v.Type()) {
+		args = append(args, v.FieldByIndex(f.index).Interface())
+		columns = append(columns, f.column)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) %s",
+		r.Table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		r.Returning(),
+	)
+	return r.QueryRow(ctx, query, args...)
+}
+
+// Patch answers ErrNoFields when updates has no field set and sql.ErrNoRows
+// when there is no row with this id.
+func (r Repository[T]) Patch(ctx context.Context, id int, updates any) (*T, error) {
+	v := reflect.Indirect(reflect.ValueOf(updates))
+
+	var setClauses []string
+	var args []any
+	for _, f := range fields(v.Type()) {
+		value := v.FieldByIndex(f.index)
+		if value.Kind() == reflect.Pointer && value.IsNil() {
+			continue
+		}
+		args = append(args, value.Interface())
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", f.column, len(args)))
+	}
+
+	if len(setClauses) == 0 {
+		return nil, ErrNoFields
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s %s",
+		r.Table,
+		strings.Join(setClauses, ", "),
+		r.where(fmt.Sprintf("id = $%d", len(args)+1)),
+		r.Returning(),
+	)
+	args = append(args, id)
+
+	return r.QueryRow(ctx, query, args...)
+}
+
+func (r Repository[T]) Delete(ctx context.Context, id int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", r.Table, r.where("id = $1"))
+
+	ctx, cancel := WithQueryTimeout(ctx)
+	defer cancel()
+	return RequireRows(Conn(ctx, r.DB).ExecContext(ctx, query, id))
+}
+
+// QueryRow runs a statement returning the columns of Returning, like an
+// UPDATE with a custom SET clause, and scans its single row.
+func (r Repository[T]) QueryRow(ctx context.Context, query string, args ...any) (*T, error) {
+	ctx, cancel := WithQueryTimeout(ctx)
+	defer cancel()
+	return r.Scan(Conn(ctx, r.DB).QueryRowContext(ctx, query, args...))
+}
+
+// Query is QueryRow for statements writing many rows, like an UPDATE or a
+// DELETE of every row of a customer, and answers all of them.
+func (r Repository[T]) Query(ctx context.Context, query string, args ...any) ([]T, error) {
+	return collect(func(fn func(T) error) error {
+		return r.stream(ctx, fn, query, args...)
+	})
+}
+
+// where puts the Scope of the repository before clause.
+func (r Repository[T]) where(clause string) string {
+	if r.Scope == "" {
+		return clause
+	}
+	return r.Scope + " AND " + clause
+}
+
+// stream calls fn with every row of query as it is read, fn may write each
+// one to a slow client.
+func (r Repository[T]) stream(ctx context.Context, fn func(T) error, query string, args ...any) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows, err := queryWithTimeout(ctx, cancel, Conn(ctx, r.DB), query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v, err := r.Scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*v); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// queryWithTimeout runs query with ctx, cancelling it if it takes longer than
+// QueryTimeout to answer. Unlike WithQueryTimeout, the rows can then be read
+// for as long as ctx lasts.
+func queryWithTimeout(ctx context.Context, cancel context.CancelFunc, q Queryer, query string, args ...any) (*sql.Rows, error) {
+	if QueryTimeout <= 0 {
+		return q.QueryContext(ctx, query, args...)
+	}
+	timer := time.AfterFunc(QueryTimeout, cancel)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if !timer.Stop() && err == nil {
+		// NOTE: The timeout went off as the query answered, its rows are closed
+		rows.Close()
+		return nil, context.DeadlineExceeded
+	}
+	return rows, err
+}
+
+// collect gathers every row of stream, an empty result is an empty slice so
+// it is sent as [] rather than null.
+func collect[T any](stream func(func(T) error) error) ([]T, error) {
+	result := []T{}
+	err := stream(func(v T) error {
+		result = append(result, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
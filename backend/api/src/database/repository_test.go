@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type note struct {
+	Id        int       `db:"id"`
+	Title     string    `db:"title"`
+	Author_Id *int      `db:"author_id"`
+	CreatedAt time.Time `db:"created_at"`
+	Computed  string
+}
+
+type addNote struct {
+	Title     string `db:"title"`
+	Author_Id *int   `db:"author_id"`
+}
+
+type updateNote struct {
+	Title     *string `db:"title"`
+	Author_Id *int    `db:"author_id"`
+}
+
+type importNote struct {
+	*addNote
+	Source string `db:"source"`
+}
+
+func setupRepository(t *testing.T) Repository[note] {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// NOTE: source is not a field of note, extra columns must not break scans
+	schema := `
+	CREATE TABLE note (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL DEFAULT 'api',
+		title TEXT NOT NULL,
+		author_id INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	return Repository[note]{DB: db, Table: "note"}
+}
+
+func TestRepository(t *testing.T) {
+	notes := setupRepository(t)
+	author := 7
+
+	t.Run("Find All Empty", func(t *testing.T) {
+		all, err := notes.FindAll(context.Background())
+		if err != nil || all == nil || len(all) != 0 {
+			t.Errorf("Expected an empty list, got %#v (%v)", all, err)
+		}
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		n, err := notes.Add(context.Background(), &addNote{Title: "First", Author_Id: &author})
+		if err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+		if n.Id != 1 || n.Title != "First" || n.Author_Id == nil || *n.Author_Id != author || n.CreatedAt.IsZero() {
+			t.Errorf("Unexpected note %+v", n)
+		}
+	})
+
+	t.Run("Add Embedded", func(t *testing.T) {
+		n, err := notes.Add(context.Background(), importNote{addNote: &addNote{Title: "Second"}, Source: "csv"})
+		if err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+		var source string
+		if err := notes.DB.QueryRowContext(context.Background(), "SELECT source FROM note WHERE id = $1", n.Id).Scan(&source); err != nil || source != "csv" {
+			t.Errorf("Expected source csv, got %q (%v)", source, err)
+		}
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		n, err := notes.FindByID(context.Background(), 2)
+		if err != nil || n.Title != "Second" || n.Author_Id != nil {
+			t.Errorf("Unexpected note %+v (%v)", n, err)
+		}
+
+		byAuthor, err := notes.FindBy(context.Background(), "author_id", author)
+		if err != nil || len(byAuthor) != 1 || byAuthor[0].Id != 1 {
+			t.Errorf("Unexpected notes %+v (%v)", byAuthor, err)
+		}
+
+		ordered, err := notes.FindWhere(context.Background(), "id > $1 ORDER BY id DESC", 0)
+		if err != nil || len(ordered) != 2 || ordered[0].Id != 2 {
+			t.Errorf("Unexpected notes %+v (%v)", ordered, err)
+		}
+
+		in, err := notes.FindIn(context.Background(), "id", []int{1, 2, 404})
+		if err != nil || len(in) != 2 {
+			t.Errorf("Unexpected notes %+v (%v)", in, err)
+		}
+
+		none, err := notes.FindIn(context.Background(), "id", nil)
+		if err != nil || none == nil || len(none) != 0 {
+			t.Errorf("Expected an empty list, got %#v (%v)", none, err)
+		}
+
+		_, err = notes.FindOneBy(context.Background(), "title", "Missing")
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		title := "Renamed"
+		n, err := notes.Patch(context.Background(), 1, &updateNote{Title: &title})
+		if err != nil {
+			t.Fatalf("Failed to patch note: %v", err)
+		}
+		if n.Title != title || n.Author_Id == nil || *n.Author_Id != author {
+			t.Errorf("Only the title should change, got %+v", n)
+		}
+
+		if _, err := notes.Patch(context.Background(), 1, &updateNote{}); !errors.Is(err, ErrNoFields) {
+			t.Errorf("Expected ErrNoFields, got %v", err)
+		}
+		if _, err := notes.Patch(context.Background(), 99, &updateNote{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("Cancelled Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := notes.FindAll(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		scoped := notes
+		scoped.Scope = "source = 'api'"
+
+		all, err := scoped.FindAll(context.Background())
+		if err != nil || len(all) != 1 || all[0].Id != 1 {
+			t.Errorf("Expected the note added by the api, got %+v (%v)", all, err)
+		}
+		in, err := scoped.FindIn(context.Background(), "id", []int{1, 2})
+		if err != nil || len(in) != 1 {
+			t.Errorf("Expected the note added by the api, got %+v (%v)", in, err)
+		}
+		if _, err := scoped.FindByID(context.Background(), 2); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows out of scope, got %v", err)
+		}
+		title := "Out of scope"
+		if _, err := scoped.Patch(context.Background(), 2, &updateNote{Title: &title}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows out of scope, got %v", err)
+		}
+		if err := scoped.Delete(context.Background(), 2); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows out of scope, got %v", err)
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		renamed, err := notes.Query(context.Background(), "UPDATE note SET title = title || '!' WHERE source = $1 "+notes.Returning(), "csv")
+		if err != nil || len(renamed) != 1 || renamed[0].Title != "Second!" {
+			t.Errorf("Unexpected notes %+v (%v)", renamed, err)
+		}
+		none, err := notes.Query(context.Background(), "DELETE FROM note WHERE id = $1 "+notes.Returning(), 404)
+		if err != nil || none == nil || len(none) != 0 {
+			t.Errorf("Expected an empty list, got %#v (%v)", none, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := notes.Delete(context.Background(), 1); err != nil {
+			t.Fatalf("Failed to delete note: %v", err)
+		}
+		if err := notes.Delete(context.Background(), 1); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestStreamOutlastsQueryTimeout(t *testing.T) {
+	notes := setupRepository(t)
+	for _, title := range []string{"First", "Second", "Third"} {
+		if _, err := notes.Add(context.Background(), &addNote{Title: title}); err != nil {
+			t.Fatalf("Failed to add note: %v", err)
+		}
+	}
+	defer func(timeout time.Duration) { QueryTimeout = timeout }(QueryTimeout)
+	QueryTimeout = 20 * time.Millisecond
+
+	// NOTE: Like an export written to a slow client, every row takes longer than the query may
+	var titles []string
+	err := notes.StreamAll(context.Background(), func(n note) error {
+		time.Sleep(2 * QueryTimeout)
+		titles = append(titles, n.Title)
+		return nil
+	})
+	if err != nil || len(titles) != 3 {
+		t.Errorf("Expected the 3 notes, got %v (%v)", titles, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = notes.StreamAll(ctx, func(n note) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the stream to end with its context, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Package encryption seals sensitive columns at rest with AES-GCM under keys
+// named by an id, so that they can be rotated, and computes blind indexes to
+// look the sealed values up.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// prefix starts every sealed value, "enc:<key id>:<nonce and ciphertext>" in
+// base64. Values without it are plaintext written before encryption.
+const prefix = "enc:"
+
+const (
+	keySize      = 32
+	minIndexSize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("encryption: value sealed with an unknown key")
+	ErrMalformed  = errors.New("encryption: malformed sealed value")
+	keyId         = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// Keyring holds the keys sealing and opening values. The current key seals
+// every new value, the others are only kept to open the values they sealed
+// until those are sealed again, see the reencrypt command. A nil Keyring
+// leaves values as they are.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+	index   []byte
+}
+
+// NewKeyring reads keys as "id:base64" of 32 bytes, the first one being the
+// current, and the base64 indexKey of the blind indexes. No keys at all
+// disable encryption and answer a nil Keyring.
+func NewKeyring(keys []string, indexKey string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	k := &Keyring{aeads: map[string]cipher.AEAD{}}
+	for _, key := range keys {
+		id, encoded, ok := strings.Cut(key, ":")
+		if !ok || !keyId.MatchString(id) {
+			return nil, fmt.Errorf("encryption: keys must be written id:base64, with an id of letters, digits, - and _")
+		}
+		if _, ok := k.aeads[id]; ok {
+			return nil, fmt.Errorf("encryption: key %q is given twice", id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(secret) != keySize {
+			return nil, fmt.Errorf("encryption: key %q must be %d bytes in base64", id, keySize)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+		if k.current == "" {
+			k.current = id
+		}
+	}
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || len(index) < minIndexSize {
+		return nil, fmt.Errorf("encryption: the index key must be at least %d bytes in base64", minIndexSize)
+	}
+	k.index = index
+	return k, nil
+}
+
+// Current is the id of the key sealing new values.
+func (k *Keyring) Current() string {
+	if k == nil {
+		return ""
+	}
+	return k.current
+}
+
+// Seal encrypts value with the current key. The column is authenticated
+// along with it, a value copied to another column no longer opens. Empty
+// values are left empty.
+func (k *Keyring) Seal(column string, value string) (string, error) {
+	if k == nil || value == "" {
+		return value, nil
+	}
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(column))
+	return prefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed by any key of the keyring, plaintext values
+// are answered as they are.
+func (k *Keyring) Open(column string, value string) (string, error) {
+	id, encoded, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("encryption: could not open %s with key %q: %w", column, id, err)
+	}
+	return string(plaintext), nil
+}
+
+// KeyId is the id of the key that sealed value, empty for plaintext.
+func KeyId(value string) string {
+	id, _, _ := split(value)
+	return id
+}
+
+func split(value string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// BlindIndex is the HMAC-SHA256 of value in column, in hex. Equal values
+// have equal indexes, which is all an exact lookup needs, while the index
+// tells nothing about the value without the key. Empty values, and any value
+// without a keyring, have an empty index.
+func (k *Keyring) BlindIndex(column string, value string) string {
+	if k == nil || value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(column))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Fields are the columns of a table sealed by Keyring, set by name.
+type Fields struct {
+	Keyring *Keyring
+	Columns []string
+}
+
+// NewFields checks that every column of columns is one of allowed.
+func NewFields(keyring *Keyring, columns []string, allowed []string) (*Fields, error) {
+	for _, column := range columns {
+		if !slices.Contains(allowed, column) {
+			return nil, fmt.Errorf("encryption: %q cannot be encrypted, expected one of %s", column, strings.Join(allowed, ", "))
+		}
+	}
+	return &Fields{Keyring: keyring, Columns: columns}, nil
+}
+
+// Seals tells whether the values written to column are sealed.
+func (f *Fields) Seals(column string) bool {
+	return f != nil && f.Keyring != nil && slices.Contains(f.Columns, column)
+}
+
+// Seal encrypts in place the values of the sealed columns, by column name.
+func (f *Fields) Seal(values map[string]*string) error {
+	for column, value := range values {
+		if value == nil || !f.Seals(column) {
+			continue
+		}
+		sealed, err := f.Keyring.Seal(column, *value)
+		if err != nil {
+			return err
+		}
+		*value = sealed
+	}
+	return nil
+}
+
+// Open decrypts in place every sealed value, whether its column is still
+// configured or not.
+func (f *Fields) Open(values map[string]*string) error {
+	var keyring *Keyring
+	if f != nil {
+		keyring = f.Keyring
+	}
+	for column, value := range values {
+		if value == nil {
+			continue
+		}
+		opened, err := keyring.Open(column, *value)
+		if err != nil {
+			return err
+		}
+		*value = opened
+	}
+	return nil
+}
+
+// BlindIndex is the blind index of value in column, empty unless the column
+// is sealed.
+func (f *Fields) BlindIndex(column string, value string) string {
+	if !f.Seals(column) {
+		return ""
+	}
+	return f.Keyring.BlindIndex(column, value)
+}
@@ -0,0 +1,144 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func key(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+var indexKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("i", minIndexSize)))
+
+func TestKeyring(t *testing.T) {
+	old, err := NewKeyring([]string{key("2024", 'a')}, indexKey)
+	if err != nil {
+		t.Fatalf("Failed to read the keys: %v", err)
+	}
+	rotated, err := NewKeyring([]string{key("2025", 'b'), key("2024", 'a')}, indexKey)
+	if err != nil {
+		t.Fatalf("Failed to read the keys: %v", err)
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		sealed, err := old.Seal("address", "1 rue de Paris")
+		if err != nil || !strings.HasPrefix(sealed, "enc:2024:") || strings.Contains(sealed, "Paris") {
+			t.Fatalf("Expected a value sealed with 2024, got %q (%v)", sealed, err)
+		}
+		if again, _ := old.Seal("address", "1 rue de Paris"); again == sealed {
+			t.Errorf("Expected a new nonce for every seal")
+		}
+		if opened, err := old.Open("address", sealed); err != nil || opened != "1 rue de Paris" {
+			t.Errorf("Expected the value back, got %q (%v)", opened, err)
+		}
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		sealed, _ := old.Seal("address", "1 rue de Paris")
+		if opened, err := rotated.Open("address", sealed); err != nil || opened != "1 rue de Paris" {
+			t.Errorf("Expected the former key to still open, got %q (%v)", opened, err)
+		}
+		resealed, _ := rotated.Seal("address", "1 rue de Paris")
+		if KeyId(resealed) != "2025" || rotated.Current() != "2025" {
+			t.Errorf("Expected the first key to seal, got %q", resealed)
+		}
+		if _, err := old.Open("address", resealed); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+	})
+
+	t.Run("Column", func(t *testing.T) {
+		sealed, _ := old.Seal("address", "1 rue de Paris")
+		if _, err := old.Open("description", sealed); err == nil {
+			t.Errorf("Expected a value moved to another column not to open")
+		}
+		if _, err := old.Open("address", sealed[:len(sealed)-2]); err == nil {
+			t.Errorf("Expected a truncated value not to open")
+		}
+	})
+
+	t.Run("Plaintext", func(t *testing.T) {
+		if opened, err := old.Open("address", "2 rue de Lyon"); err != nil || opened != "2 rue de Lyon" {
+			t.Errorf("Expected plaintext as it is, got %q (%v)", opened, err)
+		}
+		if sealed, _ := old.Seal("address", ""); sealed != "" {
+			t.Errorf("Expected empty values to stay empty, got %q", sealed)
+		}
+	})
+
+	t.Run("Blind Index", func(t *testing.T) {
+		index := old.BlindIndex("phone_number", "0600000000")
+		if len(index) != 64 || index != rotated.BlindIndex("phone_number", "0600000000") {
+			t.Errorf("Expected the index to survive the rotation, got %q", index)
+		}
+		if index == old.BlindIndex("phone_number", "0611111111") || index == old.BlindIndex("address", "0600000000") {
+			t.Errorf("Expected distinct values and columns to have distinct indexes")
+		}
+		if old.BlindIndex("phone_number", "") != "" {
+			t.Errorf("Expected no index for empty values")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var disabled *Keyring
+		if sealed, _ := disabled.Seal("address", "1 rue de Paris"); sealed != "1 rue de Paris" {
+			t.Errorf("Expected a nil keyring to leave values as they are, got %q", sealed)
+		}
+		sealed, _ := old.Seal("address", "1 rue de Paris")
+		if _, err := disabled.Open("address", sealed); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+	})
+}
+
+func TestNewKeyring(t *testing.T) {
+	if keyring, err := NewKeyring(nil, ""); keyring != nil || err != nil {
+		t.Errorf("Expected no keys to disable encryption, got %v (%v)", keyring, err)
+	}
+	tests := []struct {
+		name     string
+		keys     []string
+		indexKey string
+	}{
+		{"Missing Id", []string{base64.StdEncoding.EncodeToString(make([]byte, keySize))}, indexKey},
+		{"Short Key", []string{"2024:" + base64.StdEncoding.EncodeToString(make([]byte, 16))}, indexKey},
+		{"Twice", []string{key("2024", 'a'), key("2024", 'b')}, indexKey},
+		{"Short Index Key", []string{key("2024", 'a')}, base64.StdEncoding.EncodeToString(make([]byte, 8))},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewKeyring(tc.keys, tc.indexKey); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	keyring, _ := NewKeyring([]string{key("2024", 'a')}, indexKey)
+	if _, err := NewFields(keyring, []string{"email"}, []string{"address"}); err == nil {
+		t.Errorf("Expected columns that cannot be sealed to be refused")
+	}
+	fields, err := NewFields(keyring, []string{"address"}, []string{"address", "description"})
+	if err != nil {
+		t.Fatalf("Failed to create fields: %v", err)
+	}
+
+	address, description := "1 rue de Paris", "Likes cats"
+	values := map[string]*string{"address": &address, "description": &description, "phone_number": nil}
+	if err := fields.Seal(values); err != nil || KeyId(address) != "2024" || description != "Likes cats" {
+		t.Fatalf("Expected the address only to be sealed, got %q %q (%v)", address, description, err)
+	}
+	if fields.BlindIndex("description", description) != "" {
+		t.Errorf("Expected no index for a column that is not sealed")
+	}
+
+	// NOTE: A column no longer configured still opens
+	others, _ := NewFields(keyring, []string{"description"}, []string{"address", "description"})
+	if err := others.Open(values); err != nil || address != "1 rue de Paris" {
+		t.Errorf("Expected the address back, got %q (%v)", address, err)
+	}
+}
@@ -2,16 +2,29 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
+	"soul-connection.com/api/src/apierror"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/ratelimit"
 )
 
 type AuthModel struct {
 	Auth interface {
 		GetApiKey() string
 	}
+	BaseUri string
+	// Lockout refuses the accounts with too many failed logins from an
+	// address, nil never does
+	Lockout *ratelimit.Lockout
+	// ForwardedFor takes the address of the client from X-Forwarded-For, see
+	// ratelimit.ClientIP
+	ForwardedFor bool
 }
 
 type ApiKeyAuth struct {
@@ -25,20 +38,41 @@ func (model *AuthModel) Login(res http.ResponseWriter, req *http.Request) {
 	}
 	err := json.NewDecoder(req.Body).Decode(&c)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("request body is not valid JSON"))
 		return
 	}
-	jwt, err := lib.Auth(lib.LoginCredentials{
+	log := logger.FromContext(req.Context())
+	address := ratelimit.ClientIP(req, model.ForwardedFor)
+	// NOTE: The logins go on when the store of the lockout is down
+	locked, err := model.Lockout.Locked(req.Context(), c.Email, address)
+	if err != nil {
+		log.Warn("could not check the lockout of the account", "error", err)
+	}
+	if locked > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.Seconds()))))
+		apierror.Write(res, req, apierror.TooManyRequests("Too many failed logins, retry later"))
+		return
+	}
+	jwt, err := lib.Auth(req.Context(), lib.LoginCredentials{
+		BaseUri:              model.BaseUri,
 		XGroupAuthentication: model.Auth.GetApiKey(),
 		AuthEmail:            c.Email,
 		AuthPassword:         c.Password,
 	})
+	if errors.Is(err, lib.ErrRejected) {
+		if _, err := model.Lockout.Failed(req.Context(), c.Email, address); err != nil {
+			log.Warn("could not count the failed login", "error", err)
+		}
+		apierror.Write(res, req, apierror.Unauthorized("Invalid email or password"))
+		return
+	}
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	if err := model.Lockout.Succeeded(req.Context(), c.Email, address); err != nil {
+		log.Warn("could not reset the failed logins", "error", err)
+	}
 	res.Header().Set("Authorization", fmt.Sprintf("Bearer %s", jwt))
 }
 
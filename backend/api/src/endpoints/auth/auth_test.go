@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"soul-connection.com/api/src/ratelimit"
+)
+
+func TestLoginLockout(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		body, _ := io.ReadAll(req.Body)
+		if strings.Contains(string(body), `"password":"right"`) {
+			res.Write([]byte(`{"access_token": "jwt"}`))
+			return
+		}
+		res.WriteHeader(http.StatusUnauthorized)
+		res.Write([]byte(`{"detail": "Invalid Email and Password combination."}`))
+	}))
+	defer upstream.Close()
+
+	model := &AuthModel{
+		Auth:    ApiKeyAuth{ApiKey: "key"},
+		BaseUri: upstream.URL,
+		Lockout: ratelimit.NewLockout(ratelimit.NewMemoryStore(), 2, time.Minute, time.Hour),
+	}
+	login := func(password string, address string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"email": "zoe@test.com", "password": "`+password+`"}`))
+		req.RemoteAddr = address + ":1234"
+		res := httptest.NewRecorder()
+		model.Login(res, req)
+		return res
+	}
+
+	if res := login("right", "192.0.2.1"); res.Code != http.StatusOK || res.Header().Get("Authorization") != "Bearer jwt" {
+		t.Fatalf("Expected to log in, got %d %v", res.Code, res.Header())
+	}
+	for i := 0; i < 2; i++ {
+		if res := login("wrong", "192.0.2.1"); res.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401 for a wrong password, got %d %s", res.Code, res.Body.String())
+		}
+	}
+	locked := login("right", "192.0.2.1")
+	if locked.Code != http.StatusTooManyRequests || locked.Header().Get("Retry-After") != "3600" {
+		t.Errorf("Expected the account to be locked, got %d %v", locked.Code, locked.Header())
+	}
+	if calls != 3 {
+		t.Errorf("Expected the locked login not to reach the upstream api, it was called %d times", calls)
+	}
+	if res := login("right", "198.51.100.7"); res.Code != http.StatusOK {
+		t.Errorf("Expected the account to stay open to other addresses, got %d %s", res.Code, res.Body.String())
+	}
+}
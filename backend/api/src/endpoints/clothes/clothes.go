@@ -1,6 +1,7 @@
 package clothes
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,44 +9,67 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/validation"
 )
 
 type Clothe struct {
-	Id                 int
-	Soul_Connection_Id *int
-	Type               string
-	Image_Id           *string
-	CreatedAt          time.Time
-	CustomerId         int
+	Id                 int       `json:"id" db:"id"`
+	Soul_Connection_Id *int      `json:"soul_connection_id" db:"soul_connection_id"`
+	Type               string    `json:"type" db:"type"`
+	Image_Id           *string   `json:"-" db:"image_id"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	CustomerId         int       `json:"customer_id" db:"customer_id"`
+}
+
+// ClothesRepository is the storage behind ClothesModel.
+type ClothesRepository interface {
+	FindAll(context.Context) ([]Clothe, error)
+	StreamAll(context.Context, func(Clothe) error) error
+	FindByID(context.Context, int) (*Clothe, error)
+	FindByCustomerID(context.Context, int) ([]Clothe, error)
+	FindByCustomerIDs(context.Context, []int) ([]Clothe, error)
+	StreamByCustomerID(context.Context, int, func(Clothe) error) error
+	Add(context.Context, *AddClothe) (*Clothe, error)
+	Delete(context.Context, int) error
+	Patch(context.Context, int, *UpdateClothe) (*Clothe, error)
+	UploadFile(context.Context, int, io.Reader, string) (*primitive.ObjectID, error)
+	GetFile(context.Context, primitive.ObjectID) ([]byte, error)
 }
 
 type ClothesModel struct {
-	Clothes interface {
-		FindAll() ([]Clothe, error)
-		FindByID(int) (*Clothe, error)
-		FindByCustomerID(int) ([]Clothe, error)
-		Add(*AddClothe) (*Clothe, error)
-		Delete(int) error
-		Patch(int, *UpdateClothe) (*Clothe, error)
-		UploadFile(int, io.Reader, string) (*primitive.ObjectID, error)
-		GetFile(primitive.ObjectID) ([]byte, error)
-	}
+	Clothes ClothesRepository
+	Changes *changes.Bus
+	Audit   *audit.Log
 }
 
-func (model *ClothesModel) GetAllClothes(res http.ResponseWriter, _ *http.Request) {
-	clothes, err := model.Clothes.FindAll()
+func (model *ClothesModel) GetAllClothes(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "clothes", func(fn func(ClotheResponse) error) error {
+			return model.Clothes.StreamAll(req.Context(), func(item Clothe) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	clothes, err := model.Clothes.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(clothes); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(clothes, Clothe.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -53,22 +77,19 @@ func (model *ClothesModel) GetAllClothes(res http.ResponseWriter, _ *http.Reques
 func (model *ClothesModel) GetClotheById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "clothe_id")
 	if err != nil {
-		http.Error(res, "Invalid clothe ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid clothe ID format"))
 		return
 	}
 
-	clothe, err := model.Clothes.FindByID(id)
+	clothe, err := model.Clothes.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Clothe not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*clothe); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(clothe.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -76,46 +97,57 @@ func (model *ClothesModel) GetClotheById(res http.ResponseWriter, req *http.Requ
 func (model *ClothesModel) GetClotheByCustomerId(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, fmt.Sprintf("customer_%d_clothes", id), func(fn func(ClotheResponse) error) error {
+			return model.Clothes.StreamByCustomerID(req.Context(), id, func(item Clothe) error { return fn(item.Response()) })
+		})
 		return
 	}
 
-	clothe, err := model.Clothes.FindByCustomerID(id)
+	clothe, err := model.Clothes.FindByCustomerID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Clothes not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(clothe); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(clothe, Clothe.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *ClothesModel) AddClothe(res http.ResponseWriter, req *http.Request) {
 	var nc AddClothe
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	clothe, err := model.Clothes.Add(&nc)
+	var clothe *Clothe
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if clothe, err = model.Clothes.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Clothes, audit.Created, clothe.Id, nil, clothe.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add clothe", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), clothe.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*clothe); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(clothe.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -123,21 +155,30 @@ func (model *ClothesModel) AddClothe(res http.ResponseWriter, req *http.Request)
 func (model *ClothesModel) DeleteClothe(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "clothe_id")
 	if err != nil {
-		http.Error(res, "Invalid clothe ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid clothe ID format"))
 		return
 	}
 
-	err = model.Clothes.Delete(id)
+	clothe, err := model.Clothes.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Clothes.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Clothes, audit.Deleted, id, clothe.Response(), nil)
+	})
 	if err != nil {
-		http.Error(res, "Unable to delete clothe", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
 	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Clothes, changes.Deleted, id, nil).ForCustomer(clothe.CustomerId))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -145,30 +186,38 @@ func (model *ClothesModel) DeleteClothe(res http.ResponseWriter, req *http.Reque
 func (model *ClothesModel) PatchClothes(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "clothe_id")
 	if err != nil {
-		http.Error(res, "Invalid clothe ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid clothe ID format"))
 		return
 	}
 
 	var updates UpdateClothe
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Clothes.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedClothe, err := model.Clothes.Patch(id, &updates)
+	var updatedClothe *Clothe
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedClothe, err = model.Clothes.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Clothes, audit.Updated, id, previous.Response(), updatedClothe.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to update clothe", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), updatedClothe.change(changes.Updated))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedClothe); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(updatedClothe.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -176,41 +225,36 @@ func (model *ClothesModel) PatchClothes(res http.ResponseWriter, req *http.Reque
 func (model *ClothesModel) GetImage(res http.ResponseWriter, req *http.Request) {
 	clotheId, err := lib.GetIdFromRequest(req, "clothe_id")
 	if err != nil {
-		http.Error(res, "Invalid clothe ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid clothe ID format"))
 		return
 	}
 
-	clothe, err := model.Clothes.FindByID(clotheId)
+	clothe, err := model.Clothes.FindByID(req.Context(), clotheId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	if clothe.Image_Id == nil {
-		http.Error(res, "Could not find image for clothe", http.StatusNotFound)
+		apierror.Write(res, req, apierror.NotFound("Could not find image for clothe"))
 		return
 	}
 
 	fileId, err := primitive.ObjectIDFromHex(*clothe.Image_Id)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	fileContent, err := model.Clothes.GetFile(fileId)
+	fileContent, err := model.Clothes.GetFile(req.Context(), fileId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	_, err = res.Write(fileContent)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 	res.Header().Set("Content-Type", "application/octet-stream")
@@ -0,0 +1,32 @@
+package clothes
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// ClotheResponse is the JSON of a Clothe.
+type ClotheResponse struct {
+	Id                 int       `json:"id"`
+	Soul_Connection_Id *int      `json:"soul_connection_id"`
+	Type               string    `json:"type"`
+	Has_Image          bool      `json:"has_image"`
+	CreatedAt          time.Time `json:"created_at"`
+	CustomerId         int       `json:"customer_id"`
+}
+
+func (c Clothe) Response() ClotheResponse {
+	return ClotheResponse{
+		Id:                 c.Id,
+		Soul_Connection_Id: c.Soul_Connection_Id,
+		Type:               c.Type,
+		Has_Image:          c.Image_Id != nil,
+		CreatedAt:          c.CreatedAt,
+		CustomerId:         c.CustomerId,
+	}
+}
+
+func (c Clothe) change(action changes.Action) changes.Change {
+	return changes.New(changes.Clothes, action, c.Id, c.Response()).ForCustomer(c.CustomerId)
+}
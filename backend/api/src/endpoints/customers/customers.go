@@ -1,6 +1,7 @@
 package customers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,53 +9,136 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/middleware"
+	"soul-connection.com/api/src/validation"
 )
 
 type Customer struct {
-	Id                 int
-	Soul_Connection_Id *int
-	Email              string
-	Name               string
-	Surname            string
-	Birth_Date         string
-	Gender             string
-	Description        string
-	Astrological_Sign  string
-	Phone_Number       string
-	Address            string
-	Image_Id           *string
-	CreatedAt          time.Time
-	Employee_Id        *int
+	Id                 int        `json:"id" db:"id"`
+	Soul_Connection_Id *int       `json:"soul_connection_id" db:"soul_connection_id"`
+	Email              string     `json:"email" db:"email"`
+	Name               string     `json:"name" db:"name"`
+	Surname            string     `json:"surname" db:"surname"`
+	Birth_Date         string     `json:"birth_date" db:"birth_date"`
+	Gender             string     `json:"gender" db:"gender"`
+	Description        string     `json:"description" db:"description"`
+	Astrological_Sign  string     `json:"astrological_sign" db:"astrological_sign"`
+	Phone_Number       string     `json:"phone_number" db:"phone_number"`
+	Address            string     `json:"address" db:"address"`
+	Image_Id           *string    `json:"-" db:"image_id"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	Deleted_At         *time.Time `json:"deleted_at" db:"deleted_at"`
+	Employee_Id        *int       `json:"employee_id" db:"employee_id"`
+}
+
+type Assignment struct {
+	Id                   int       `json:"id" db:"id"`
+	Customer_Id          int       `json:"customer_id" db:"customer_id"`
+	Previous_Employee_Id *int      `json:"previous_employee_id" db:"previous_employee_id"`
+	Employee_Id          *int      `json:"employee_id" db:"employee_id"`
+	Assigned_By          *int      `json:"assigned_by" db:"assigned_by"`
+	CreatedAt            time.Time `json:"created_at" db:"created_at"`
+}
+
+// CustomersRepository is the storage behind CustomersModel.
+type CustomersRepository interface {
+	FindAll(context.Context) ([]Customer, error)
+	StreamAll(context.Context, func(Customer) error) error
+	FindByID(context.Context, int) (*Customer, error)
+	FindByIDs(context.Context, []int) ([]Customer, error)
+	FindByEmployeeID(context.Context, int) ([]Customer, error)
+	FindByEmployeeIDs(context.Context, []int) ([]Customer, error)
+	StreamByEmployeeID(context.Context, int, func(Customer) error) error
+	FindByPhoneNumber(context.Context, string) ([]Customer, error)
+	FindByOldID(context.Context, int) (*Customer, error)
+	Add(context.Context, *AddCustomer) (*Customer, error)
+	Delete(context.Context, int) error
+	Restore(context.Context, int) (*Customer, error)
+	IncludingDeleted() CustomersRepository
+	Patch(context.Context, int, *UpdateCustomer) (*Customer, error)
+	Assign(context.Context, int, *int, *int) (*Customer, error)
+	Reassign(context.Context, *ReassignCustomers, *int) ([]Customer, error)
+	FindAssignments(context.Context, int) ([]Assignment, error)
+	UploadFile(context.Context, int, io.Reader, string) (*primitive.ObjectID, error)
+	GetFile(context.Context, primitive.ObjectID) ([]byte, error)
 }
 
 type CustomersModel struct {
-	Customers interface {
-		FindAll() ([]Customer, error)
-		FindByID(int) (*Customer, error)
-		FindByEmployeeID(int) ([]Customer, error)
-		FindByOldID(int) (*Customer, error)
-		Add(*AddCustomer) (*Customer, error)
-		Delete(int) error
-		Patch(int, *UpdateCustomer) (*Customer, error)
-		UploadFile(int, io.Reader, string) (*primitive.ObjectID, error)
-		GetFile(primitive.ObjectID) ([]byte, error)
+	Customers CustomersRepository
+	Changes   *changes.Bus
+	Audit     *audit.Log
+}
+
+// reads answers the repository the reads of req go through, it includes the
+// deleted customers when a manager asks for them.
+func (model *CustomersModel) reads(req *http.Request) (CustomersRepository, error) {
+	include, err := middleware.IncludeDeleted(req)
+	if err != nil || !include {
+		return model.Customers, err
+	}
+	return model.Customers.IncludingDeleted(), nil
+}
+
+// assignedBy is the upstream id of the employee assigning customers in req,
+// nil when the API runs without authentication.
+func assignedBy(req *http.Request) *int {
+	if user, ok := middleware.UserFrom(req.Context()); ok {
+		return &user.Id
 	}
+	return nil
 }
 
-func (model *CustomersModel) GetAllCustomers(res http.ResponseWriter, _ *http.Request) {
-	customers, err := model.Customers.FindAll()
+func (model *CustomersModel) GetAllCustomers(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	repo, err := model.reads(req)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	phoneNumber := req.URL.Query().Get("phone_number")
+	if format != export.JSON {
+		export.Write(res, req, format, "customers", func(fn func(CustomerResponse) error) error {
+			if phoneNumber == "" {
+				return repo.StreamAll(req.Context(), func(item Customer) error { return fn(item.Response()) })
+			}
+			customers, err := repo.FindByPhoneNumber(req.Context(), phoneNumber)
+			if err != nil {
+				return err
+			}
+			for _, item := range customers {
+				if err := fn(item.Response()); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return
+	}
+
+	var customers []Customer
+	if phoneNumber != "" {
+		customers, err = repo.FindByPhoneNumber(req.Context(), phoneNumber)
+	} else {
+		customers, err = repo.FindAll(req.Context())
+	}
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(customers); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(customers, Customer.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -62,22 +146,24 @@ func (model *CustomersModel) GetAllCustomers(res http.ResponseWriter, _ *http.Re
 func (model *CustomersModel) GetCustomerById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+	repo, err := model.reads(req)
+	if err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 
-	customer, err := model.Customers.FindByID(id)
+	customer, err := repo.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Customer not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*customer); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(customer.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -85,46 +171,57 @@ func (model *CustomersModel) GetCustomerById(res http.ResponseWriter, req *http.
 func (model *CustomersModel) GetCustomerByEmployeeId(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "employee_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
 		return
 	}
 
-	customer, err := model.Customers.FindByEmployeeID(id)
+	format, err := export.Format(req)
 	if err != nil {
-		http.Error(res, "Employee not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, fmt.Sprintf("employee_%d_customers", id), func(fn func(CustomerResponse) error) error {
+			return model.Customers.StreamByEmployeeID(req.Context(), id, func(item Customer) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	customer, err := model.Customers.FindByEmployeeID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(customer); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(customer, Customer.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *CustomersModel) AddCustomer(res http.ResponseWriter, req *http.Request) {
 	var nc AddCustomer
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	customer, err := model.Customers.Add(&nc)
+	var customer *Customer
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if customer, err = model.Customers.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Created, customer.Id, nil, customer.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add customer", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), customer.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*customer); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(customer.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -132,21 +229,67 @@ func (model *CustomersModel) AddCustomer(res http.ResponseWriter, req *http.Requ
 func (model *CustomersModel) DeleteCustomer(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
 		return
 	}
 
-	err = model.Customers.Delete(id)
+	previous, err := model.Customers.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to delete customer", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
+	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Customers.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Deleted, id, previous.Response(), nil)
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
 	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Customers, changes.Deleted, id, nil).ForCustomer(id))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *CustomersModel) RestoreCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+	if err := middleware.RequireManager(req, "Only managers can restore customers"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Customers.IncludingDeleted().FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	var customer *Customer
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if customer, err = model.Customers.Restore(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Restored, id, previous.Response(), customer.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	// NOTE: Followers dropped the customer when it was deleted, it comes back as new
+	model.Changes.Publish(req.Context(), customer.change(changes.Created))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(customer.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -154,30 +297,203 @@ func (model *CustomersModel) DeleteCustomer(res http.ResponseWriter, req *http.R
 func (model *CustomersModel) PatchCustomer(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
 		return
 	}
 
 	var updates UpdateCustomer
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Customers.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var updatedCustomer *Customer
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		// NOTE: Coach changes go through Assign so they are kept in the assignment history
+		if updates.Employee_Id != nil {
+			if _, err = model.Customers.Assign(ctx, id, updates.Employee_Id, nil); err != nil {
+				return err
+			}
+			updates.Employee_Id = nil
+		}
+
+		if updates.isEmpty() {
+			updatedCustomer, err = model.Customers.FindByID(ctx, id)
+		} else {
+			updatedCustomer, err = model.Customers.Patch(ctx, id, &updates)
+		}
+		if err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Updated, id, previous.Response(), updatedCustomer.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), updatedCustomer.change(changes.Updated))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(updatedCustomer.Response()); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *CustomersModel) AssignCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	var assignment AssignCustomer
+	if err := validation.DecodeStrict(req.Body, &assignment); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Customers.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var customer *Customer
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if customer, err = model.Customers.Assign(ctx, id, &assignment.Employee_Id, assignedBy(req)); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Updated, id, previous.Response(), customer.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), customer.change(changes.Updated))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(customer.Response()); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *CustomersModel) UnassignCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	previous, err := model.Customers.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var customer *Customer
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if customer, err = model.Customers.Assign(ctx, id, nil, assignedBy(req)); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Customers, audit.Updated, id, previous.Response(), customer.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), customer.change(changes.Updated))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(customer.Response()); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *CustomersModel) ReassignCustomers(res http.ResponseWriter, req *http.Request) {
+	var reassignment ReassignCustomers
+	if err := validation.DecodeStrict(req.Body, &reassignment); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	if err := reassignment.validate(); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var previous []Customer
+	var err error
+	if len(reassignment.Customer_Ids) > 0 {
+		previous, err = model.Customers.FindByIDs(req.Context(), reassignment.Customer_Ids)
+	} else {
+		previous, err = model.Customers.FindByEmployeeID(req.Context(), *reassignment.From_Employee_Id)
+	}
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	before := make(map[int]CustomerResponse, len(previous))
+	for _, customer := range previous {
+		before[customer.Id] = customer.Response()
+	}
+
+	var customers []Customer
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if customers, err = model.Customers.Reassign(ctx, &reassignment, assignedBy(req)); err != nil {
+			return err
+		}
+		for _, customer := range customers {
+			if err := model.Audit.Record(ctx, changes.Customers, audit.Updated, customer.Id, before[customer.Id], customer.Response()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	for _, customer := range customers {
+		model.Changes.Publish(req.Context(), customer.change(changes.Updated))
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(lib.Map(customers, Customer.Response)); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *CustomersModel) GetAssignments(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	// NOTE: Checked first so an unknown customer is told apart from one never assigned
+	if _, err := model.Customers.FindByID(req.Context(), id); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedCustomer, err := model.Customers.Patch(id, &updates)
+	assignments, err := model.Customers.FindAssignments(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to update customer", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedCustomer); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(assignments, Assignment.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -185,41 +501,36 @@ func (model *CustomersModel) PatchCustomer(res http.ResponseWriter, req *http.Re
 func (model *CustomersModel) GetImage(res http.ResponseWriter, req *http.Request) {
 	customerId, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
 		return
 	}
 
-	customer, err := model.Customers.FindByID(customerId)
+	customer, err := model.Customers.FindByID(req.Context(), customerId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	if customer.Image_Id == nil {
-		http.Error(res, "Could not find image for customer", http.StatusNotFound)
+		apierror.Write(res, req, apierror.NotFound("Could not find image for customer"))
 		return
 	}
 
 	fileId, err := primitive.ObjectIDFromHex(*customer.Image_Id)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	fileContent, err := model.Customers.GetFile(fileId)
+	fileContent, err := model.Customers.GetFile(req.Context(), fileId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	_, err = res.Write(fileContent)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 	res.Header().Set("Content-Type", "application/octet-stream")
@@ -0,0 +1,121 @@
+package customers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/middleware"
+)
+
+func TestAuditPersonalFields(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	// NOTE: Every connection of :memory: is a database of its own
+	db.SetMaxOpenConns(1)
+	model := &CustomersModel{
+		Customers: CustomersDB{DB: db, Encryption: testEncryption(t, EncryptedColumns, "a2024")},
+		Audit:     &audit.Log{DB: db, Entries: audit.EntriesDB{DB: db}},
+	}
+
+	res := httptest.NewRecorder()
+	model.AddCustomer(res, httptest.NewRequest(http.MethodPost, "/api/v1/customers", strings.NewReader(`{
+		"email": "jane@test.com", "name": "Jane", "surname": "Doe", "birth_date": "1990-01-01", "gender": "Female",
+		"description": "Likes green", "astrological_sign": "Leo", "phone_number": "0600000000", "address": "1 rue de Paris"
+	}`)))
+	if res.Code != http.StatusOK {
+		t.Fatalf("Failed to add the customer: %d %s", res.Code, res.Body.String())
+	}
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/customers/1", strings.NewReader(`{
+		"birth_date": "1991-02-02", "description": "Likes blue", "phone_number": "0611111111", "address": "2 rue de Lyon"
+	}`))
+	model.PatchCustomer(res, mux.SetURLVars(req, map[string]string{"customer_id": "1"}))
+	if res.Code != http.StatusOK {
+		t.Fatalf("Failed to patch the customer: %d %s", res.Code, res.Body.String())
+	}
+
+	rows, err := db.Query("SELECT diff FROM audit_log")
+	if err != nil {
+		t.Fatalf("Failed to read the audit log: %v", err)
+	}
+	defer rows.Close()
+	entries := 0
+	for rows.Next() {
+		var diff string
+		if err := rows.Scan(&diff); err != nil {
+			t.Fatalf("Failed to read the diff: %v", err)
+		}
+		entries++
+		for _, value := range []string{"jane@test.com", "Jane", "1990-01-01", "1991-02-02", "Likes green", "Likes blue", "0600000000", "0611111111", "rue de"} {
+			if strings.Contains(diff, value) {
+				t.Errorf("Expected no personal data in the audit log, found %q in %s", value, diff)
+			}
+		}
+		if !strings.Contains(diff, `"phone_number"`) {
+			t.Errorf("Expected the change of the phone number to be recorded, got %s", diff)
+		}
+	}
+	if entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", entries)
+	}
+}
+
+func TestAssignmentRequests(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("INSERT INTO employee (id, soul_connection_id) VALUES (1, 10), (2, 20)"); err != nil {
+		t.Fatalf("Failed to add the employees: %v", err)
+	}
+	model := &CustomersModel{Customers: CustomersDB{DB: db}}
+	customer := addTestCustomer(t, model.Customers.(CustomersDB), "assigned@test.com", nil)
+	manager := &middleware.User{Id: 20, Work: "Manager"}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		path     string
+		body     string
+		expected int
+	}{
+		{"Assign", model.AssignCustomer, "/1/assign", `{"employee_id": 1}`, http.StatusOK},
+		{"Assign Client Author", model.AssignCustomer, "/1/assign", `{"employee_id": 1, "assigned_by": 1}`, http.StatusBadRequest},
+		{"Assign Invalid Employee", model.AssignCustomer, "/1/assign", `{"employee_id": 0}`, http.StatusBadRequest},
+		{"Assign Unknown Customer", model.AssignCustomer, "/99/assign", `{"employee_id": 1}`, http.StatusNotFound},
+		{"Reassign Nobody", model.ReassignCustomers, "/reassign", `{"customer_ids": [], "employee_id": 2}`, http.StatusBadRequest},
+		{"Reassign Invalid Customer", model.ReassignCustomers, "/reassign", `{"customer_ids": [-1], "employee_id": 2}`, http.StatusBadRequest},
+		{"Assignments Unknown Customer", model.GetAssignments, "/99/assignments", ``, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/customers"+tt.path, strings.NewReader(tt.body))
+			id, _, _ := strings.Cut(strings.TrimPrefix(tt.path, "/"), "/")
+			req = mux.SetURLVars(req, map[string]string{"customer_id": id})
+			res := httptest.NewRecorder()
+			tt.handler(res, req.WithContext(middleware.WithUser(req.Context(), manager)))
+			if res.Code != tt.expected {
+				t.Errorf("Expected %d, got %d %s", tt.expected, res.Code, res.Body.String())
+			}
+		})
+	}
+
+	assignments, err := model.Customers.FindAssignments(context.Background(), customer.Id)
+	if err != nil {
+		t.Fatalf("Failed to find assignments: %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].Assigned_By == nil || *assignments[0].Assigned_By != 2 {
+		t.Errorf("Expected one assignment made by employee 2, got %+v", assignments)
+	}
+}
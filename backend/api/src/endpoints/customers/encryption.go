@@ -0,0 +1,268 @@
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
+)
+
+// EncryptedColumns are the columns of customer that can be encrypted at rest,
+// see CustomersDB.Encryption.
+var EncryptedColumns = []string{"phone_number", "address", "birth_date", "description"}
+
+// phoneIndex is the column of the blind index of phone_number.
+const phoneIndex = "phone_number_index"
+
+// NewEncryption seals columns, some of EncryptedColumns, with the keys and
+// index key read by encryption.NewKeyring. Without keys it answers Fields
+// that only open the values sealed before, which none can.
+func NewEncryption(keys []string, indexKey string, columns []string) (*encryption.Fields, error) {
+	keyring, err := encryption.NewKeyring(keys, indexKey)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewFields(keyring, columns, EncryptedColumns)
+}
+
+func (c *Customer) sealed() map[string]*string {
+	return map[string]*string{"phone_number": &c.Phone_Number, "address": &c.Address, "birth_date": &c.Birth_Date, "description": &c.Description}
+}
+
+func (c *AddCustomer) sealed() map[string]*string {
+	return map[string]*string{"phone_number": &c.Phone_Number, "address": &c.Address, "birth_date": &c.Birth_Date, "description": &c.Description}
+}
+
+func (u *UpdateCustomer) sealed() map[string]*string {
+	return map[string]*string{"phone_number": u.Phone_Number, "address": u.Address, "birth_date": u.Birth_Date, "description": u.Description}
+}
+
+// sealedCustomer is an AddCustomer as stored, with its blind index.
+type sealedCustomer struct {
+	*AddCustomer
+	Phone_Number_Index string `db:"phone_number_index"`
+}
+
+// sealedUpdate is an UpdateCustomer as stored, the blind index changes along
+// with the phone number.
+type sealedUpdate struct {
+	*UpdateCustomer
+	Phone_Number_Index *string `db:"phone_number_index"`
+}
+
+// seal answers a copy of customer as it is stored.
+func (db CustomersDB) seal(customer *AddCustomer) (any, error) {
+	if db.Encryption == nil {
+		return customer, nil
+	}
+	sealed := *customer
+	if err := db.Encryption.Seal(sealed.sealed()); err != nil {
+		return nil, err
+	}
+	return sealedCustomer{AddCustomer: &sealed, Phone_Number_Index: db.Encryption.BlindIndex("phone_number", customer.Phone_Number)}, nil
+}
+
+// sealUpdate answers a copy of updates as they are stored, updates itself is
+// left as it is.
+func (db CustomersDB) sealUpdate(updates *UpdateCustomer) (any, error) {
+	if db.Encryption == nil {
+		return updates, nil
+	}
+	sealed := *updates
+	for _, value := range []**string{&sealed.Phone_Number, &sealed.Address, &sealed.Birth_Date, &sealed.Description} {
+		if *value != nil {
+			copied := **value
+			*value = &copied
+		}
+	}
+	if err := db.Encryption.Seal(sealed.sealed()); err != nil {
+		return nil, err
+	}
+	result := sealedUpdate{UpdateCustomer: &sealed}
+	if updates.Phone_Number != nil {
+		index := db.Encryption.BlindIndex("phone_number", *updates.Phone_Number)
+		result.Phone_Number_Index = &index
+	}
+	return result, nil
+}
+
+// open decrypts customer as read, whatever key sealed it.
+func (db CustomersDB) open(customer *Customer, err error) (*Customer, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Encryption.Open(customer.sealed()); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+func (db CustomersDB) openAll(customers []Customer, err error) ([]Customer, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range customers {
+		if err := db.Encryption.Open(customers[i].sealed()); err != nil {
+			return nil, err
+		}
+	}
+	return customers, nil
+}
+
+func (db CustomersDB) openEach(fn func(Customer) error) func(Customer) error {
+	return func(customer Customer) error {
+		if err := db.Encryption.Open(customer.sealed()); err != nil {
+			return err
+		}
+		return fn(customer)
+	}
+}
+
+// FindByPhoneNumber answers the customers with exactly this phone number,
+// through its blind index once it is encrypted. Rows written before are
+// still found by their plaintext until Reencrypt seals them.
+func (db CustomersDB) FindByPhoneNumber(ctx context.Context, phoneNumber string) ([]Customer, error) {
+	if !db.Encryption.Seals("phone_number") {
+		return db.openAll(db.customers().FindBy(ctx, "phone_number", phoneNumber))
+	}
+	index := db.Encryption.BlindIndex("phone_number", phoneNumber)
+	return db.openAll(db.customers().FindWhere(ctx, "("+phoneIndex+" = $1 OR phone_number = $2) ORDER BY id", index, phoneNumber))
+}
+
+// Reencryption counts the customers Reencrypt went through.
+type Reencryption struct {
+	Customers int
+	Updated   int
+}
+
+// storedCustomer holds the encrypted columns of a customer as stored.
+type storedCustomer struct {
+	Id                 int    `db:"id"`
+	Phone_Number       string `db:"phone_number"`
+	Address            string `db:"address"`
+	Birth_Date         string `db:"birth_date"`
+	Description        string `db:"description"`
+	Phone_Number_Index string `db:"phone_number_index"`
+}
+
+func (c *storedCustomer) sealed() map[string]*string {
+	return map[string]*string{"phone_number": &c.Phone_Number, "address": &c.Address, "birth_date": &c.Birth_Date, "description": &c.Description}
+}
+
+// Reencrypt seals every customer, deleted ones included, as db would write it
+// now: the values of the sealed columns under the current key, the others in
+// plaintext, and the blind index matching. The keyring must still hold the
+// keys being replaced. Customers are updated batchSize at a time, each batch
+// in its own transaction, and a dry run only counts them. A customer edited
+// between its read and its update is read and sealed again, never overwritten.
+func (db CustomersDB) Reencrypt(ctx context.Context, batchSize int, dryRun bool) (Reencryption, error) {
+	var result Reencryption
+	repo := database.Repository[storedCustomer]{DB: db.DB, Table: "customer"}
+	lastId := 0
+	for {
+		batch, err := repo.FindWhere(ctx, "id > $1 ORDER BY id LIMIT $2", lastId, batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+		lastId = batch[len(batch)-1].Id
+		result.Customers += len(batch)
+
+		for len(batch) > 0 {
+			var stale []resealed
+			for _, stored := range batch {
+				current, err := db.reseal(stored)
+				if err != nil {
+					return result, err
+				}
+				if current != nil {
+					stale = append(stale, resealed{stored: stored, current: *current})
+				}
+			}
+			if dryRun {
+				result.Updated += len(stale)
+				break
+			}
+			edited, err := db.update(ctx, stale)
+			if err != nil {
+				return result, err
+			}
+			result.Updated += len(stale) - len(edited)
+			// NOTE: A purged customer is not found again and left out
+			if batch, err = repo.FindIn(ctx, "id", edited); err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// resealed is a customer as Reencrypt read it and as it should now be.
+type resealed struct {
+	stored  storedCustomer
+	current storedCustomer
+}
+
+// reseal answers stored as it should now be, nil when it already is.
+func (db CustomersDB) reseal(stored storedCustomer) (*storedCustomer, error) {
+	current := stored
+	if err := db.Encryption.Open(current.sealed()); err != nil {
+		return nil, err
+	}
+	current.Phone_Number_Index = db.Encryption.BlindIndex("phone_number", current.Phone_Number)
+
+	storedValues, currentValues := stored.sealed(), current.sealed()
+	changed := stored.Phone_Number_Index != current.Phone_Number_Index
+	for column, value := range storedValues {
+		keyId := encryption.KeyId(*value)
+		switch {
+		case db.Encryption.Seals(column) && *currentValues[column] != "":
+			// NOTE: Sealing again draws a new nonce, only the key tells whether it is needed
+			changed = changed || keyId != db.Encryption.Keyring.Current()
+		default:
+			changed = changed || keyId != ""
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	if err := db.Encryption.Seal(currentValues); err != nil {
+		return nil, err
+	}
+	return &current, nil
+}
+
+// update writes customers in one transaction, each only if it is still as
+// it was read, and answers the ids of those that were edited meanwhile.
+func (db CustomersDB) update(ctx context.Context, customers []resealed) ([]int, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var edited []int
+	for _, c := range customers {
+		err := updateStored(ctx, tx, c)
+		if errors.Is(err, sql.ErrNoRows) {
+			edited = append(edited, c.stored.Id)
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return edited, tx.Commit()
+}
+
+func updateStored(ctx context.Context, tx *sql.Tx, c resealed) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	query := "UPDATE customer SET phone_number = $1, address = $2, birth_date = $3, description = $4, " + phoneIndex + " = $5" +
+		" WHERE id = $6 AND phone_number = $7 AND address = $8 AND birth_date = $9 AND description = $10"
+	return database.RequireRows(tx.ExecContext(ctx, query,
+		c.current.Phone_Number, c.current.Address, c.current.Birth_Date, c.current.Description, c.current.Phone_Number_Index,
+		c.stored.Id, c.stored.Phone_Number, c.stored.Address, c.stored.Birth_Date, c.stored.Description))
+}
@@ -1,224 +1,369 @@
 package customers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
 	"reflect"
-	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
 	filestorage "soul-connection.com/api/src/file-storage"
-	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/validation"
 )
 
 type CustomersDB struct {
 	DB     *sql.DB
 	Bucket *gridfs.Bucket
+	// WithDeleted makes the reads include the deleted customers, see
+	// IncludingDeleted
+	WithDeleted bool
+	// Encryption seals the sensitive columns written and opens them once
+	// read, nil stores them as they are
+	Encryption *encryption.Fields
 }
 
 type AddCustomer struct {
-	Soul_Connection_Id *int
-	Email              string
-	Name               string
-	Surname            string
-	Birth_Date         string
-	Gender             string
-	Description        string
-	Astrological_Sign  string
-	Phone_Number       string
-	Address            string
-	Employee_Id        *int
+	Soul_Connection_Id *int   `json:"soul_connection_id" db:"soul_connection_id" validate:"min=1"`
+	Email              string `json:"email" db:"email" validate:"required,email,max=255"`
+	Name               string `json:"name" db:"name" validate:"required,max=255"`
+	Surname            string `json:"surname" db:"surname" validate:"required,max=255"`
+	Birth_Date         string `json:"birth_date" db:"birth_date" validate:"date"`
+	Gender             string `json:"gender" db:"gender" validate:"oneof=Male|Female|Other"`
+	Description        string `json:"description" db:"description" validate:"max=255"`
+	Astrological_Sign  string `json:"astrological_sign" db:"astrological_sign" validate:"oneof=Aries|Taurus|Gemini|Cancer|Leo|Virgo|Libra|Scorpio|Sagittarius|Capricorn|Aquarius|Pisces"`
+	Phone_Number       string `json:"phone_number" db:"phone_number" validate:"max=255"`
+	Address            string `json:"address" db:"address" validate:"max=255"`
+	Employee_Id        *int   `json:"employee_id" db:"employee_id" validate:"min=1"`
 }
 
 type UpdateCustomer struct {
-	Email             *string
-	Name              *string
-	Surname           *string
-	Birth_Date        *string
-	Gender            *string
-	Description       *string
-	Astrological_Sign *string
-	Phone_Number      *string
-	Address           *string
-	Employee_Id       *int
-}
-
-func (db CustomersDB) FindAll() ([]Customer, error) {
-	rows, err := db.DB.Query("SELECT * FROM customer")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var customers []Customer
+	Email             *string `json:"email" db:"email" validate:"required,email,max=255"`
+	Name              *string `json:"name" db:"name" validate:"required,max=255"`
+	Surname           *string `json:"surname" db:"surname" validate:"required,max=255"`
+	Birth_Date        *string `json:"birth_date" db:"birth_date" validate:"date"`
+	Gender            *string `json:"gender" db:"gender" validate:"oneof=Male|Female|Other"`
+	Description       *string `json:"description" db:"description" validate:"max=255"`
+	Astrological_Sign *string `json:"astrological_sign" db:"astrological_sign" validate:"oneof=Aries|Taurus|Gemini|Cancer|Leo|Virgo|Libra|Scorpio|Sagittarius|Capricorn|Aquarius|Pisces"`
+	Phone_Number      *string `json:"phone_number" db:"phone_number" validate:"max=255"`
+	Address           *string `json:"address" db:"address" validate:"max=255"`
+	Employee_Id       *int    `json:"employee_id" db:"employee_id" validate:"min=1"`
+}
 
-	for rows.Next() {
-		var c Customer
-		err := rows.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
-		if err != nil {
-			return nil, err
+type AssignCustomer struct {
+	Employee_Id int `json:"employee_id" validate:"required,min=1"`
+}
+
+// ReassignCustomers moves Customer_Ids, or every customer of
+// From_Employee_Id, to Employee_Id, no one when it is nil.
+type ReassignCustomers struct {
+	Customer_Ids     []int `json:"customer_ids"`
+	From_Employee_Id *int  `json:"from_employee_id" validate:"min=1"`
+	Employee_Id      *int  `json:"employee_id" validate:"min=1"`
+}
+
+// validate checks what the validate tags cannot, the customers to move.
+func (r *ReassignCustomers) validate() error {
+	if len(r.Customer_Ids) == 0 && r.From_Employee_Id == nil {
+		return validation.Errors{{Field: "customer_ids", Message: "is required without from_employee_id"}}
+	}
+	for _, id := range r.Customer_Ids {
+		if id < 1 {
+			return validation.Errors{{Field: "customer_ids", Message: "must only hold ids of at least 1"}}
 		}
-		customers = append(customers, c)
 	}
-	err = rows.Err()
+	return nil
+}
 
-	if err != nil {
-		return nil, err
+func (updates *UpdateCustomer) isEmpty() bool {
+	v := reflect.ValueOf(updates).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsNil() {
+			return false
+		}
 	}
-	return customers, nil
+	return true
 }
 
-func (db CustomersDB) FindByID(id int) (*Customer, error) {
-	query := "SELECT * FROM customer WHERE id = $1"
+// notDeleted scopes the queries to the customers that were not deleted, the
+// others are kept until the retention job purges them.
+const notDeleted = "deleted_at IS NULL"
 
-	row := db.DB.QueryRow(query, id)
-	var c Customer
+func repository(q database.Queryer) database.Repository[Customer] {
+	return database.Repository[Customer]{DB: q, Table: "customer", Scope: notDeleted}
+}
 
-	err := row.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
-	if err != nil {
-		return nil, err
+// customers is the repository the reads of db go through.
+func (db CustomersDB) customers() database.Repository[Customer] {
+	repo := repository(db.DB)
+	if db.WithDeleted {
+		repo.Scope = ""
 	}
+	return repo
+}
 
-	return &c, nil
+// IncludingDeleted answers a copy of db whose reads include the deleted
+// customers.
+func (db CustomersDB) IncludingDeleted() CustomersRepository {
+	db.WithDeleted = true
+	return db
 }
 
-func (db CustomersDB) FindByEmployeeID(id int) ([]Customer, error) {
-	query := "SELECT * FROM customer WHERE employee_id = $1"
+func (db CustomersDB) FindAll(ctx context.Context) ([]Customer, error) {
+	return db.openAll(db.customers().FindAll(ctx))
+}
 
-	rows, err := db.DB.Query(query, id)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var customers []Customer
+func (db CustomersDB) StreamAll(ctx context.Context, fn func(Customer) error) error {
+	return db.customers().StreamAll(ctx, db.openEach(fn))
+}
 
-	for rows.Next() {
-		var c Customer
-	err := rows.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
-		if err != nil {
-			return nil, err
-		}
-		customers = append(customers, c)
-	}
-	err = rows.Err()
+func (db CustomersDB) FindByID(ctx context.Context, id int) (*Customer, error) {
+	return db.open(db.customers().FindByID(ctx, id))
+}
 
-	if err != nil {
-		return nil, err
-	}
+func (db CustomersDB) FindByIDs(ctx context.Context, ids []int) ([]Customer, error) {
+	return db.openAll(db.customers().FindIn(ctx, "id", ids))
+}
 
-	return customers, nil
+func (db CustomersDB) FindByEmployeeID(ctx context.Context, id int) ([]Customer, error) {
+	return db.openAll(db.customers().FindBy(ctx, "employee_id", id))
 }
 
-func (db CustomersDB) FindByOldID(id int) (*Customer, error) {
-	query := "SELECT * FROM customer WHERE soul_connection_id = $1"
+func (db CustomersDB) FindByEmployeeIDs(ctx context.Context, ids []int) ([]Customer, error) {
+	return db.openAll(db.customers().FindIn(ctx, "employee_id", ids))
+}
 
-	row := db.DB.QueryRow(query, id)
-	var c Customer
+func (db CustomersDB) StreamByEmployeeID(ctx context.Context, id int, fn func(Customer) error) error {
+	return db.customers().StreamBy(ctx, "employee_id", id, db.openEach(fn))
+}
 
-	err := row.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
+func (db CustomersDB) FindByOldID(ctx context.Context, id int) (*Customer, error) {
+	return db.open(db.customers().FindOneBy(ctx, "soul_connection_id", id))
+}
+
+func (db CustomersDB) Add(ctx context.Context, customer *AddCustomer) (*Customer, error) {
+	return db.Insert(ctx, db.DB, customer)
+}
+
+func (db CustomersDB) Insert(ctx context.Context, q database.Queryer, customer *AddCustomer) (*Customer, error) {
+	sealed, err := db.seal(customer)
 	if err != nil {
 		return nil, err
 	}
+	return db.open(repository(q).Add(ctx, sealed))
+}
+
+func (db CustomersDB) FindIDByEmail(ctx context.Context, q database.Queryer, email string) (int, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
 
-	return &c, nil
+	var id int
+	err := q.QueryRowContext(ctx, "SELECT id FROM customer WHERE email = $1 AND "+notDeleted, email).Scan(&id)
+	return id, err
 }
 
-func (db CustomersDB) Add(customer *AddCustomer) (*Customer, error) {
+// MergeByEmail overwrites the customer sharing customer.Email with every value
+// that is set, empty strings and nil values keep what is already stored.
+func (db CustomersDB) MergeByEmail(ctx context.Context, q database.Queryer, customer *AddCustomer) (*Customer, error) {
+	values := *customer
+	if err := db.Encryption.Seal(values.sealed()); err != nil {
+		return nil, err
+	}
+	// NOTE: Without a phone number the index already stored is kept
+	var index *string
+	if customer.Phone_Number != "" {
+		phoneNumberIndex := db.Encryption.BlindIndex("phone_number", customer.Phone_Number)
+		index = &phoneNumberIndex
+	}
+	repo := repository(q)
 	query := `
-		INSERT INTO customer (soul_connection_id, email, name, surname, birth_date, gender, description, astrological_sign, phone_number, address, employee_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING *
+		UPDATE customer SET
+			soul_connection_id = COALESCE($1, soul_connection_id),
+			name = COALESCE(NULLIF($2, ''), name),
+			surname = COALESCE(NULLIF($3, ''), surname),
+			birth_date = COALESCE(NULLIF($4, ''), birth_date),
+			gender = COALESCE(NULLIF($5, ''), gender),
+			description = COALESCE(NULLIF($6, ''), description),
+			astrological_sign = COALESCE(NULLIF($7, ''), astrological_sign),
+			phone_number = COALESCE(NULLIF($8, ''), phone_number),
+			address = COALESCE(NULLIF($9, ''), address),
+			employee_id = COALESCE($10, employee_id),
+			phone_number_index = COALESCE($11, phone_number_index)
+		WHERE email = $12 AND deleted_at IS NULL
     `
-	row := db.DB.QueryRow(query, customer.Soul_Connection_Id, customer.Email, customer.Name, customer.Surname, customer.Birth_Date, customer.Gender, customer.Description, customer.Astrological_Sign, customer.Phone_Number, customer.Address, customer.Employee_Id)
-	var c Customer
+	return db.open(repo.QueryRow(ctx, query+repo.Returning(), values.Soul_Connection_Id, values.Name, values.Surname, values.Birth_Date, values.Gender, values.Description, values.Astrological_Sign, values.Phone_Number, values.Address, values.Employee_Id, index, values.Email))
+}
+
+// Delete marks the customer as deleted, it is hidden from then on but kept
+// along with its rows until purged.
+func (db CustomersDB) Delete(ctx context.Context, id int) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	query := "UPDATE customer SET deleted_at = $1 WHERE id = $2 AND " + notDeleted
+	return database.RequireRows(database.Conn(ctx, db.DB).ExecContext(ctx, query, time.Now().UTC(), id))
+}
 
-	err := row.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
+// Restore brings a deleted customer back, sql.ErrNoRows when there is no
+// deleted customer with this id.
+func (db CustomersDB) Restore(ctx context.Context, id int) (*Customer, error) {
+	repo := repository(db.DB)
+	return db.open(repo.QueryRow(ctx, "UPDATE customer SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL "+repo.Returning(), id))
+}
 
+func (db CustomersDB) Patch(ctx context.Context, id int, updates *UpdateCustomer) (*Customer, error) {
+	sealed, err := db.sealUpdate(updates)
 	if err != nil {
 		return nil, err
 	}
-	return &c, nil
+	return db.open(repository(db.DB).Patch(ctx, id, sealed))
 }
 
-func (db CustomersDB) Delete(id int) error {
-	query := "DELETE FROM customer WHERE id = $1"
+// Assign gives the customer to employeeId, or to no one when it is nil, and
+// keeps the change in its history. assignedBy is the upstream id of the
+// employee making the change, as middleware.User has it.
+func (db CustomersDB) Assign(ctx context.Context, customerId int, employeeId *int, assignedBy *int) (*Customer, error) {
+	tx, err := database.Begin(ctx, db.DB)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := db.DB.Exec(query, id)
-	return err
+	c, err := assign(ctx, tx.Tx, customerId, employeeId, assignedBy)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return db.open(c, nil)
 }
 
-func (db CustomersDB) Patch(id int, updates *UpdateCustomer) (*Customer, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
+func (db CustomersDB) Reassign(ctx context.Context, reassignment *ReassignCustomers, assignedBy *int) ([]Customer, error) {
+	tx, err := database.Begin(ctx, db.DB)
+	if err != nil {
+		return nil, err
+	}
 
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
+	customerIds := reassignment.Customer_Ids
+	if len(customerIds) == 0 && reassignment.From_Employee_Id != nil {
+		customerIds, err = findIdsByEmployeeID(ctx, tx.Tx, *reassignment.From_Employee_Id)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
+	var customers []Customer
+	for _, customerId := range customerIds {
+		c, err := assign(ctx, tx.Tx, customerId, reassignment.Employee_Id, assignedBy)
+		if err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("could not reassign customer %d: %w", customerId, err)
 		}
+		customers = append(customers, *c)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
+	return db.openAll(customers, nil)
+}
+
+func (db CustomersDB) FindAssignments(ctx context.Context, customerId int) ([]Assignment, error) {
+	assignments := database.Repository[Assignment]{DB: db.DB, Table: "customer_assignment"}
+
+	return assignments.FindWhere(ctx, "customer_id = $1 ORDER BY created_at, id", customerId)
+}
 
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+func assign(ctx context.Context, tx *sql.Tx, customerId int, employeeId *int, assignedBy *int) (*Customer, error) {
+	// NOTE: Touching the row locks it until the end of the transaction, like
+	// SELECT ... FOR UPDATE which SQLite does not know, so concurrent
+	// assignments of a customer are kept in its history one after the other
+	var previousEmployeeId *int
+	query := "UPDATE customer SET employee_id = employee_id WHERE id = $1 AND " + notDeleted + " RETURNING employee_id"
+	err := tx.QueryRowContext(ctx, query, customerId).Scan(&previousEmployeeId)
+	if err != nil {
+		return nil, err
 	}
 
-	query := fmt.Sprintf(
-		"UPDATE customer SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
+	repo := repository(tx)
+	c, err := repo.QueryRow(ctx, "UPDATE customer SET employee_id = $1 WHERE id = $2 "+repo.Returning(), employeeId, customerId)
+	if err != nil {
+		return nil, err
+	}
 
-	args = append(args, id)
+	if sameEmployee(previousEmployeeId, employeeId) {
+		return c, nil
+	}
+	query = `
+		INSERT INTO customer_assignment (customer_id, previous_employee_id, employee_id, assigned_by)
+		VALUES ($1, $2, $3, (SELECT id FROM employee WHERE soul_connection_id = $4))
+    `
+	_, err = tx.ExecContext(ctx, query, customerId, previousEmployeeId, employeeId, assignedBy)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	row := db.DB.QueryRow(query, args...)
-	var c Customer
-	err := row.Scan(&c.Id, &c.Soul_Connection_Id, &c.Email, &c.Name, &c.Surname, &c.Birth_Date, &c.Gender, &c.Description, &c.Astrological_Sign, &c.Phone_Number, &c.Address, &c.Image_Id, &c.CreatedAt, &c.Employee_Id)
+func findIdsByEmployeeID(ctx context.Context, tx *sql.Tx, employeeId int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM customer WHERE employee_id = $1 AND "+notDeleted, employeeId)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	var ids []int
 
-	return &c, nil
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func sameEmployee(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
-func (db CustomersDB) UploadFile(customerId int, r io.Reader, filename string) (*primitive.ObjectID, error) {
-	fileId, err := filestorage.Upload(db.Bucket, r, filename)
+func (db CustomersDB) UploadFile(ctx context.Context, customerId int, r io.Reader, filename string) (*primitive.ObjectID, error) {
+	fileId, err := filestorage.Upload(ctx, db.Bucket, r, filename)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := db.DB.Begin()
+	tx, err := database.Begin(ctx, db.DB)
 	if err != nil {
 		return nil, err
 	}
 	query := `
     UPDATE customer SET image_id = $1 WHERE id = $2
     `
-	_, err = tx.Exec(query, fileId.Hex(), customerId)
+	_, err = tx.ExecContext(ctx, query, fileId.Hex(), customerId)
 	if err != nil {
-		filestorage.Delete(db.Bucket, *fileId)
+		filestorage.Delete(context.WithoutCancel(ctx), db.Bucket, *fileId)
 		tx.Rollback()
-		lib.ServerLog("ERROR", fmt.Errorf("Failed to update customer image_id: %v", err))
+		logger.FromContext(ctx).Error("Failed to update customer image_id", "error", err)
 		return nil, err
 	}
 	if err := tx.Commit(); err != nil {
-		filestorage.Delete(db.Bucket, *fileId)
-		lib.ServerLog("ERROR", "Could not commit customer")
+		filestorage.Delete(context.WithoutCancel(ctx), db.Bucket, *fileId)
+		logger.FromContext(ctx).Error("Could not commit customer", "error", err)
 		return nil, err
 	}
 	return fileId, nil
 }
 
-func (db CustomersDB) GetFile(fileId primitive.ObjectID) ([]byte, error) {
-	return filestorage.DownloadById(db.Bucket, fileId)
+func (db CustomersDB) GetFile(ctx context.Context, fileId primitive.ObjectID) ([]byte, error) {
+	return filestorage.DownloadById(ctx, db.Bucket, fileId)
 }
@@ -0,0 +1,440 @@
+package customers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
+)
+
+func setupTestDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	schema := `
+	CREATE TABLE employee (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE
+	);
+	CREATE TABLE customer (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL,
+		surname TEXT NOT NULL,
+		birth_date TEXT NOT NULL,
+		gender TEXT NOT NULL,
+		description TEXT NOT NULL,
+		astrological_sign TEXT NOT NULL,
+		phone_number TEXT NOT NULL,
+		phone_number_index TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL,
+		image_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		employee_id INTEGER
+	);
+	CREATE UNIQUE INDEX customer_email ON customer (email) WHERE deleted_at IS NULL;
+	CREATE TABLE customer_assignment (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_id INTEGER NOT NULL,
+		previous_employee_id INTEGER,
+		employee_id INTEGER,
+		assigned_by INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor_id INTEGER,
+		actor_email TEXT,
+		entity TEXT NOT NULL,
+		entity_id INTEGER,
+		action TEXT NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		diff TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func addTestCustomer(t *testing.T, db CustomersDB, email string, employeeId *int) *Customer {
+	customer, err := db.Add(context.Background(), &AddCustomer{
+		Email:             email,
+		Name:              "John",
+		Surname:           "Doe",
+		Birth_Date:        "1990-01-01",
+		Gender:            "Male",
+		Description:       "description",
+		Astrological_Sign: "Leo",
+		Phone_Number:      "0600000000",
+		Address:           "1 rue de Paris",
+		Employee_Id:       employeeId,
+	})
+	if err != nil {
+		t.Fatalf("Failed to add customer: %v", err)
+	}
+	return customer
+}
+
+func TestCustomerAssignmentQueries(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+
+	customersDB := CustomersDB{DB: db}
+	coach, otherCoach, manager := 1, 2, 3
+	// NOTE: Assignments are made by the upstream id of the manager
+	upstreamManager := 30
+	if _, err := db.Exec("INSERT INTO employee (id, soul_connection_id) VALUES ($1, $2)", manager, upstreamManager); err != nil {
+		t.Fatalf("Failed to add the manager: %v", err)
+	}
+
+	t.Run("Assign Customer", func(t *testing.T) {
+		customer := addTestCustomer(t, customersDB, "assign@test.com", nil)
+
+		assigned, err := customersDB.Assign(context.Background(), customer.Id, &coach, &upstreamManager)
+		if err != nil {
+			t.Fatalf("Failed to assign customer: %v", err)
+		}
+		if assigned.Employee_Id == nil || *assigned.Employee_Id != coach {
+			t.Errorf("Expected employee %d, got %v", coach, assigned.Employee_Id)
+		}
+
+		assignments, err := customersDB.FindAssignments(context.Background(), customer.Id)
+		if err != nil {
+			t.Fatalf("Failed to find assignments: %v", err)
+		}
+		if len(assignments) != 1 {
+			t.Fatalf("Expected 1 assignment, got %d", len(assignments))
+		}
+		if assignments[0].Previous_Employee_Id != nil || *assignments[0].Assigned_By != manager {
+			t.Errorf("Unexpected assignment %+v", assignments[0])
+		}
+	})
+
+	t.Run("Assign Same Employee Skips History", func(t *testing.T) {
+		customer := addTestCustomer(t, customersDB, "same@test.com", &coach)
+
+		if _, err := customersDB.Assign(context.Background(), customer.Id, &coach, &upstreamManager); err != nil {
+			t.Fatalf("Failed to assign customer: %v", err)
+		}
+
+		assignments, err := customersDB.FindAssignments(context.Background(), customer.Id)
+		if err != nil {
+			t.Fatalf("Failed to find assignments: %v", err)
+		}
+		if len(assignments) != 0 {
+			t.Errorf("Expected no assignment, got %d", len(assignments))
+		}
+	})
+
+	t.Run("Unassign Customer", func(t *testing.T) {
+		customer := addTestCustomer(t, customersDB, "unassign@test.com", &coach)
+
+		unassigned, err := customersDB.Assign(context.Background(), customer.Id, nil, &upstreamManager)
+		if err != nil {
+			t.Fatalf("Failed to unassign customer: %v", err)
+		}
+		if unassigned.Employee_Id != nil {
+			t.Errorf("Expected no employee, got %d", *unassigned.Employee_Id)
+		}
+
+		assignments, err := customersDB.FindAssignments(context.Background(), customer.Id)
+		if err != nil {
+			t.Fatalf("Failed to find assignments: %v", err)
+		}
+		if len(assignments) != 1 || *assignments[0].Previous_Employee_Id != coach || assignments[0].Employee_Id != nil {
+			t.Errorf("Unexpected assignments %+v", assignments)
+		}
+	})
+
+	t.Run("Assign Unknown Customer", func(t *testing.T) {
+		_, err := customersDB.Assign(context.Background(), 99, &coach, &upstreamManager)
+		if err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("Reassign From Employee", func(t *testing.T) {
+		before, err := customersDB.FindByEmployeeID(context.Background(), coach)
+		if err != nil {
+			t.Fatalf("Failed to find customers by employee: %v", err)
+		}
+
+		reassigned, err := customersDB.Reassign(context.Background(), &ReassignCustomers{
+			From_Employee_Id: &coach,
+			Employee_Id:      &otherCoach,
+		}, &upstreamManager)
+		if err != nil {
+			t.Fatalf("Failed to reassign customers: %v", err)
+		}
+		if len(reassigned) != len(before) {
+			t.Errorf("Expected %d reassigned customers, got %d", len(before), len(reassigned))
+		}
+
+		after, err := customersDB.FindByEmployeeID(context.Background(), coach)
+		if err != nil {
+			t.Fatalf("Failed to find customers by employee: %v", err)
+		}
+		if len(after) != 0 {
+			t.Errorf("Expected no customers left for employee %d, got %d", coach, len(after))
+		}
+	})
+
+	t.Run("Reassign Rolls Back On Error", func(t *testing.T) {
+		customer := addTestCustomer(t, customersDB, "rollback@test.com", &otherCoach)
+
+		_, err := customersDB.Reassign(context.Background(), &ReassignCustomers{
+			Customer_Ids: []int{customer.Id, 99},
+			Employee_Id:  &coach,
+		}, nil)
+		if err == nil {
+			t.Fatalf("Expected error when reassigning unknown customer")
+		}
+
+		unchanged, err := customersDB.FindByID(context.Background(), customer.Id)
+		if err != nil {
+			t.Fatalf("Failed to find customer: %v", err)
+		}
+		if *unchanged.Employee_Id != otherCoach {
+			t.Errorf("Expected employee %d after rollback, got %d", otherCoach, *unchanged.Employee_Id)
+		}
+	})
+}
+
+func TestCustomerDeletionQueries(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	customersDB := CustomersDB{DB: db}
+	coach := 1
+	customer := addTestCustomer(t, customersDB, "deleted@test.com", &coach)
+	kept := addTestCustomer(t, customersDB, "kept@test.com", &coach)
+
+	t.Run("Delete Hides The Customer", func(t *testing.T) {
+		if err := customersDB.Delete(ctx, customer.Id); err != nil {
+			t.Fatalf("Failed to delete customer: %v", err)
+		}
+		if _, err := customersDB.FindByID(ctx, customer.Id); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+		all, err := customersDB.FindAll(ctx)
+		if err != nil || len(all) != 1 || all[0].Id != kept.Id {
+			t.Errorf("Expected only customer %d, got %+v (%v)", kept.Id, all, err)
+		}
+		byEmployee, err := customersDB.FindByEmployeeID(ctx, coach)
+		if err != nil || len(byEmployee) != 1 {
+			t.Errorf("Expected only customer %d, got %+v (%v)", kept.Id, byEmployee, err)
+		}
+		if _, err := customersDB.Assign(ctx, customer.Id, nil, nil); err != sql.ErrNoRows {
+			t.Errorf("Expected a deleted customer not to be assigned, got %v", err)
+		}
+		if err := customersDB.Delete(ctx, customer.Id); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows when deleting twice, got %v", err)
+		}
+	})
+
+	t.Run("Including Deleted", func(t *testing.T) {
+		found, err := customersDB.IncludingDeleted().FindByID(ctx, customer.Id)
+		if err != nil || found.Deleted_At == nil {
+			t.Fatalf("Expected the deleted customer, got %+v (%v)", found, err)
+		}
+		all, err := customersDB.IncludingDeleted().FindAll(ctx)
+		if err != nil || len(all) != 2 {
+			t.Errorf("Expected both customers, got %+v (%v)", all, err)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		restored, err := customersDB.Restore(ctx, customer.Id)
+		if err != nil || restored.Deleted_At != nil || *restored.Employee_Id != coach {
+			t.Fatalf("Expected the customer back with its coach, got %+v (%v)", restored, err)
+		}
+		if _, err := customersDB.FindByID(ctx, customer.Id); err != nil {
+			t.Errorf("Expected the customer to be found again, got %v", err)
+		}
+		if _, err := customersDB.Restore(ctx, kept.Id); err != sql.ErrNoRows {
+			t.Errorf("Expected sql.ErrNoRows restoring a customer that was not deleted, got %v", err)
+		}
+	})
+}
+
+func testEncryption(t *testing.T, columns []string, keys ...string) *encryption.Fields {
+	var encodedKeys []string
+	for _, id := range keys {
+		encodedKeys = append(encodedKeys, id+":"+base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id[:1], 32))))
+	}
+	fields, err := NewEncryption(encodedKeys, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("i", 32))), columns)
+	if err != nil {
+		t.Fatalf("Failed to create the encryption: %v", err)
+	}
+	return fields
+}
+
+func storedValue(t *testing.T, db *sql.DB, column string, id int) string {
+	var value string
+	if err := db.QueryRow("SELECT "+column+" FROM customer WHERE id = $1", id).Scan(&value); err != nil {
+		t.Fatalf("Failed to read %s: %v", column, err)
+	}
+	return value
+}
+
+func TestCustomerEncryption(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	plain := addTestCustomer(t, CustomersDB{DB: db}, "plain@test.com", nil)
+	customersDB := CustomersDB{DB: db, Encryption: testEncryption(t, EncryptedColumns, "a2024")}
+	customer := addTestCustomer(t, customersDB, "sealed@test.com", nil)
+
+	t.Run("Sealed At Rest", func(t *testing.T) {
+		if customer.Phone_Number != "0600000000" || customer.Address != "1 rue de Paris" {
+			t.Errorf("Expected the added customer in plaintext, got %+v", customer)
+		}
+		for _, column := range EncryptedColumns {
+			if value := storedValue(t, db, column, customer.Id); encryption.KeyId(value) != "a2024" {
+				t.Errorf("Expected %s to be sealed, got %q", column, value)
+			}
+		}
+		if storedValue(t, db, "name", customer.Id) != "John" || storedValue(t, db, phoneIndex, customer.Id) == "" {
+			t.Errorf("Expected the name in plaintext along with the index of the phone number")
+		}
+		all, err := customersDB.FindAll(ctx)
+		if err != nil || len(all) != 2 || all[0].Address != "1 rue de Paris" || all[1].Address != "1 rue de Paris" {
+			t.Errorf("Expected every customer opened, got %+v (%v)", all, err)
+		}
+		if _, err := (CustomersDB{DB: db}).FindByID(ctx, customer.Id); !errors.Is(err, encryption.ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey without the keys, got %v", err)
+		}
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		phoneNumber, address := "0611111111", "2 rue de Lyon"
+		updates := &UpdateCustomer{Phone_Number: &phoneNumber, Address: &address}
+		patched, err := customersDB.Patch(ctx, customer.Id, updates)
+		if err != nil || patched.Phone_Number != phoneNumber || patched.Address != address || *updates.Address != address {
+			t.Fatalf("Expected the patched values in plaintext, got %+v (%v)", patched, err)
+		}
+		if value := storedValue(t, db, "address", customer.Id); encryption.KeyId(value) != "a2024" {
+			t.Errorf("Expected the address to be sealed, got %q", value)
+		}
+	})
+
+	t.Run("Find By Phone Number", func(t *testing.T) {
+		found, err := customersDB.FindByPhoneNumber(ctx, "0611111111")
+		if err != nil || len(found) != 1 || found[0].Id != customer.Id {
+			t.Errorf("Expected the customer through its blind index, got %+v (%v)", found, err)
+		}
+		found, err = customersDB.FindByPhoneNumber(ctx, "0600000000")
+		if err != nil || len(found) != 1 || found[0].Id != plain.Id {
+			t.Errorf("Expected the customer written in plaintext, got %+v (%v)", found, err)
+		}
+	})
+
+	t.Run("Merge By Email", func(t *testing.T) {
+		merged, err := customersDB.MergeByEmail(ctx, db, &AddCustomer{Email: "sealed@test.com", Phone_Number: "0622222222"})
+		if err != nil || merged.Phone_Number != "0622222222" || merged.Address != "2 rue de Lyon" {
+			t.Fatalf("Expected the phone number merged, got %+v (%v)", merged, err)
+		}
+		if found, err := customersDB.FindByPhoneNumber(ctx, "0622222222"); err != nil || len(found) != 1 {
+			t.Errorf("Expected the index to follow the phone number, got %+v (%v)", found, err)
+		}
+	})
+
+	t.Run("Reencrypt", func(t *testing.T) {
+		rotated := CustomersDB{DB: db, Encryption: testEncryption(t, EncryptedColumns, "b2025", "a2024")}
+		dryRun, err := rotated.Reencrypt(ctx, 1, true)
+		if err != nil || dryRun.Customers != 2 || dryRun.Updated != 2 {
+			t.Fatalf("Expected both customers to be sealed again, got %+v (%v)", dryRun, err)
+		}
+		if value := storedValue(t, db, "address", plain.Id); value != "1 rue de Paris" {
+			t.Errorf("Expected a dry run to save nothing, got %q", value)
+		}
+
+		result, err := rotated.Reencrypt(ctx, 1, false)
+		if err != nil || result != dryRun {
+			t.Fatalf("Expected %+v, got %+v (%v)", dryRun, result, err)
+		}
+		for _, id := range []int{plain.Id, customer.Id} {
+			if value := storedValue(t, db, "description", id); encryption.KeyId(value) != "b2025" {
+				t.Errorf("Expected the description of %d sealed with the new key, got %q", id, value)
+			}
+		}
+		newKeyOnly := CustomersDB{DB: db, Encryption: testEncryption(t, EncryptedColumns, "b2025")}
+		if found, err := newKeyOnly.FindByPhoneNumber(ctx, "0600000000"); err != nil || len(found) != 1 || found[0].Address != "1 rue de Paris" {
+			t.Errorf("Expected the former key to be no longer needed, got %+v (%v)", found, err)
+		}
+		if again, err := newKeyOnly.Reencrypt(ctx, 10, false); err != nil || again.Updated != 0 {
+			t.Errorf("Expected nothing left to seal, got %+v (%v)", again, err)
+		}
+	})
+
+	t.Run("Decrypt", func(t *testing.T) {
+		partial := CustomersDB{DB: db, Encryption: testEncryption(t, []string{"phone_number"}, "b2025")}
+		if result, err := partial.Reencrypt(ctx, 10, false); err != nil || result.Updated != 2 {
+			t.Fatalf("Expected both customers to be written back, got %+v (%v)", result, err)
+		}
+		if value := storedValue(t, db, "address", customer.Id); value != "2 rue de Lyon" {
+			t.Errorf("Expected the address in plaintext once no longer sealed, got %q", value)
+		}
+		if value := storedValue(t, db, "phone_number", customer.Id); encryption.KeyId(value) != "b2025" {
+			t.Errorf("Expected the phone number still sealed, got %q", value)
+		}
+	})
+
+	t.Run("Edited Meanwhile", func(t *testing.T) {
+		stored, err := database.Repository[storedCustomer]{DB: db, Table: "customer"}.FindOneBy(ctx, "id", customer.Id)
+		if err != nil {
+			t.Fatalf("Failed to read the customer: %v", err)
+		}
+		rotated := CustomersDB{DB: db, Encryption: testEncryption(t, EncryptedColumns, "c2026", "b2025")}
+		current, err := rotated.reseal(*stored)
+		if err != nil || current == nil {
+			t.Fatalf("Expected the customer to be sealed again, got %+v (%v)", current, err)
+		}
+		address := "3 rue de Nice"
+		partial := CustomersDB{DB: db, Encryption: testEncryption(t, []string{"phone_number"}, "b2025")}
+		if _, err := partial.Patch(ctx, customer.Id, &UpdateCustomer{Address: &address}); err != nil {
+			t.Fatalf("Failed to patch the customer: %v", err)
+		}
+
+		edited, err := rotated.update(ctx, []resealed{{stored: *stored, current: *current}})
+		if err != nil || len(edited) != 1 || edited[0] != customer.Id {
+			t.Fatalf("Expected the edit to be noticed, got %v (%v)", edited, err)
+		}
+		if value := storedValue(t, db, "address", customer.Id); value != address {
+			t.Errorf("Expected the edit to be kept, got %q", value)
+		}
+		if result, err := rotated.Reencrypt(ctx, 10, false); err != nil || result.Updated != 2 {
+			t.Fatalf("Expected both customers to be sealed, got %+v (%v)", result, err)
+		}
+		if found, err := rotated.FindByID(ctx, customer.Id); err != nil || found.Address != address {
+			t.Errorf("Expected the edited address sealed, got %+v (%v)", found, err)
+		}
+	})
+}
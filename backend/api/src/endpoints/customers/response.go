@@ -0,0 +1,81 @@
+package customers
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// CustomerResponse is the JSON of a Customer, Has_Image tells whether its
+// image route has anything to send. Deleted_At is only sent for the deleted
+// customers listed with include_deleted.
+type CustomerResponse struct {
+	Id                 int        `json:"id"`
+	Soul_Connection_Id *int       `json:"soul_connection_id"`
+	Email              string     `json:"email"`
+	Name               string     `json:"name"`
+	Surname            string     `json:"surname"`
+	Birth_Date         string     `json:"birth_date"`
+	Gender             string     `json:"gender"`
+	Description        string     `json:"description"`
+	Astrological_Sign  string     `json:"astrological_sign"`
+	Phone_Number       string     `json:"phone_number"`
+	Address            string     `json:"address"`
+	Has_Image          bool       `json:"has_image"`
+	CreatedAt          time.Time  `json:"created_at"`
+	Deleted_At         *time.Time `json:"deleted_at,omitempty"`
+	Employee_Id        *int       `json:"employee_id"`
+}
+
+func (c Customer) Response() CustomerResponse {
+	return CustomerResponse{
+		Id:                 c.Id,
+		Soul_Connection_Id: c.Soul_Connection_Id,
+		Email:              c.Email,
+		Name:               c.Name,
+		Surname:            c.Surname,
+		Birth_Date:         c.Birth_Date,
+		Gender:             c.Gender,
+		Description:        c.Description,
+		Astrological_Sign:  c.Astrological_Sign,
+		Phone_Number:       c.Phone_Number,
+		Address:            c.Address,
+		Has_Image:          c.Image_Id != nil,
+		CreatedAt:          c.CreatedAt,
+		Deleted_At:         c.Deleted_At,
+		Employee_Id:        c.Employee_Id,
+	}
+}
+
+// PersonalFields tell who the customer is, the audit log does not hold their
+// values. Those that can be sealed are among them.
+func (CustomerResponse) PersonalFields() []string {
+	return append([]string{"email", "name", "surname", "gender", "astrological_sign"}, EncryptedColumns...)
+}
+
+// AssignmentResponse is the JSON of an Assignment.
+type AssignmentResponse struct {
+	Id                   int       `json:"id"`
+	Customer_Id          int       `json:"customer_id"`
+	Previous_Employee_Id *int      `json:"previous_employee_id"`
+	Employee_Id          *int      `json:"employee_id"`
+	Assigned_By          *int      `json:"assigned_by"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+func (a Assignment) Response() AssignmentResponse {
+	return AssignmentResponse{
+		Id:                   a.Id,
+		Customer_Id:          a.Customer_Id,
+		Previous_Employee_Id: a.Previous_Employee_Id,
+		Employee_Id:          a.Employee_Id,
+		Assigned_By:          a.Assigned_By,
+		CreatedAt:            a.CreatedAt,
+	}
+}
+
+// change describes a write of c, the coach it has once written is part of it
+// so streams know who follows the customer.
+func (c Customer) change(action changes.Action) changes.Change {
+	return changes.New(changes.Customers, action, c.Id, c.Response()).ForCustomer(c.Id).WithCoach(c.Employee_Id)
+}
@@ -0,0 +1,144 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/payments"
+	filestorage "soul-connection.com/api/src/file-storage"
+	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+)
+
+const (
+	receiptDocument = "receipt"
+	invoiceDocument = "invoice"
+)
+
+type DocumentsModel struct {
+	Payments interface {
+		FindByID(context.Context, int) (*payments.Payment, error)
+		FindByCustomerID(context.Context, int) ([]payments.Payment, error)
+	}
+	Customers interface {
+		FindByID(context.Context, int) (*customers.Customer, error)
+	}
+	// NOTE: Generated documents are only kept when a bucket is set and the
+	// request asks for it with ?store=true
+	Bucket *gridfs.Bucket
+}
+
+func (model *DocumentsModel) GetPaymentDocument(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "payment_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid payment ID format"))
+		return
+	}
+
+	kind := req.URL.Query().Get("type")
+	if kind == "" {
+		kind = receiptDocument
+	}
+	if kind != receiptDocument && kind != invoiceDocument {
+		apierror.Write(res, req, apierror.BadRequest("Invalid document type, expected receipt or invoice"))
+		return
+	}
+
+	payment, err := model.Payments.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	customer, err := model.Customers.FindByID(req.Context(), payment.CustomerId)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var document bytes.Buffer
+	if err := renderPaymentDocument(&document, kind, customer, payment); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	model.writeDocument(res, req, fmt.Sprintf("%s-%d.pdf", kind, payment.Id), &document)
+}
+
+func (model *DocumentsModel) GetCustomerStatement(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	from := req.URL.Query().Get("from")
+	to := req.URL.Query().Get("to")
+	if !isDate(from) || !isDate(to) {
+		apierror.Write(res, req, apierror.BadRequest("Invalid date format, expected YYYY-MM-DD"))
+		return
+	}
+
+	customer, err := model.Customers.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	customerPayments, err := model.Payments.FindByCustomerID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var statementPayments []payments.Payment
+	for _, p := range customerPayments {
+		if (from == "" || p.Date >= from) && (to == "" || p.Date <= to) {
+			statementPayments = append(statementPayments, p)
+		}
+	}
+	sort.SliceStable(statementPayments, func(i, j int) bool {
+		return statementPayments[i].Date < statementPayments[j].Date
+	})
+
+	var document bytes.Buffer
+	if err := renderStatement(&document, customer, statementPayments, from, to); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	model.writeDocument(res, req, fmt.Sprintf("statement-%d.pdf", customer.Id), &document)
+}
+
+func (model *DocumentsModel) writeDocument(res http.ResponseWriter, req *http.Request, filename string, document *bytes.Buffer) {
+	if model.Bucket != nil && req.URL.Query().Get("store") == "true" {
+		fileId, err := filestorage.Upload(req.Context(), model.Bucket, bytes.NewReader(document.Bytes()), filename)
+		if err != nil {
+			apierror.Write(res, req, err)
+			return
+		}
+		res.Header().Set("X-Document-Id", fileId.Hex())
+	}
+
+	res.Header().Set("Content-Type", "application/pdf")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	res.Header().Set("Content-Length", fmt.Sprintf("%d", document.Len()))
+	if _, err := document.WriteTo(res); err != nil {
+		logger.FromContext(req.Context()).Error("could not send document", "error", err)
+	}
+}
+
+func isDate(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse(time.DateOnly, value)
+	return err == nil
+}
@@ -0,0 +1,140 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/payments"
+)
+
+type MockPaymentsDB struct {
+	Payments []payments.Payment
+}
+
+func (m *MockPaymentsDB) FindByID(ctx context.Context, id int) (*payments.Payment, error) {
+	for _, payment := range m.Payments {
+		if payment.Id == id {
+			return &payment, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockPaymentsDB) FindByCustomerID(ctx context.Context, id int) ([]payments.Payment, error) {
+	var p []payments.Payment
+	for _, payment := range m.Payments {
+		if payment.CustomerId == id {
+			p = append(p, payment)
+		}
+	}
+	return p, nil
+}
+
+type MockCustomersDB struct {
+	Customers []customers.Customer
+}
+
+func (m *MockCustomersDB) FindByID(ctx context.Context, id int) (*customers.Customer, error) {
+	for _, customer := range m.Customers {
+		if customer.Id == id {
+			return &customer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func setupTestModel() *DocumentsModel {
+	return &DocumentsModel{
+		Payments: &MockPaymentsDB{Payments: []payments.Payment{
+			{Id: 1, Date: "2023-04-25", PaymentMethod: "cash", Amount: 4500, Comment: "Séance de coaching", CustomerId: 1},
+			{Id: 2, Date: "2023-05-02", PaymentMethod: "card", Amount: -1500, CustomerId: 1},
+			{Id: 3, Date: "2023-05-03", PaymentMethod: "card", Amount: 1500, CustomerId: 2},
+		}},
+		Customers: &MockCustomersDB{Customers: []customers.Customer{
+			{Id: 1, Name: "Zoé", Surname: "Durand", Email: "zoe@test.com", Address: "1 rue de Paris"},
+		}},
+	}
+}
+
+func checkPDF(t *testing.T, rr *httptest.ResponseRecorder) {
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/pdf" {
+		t.Errorf("Expected application/pdf, got %s", contentType)
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")) {
+		t.Errorf("Expected a PDF document")
+	}
+}
+
+func TestPaymentDocument(t *testing.T) {
+	model := setupTestModel()
+
+	tests := []struct {
+		name         string
+		url          string
+		paymentId    string
+		expectedCode int
+	}{
+		{"Receipt", "/api/payments/1/document", "1", http.StatusOK},
+		{"Invoice", "/api/payments/1/document?type=invoice", "1", http.StatusOK},
+		{"Credit Note", "/api/payments/2/document", "2", http.StatusOK},
+		{"Invalid Type", "/api/payments/1/document?type=quote", "1", http.StatusBadRequest},
+		{"Payment Not Found", "/api/payments/99/document", "99", http.StatusNotFound},
+		{"Customer Not Found", "/api/payments/3/document", "3", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req = mux.SetURLVars(req, map[string]string{"payment_id": tt.paymentId})
+			rr := httptest.NewRecorder()
+			model.GetPaymentDocument(rr, req)
+
+			if tt.expectedCode == http.StatusOK {
+				checkPDF(t, rr)
+			} else if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCustomerStatement(t *testing.T) {
+	model := setupTestModel()
+
+	tests := []struct {
+		name         string
+		url          string
+		customerId   string
+		expectedCode int
+	}{
+		{"Full Statement", "/api/customers/1/statement", "1", http.StatusOK},
+		{"Date Range", "/api/customers/1/statement?from=2023-05-01&to=2023-05-31", "1", http.StatusOK},
+		{"Empty Range", "/api/customers/1/statement?from=2024-01-01", "1", http.StatusOK},
+		{"Invalid Date", "/api/customers/1/statement?from=01-05-2023", "1", http.StatusBadRequest},
+		{"Customer Not Found", "/api/customers/99/statement", "99", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req = mux.SetURLVars(req, map[string]string{"customer_id": tt.customerId})
+			rr := httptest.NewRecorder()
+			model.GetCustomerStatement(rr, req)
+
+			if tt.expectedCode == http.StatusOK {
+				checkPDF(t, rr)
+			} else if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package documents
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/payments"
+)
+
+const (
+	companyName    = "Soul Connection"
+	companyAddress = "soul-connection.fr"
+	pageWidth      = 190.0
+)
+
+type document struct {
+	pdf *fpdf.Fpdf
+	tr  func(string) string
+}
+
+func newDocument(title string) *document {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(title, true)
+	pdf.SetAuthor(companyName, true)
+	pdf.SetCreationDate(time.Now())
+	pdf.AddPage()
+
+	return &document{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+func (d *document) header(title string, number string, date string) {
+	d.pdf.SetFont("Helvetica", "B", 18)
+	d.pdf.CellFormat(pageWidth/2, 10, d.tr(companyName), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(pageWidth/2, 10, d.tr(title), "", 1, "R", false, 0, "")
+
+	d.pdf.SetFont("Helvetica", "", 10)
+	d.pdf.CellFormat(pageWidth/2, 5, d.tr(companyAddress), "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(pageWidth/2, 5, d.tr(number), "", 1, "R", false, 0, "")
+	d.pdf.CellFormat(pageWidth/2, 5, "", "", 0, "L", false, 0, "")
+	d.pdf.CellFormat(pageWidth/2, 5, d.tr(date), "", 1, "R", false, 0, "")
+	d.pdf.Ln(10)
+}
+
+func (d *document) customer(c *customers.Customer) {
+	d.pdf.SetFont("Helvetica", "B", 11)
+	d.pdf.CellFormat(pageWidth, 6, d.tr("Billed to"), "", 1, "L", false, 0, "")
+	d.pdf.SetFont("Helvetica", "", 10)
+	for _, line := range []string{
+		fmt.Sprintf("%s %s", c.Name, c.Surname),
+		c.Address,
+		c.Email,
+		c.Phone_Number,
+		fmt.Sprintf("Customer #%d", c.Id),
+	} {
+		d.pdf.CellFormat(pageWidth, 5, d.tr(line), "", 1, "L", false, 0, "")
+	}
+	d.pdf.Ln(8)
+}
+
+func (d *document) lineItems(ps []payments.Payment) {
+	widths := []float64{30, 35, 95, 30}
+
+	d.pdf.SetFont("Helvetica", "B", 10)
+	d.pdf.SetFillColor(230, 230, 230)
+	for i, title := range []string{"Date", "Method", "Description", "Amount"} {
+		align := "L"
+		if i == len(widths)-1 {
+			align = "R"
+		}
+		d.pdf.CellFormat(widths[i], 7, d.tr(title), "1", 0, align, true, 0, "")
+	}
+	d.pdf.Ln(-1)
+
+	d.pdf.SetFont("Helvetica", "", 10)
+	for _, p := range ps {
+		d.pdf.CellFormat(widths[0], 7, d.tr(p.Date), "1", 0, "L", false, 0, "")
+		d.pdf.CellFormat(widths[1], 7, d.tr(p.PaymentMethod), "1", 0, "L", false, 0, "")
+		d.pdf.CellFormat(widths[2], 7, d.tr(description(p)), "1", 0, "L", false, 0, "")
+		d.pdf.CellFormat(widths[3], 7, p.Amount.String(), "1", 1, "R", false, 0, "")
+	}
+	d.pdf.Ln(4)
+}
+
+func (d *document) totals(lines [][2]string) {
+	for i, line := range lines {
+		style := ""
+		if i == len(lines)-1 {
+			style = "B"
+		}
+		d.pdf.SetFont("Helvetica", style, 10)
+		d.pdf.CellFormat(pageWidth-30, 6, d.tr(line[0]), "", 0, "R", false, 0, "")
+		d.pdf.CellFormat(30, 6, line[1], "", 1, "R", false, 0, "")
+	}
+}
+
+func (d *document) note(text string) {
+	d.pdf.Ln(10)
+	d.pdf.SetFont("Helvetica", "I", 9)
+	d.pdf.MultiCell(pageWidth, 5, d.tr(text), "", "L", false)
+}
+
+func (d *document) output(w io.Writer) error {
+	return d.pdf.Output(w)
+}
+
+func description(p payments.Payment) string {
+	if p.Comment != "" {
+		return p.Comment
+	}
+	if p.Amount < 0 {
+		return "Refund"
+	}
+	return "Coaching services"
+}
+
+func renderPaymentDocument(w io.Writer, kind string, c *customers.Customer, p *payments.Payment) error {
+	title := "Receipt"
+	prefix := "R"
+	if kind == invoiceDocument {
+		title = "Invoice"
+		prefix = "INV"
+	}
+	if p.Amount < 0 {
+		title = "Credit note"
+		prefix = "CN"
+	}
+
+	d := newDocument(fmt.Sprintf("%s %s-%06d", title, prefix, p.Id))
+	d.header(title, fmt.Sprintf("No. %s-%06d", prefix, p.Id), fmt.Sprintf("Date: %s", p.Date))
+	d.customer(c)
+	d.lineItems([]payments.Payment{*p})
+	d.totals([][2]string{{"Total", p.Amount.String()}})
+
+	switch {
+	case p.Amount < 0:
+		d.note(fmt.Sprintf("This amount was refunded by %s.", p.PaymentMethod))
+	case kind == invoiceDocument:
+		d.note("Thank you for your trust.")
+	default:
+		d.note(fmt.Sprintf("Paid in full by %s. Thank you for your trust.", p.PaymentMethod))
+	}
+	return d.output(w)
+}
+
+func renderStatement(w io.Writer, c *customers.Customer, ps []payments.Payment, from string, to string) error {
+	var gross, refunds payments.Amount
+	for _, p := range ps {
+		if p.Amount < 0 {
+			refunds -= p.Amount
+		} else {
+			gross += p.Amount
+		}
+	}
+
+	period := "All payments"
+	if from != "" || to != "" {
+		period = fmt.Sprintf("Period: %s - %s", orDefault(from, "start"), orDefault(to, time.Now().Format(time.DateOnly)))
+	}
+
+	d := newDocument(fmt.Sprintf("Statement for customer %d", c.Id))
+	d.header("Statement", fmt.Sprintf("Customer #%d", c.Id), period)
+	d.customer(c)
+	d.lineItems(ps)
+	d.totals([][2]string{
+		{"Payments", gross.String()},
+		{"Refunds", (-refunds).String()},
+		{"Total", (gross - refunds).String()},
+	})
+	if len(ps) == 0 {
+		d.note("No payments were recorded for this period.")
+	}
+	return d.output(w)
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -1,6 +1,7 @@
 package employees
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,49 +9,90 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/middleware"
+	"soul-connection.com/api/src/validation"
 )
 
 type Employee struct {
-	Id                 int
-	Soul_Connection_Id *int
-	Email              string
-	Password           string
-	Name               string
-	Surname            string
-	Birth_Date         string
-	Gender             string
-	Work               string
-	Image_Id           *string
-	CreatedAt          time.Time
+	Id                 int        `json:"id" db:"id"`
+	Soul_Connection_Id *int       `json:"soul_connection_id" db:"soul_connection_id"`
+	Email              string     `json:"email" db:"email"`
+	Password           string     `json:"-" db:"password" export:"-"`
+	Name               string     `json:"name" db:"name"`
+	Surname            string     `json:"surname" db:"surname"`
+	Birth_Date         string     `json:"birth_date" db:"birth_date"`
+	Gender             string     `json:"gender" db:"gender"`
+	Work               string     `json:"work" db:"work"`
+	Image_Id           *string    `json:"-" db:"image_id"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	Deleted_At         *time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// EmployeesRepository is the storage behind EmployeesModel.
+type EmployeesRepository interface {
+	FindAll(context.Context) ([]Employee, error)
+	StreamAll(context.Context, func(Employee) error) error
+	FindByID(context.Context, int) (*Employee, error)
+	FindByIDs(context.Context, []int) ([]Employee, error)
+	FindByOldID(context.Context, int) (*Employee, error)
+	Add(context.Context, *AddEmployee) (*Employee, error)
+	Delete(context.Context, int) error
+	Restore(context.Context, int) (*Employee, error)
+	IncludingDeleted() EmployeesRepository
+	Patch(context.Context, int, *UpdateEmployee) (*Employee, error)
+	UploadFile(context.Context, int, io.Reader, string) (*primitive.ObjectID, error)
+	GetFile(context.Context, primitive.ObjectID) ([]byte, error)
 }
 
 type EmployeesModel struct {
-	Employees interface {
-		FindAll() ([]Employee, error)
-		FindByID(int) (*Employee, error)
-		FindByOldID(int) (*Employee, error)
-		Add(*AddEmployee) (*Employee, error)
-		Delete(int) error
-		Patch(int, *UpdateEmployee) (*Employee, error)
-		UploadFile(int, io.Reader, string) (*primitive.ObjectID, error)
-		GetFile(primitive.ObjectID) ([]byte, error)
+	Employees EmployeesRepository
+	Changes   *changes.Bus
+	Audit     *audit.Log
+}
+
+// reads answers the repository the reads of req go through, with the
+// deleted employees when a manager asks for them.
+func (model *EmployeesModel) reads(req *http.Request) (EmployeesRepository, error) {
+	include, err := middleware.IncludeDeleted(req)
+	if err != nil || !include {
+		return model.Employees, err
 	}
+	return model.Employees.IncludingDeleted(), nil
 }
 
-func (model *EmployeesModel) GetAllEmployees(res http.ResponseWriter, _ *http.Request) {
-	employees, err := model.Employees.FindAll()
+func (model *EmployeesModel) GetAllEmployees(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	repo, err := model.reads(req)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "employees", func(fn func(EmployeeResponse) error) error {
+			return repo.StreamAll(req.Context(), func(item Employee) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	employees, err := repo.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(employees); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(employees, Employee.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -58,46 +100,50 @@ func (model *EmployeesModel) GetAllEmployees(res http.ResponseWriter, _ *http.Re
 func (model *EmployeesModel) GetEmployeeById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "employee_id")
 	if err != nil {
-		http.Error(res, "Invalid employee ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
+		return
+	}
+	repo, err := model.reads(req)
+	if err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 
-	employee, err := model.Employees.FindByID(id)
+	employee, err := repo.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Employee not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*employee); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(employee.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *EmployeesModel) AddEmployee(res http.ResponseWriter, req *http.Request) {
 	var nc AddEmployee
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	employee, err := model.Employees.Add(&nc)
+	var employee *Employee
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if employee, err = model.Employees.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Employees, audit.Created, employee.Id, nil, employee.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add employee", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), employee.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*employee); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(employee.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -105,21 +151,66 @@ func (model *EmployeesModel) AddEmployee(res http.ResponseWriter, req *http.Requ
 func (model *EmployeesModel) DeleteEmployee(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "employee_id")
 	if err != nil {
-		http.Error(res, "Invalid employee ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
 		return
 	}
 
-	err = model.Employees.Delete(id)
+	previous, err := model.Employees.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to delete employee", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
+	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Employees.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Employees, audit.Deleted, id, previous.Response(), nil)
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
 	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Employees, changes.Deleted, id, nil))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *EmployeesModel) RestoreEmployee(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "employee_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
+		return
+	}
+	if err := middleware.RequireManager(req, "Only managers can restore employees"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Employees.IncludingDeleted().FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	var employee *Employee
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if employee, err = model.Employees.Restore(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Employees, audit.Restored, id, previous.Response(), employee.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), employee.change(changes.Created))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(employee.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -127,30 +218,38 @@ func (model *EmployeesModel) DeleteEmployee(res http.ResponseWriter, req *http.R
 func (model *EmployeesModel) PatchEmployee(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "employee_id")
 	if err != nil {
-		http.Error(res, "Invalid employee ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
 		return
 	}
 
 	var updates UpdateEmployee
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Employees.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedEmployee, err := model.Employees.Patch(id, &updates)
+	var updatedEmployee *Employee
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedEmployee, err = model.Employees.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Employees, audit.Updated, id, previous.Response(), updatedEmployee.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to update employee", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), updatedEmployee.change(changes.Updated))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedEmployee); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(updatedEmployee.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -158,41 +257,36 @@ func (model *EmployeesModel) PatchEmployee(res http.ResponseWriter, req *http.Re
 func (model *EmployeesModel) GetImage(res http.ResponseWriter, req *http.Request) {
 	employeeId, err := lib.GetIdFromRequest(req, "employee_id")
 	if err != nil {
-		http.Error(res, "Invalid employee ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
 		return
 	}
 
-	employee, err := model.Employees.FindByID(employeeId)
+	employee, err := model.Employees.FindByID(req.Context(), employeeId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	if employee.Image_Id == nil {
-		http.Error(res, "Could not find image for customer", http.StatusNotFound)
+		apierror.Write(res, req, apierror.NotFound("Could not find image for customer"))
 		return
 	}
 
 	fileId, err := primitive.ObjectIDFromHex(*employee.Image_Id)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	fileContent, err := model.Employees.GetFile(fileId)
+	fileContent, err := model.Employees.GetFile(req.Context(), fileId)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	_, err = res.Write(fileContent)
 	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 	res.Header().Set("Content-Type", "application/octet-stream")
@@ -1,191 +1,171 @@
 package employees
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"io"
-	"reflect"
-	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"soul-connection.com/api/src/database"
 	filestorage "soul-connection.com/api/src/file-storage"
-	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
 type EmployeesDB struct {
 	DB     *sql.DB
 	Bucket *gridfs.Bucket
+	// WithDeleted makes the reads include the deleted employees
+	WithDeleted bool
 }
 
 type AddEmployee struct {
-	Soul_Connection_Id *int
-	Email              string
+	Soul_Connection_Id *int   `json:"soul_connection_id" db:"soul_connection_id" validate:"min=1"`
+	Email              string `json:"email" db:"email" validate:"required,email,max=255"`
 	// Password  string
-	Name       string
-	Surname    string
-	Birth_Date string
-	Gender     string
-	Work       string
+	Name       string `json:"name" db:"name" validate:"required,max=255"`
+	Surname    string `json:"surname" db:"surname" validate:"required,max=255"`
+	Birth_Date string `json:"birth_date" db:"birth_date" validate:"date"`
+	Gender     string `json:"gender" db:"gender" validate:"oneof=Male|Female|Other"`
+	Work       string `json:"work" db:"work" validate:"max=255"`
 }
 
 type UpdateEmployee struct {
-	Email *string
+	Email *string `json:"email" db:"email" validate:"required,email,max=255"`
 	// Password  string
-	Name       *string
-	Surname    *string
-	Birth_Date *string
-	Gender     *string
-	Work       *string
+	Name       *string `json:"name" db:"name" validate:"required,max=255"`
+	Surname    *string `json:"surname" db:"surname" validate:"required,max=255"`
+	Birth_Date *string `json:"birth_date" db:"birth_date" validate:"date"`
+	Gender     *string `json:"gender" db:"gender" validate:"oneof=Male|Female|Other"`
+	Work       *string `json:"work" db:"work" validate:"max=255"`
 }
 
-func (db EmployeesDB) FindAll() ([]Employee, error) {
-	rows, err := db.DB.Query("SELECT * FROM employee")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var employees []Employee
-
-	for rows.Next() {
-		var e Employee
-		err := rows.Scan(&e.Id, &e.Soul_Connection_Id, &e.Email, &e.Password, &e.Name, &e.Surname, &e.Birth_Date, &e.Gender, &e.Work, &e.Image_Id, &e.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		employees = append(employees, e)
-	}
-	err = rows.Err()
-
-	if err != nil {
-		return nil, err
-	}
-	return employees, nil
+// NOTE: Password is hard coded till we can retrieve it
+type insertEmployee struct {
+	*AddEmployee
+	Password string `db:"password"`
 }
 
-func (db EmployeesDB) FindByID(id int) (*Employee, error) {
-	query := "SELECT * FROM employee WHERE id = $1"
+const notDeleted = "deleted_at IS NULL"
 
-	row := db.DB.QueryRow(query, id)
-	var e Employee
+func repository(q database.Queryer) database.Repository[Employee] {
+	return database.Repository[Employee]{DB: q, Table: "employee", Scope: notDeleted}
+}
 
-	err := row.Scan(&e.Id, &e.Soul_Connection_Id, &e.Email, &e.Password, &e.Name, &e.Surname, &e.Birth_Date, &e.Gender, &e.Work, &e.Image_Id, &e.CreatedAt)
-	if err != nil {
-		return nil, err
+func (db EmployeesDB) employees() database.Repository[Employee] {
+	repo := repository(db.DB)
+	if db.WithDeleted {
+		repo.Scope = ""
 	}
-
-	return &e, nil
+	return repo
 }
 
-func (db EmployeesDB) FindByOldID(id int) (*Employee, error) {
-	query := "SELECT * FROM employee WHERE soul_connection_id = $1"
-
-	row := db.DB.QueryRow(query, id)
-	var e Employee
-
-	err := row.Scan(&e.Id, &e.Soul_Connection_Id, &e.Email, &e.Password, &e.Name, &e.Surname, &e.Birth_Date, &e.Gender, &e.Work, &e.Image_Id, &e.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
+func (db EmployeesDB) IncludingDeleted() EmployeesRepository {
+	db.WithDeleted = true
+	return db
+}
 
-	return &e, nil
+func (db EmployeesDB) FindAll(ctx context.Context) ([]Employee, error) {
+	return db.employees().FindAll(ctx)
 }
 
-func (db EmployeesDB) Add(employee *AddEmployee) (*Employee, error) {
-	query := `
-		INSERT INTO employee (soul_connection_id, email, password, name, surname, birth_date, gender, work)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING *
-    `
-	// NOTE: Password is hard coded till we can retrieve it
-	var e Employee
-	row := db.DB.QueryRow(query, employee.Soul_Connection_Id, employee.Email, "password", employee.Name, employee.Surname, employee.Birth_Date, employee.Gender, employee.Work)
-	err := row.Scan(&e.Id, &e.Soul_Connection_Id, &e.Email, &e.Password, &e.Name, &e.Surname, &e.Birth_Date, &e.Gender, &e.Work, &e.Image_Id, &e.CreatedAt)
+func (db EmployeesDB) StreamAll(ctx context.Context, fn func(Employee) error) error {
+	return db.employees().StreamAll(ctx, fn)
+}
 
-	if err != nil {
-		return nil, err
-	}
-	return &e, nil
+func (db EmployeesDB) FindByID(ctx context.Context, id int) (*Employee, error) {
+	return db.employees().FindByID(ctx, id)
 }
 
-func (db EmployeesDB) Delete(id int) error {
-	query := "DELETE FROM employee WHERE id = $1"
+func (db EmployeesDB) FindByIDs(ctx context.Context, ids []int) ([]Employee, error) {
+	return db.employees().FindIn(ctx, "id", ids)
+}
 
-	_, err := db.DB.Exec(query, id)
-	return err
+func (db EmployeesDB) FindByOldID(ctx context.Context, id int) (*Employee, error) {
+	return db.employees().FindOneBy(ctx, "soul_connection_id", id)
 }
 
-func (db EmployeesDB) Patch(id int, updates *UpdateEmployee) (*Employee, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
+func (db EmployeesDB) Add(ctx context.Context, employee *AddEmployee) (*Employee, error) {
+	return db.Insert(ctx, db.DB, employee)
+}
 
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
+func (db EmployeesDB) Insert(ctx context.Context, q database.Queryer, employee *AddEmployee) (*Employee, error) {
+	return repository(q).Add(ctx, insertEmployee{AddEmployee: employee, Password: "password"})
+}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
-		}
-	}
+func (db EmployeesDB) FindIDByEmail(ctx context.Context, q database.Queryer, email string) (int, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
 
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
-	}
+	var id int
+	err := q.QueryRowContext(ctx, "SELECT id FROM employee WHERE email = $1 AND "+notDeleted, email).Scan(&id)
+	return id, err
+}
 
-	query := fmt.Sprintf(
-		"UPDATE employee SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
+// MergeByEmail overwrites the employee sharing employee.Email with every value
+// that is set, empty strings and nil values keep what is already stored.
+func (db EmployeesDB) MergeByEmail(ctx context.Context, q database.Queryer, employee *AddEmployee) (*Employee, error) {
+	repo := repository(q)
+	query := `
+		UPDATE employee SET
+			soul_connection_id = COALESCE($1, soul_connection_id),
+			name = COALESCE(NULLIF($2, ''), name),
+			surname = COALESCE(NULLIF($3, ''), surname),
+			birth_date = COALESCE(NULLIF($4, ''), birth_date),
+			gender = COALESCE(NULLIF($5, ''), gender),
+			work = COALESCE(NULLIF($6, ''), work)
+		WHERE email = $7 AND deleted_at IS NULL
+    ` + repo.Returning()
+	return repo.QueryRow(ctx, query, employee.Soul_Connection_Id, employee.Name, employee.Surname, employee.Birth_Date, employee.Gender, employee.Work, employee.Email)
+}
 
-	args = append(args, id)
+// Delete marks the employee as deleted, their customers keep them as coach
+// until reassigned.
+func (db EmployeesDB) Delete(ctx context.Context, id int) error {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	query := "UPDATE employee SET deleted_at = $1 WHERE id = $2 AND " + notDeleted
+	return database.RequireRows(database.Conn(ctx, db.DB).ExecContext(ctx, query, time.Now().UTC(), id))
+}
 
-	row := db.DB.QueryRow(query, args...)
-	var e Employee
-	err := row.Scan(&e.Id, &e.Soul_Connection_Id, &e.Email, &e.Password, &e.Name, &e.Surname, &e.Birth_Date, &e.Gender, &e.Work, &e.Image_Id, &e.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
+func (db EmployeesDB) Restore(ctx context.Context, id int) (*Employee, error) {
+	repo := repository(db.DB)
+	return repo.QueryRow(ctx, "UPDATE employee SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL "+repo.Returning(), id)
+}
 
-	return &e, nil
+func (db EmployeesDB) Patch(ctx context.Context, id int, updates *UpdateEmployee) (*Employee, error) {
+	return repository(db.DB).Patch(ctx, id, updates)
 }
 
-func (db EmployeesDB) UploadFile(employeeId int, r io.Reader, filename string) (*primitive.ObjectID, error) {
-	fileId, err := filestorage.Upload(db.Bucket, r, filename)
+func (db EmployeesDB) UploadFile(ctx context.Context, employeeId int, r io.Reader, filename string) (*primitive.ObjectID, error) {
+	fileId, err := filestorage.Upload(ctx, db.Bucket, r, filename)
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := db.DB.Begin()
+	tx, err := database.Begin(ctx, db.DB)
 	if err != nil {
 		return nil, err
 	}
 	query := `
     UPDATE employee SET image_id = $1 WHERE id = $2
     `
-	_, err = tx.Exec(query, fileId.Hex(), employeeId)
+	_, err = tx.ExecContext(ctx, query, fileId.Hex(), employeeId)
 	if err != nil {
-		filestorage.Delete(db.Bucket, *fileId)
+		filestorage.Delete(context.WithoutCancel(ctx), db.Bucket, *fileId)
 		tx.Rollback()
-		lib.ServerLog("ERROR", fmt.Errorf("Failed to update employee image_id: %v", err))
+		logger.FromContext(ctx).Error("Failed to update employee image_id", "error", err)
 		return nil, err
 	}
 	if err := tx.Commit(); err != nil {
-		filestorage.Delete(db.Bucket, *fileId)
-		lib.ServerLog("ERROR", "Could not commit employee")
+		filestorage.Delete(context.WithoutCancel(ctx), db.Bucket, *fileId)
+		logger.FromContext(ctx).Error("Could not commit employee", "error", err)
 		return nil, err
 	}
 	return fileId, nil
 }
 
-func (db EmployeesDB) GetFile(fileId primitive.ObjectID) ([]byte, error) {
-	return filestorage.DownloadById(db.Bucket, fileId)
+func (db EmployeesDB) GetFile(ctx context.Context, fileId primitive.ObjectID) ([]byte, error) {
+	return filestorage.DownloadById(ctx, db.Bucket, fileId)
 }
@@ -0,0 +1,43 @@
+package employees
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// EmployeeResponse is the JSON of an Employee, neither its password nor the
+// id of its image ever leave the server.
+type EmployeeResponse struct {
+	Id                 int        `json:"id"`
+	Soul_Connection_Id *int       `json:"soul_connection_id"`
+	Email              string     `json:"email"`
+	Name               string     `json:"name"`
+	Surname            string     `json:"surname"`
+	Birth_Date         string     `json:"birth_date"`
+	Gender             string     `json:"gender"`
+	Work               string     `json:"work"`
+	Has_Image          bool       `json:"has_image"`
+	CreatedAt          time.Time  `json:"created_at"`
+	Deleted_At         *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (e Employee) Response() EmployeeResponse {
+	return EmployeeResponse{
+		Id:                 e.Id,
+		Soul_Connection_Id: e.Soul_Connection_Id,
+		Email:              e.Email,
+		Name:               e.Name,
+		Surname:            e.Surname,
+		Birth_Date:         e.Birth_Date,
+		Gender:             e.Gender,
+		Work:               e.Work,
+		Has_Image:          e.Image_Id != nil,
+		CreatedAt:          e.CreatedAt,
+		Deleted_At:         e.Deleted_At,
+	}
+}
+
+func (e Employee) change(action changes.Action) changes.Change {
+	return changes.New(changes.Employees, action, e.Id, e.Response())
+}
@@ -1,47 +1,72 @@
 package encounters
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/validation"
 )
 
 type Encounter struct {
-	Id          int
-	Date        string
-	Rating      int
-	Comment     string
-	Source      string
-	CreatedAt   time.Time
-	Customer_Id int
+	Id          int       `json:"id" db:"id"`
+	Date        string    `json:"date" db:"date"`
+	Rating      int       `json:"rating" db:"rating"`
+	Comment     string    `json:"comment" db:"comment"`
+	Source      string    `json:"source" db:"source"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Customer_Id int       `json:"customer_id" db:"customer_id"`
+}
+
+// EncountersRepository is the storage behind EncounterModel.
+type EncountersRepository interface {
+	FindAll(context.Context) ([]Encounter, error)
+	StreamAll(context.Context, func(Encounter) error) error
+	FindByID(context.Context, int) (*Encounter, error)
+	FindByCustomerID(context.Context, int) ([]Encounter, error)
+	FindByCustomerIDs(context.Context, []int) ([]Encounter, error)
+	StreamByCustomerID(context.Context, int, func(Encounter) error) error
+	Add(context.Context, *AddEncounter) (*Encounter, error)
+	Delete(context.Context, int) error
+	Patch(context.Context, int, *UpdateEncounter) (*Encounter, error)
 }
 
 type EncounterModel struct {
-	Encounters interface {
-		FindAll() ([]Encounter, error)
-		FindByID(int) (*Encounter, error)
-		FindByCustomerID(int) ([]Encounter, error)
-		Add(*AddEncounter) (*Encounter, error)
-		Delete(int) error
-		Patch(int, *UpdateEncounter) (*Encounter, error)
-	}
+	Encounters EncountersRepository
+	Changes    *changes.Bus
+	Audit      *audit.Log
 }
 
-func (model *EncounterModel) GetAllEncounters(res http.ResponseWriter, _ *http.Request) {
-	encounters, err := model.Encounters.FindAll()
+func (model *EncounterModel) GetAllEncounters(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "encounters", func(fn func(EncounterResponse) error) error {
+			return model.Encounters.StreamAll(req.Context(), func(item Encounter) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	encounters, err := model.Encounters.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(encounters); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(encounters, Encounter.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -49,22 +74,19 @@ func (model *EncounterModel) GetAllEncounters(res http.ResponseWriter, _ *http.R
 func (model *EncounterModel) GetEncounterById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "encounter_id")
 	if err != nil {
-		http.Error(res, "Invalid encounter ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid encounter ID format"))
 		return
 	}
 
-	encounter, err := model.Encounters.FindByID(id)
+	encounter, err := model.Encounters.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Encounter not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*encounter); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(encounter.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -72,46 +94,57 @@ func (model *EncounterModel) GetEncounterById(res http.ResponseWriter, req *http
 func (model *EncounterModel) GetEncounterByCustomerId(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, fmt.Sprintf("customer_%d_encounters", id), func(fn func(EncounterResponse) error) error {
+			return model.Encounters.StreamByCustomerID(req.Context(), id, func(item Encounter) error { return fn(item.Response()) })
+		})
 		return
 	}
 
-	encounters, err := model.Encounters.FindByCustomerID(id)
+	encounters, err := model.Encounters.FindByCustomerID(req.Context(), id)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(encounters); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(encounters, Encounter.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *EncounterModel) AddEncounter(res http.ResponseWriter, req *http.Request) {
 	var nc AddEncounter
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	encounter, err := model.Encounters.Add(&nc)
+	var encounter *Encounter
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if encounter, err = model.Encounters.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Encounters, audit.Created, encounter.Id, nil, encounter.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add encounter", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), encounter.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*encounter); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(encounter.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -119,21 +152,30 @@ func (model *EncounterModel) AddEncounter(res http.ResponseWriter, req *http.Req
 func (model *EncounterModel) DeleteEncounter(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "encounter_id")
 	if err != nil {
-		http.Error(res, "Invalid encounter ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid encounter ID format"))
 		return
 	}
 
-	err = model.Encounters.Delete(id)
+	encounter, err := model.Encounters.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Encounters.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Encounters, audit.Deleted, id, encounter.Response(), nil)
+	})
 	if err != nil {
-		http.Error(res, "Unable to delete encounter", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
 	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Encounters, changes.Deleted, id, nil).ForCustomer(encounter.Customer_Id))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -141,30 +183,38 @@ func (model *EncounterModel) DeleteEncounter(res http.ResponseWriter, req *http.
 func (model *EncounterModel) PatchEncounter(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "encounter_id")
 	if err != nil {
-		http.Error(res, "Invalid encounter ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid encounter ID format"))
 		return
 	}
 
 	var updates UpdateEncounter
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Encounters.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedEncounter, err := model.Encounters.Patch(id, &updates)
+	var updatedEncounter *Encounter
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedEncounter, err = model.Encounters.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Encounters, audit.Updated, id, previous.Response(), updatedEncounter.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to update encounter", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), updatedEncounter.change(changes.Updated))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedEncounter); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(updatedEncounter.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
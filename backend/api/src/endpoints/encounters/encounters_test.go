@@ -2,13 +2,18 @@ package encounters
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
+
+	"soul-connection.com/api/src/changes"
 )
 
 type MockEncountersDB struct {
@@ -16,23 +21,23 @@ type MockEncountersDB struct {
 	Err        error
 }
 
-func (m *MockEncountersDB) FindAll() ([]Encounter, error) {
+func (m *MockEncountersDB) FindAll(ctx context.Context) ([]Encounter, error) {
 	if m.Encounters == nil {
 		return nil, errors.New("no encounters found")
 	}
 	return m.Encounters, nil
 }
 
-func (m *MockEncountersDB) FindByID(id int) (*Encounter, error) {
+func (m *MockEncountersDB) FindByID(ctx context.Context, id int) (*Encounter, error) {
 	for _, encounter := range m.Encounters {
 		if encounter.Id == id {
 			return &encounter, nil
 		}
 	}
-	return nil, errors.New("encounter not found")
+	return nil, sql.ErrNoRows
 }
 
-func (m *MockEncountersDB) FindByCustomerID(id int) ([]Encounter, error) {
+func (m *MockEncountersDB) FindByCustomerID(ctx context.Context, id int) ([]Encounter, error) {
 	var e []Encounter
 	for _, encounter := range m.Encounters {
 		if encounter.Customer_Id == id {
@@ -42,7 +47,16 @@ func (m *MockEncountersDB) FindByCustomerID(id int) ([]Encounter, error) {
 	return e, nil
 }
 
-func (m *MockEncountersDB) Add(encounter *AddEncounter) (*Encounter, error) {
+func (m *MockEncountersDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]Encounter, error) {
+	var e []Encounter
+	for _, id := range ids {
+		found, _ := m.FindByCustomerID(ctx, id)
+		e = append(e, found...)
+	}
+	return e, nil
+}
+
+func (m *MockEncountersDB) Add(ctx context.Context, encounter *AddEncounter) (*Encounter, error) {
 	newEncounter := Encounter{
 		Id:          len(m.Encounters) + 1,
 		Date:        encounter.Date,
@@ -58,17 +72,17 @@ func (m *MockEncountersDB) Add(encounter *AddEncounter) (*Encounter, error) {
 	return &newEncounter, nil
 }
 
-func (m *MockEncountersDB) Delete(id int) error {
+func (m *MockEncountersDB) Delete(ctx context.Context, id int) error {
 	for i, encounter := range m.Encounters {
 		if encounter.Id == id {
 			m.Encounters = append(m.Encounters[:i], m.Encounters[i+1:]...)
 			return nil
 		}
 	}
-	return errors.New("encounter not found")
+	return sql.ErrNoRows
 }
 
-func (m *MockEncountersDB) Patch(id int, updates *UpdateEncounter) (*Encounter, error) {
+func (m *MockEncountersDB) Patch(ctx context.Context, id int, updates *UpdateEncounter) (*Encounter, error) {
 	for i, encounter := range m.Encounters {
 		if encounter.Id == id {
 			if updates.Comment != nil {
@@ -80,7 +94,33 @@ func (m *MockEncountersDB) Patch(id int, updates *UpdateEncounter) (*Encounter,
 			return &m.Encounters[i], nil
 		}
 	}
-	return nil, errors.New("encounter not found")
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEncountersDB) StreamAll(ctx context.Context, fn func(Encounter) error) error {
+	all, err := m.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range all {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockEncountersDB) StreamByCustomerID(ctx context.Context, id int, fn func(Encounter) error) error {
+	found, err := m.FindByCustomerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, v := range found {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func setupTestModel(mockDB *MockEncountersDB) *EncounterModel {
@@ -119,13 +159,13 @@ func testAddEncounter(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/encounters", bytes.NewBuffer([]byte(`{invalid json}`)))
 		rr := httptest.NewRecorder()
 		model.AddEncounter(rr, req)
-		checkResponseCode(t, rr, http.StatusInternalServerError)
+		checkResponseCode(t, rr, http.StatusBadRequest)
 	})
 
 	t.Run("Error Add Method", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{Err: errors.New("database error")})
 		req := createRequest(t, http.MethodPost, "/api/encounters", &AddEncounter{
-			Date:        "25-04-2023",
+			Date:        "2023-04-25",
 			Rating:      5,
 			Comment:     "Great encounter",
 			Source:      "Referral",
@@ -133,12 +173,35 @@ func testAddEncounter(t *testing.T) {
 		})
 		rr := httptest.NewRecorder()
 		model.AddEncounter(rr, req)
+		checkResponseCode(t, rr, http.StatusInternalServerError)
+	})
+
+	t.Run("Invalid Encounter", func(t *testing.T) {
+		req := createRequest(t, http.MethodPost, "/api/encounters", &AddEncounter{
+			Date:        "2023-04-25",
+			Rating:      42,
+			Comment:     "Great encounter",
+			Source:      "Carrier pigeon",
+			Customer_Id: 1,
+		})
+		rr := httptest.NewRecorder()
+		model.AddEncounter(rr, req)
 		checkResponseCode(t, rr, http.StatusBadRequest)
+
+		var body struct {
+			Error struct {
+				Details []struct{ Field, Message string }
+			}
+		}
+		decodeResponseBody(t, rr, &body)
+		if len(body.Error.Details) != 2 || body.Error.Details[0].Field != "rating" || body.Error.Details[1].Field != "source" {
+			t.Errorf("Expected rating and source errors, got %+v", body.Error.Details)
+		}
 	})
 
 	t.Run("Add Encounter", func(t *testing.T) {
 		req := createRequest(t, http.MethodPost, "/api/encounters", &AddEncounter{
-			Date:        "25-04-2023",
+			Date:        "2023-04-25",
 			Rating:      5,
 			Comment:     "Great encounter",
 			Source:      "Referral",
@@ -155,13 +218,41 @@ func testAddEncounter(t *testing.T) {
 			t.Errorf("Expected comment 'Great encounter', got %s", addedEncounter.Comment)
 		}
 	})
+	t.Run("Publishes Change", func(t *testing.T) {
+		model := setupTestModel(&MockEncountersDB{})
+		model.Changes = changes.NewBus()
+		received, unsubscribe := model.Changes.Subscribe()
+		defer unsubscribe()
+
+		req := createRequest(t, http.MethodPost, "/api/encounters", &AddEncounter{
+			Date:        "2023-04-25",
+			Rating:      5,
+			Comment:     "Great encounter",
+			Source:      "Referral",
+			Customer_Id: 7,
+		})
+		rr := httptest.NewRecorder()
+		model.AddEncounter(rr, req)
+		checkResponseCode(t, rr, http.StatusOK)
+
+		change := <-received
+		if change.Entity != changes.Encounters || change.Action != changes.Created || change.Id != 1 {
+			t.Errorf("Expected encounter 1 created, got %s %s %d", change.Entity, change.Action, change.Id)
+		}
+		if change.CustomerId == nil || *change.CustomerId != 7 {
+			t.Errorf("Expected the change of customer 7, got %v", change.CustomerId)
+		}
+		if !strings.Contains(string(change.Data), `"rating":5`) || strings.Contains(string(change.Data), `"comment"`) {
+			t.Errorf("Expected the encounter without its comment as data, got %s", change.Data)
+		}
+	})
 }
 
 func testGetAllEncounters(t *testing.T) {
 	t.Run("Get All Encounters", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
-		model.Encounters.Add(&AddEncounter{Date: "25-04-2023", Rating: 5, Comment: "Encounter 1", Source: "Referral", Customer_Id: 1})
-		model.Encounters.Add(&AddEncounter{Date: "26-04-2023", Rating: 4, Comment: "Encounter 2", Source: "Website", Customer_Id: 2})
+		model.Encounters.Add(context.Background(), &AddEncounter{Date: "2023-04-25", Rating: 5, Comment: "Encounter 1", Source: "Referral", Customer_Id: 1})
+		model.Encounters.Add(context.Background(), &AddEncounter{Date: "2023-04-26", Rating: 4, Comment: "Encounter 2", Source: "Website", Customer_Id: 2})
 
 		req := createRequest(t, http.MethodGet, "/api/encounters", nil)
 		rr := httptest.NewRecorder()
@@ -190,8 +281,8 @@ func testGetAllEncounters(t *testing.T) {
 func testGetEncounterById(t *testing.T) {
 	t.Run("Get Encounter by ID", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
-		model.Encounters.Add(&AddEncounter{
-			Date:        "25-04-2023",
+		model.Encounters.Add(context.Background(), &AddEncounter{
+			Date:        "2023-04-25",
 			Rating:      5,
 			Comment:     "Encounter 3",
 			Source:      "Referral",
@@ -237,7 +328,7 @@ func testGetEncounterById(t *testing.T) {
 func testGetEncounterByCustomerId(t *testing.T) {
 	t.Run("Get Encounter by customer ID", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
-		model.Encounters.Add(&AddEncounter{
+		model.Encounters.Add(context.Background(), &AddEncounter{
 			Date:        "20-04-2023",
 			Rating:      5,
 			Comment:     "Encounter 10",
@@ -284,8 +375,8 @@ func testGetEncounterByCustomerId(t *testing.T) {
 func testDeleteEncounter(t *testing.T) {
 	t.Run("Delete Encounter", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
-		model.Encounters.Add(&AddEncounter{
-			Date:        "26-04-2023",
+		model.Encounters.Add(context.Background(), &AddEncounter{
+			Date:        "2023-04-26",
 			Rating:      5,
 			Comment:     "Encounter 4",
 			Source:      "Referral",
@@ -305,6 +396,24 @@ func testDeleteEncounter(t *testing.T) {
 		}
 	})
 
+	t.Run("Publishes Deletion", func(t *testing.T) {
+		model := setupTestModel(&MockEncountersDB{Encounters: []Encounter{{Id: 1, Customer_Id: 4}}})
+		model.Changes = changes.NewBus()
+		received, unsubscribe := model.Changes.Subscribe()
+		defer unsubscribe()
+
+		req := createRequest(t, http.MethodDelete, "/api/encounters/1", nil)
+		req = mux.SetURLVars(req, map[string]string{"encounter_id": "1"})
+		rr := httptest.NewRecorder()
+		model.DeleteEncounter(rr, req)
+		checkResponseCode(t, rr, http.StatusOK)
+
+		change := <-received
+		if change.Action != changes.Deleted || change.Data != nil || change.CustomerId == nil || *change.CustomerId != 4 {
+			t.Errorf("Expected the deletion of an encounter of customer 4 without data, got %+v", change)
+		}
+	})
+
 	t.Run("Encounter Not Found for Deletion", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
 		req := createRequest(t, http.MethodDelete, "/api/encounters/99", nil)
@@ -313,7 +422,7 @@ func testDeleteEncounter(t *testing.T) {
 
 		model.DeleteEncounter(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Deleting Encounter", func(t *testing.T) {
@@ -324,7 +433,7 @@ func testDeleteEncounter(t *testing.T) {
 
 		model.DeleteEncounter(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
@@ -332,7 +441,7 @@ func testPatchEncounters(t *testing.T) {
 	newComment := "Updated Encounter"
 	t.Run("Patch Event", func(t *testing.T) {
 		model := setupTestModel(&MockEncountersDB{})
-		model.Encounters.Add(&AddEncounter{
+		model.Encounters.Add(context.Background(), &AddEncounter{
 			Date:        "27-04-2023",
 			Rating:      5,
 			Comment:     "Encounter 5",
@@ -364,7 +473,7 @@ func testPatchEncounters(t *testing.T) {
 
 		model.PatchEncounter(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Patching Encounter", func(t *testing.T) {
@@ -375,7 +484,7 @@ func testPatchEncounters(t *testing.T) {
 
 		model.PatchEncounter(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
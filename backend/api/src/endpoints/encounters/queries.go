@@ -1,10 +1,10 @@
 package encounters
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"reflect"
-	"strings"
+
+	"soul-connection.com/api/src/database"
 )
 
 type EncountersDB struct {
@@ -12,147 +12,56 @@ type EncountersDB struct {
 }
 
 type AddEncounter struct {
-	Date        string
-	Rating      int
-	Comment     string
-	Source      string
-	Customer_Id int
+	Date        string `json:"date" db:"date" validate:"required,date"`
+	Rating      int    `json:"rating" db:"rating" validate:"required,min=1,max=5"`
+	Comment     string `json:"comment" db:"comment" validate:"max=255"`
+	Source      string `json:"source" db:"source" validate:"required,oneof=Dating App|Social Media|Friends|Referral|Website|Event|Other"`
+	Customer_Id int    `json:"customer_id" db:"customer_id" validate:"required,min=1"`
 }
 
 type UpdateEncounter struct {
-	Date    *string
-	Rating  *int
-	Comment *string
-	Source  *string
+	Date    *string `json:"date" db:"date" validate:"required,date"`
+	Rating  *int    `json:"rating" db:"rating" validate:"required,min=1,max=5"`
+	Comment *string `json:"comment" db:"comment" validate:"max=255"`
+	Source  *string `json:"source" db:"source" validate:"required,oneof=Dating App|Social Media|Friends|Referral|Website|Event|Other"`
 }
 
-func (db EncountersDB) FindAll() ([]Encounter, error) {
-	rows, err := db.DB.Query("SELECT * FROM encounter")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var encounter []Encounter
-
-	for rows.Next() {
-		var e Encounter
-		err := rows.Scan(&e.Id, &e.Date, &e.Rating, &e.Comment, &e.Source, &e.CreatedAt, &e.Customer_Id)
-		if err != nil {
-			return nil, err
-		}
-		encounter = append(encounter, e)
-	}
-	err = rows.Err()
-
-	if err != nil {
-		return nil, err
-	}
-	return encounter, nil
+func repository(q database.Queryer) database.Repository[Encounter] {
+	return database.Repository[Encounter]{DB: q, Table: "encounter"}
 }
 
-func (db EncountersDB) FindByID(id int) (*Encounter, error) {
-	query := "SELECT * FROM encounter WHERE id = $1"
-
-	row := db.DB.QueryRow(query, id)
-	var e Encounter
-
-	err := row.Scan(&e.Id, &e.Date, &e.Rating, &e.Comment, &e.Source, &e.CreatedAt, &e.Customer_Id)
-	if err != nil {
-		return nil, err
-	}
-
-	return &e, nil
+func (db EncountersDB) FindAll(ctx context.Context) ([]Encounter, error) {
+	return repository(db.DB).FindAll(ctx)
 }
 
-func (db EncountersDB) FindByCustomerID(id int) ([]Encounter, error) {
-	query := "SELECT * FROM encounter WHERE customer_id = $1"
-
-	rows, err := db.DB.Query(query, id)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var encounter []Encounter
-
-	for rows.Next() {
-		var e Encounter
-		err := rows.Scan(&e.Id, &e.Date, &e.Rating, &e.Comment, &e.Source, &e.CreatedAt, &e.Customer_Id)
-		if err != nil {
-			return nil, err
-		}
-		encounter = append(encounter, e)
-	}
-	err = rows.Err()
-	if err != nil {
-		return nil, err
-	}
-
-	return encounter, nil
+func (db EncountersDB) StreamAll(ctx context.Context, fn func(Encounter) error) error {
+	return repository(db.DB).StreamAll(ctx, fn)
 }
 
-func (db EncountersDB) Add(payment *AddEncounter) (*Encounter, error) {
-	query := `
-		INSERT INTO encounter (date, rating, comment, source, customer_id)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING *
-    `
-	row := db.DB.QueryRow(query, payment.Date, payment.Rating, payment.Comment, payment.Source, payment.Customer_Id)
-	var e Encounter
-
-	err := row.Scan(&e.Id, &e.Date, &e.Rating, &e.Comment, &e.Source, &e.CreatedAt, &e.Customer_Id)
-
-	if err != nil {
-		return nil, err
-	}
-	return &e, nil
+func (db EncountersDB) FindByID(ctx context.Context, id int) (*Encounter, error) {
+	return repository(db.DB).FindByID(ctx, id)
 }
 
-func (db EncountersDB) Delete(id int) error {
-	query := "DELETE FROM encounter WHERE id = $1"
-
-	_, err := db.DB.Exec(query, id)
-	return err
+func (db EncountersDB) FindByCustomerID(ctx context.Context, id int) ([]Encounter, error) {
+	return repository(db.DB).FindBy(ctx, "customer_id", id)
 }
 
-func (db EncountersDB) Patch(id int, updates *UpdateEncounter) (*Encounter, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
-
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
-		}
-	}
-
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
-	}
+func (db EncountersDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]Encounter, error) {
+	return repository(db.DB).FindIn(ctx, "customer_id", ids)
+}
 
-	query := fmt.Sprintf(
-		"UPDATE encounter SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
+func (db EncountersDB) StreamByCustomerID(ctx context.Context, id int, fn func(Encounter) error) error {
+	return repository(db.DB).StreamBy(ctx, "customer_id", id, fn)
+}
 
-	args = append(args, id)
+func (db EncountersDB) Add(ctx context.Context, encounter *AddEncounter) (*Encounter, error) {
+	return repository(db.DB).Add(ctx, encounter)
+}
 
-	row := db.DB.QueryRow(query, args...)
-	var e Encounter
-	err := row.Scan(&e.Id, &e.Date, &e.Rating, &e.Comment, &e.Source, &e.CreatedAt, &e.Customer_Id)
-	if err != nil {
-		return nil, err
-	}
+func (db EncountersDB) Delete(ctx context.Context, id int) error {
+	return repository(db.DB).Delete(ctx, id)
+}
 
-	return &e, nil
+func (db EncountersDB) Patch(ctx context.Context, id int, updates *UpdateEncounter) (*Encounter, error) {
+	return repository(db.DB).Patch(ctx, id, updates)
 }
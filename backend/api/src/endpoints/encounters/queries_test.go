@@ -1,6 +1,7 @@
 package encounters
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
 	"testing"
@@ -21,6 +22,7 @@ func setupTestDB() (*sql.DB, error) {
 		rating INTEGER NOT NULL,
 		comment TEXT NOT NULL,
 		source TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		customer_id INTEGER,
 		UNIQUE (date, comment, source, customer_id)
 	);
@@ -60,7 +62,7 @@ func TestEncounterQueries(t *testing.T) {
 			Customer_Id: customer_ID,
 		}
 
-		addedEncounter, err := encountersDB.Add(newEncounter)
+		addedEncounter, err := encountersDB.Add(context.Background(), newEncounter)
 		if err != nil {
 			t.Errorf("Failed to add encounter: %v", err)
 			return
@@ -72,7 +74,7 @@ func TestEncounterQueries(t *testing.T) {
 	})
 
 	t.Run("Find All Encounters", func(t *testing.T) {
-		_, err := encountersDB.Add(&AddEncounter{
+		_, err := encountersDB.Add(context.Background(), &AddEncounter{
 			Date:        "26-04-2023",
 			Rating:      4,
 			Comment:     "Good encounter",
@@ -83,7 +85,7 @@ func TestEncounterQueries(t *testing.T) {
 			t.Fatalf("Failed to add encounter: %v", err)
 		}
 
-		encounters, err := encountersDB.FindAll()
+		encounters, err := encountersDB.FindAll(context.Background())
 		if err != nil {
 			t.Errorf("Failed to find all encounters: %v", err)
 			return
@@ -95,7 +97,7 @@ func TestEncounterQueries(t *testing.T) {
 	})
 
 	t.Run("Find Encounter by ID", func(t *testing.T) {
-		_, err := encountersDB.Add(&AddEncounter{
+		_, err := encountersDB.Add(context.Background(), &AddEncounter{
 			Date:        "27-04-2023",
 			Rating:      3,
 			Comment:     "Average encounter",
@@ -106,7 +108,7 @@ func TestEncounterQueries(t *testing.T) {
 			t.Fatalf("Failed to add encounter: %v", err)
 		}
 
-		encounter, err := encountersDB.FindByID(1)
+		encounter, err := encountersDB.FindByID(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to find encounter by ID: %v", err)
 			return
@@ -118,7 +120,7 @@ func TestEncounterQueries(t *testing.T) {
 	})
 
 	t.Run("Patch Encounter", func(t *testing.T) {
-		_, err := encountersDB.Add(&AddEncounter{
+		_, err := encountersDB.Add(context.Background(), &AddEncounter{
 			Date:        "28-04-2023",
 			Rating:      2,
 			Comment:     "Below average encounter",
@@ -132,7 +134,7 @@ func TestEncounterQueries(t *testing.T) {
 		newComment := "Updated comment"
 		updates := &UpdateEncounter{Comment: &newComment}
 
-		updatedEncounter, err := encountersDB.Patch(1, updates)
+		updatedEncounter, err := encountersDB.Patch(context.Background(), 1, updates)
 		if err != nil {
 			t.Errorf("Failed to update encounter: %v", err)
 			return
@@ -144,7 +146,7 @@ func TestEncounterQueries(t *testing.T) {
 	})
 
 	t.Run("Delete Encounter", func(t *testing.T) {
-		_, err := encountersDB.Add(&AddEncounter{
+		_, err := encountersDB.Add(context.Background(), &AddEncounter{
 			Date:        "29-04-2023",
 			Rating:      1,
 			Comment:     "Bad encounter",
@@ -155,13 +157,13 @@ func TestEncounterQueries(t *testing.T) {
 			t.Fatalf("Failed to add encounter: %v", err)
 		}
 
-		err = encountersDB.Delete(1)
+		err = encountersDB.Delete(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to delete encounter: %v", err)
 			return
 		}
 
-		_, err = encountersDB.FindByID(1)
+		_, err = encountersDB.FindByID(context.Background(), 1)
 		if err == nil {
 			t.Errorf("Expected error when finding deleted encounter, got nil")
 		}
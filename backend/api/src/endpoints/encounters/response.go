@@ -0,0 +1,40 @@
+package encounters
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// EncounterResponse is the JSON of an Encounter.
+type EncounterResponse struct {
+	Id          int       `json:"id"`
+	Date        string    `json:"date"`
+	Rating      int       `json:"rating"`
+	Comment     string    `json:"comment"`
+	Source      string    `json:"source"`
+	CreatedAt   time.Time `json:"created_at"`
+	Customer_Id int       `json:"customer_id"`
+}
+
+func (e Encounter) Response() EncounterResponse {
+	return EncounterResponse{
+		Id:          e.Id,
+		Date:        e.Date,
+		Rating:      e.Rating,
+		Comment:     e.Comment,
+		Source:      e.Source,
+		CreatedAt:   e.CreatedAt,
+		Customer_Id: e.Customer_Id,
+	}
+}
+
+// PersonalFields are left out of the audit log, the comment may tell who
+// the customer is.
+func (EncounterResponse) PersonalFields() []string {
+	return []string{"comment"}
+}
+
+func (e Encounter) change(action changes.Action) changes.Change {
+	return changes.New(changes.Encounters, action, e.Id, e.Response()).ForCustomer(e.Customer_Id)
+}
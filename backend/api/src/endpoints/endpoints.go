@@ -1,55 +1,190 @@
 package endpoints
 
 import (
+	"cmp"
 	"database/sql"
 	"net/http"
-	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/rs/cors"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/config"
 	"soul-connection.com/api/src/endpoints/clothes"
 	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/documents"
 	"soul-connection.com/api/src/endpoints/employees"
 	"soul-connection.com/api/src/endpoints/encounters"
 	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/imports"
 	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/privacy"
 	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/graph"
+	"soul-connection.com/api/src/health"
+	"soul-connection.com/api/src/importer"
+	"soul-connection.com/api/src/metrics"
 	"soul-connection.com/api/src/middleware"
+	"soul-connection.com/api/src/openapi"
+	"soul-connection.com/api/src/ratelimit"
+	"soul-connection.com/api/src/retention"
+	"soul-connection.com/api/src/stream"
 )
 
+const versionPrefix = "/api/v1"
+
+// legacyDeprecation is when the unversioned routes were deprecated.
+var legacyDeprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
 type Endpoint struct {
 	Path    string
 	Handler http.HandlerFunc
 	Method  string
+	// Untimed routes last as long as the client reads them, like streams and
+	// downloads, the request timeout does not apply to them nor to exports
+	Untimed bool
+
+	// NOTE: The fields below only document the route in /api/openapi.json
+
+	// Summary defaults to the name of Handler, GetAllCustomers is "Get all customers"
+	Summary string
+	// Request and Response are values of the JSON bodies, like AddCustomer{}
+	Request  any
+	Response any
+	// Consumes and Produces are the content types of bodies that are not JSON
+	Consumes string
+	Produces string
+	Query    []openapi.Parameter
+	// Export is set on the lists also answered as csv or xlsx, see export.Format
+	Export bool
 }
 
 type ModelRoutes struct {
 	BasePath string
 	Routes   []Endpoint
+	// Deprecated groups are only kept for clients of the unversioned paths
+	Deprecated bool
 }
 
-func CreateRouter(database *sql.DB, fileStorage *mongo.Database) (*mux.Router, error) {
-	apiKey := os.Getenv("API_KEY")
+// Models hold the handlers of every route.
+type Models struct {
+	Employees  employees.EmployeesModel
+	Customers  customers.CustomersModel
+	Events     events.EventModel
+	Payments   payments.PaymentModel
+	Encounters encounters.EncounterModel
+	Clothes    clothes.ClothesModel
+	Tips       tips.TipModel
+	Documents  documents.DocumentsModel
+	Imports    imports.ImportsModel
+	Privacy    privacy.PrivacyModel
+	// Changes is what the models publish to and /api/stream follows
+	Changes *changes.Bus
+	// Audit records the writes of the models, /api/audit lists them
+	Audit *audit.Log
+}
 
-	employeesBucket, err := gridfs.NewBucket(fileStorage, options.GridFSBucket().SetName("employeesBucket"))
-	customersBucket, err := gridfs.NewBucket(fileStorage, options.GridFSBucket().SetName("customersBucket"))
-	clothesBucket, err := gridfs.NewBucket(fileStorage, options.GridFSBucket().SetName("clothesBucket"))
+// CreateRouter serves the models backed by Postgres and GridFS, their writes
+// are published on bus.
+func CreateRouter(cfg *config.Config, database *sql.DB, fileStorage *mongo.Database, bus *changes.Bus, probe *health.Probe) (*mux.Router, error) {
+	buckets, err := openBuckets(fileStorage)
 	if err != nil {
 		return nil, err
 	}
 
-	// authModel := auth.AuthModel{Auth: auth.ApiKeyAuth{ApiKey: apiKey}}
-	employeeModel := employees.EmployeesModel{Employees: employees.EmployeesDB{DB: database, Bucket: employeesBucket}}
-	customerModel := customers.CustomersModel{Customers: customers.CustomersDB{DB: database, Bucket: customersBucket}}
-	eventModel := events.EventModel{Events: events.EventsDB{DB: database}}
-	paymentModel := payments.PaymentModel{Payments: payments.PaymentsDB{DB: database}}
-	encounterModel := encounters.EncounterModel{Encounters: encounters.EncountersDB{DB: database}}
-	clotheModel := clothes.ClothesModel{Clothes: clothes.ClothesDB{DB: database, Bucket: clothesBucket}}
-	tipModel := tips.TipModel{Tips: tips.TipsDB{DB: database}}
+	encrypted, err := customers.NewEncryption(cfg.Encryption.Keys, cfg.Encryption.IndexKey, cfg.Encryption.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog := &audit.Log{DB: database, Entries: audit.EntriesDB{DB: database}}
+	models := Models{
+		Employees:  employees.EmployeesModel{Employees: employees.EmployeesDB{DB: database, Bucket: buckets["employeesBucket"]}, Changes: bus, Audit: auditLog},
+		Customers:  customers.CustomersModel{Customers: customers.CustomersDB{DB: database, Bucket: buckets["customersBucket"], Encryption: encrypted}, Changes: bus, Audit: auditLog},
+		Events:     events.EventModel{Events: events.EventsDB{DB: database}, Changes: bus, Audit: auditLog},
+		Payments:   payments.PaymentModel{Payments: payments.PaymentsDB{DB: database}, Changes: bus, Audit: auditLog},
+		Encounters: encounters.EncounterModel{Encounters: encounters.EncountersDB{DB: database}, Changes: bus, Audit: auditLog},
+		Clothes:    clothes.ClothesModel{Clothes: clothes.ClothesDB{DB: database, Bucket: buckets["clothesBucket"]}, Changes: bus, Audit: auditLog},
+		Tips:       tips.TipModel{Tips: tips.TipsDB{DB: database}, Changes: bus, Audit: auditLog},
+		Documents: documents.DocumentsModel{
+			Payments:  payments.PaymentsDB{DB: database},
+			Customers: customers.CustomersDB{DB: database, Bucket: buckets["customersBucket"], Encryption: encrypted},
+			Bucket:    buckets["documentsBucket"],
+		},
+		Imports: imports.ImportsModel{Importer: importer.Importer{DB: database, Encryption: encrypted}, Audit: auditLog},
+		Privacy: privacy.PrivacyModel{
+			Customers:  customers.CustomersDB{DB: database, Bucket: buckets["customersBucket"], WithDeleted: true, Encryption: encrypted},
+			Payments:   payments.PaymentsDB{DB: database},
+			Encounters: encounters.EncountersDB{DB: database},
+			Clothes:    clothes.ClothesDB{DB: database, Bucket: buckets["clothesBucket"]},
+			Erasures:   privacy.ErasureDB{DB: database, Customers: buckets["customersBucket"], Clothes: buckets["clothesBucket"]},
+			Changes:    bus,
+			Audit:      auditLog,
+		},
+		Changes: bus,
+		Audit:   auditLog,
+	}
+	return NewRouter(cfg, models, probe)
+}
+
+// NewPurger removes the employees and customers deleted longer ago than the
+// retention period of cfg, with their images.
+func NewPurger(cfg *config.Config, database *sql.DB, fileStorage *mongo.Database) (*retention.Purger, error) {
+	buckets, err := openBuckets(fileStorage)
+	if err != nil {
+		return nil, err
+	}
+	return &retention.Purger{
+		DB: database,
+		Buckets: retention.Buckets{
+			Employees: buckets["employeesBucket"],
+			Customers: buckets["customersBucket"],
+			Clothes:   buckets["clothesBucket"],
+		},
+		Period: cfg.Retention.Period,
+		Audit:  &audit.Log{DB: database, Entries: audit.EntriesDB{DB: database}},
+	}, nil
+}
+
+func openBuckets(fileStorage *mongo.Database) (map[string]*gridfs.Bucket, error) {
+	buckets := map[string]*gridfs.Bucket{}
+	for _, name := range []string{"employeesBucket", "customersBucket", "clothesBucket", "documentsBucket"} {
+		bucket, err := gridfs.NewBucket(fileStorage, options.GridFSBucket().SetName(name))
+		if err != nil {
+			return nil, err
+		}
+		buckets[name] = bucket
+	}
+	return buckets, nil
+}
+
+// NewRouter serves models, whatever their storage, tests use mocks.
+func NewRouter(cfg *config.Config, models Models, probe *health.Probe) (*mux.Router, error) {
+	store, err := ratelimit.NewStore(cfg.RateLimit.RedisUrl)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ratelimit.ParseRules(cfg.RateLimit.Rules)
+	if err != nil {
+		return nil, err
+	}
+	limitAddress, err := limitAddresses(store, cfg.RateLimit.PerAddress, cfg.RateLimit.ForwardedFor)
+	if err != nil {
+		return nil, err
+	}
+
+	// authModel := auth.AuthModel{
+	// 	Auth:    auth.ApiKeyAuth{ApiKey: cfg.Upstream.ApiKey},
+	// 	BaseUri: cfg.Upstream.BaseUri,
+	// 	Lockout: ratelimit.NewLockout(store, int(cfg.RateLimit.LoginAttempts), cfg.RateLimit.LoginWindow, cfg.RateLimit.Lockout),
+	// 	ForwardedFor: cfg.RateLimit.ForwardedFor,
+	// }
 
 	// publicRoutes := []ModelRoutes{
 	// 	{
@@ -62,110 +197,299 @@ func CreateRouter(database *sql.DB, fileStorage *mongo.Database) (*mux.Router, e
 
 	publicRoutes := []ModelRoutes{
 		{
-			BasePath: "/api/employees",
+			BasePath: "",
+			Routes: []Endpoint{
+				{Path: "/healthz", Handler: probe.Live, Method: http.MethodGet, Summary: "Liveness probe"},
+				{Path: "/readyz", Handler: probe.Ready, Method: http.MethodGet, Summary: "Readiness probe, 503 while a dependency is down or the server is draining"},
+				{Path: "/metrics", Handler: metrics.Handler().ServeHTTP, Method: http.MethodGet, Summary: "Prometheus metrics", Produces: "text/plain"},
+			},
+		},
+		{
+			BasePath: "/api/v1/employees",
 			Routes: []Endpoint{
-				{Path: "", Handler: employeeModel.GetAllEmployees, Method: http.MethodGet},
-				{Path: "", Handler: employeeModel.AddEmployee, Method: http.MethodPost},
-				{Path: "/{employee_id}", Handler: employeeModel.GetEmployeeById, Method: http.MethodGet},
-				{Path: "/{employee_id}", Handler: employeeModel.DeleteEmployee, Method: http.MethodDelete},
-				{Path: "/{employee_id}", Handler: employeeModel.PatchEmployee, Method: http.MethodPatch},
-				{Path: "/{employee_id}/image", Handler: employeeModel.GetImage, Method: http.MethodGet},
+				{Path: "", Handler: models.Employees.GetAllEmployees, Method: http.MethodGet, Response: []employees.EmployeeResponse{}, Export: true, Query: []openapi.Parameter{includeDeletedQuery}},
+				{Path: "", Handler: models.Employees.AddEmployee, Method: http.MethodPost, Request: employees.AddEmployee{}, Response: employees.EmployeeResponse{}},
+				{Path: "/{employee_id}", Handler: models.Employees.GetEmployeeById, Method: http.MethodGet, Response: employees.EmployeeResponse{}, Query: []openapi.Parameter{includeDeletedQuery}},
+				{Path: "/{employee_id}", Handler: models.Employees.DeleteEmployee, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{employee_id}", Handler: models.Employees.PatchEmployee, Method: http.MethodPatch, Request: employees.UpdateEmployee{}, Response: employees.EmployeeResponse{}},
+				{Path: "/{employee_id}/image", Handler: models.Employees.GetImage, Method: http.MethodGet, Produces: "application/octet-stream"},
 			},
 		},
 		{
-			BasePath: "/api/customers",
+			BasePath: "/api/v1/customers",
 			Routes: []Endpoint{
-				{Path: "", Handler: customerModel.GetAllCustomers, Method: http.MethodGet},
-				{Path: "", Handler: customerModel.AddCustomer, Method: http.MethodPost},
-				{Path: "/{customer_id}", Handler: customerModel.GetCustomerById, Method: http.MethodGet},
-				{Path: "/{customer_id}", Handler: customerModel.DeleteCustomer, Method: http.MethodDelete},
-				{Path: "/{customer_id}", Handler: customerModel.PatchCustomer, Method: http.MethodPatch},
-				{Path: "/{customer_id}/image", Handler: customerModel.GetImage, Method: http.MethodGet},
-				{Path: "/employees/{employee_id}", Handler: customerModel.GetCustomerById, Method: http.MethodGet},
+				{Path: "", Handler: models.Customers.GetAllCustomers, Method: http.MethodGet, Response: []customers.CustomerResponse{}, Export: true, Query: []openapi.Parameter{phoneNumberQuery, includeDeletedQuery}},
+				{Path: "", Handler: models.Customers.AddCustomer, Method: http.MethodPost, Request: customers.AddCustomer{}, Response: customers.CustomerResponse{}},
+				{Path: "/{customer_id}", Handler: models.Customers.GetCustomerById, Method: http.MethodGet, Response: customers.CustomerResponse{}, Query: []openapi.Parameter{includeDeletedQuery}},
+				{Path: "/{customer_id}", Handler: models.Customers.DeleteCustomer, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{customer_id}", Handler: models.Customers.PatchCustomer, Method: http.MethodPatch, Request: customers.UpdateCustomer{}, Response: customers.CustomerResponse{}},
+				{Path: "/{customer_id}/image", Handler: models.Customers.GetImage, Method: http.MethodGet, Produces: "application/octet-stream"},
+				{Path: "/{customer_id}/assign", Handler: models.Customers.AssignCustomer, Method: http.MethodPost, Request: customers.AssignCustomer{}, Response: customers.CustomerResponse{}},
+				{Path: "/{customer_id}/unassign", Handler: models.Customers.UnassignCustomer, Method: http.MethodPost, Response: customers.CustomerResponse{}},
+				{Path: "/{customer_id}/assignments", Handler: models.Customers.GetAssignments, Method: http.MethodGet, Response: []customers.AssignmentResponse{}},
+				{Path: "/{customer_id}/statement", Handler: models.Documents.GetCustomerStatement, Method: http.MethodGet, Produces: "application/pdf", Query: []openapi.Parameter{fromDateQuery, toDateQuery, storeQuery}},
+				{Path: "/reassign", Handler: models.Customers.ReassignCustomers, Method: http.MethodPost, Request: customers.ReassignCustomers{}, Response: []customers.CustomerResponse{}},
+				{Path: "/employees/{employee_id}", Handler: models.Customers.GetCustomerByEmployeeId, Method: http.MethodGet, Response: []customers.CustomerResponse{}, Export: true},
 			},
 		},
 		{
-			BasePath: "/api/events",
+			BasePath: "/api/v1/events",
 			Routes: []Endpoint{
-				{Path: "", Handler: eventModel.GetAllEvents, Method: http.MethodGet},
-				{Path: "", Handler: eventModel.AddEvent, Method: http.MethodPost},
-				{Path: "/{event_id}", Handler: eventModel.GetEventsById, Method: http.MethodGet},
-				{Path: "/{event_id}", Handler: eventModel.DeleteEvent, Method: http.MethodDelete},
-				{Path: "/{event_id}", Handler: eventModel.PatchEvent, Method: http.MethodPatch},
+				{Path: "", Handler: models.Events.GetAllEvents, Method: http.MethodGet, Response: []events.EventResponse{}, Export: true},
+				{Path: "", Handler: models.Events.AddEvent, Method: http.MethodPost, Request: events.AddEvent{}, Response: events.EventResponse{}},
+				{Path: "/{event_id}", Handler: models.Events.GetEventsById, Method: http.MethodGet, Response: events.EventResponse{}},
+				{Path: "/{event_id}", Handler: models.Events.DeleteEvent, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{event_id}", Handler: models.Events.PatchEvent, Method: http.MethodPatch, Request: events.UpdateEvent{}, Response: events.EventResponse{}},
 			},
 		},
 		{
-			BasePath: "/api/payments",
+			BasePath: "/api/v1/payments",
 			Routes: []Endpoint{
-				{Path: "", Handler: paymentModel.GetAllPayments, Method: http.MethodGet},
-				{Path: "", Handler: paymentModel.AddPayment, Method: http.MethodPost},
-				{Path: "/{payment_id}", Handler: paymentModel.GetPaymentsById, Method: http.MethodGet},
-				{Path: "/{payment_id}", Handler: paymentModel.DeletePayment, Method: http.MethodDelete},
-				{Path: "/{payment_id}", Handler: paymentModel.PatchPayment, Method: http.MethodPatch},
-				{Path: "/customer/{customer_id}", Handler: paymentModel.GetPaymentsByCustomerId, Method: http.MethodGet},
+				{Path: "", Handler: models.Payments.GetAllPayments, Method: http.MethodGet, Response: []payments.PaymentResponse{}, Export: true},
+				{Path: "", Handler: models.Payments.AddPayment, Method: http.MethodPost, Request: payments.AddPayment{}, Response: payments.PaymentResponse{}},
+				{Path: "/{payment_id}", Handler: models.Payments.GetPaymentsById, Method: http.MethodGet, Response: payments.PaymentResponse{}},
+				{Path: "/{payment_id}", Handler: models.Payments.DeletePayment, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{payment_id}", Handler: models.Payments.PatchPayment, Method: http.MethodPatch, Request: payments.UpdatePayment{}, Response: payments.PaymentResponse{}},
+				{Path: "/{payment_id}/document", Handler: models.Documents.GetPaymentDocument, Method: http.MethodGet, Produces: "application/pdf", Query: []openapi.Parameter{documentTypeQuery, storeQuery}},
+				{Path: "/customer/{customer_id}", Handler: models.Payments.GetPaymentsByCustomerId, Method: http.MethodGet, Response: []payments.PaymentResponse{}, Export: true},
+				{Path: "/revenue/customers", Handler: models.Payments.GetRevenueByCustomer, Method: http.MethodGet, Response: []payments.CustomerRevenue{}},
+				{Path: "/revenue/customers/{customer_id}", Handler: models.Payments.GetRevenueForCustomer, Method: http.MethodGet, Response: payments.CustomerRevenue{}},
+				{Path: "/revenue/employees", Handler: models.Payments.GetRevenueByEmployee, Method: http.MethodGet, Summary: "Revenue of the customers of each coach, credited to their current coach rather than the one they had when paying", Response: []payments.EmployeeRevenue{}},
+				{Path: "/revenue/months", Handler: models.Payments.GetRevenueByMonth, Method: http.MethodGet, Response: []payments.MonthlyRevenue{}, Query: []openapi.Parameter{fromMonthQuery, toMonthQuery}},
+				{Path: "/revenue/methods", Handler: models.Payments.GetRevenueByMethod, Method: http.MethodGet, Response: []payments.MethodRevenue{}},
 			},
 		},
 		{
-			BasePath: "/api/encounters",
+			BasePath: "/api/v1/encounters",
 			Routes: []Endpoint{
-				{Path: "", Handler: encounterModel.GetAllEncounters, Method: http.MethodGet},
-				{Path: "", Handler: encounterModel.AddEncounter, Method: http.MethodPost},
-				{Path: "/{encounter_id}", Handler: encounterModel.GetEncounterById, Method: http.MethodGet},
-				{Path: "/{encounter_id}", Handler: encounterModel.DeleteEncounter, Method: http.MethodDelete},
-				{Path: "/{encounter_id}", Handler: encounterModel.PatchEncounter, Method: http.MethodPatch},
-				{Path: "/customer/{customer_id}", Handler: encounterModel.GetEncounterByCustomerId, Method: http.MethodGet},
+				{Path: "", Handler: models.Encounters.GetAllEncounters, Method: http.MethodGet, Response: []encounters.EncounterResponse{}, Export: true},
+				{Path: "", Handler: models.Encounters.AddEncounter, Method: http.MethodPost, Request: encounters.AddEncounter{}, Response: encounters.EncounterResponse{}},
+				{Path: "/{encounter_id}", Handler: models.Encounters.GetEncounterById, Method: http.MethodGet, Response: encounters.EncounterResponse{}},
+				{Path: "/{encounter_id}", Handler: models.Encounters.DeleteEncounter, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{encounter_id}", Handler: models.Encounters.PatchEncounter, Method: http.MethodPatch, Request: encounters.UpdateEncounter{}, Response: encounters.EncounterResponse{}},
+				{Path: "/customer/{customer_id}", Handler: models.Encounters.GetEncounterByCustomerId, Method: http.MethodGet, Response: []encounters.EncounterResponse{}, Export: true},
 			},
 		},
 		{
-			BasePath: "/api/clothes",
+			BasePath: "/api/v1/clothes",
 			Routes: []Endpoint{
-				{Path: "", Handler: clotheModel.GetAllClothes, Method: http.MethodGet},
-				{Path: "", Handler: clotheModel.AddClothe, Method: http.MethodPost},
-				{Path: "/{clothe_id}", Handler: clotheModel.GetClotheById, Method: http.MethodGet},
-				{Path: "/{clothe_id}", Handler: clotheModel.DeleteClothe, Method: http.MethodDelete},
-				{Path: "/{clothe_id}", Handler: clotheModel.PatchClothes, Method: http.MethodPatch},
-				{Path: "/{clothe_id}/image", Handler: clotheModel.GetImage, Method: http.MethodGet},
-				{Path: "/customer/{customer_id}", Handler: clotheModel.GetClotheByCustomerId, Method: http.MethodGet},
+				{Path: "", Handler: models.Clothes.GetAllClothes, Method: http.MethodGet, Response: []clothes.ClotheResponse{}, Export: true},
+				{Path: "", Handler: models.Clothes.AddClothe, Method: http.MethodPost, Request: clothes.AddClothe{}, Response: clothes.ClotheResponse{}},
+				{Path: "/{clothe_id}", Handler: models.Clothes.GetClotheById, Method: http.MethodGet, Response: clothes.ClotheResponse{}},
+				{Path: "/{clothe_id}", Handler: models.Clothes.DeleteClothe, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{clothe_id}", Handler: models.Clothes.PatchClothes, Method: http.MethodPatch, Request: clothes.UpdateClothe{}, Response: clothes.ClotheResponse{}},
+				{Path: "/{clothe_id}/image", Handler: models.Clothes.GetImage, Method: http.MethodGet, Produces: "application/octet-stream"},
+				{Path: "/customer/{customer_id}", Handler: models.Clothes.GetClotheByCustomerId, Method: http.MethodGet, Response: []clothes.ClotheResponse{}, Export: true},
 			},
 		},
 		{
-			BasePath: "/api/tips",
+			BasePath: "/api/v1/tips",
 			Routes: []Endpoint{
-				{Path: "", Handler: tipModel.GetAllTips, Method: http.MethodGet},
-				{Path: "", Handler: tipModel.AddTip, Method: http.MethodPost},
-				{Path: "/{tip_id}", Handler: tipModel.GetTipById, Method: http.MethodGet},
-				{Path: "/{tip_id}", Handler: tipModel.DeleteTip, Method: http.MethodDelete},
-				{Path: "/{tip_id}", Handler: tipModel.PatchTips, Method: http.MethodPatch},
+				{Path: "", Handler: models.Tips.GetAllTips, Method: http.MethodGet, Response: []tips.TipResponse{}, Export: true},
+				{Path: "", Handler: models.Tips.AddTip, Method: http.MethodPost, Request: tips.AddTip{}, Response: tips.TipResponse{}},
+				{Path: "/{tip_id}", Handler: models.Tips.GetTipById, Method: http.MethodGet, Response: tips.TipResponse{}},
+				{Path: "/{tip_id}", Handler: models.Tips.DeleteTip, Method: http.MethodDelete, Response: deleted},
+				{Path: "/{tip_id}", Handler: models.Tips.PatchTips, Method: http.MethodPatch, Request: tips.UpdateTip{}, Response: tips.TipResponse{}},
 			},
 		},
 	}
 
+	publicRoutes = append(publicRoutes, legacyRoutes(publicRoutes)...)
+
+	graphqlHandler, err := graph.Handler(graph.Repositories{
+		Employees:  models.Employees.Employees,
+		Customers:  models.Customers.Customers,
+		Events:     models.Events.Events,
+		Payments:   models.Payments.Payments,
+		Encounters: models.Encounters.Encounters,
+		Clothes:    models.Clothes.Clothes,
+		Tips:       models.Tips.Tips,
+	})
+	if err != nil {
+		return nil, err
+	}
+	protectedRoutes := []ModelRoutes{
+		{
+			BasePath: "/api/v1/employees",
+			Routes: []Endpoint{
+				{Path: "/{employee_id}/restore", Handler: models.Employees.RestoreEmployee, Method: http.MethodPost, Summary: "Bring a deleted employee back, managers only", Response: employees.EmployeeResponse{}},
+			},
+		},
+		{
+			BasePath: "/api/v1/customers",
+			Routes: []Endpoint{
+				{Path: "/{customer_id}/restore", Handler: models.Customers.RestoreCustomer, Method: http.MethodPost, Summary: "Bring a deleted customer back, managers only", Response: customers.CustomerResponse{}},
+				{Path: "/{customer_id}/export", Handler: models.Privacy.ExportCustomer, Method: http.MethodGet, Untimed: true, Summary: "ZIP of everything held about a customer with a JSON manifest, managers only", Produces: "application/zip"},
+				{Path: "/{customer_id}/erase", Handler: models.Privacy.EraseCustomer, Method: http.MethodPost, Summary: "Anonymize a customer, keeping the amounts of its payments, managers only", Response: privacy.ErasureResponse{}},
+			},
+		},
+		{
+			BasePath: "/api/v1/import",
+			Routes: []Endpoint{
+				{Path: "/{entity}", Handler: models.Imports.Import, Method: http.MethodPost, Summary: "Import a csv file of customers, employees or payments, managers only", Consumes: "text/csv", Response: importer.Report{}, Query: []openapi.Parameter{dryRunQuery, onDuplicateQuery}},
+			},
+		},
+		{
+			BasePath: "/api/graphql",
+			Routes: []Endpoint{
+				{Path: "", Handler: graphqlHandler, Method: http.MethodPost, Summary: "GraphQL queries over every entity and its relations, coaches only read their own customers", Request: graph.Request{}, Response: graph.Response{}},
+			},
+		},
+		{
+			BasePath: "/api/audit",
+			Routes: []Endpoint{
+				{Path: "", Handler: models.Audit.GetEntries, Method: http.MethodGet, Summary: "Audit log of every write, newest first, managers only", Response: []audit.EntryResponse{}, Query: []openapi.Parameter{auditEntityQuery, auditEntityIdQuery, auditActorQuery, auditActionQuery, fromDateQuery, toDateQuery, beforeIdQuery, limitQuery}},
+			},
+		},
+		{
+			BasePath: "/api/stream",
+			Routes: []Endpoint{
+				{Path: "", Handler: stream.Handler(models.Changes, stream.Repositories{Employees: models.Employees.Employees, Customers: models.Customers.Customers}), Method: http.MethodGet, Untimed: true, Summary: "Server-sent events of every row created, updated or deleted that the user may read", Produces: "text/event-stream", Query: []openapi.Parameter{entitiesQuery}},
+			},
+		},
+	}
+
+	protectedRoutes = append(protectedRoutes, legacyRoutes(protectedRoutes)...)
+
+	openapiHandler, err := openapi.Handler(document(append(slices.Clone(publicRoutes), protectedRoutes...)))
+	if err != nil {
+		return nil, err
+	}
+	publicRoutes = append(publicRoutes, ModelRoutes{
+		BasePath: "/api",
+		Routes: []Endpoint{
+			{Path: "/openapi.json", Handler: openapiHandler, Method: http.MethodGet},
+			{Path: "/docs", Handler: openapi.SwaggerUI("/api/docs/"), Method: http.MethodGet},
+			{Path: "/docs/", Handler: openapi.SwaggerUI("/api/docs/"), Method: http.MethodGet},
+			{Path: "/docs/{file}", Handler: openapi.SwaggerUI("/api/docs/"), Method: http.MethodGet},
+		},
+	})
+
 	router := mux.NewRouter()
-	router.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{os.Getenv("WEB_URL")},
-		AllowedMethods:   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
-		AllowCredentials: true,
-	}).Handler)
-	router.Use(middleware.Logging)
+	// NOTE: The addresses are limited before the authentication, which calls the upstream api
+	router.Use(middleware.Tracing, middleware.RequestId, middleware.Logging, middleware.Metrics, limitAddress)
+
+	authProvider := middleware.AuthProvider{ApiKey: cfg.Upstream.ApiKey, BaseUri: cfg.Upstream.BaseUri}
 
+	// NOTE: Writes sent with a token are attributed to their author in the audit log
 	publicRouter := router.PathPrefix("").Subrouter()
+	publicRouter.Use(authProvider.Identify)
 
-	authProvider := middleware.AuthProvider{ApiKey: apiKey}
 	protectedRouter := router.PathPrefix("").Subrouter()
 	protectedRouter.Use(authProvider.Auth)
 
-	attachRoutes(publicRouter, publicRoutes)
-	// attachRoutes(protectedRouter, protectedRoutes)
+	attachRoutes(publicRouter, limitRoutes(publicRoutes, store, rules, cfg.RateLimit.ForwardedFor), cfg.Timeouts.Request)
+	attachRoutes(protectedRouter, limitRoutes(protectedRoutes, store, rules, cfg.RateLimit.ForwardedFor), cfg.Timeouts.Request)
 
 	return router, nil
 }
 
-func attachRoutes(router *mux.Router, endpoints []ModelRoutes) {
+// legacyRoutes serves the /api/v1 routes at their former unversioned paths,
+// like /api/customers, with their former JSON until clients have moved on.
+func legacyRoutes(routes []ModelRoutes) []ModelRoutes {
+	deprecated := middleware.Deprecated(legacyDeprecation, "/api", versionPrefix)
+	var legacy []ModelRoutes
+	for _, group := range routes {
+		path, ok := strings.CutPrefix(group.BasePath, versionPrefix)
+		if !ok {
+			continue
+		}
+		aliases := ModelRoutes{BasePath: "/api" + path, Deprecated: true}
+		for _, route := range group.Routes {
+			// NOTE: The summary comes from the name of the handler, lost once wrapped
+			route.Summary = cmp.Or(route.Summary, summary(route.Handler))
+			route.Handler = deprecated(legacyJSON(route, route.Handler)).ServeHTTP
+			aliases.Routes = append(aliases.Routes, route)
+		}
+		legacy = append(legacy, aliases)
+	}
+	return legacy
+}
+
+// limitRoutes throttles the groups under /api with the rule matching their
+// path, the deprecated aliases sharing the buckets of the routes replacing
+// them. The rules run after the authentication of the routes, so that they
+// can tell the employees apart, behind the limit of limitAddresses.
+func limitRoutes(routes []ModelRoutes, store ratelimit.Store, rules []ratelimit.Rule, forwardedFor bool) []ModelRoutes {
+	limited := make([]ModelRoutes, 0, len(routes))
+	for _, group := range routes {
+		path := group.BasePath
+		if group.Deprecated {
+			path = versionPrefix + strings.TrimPrefix(path, "/api")
+		}
+		rule, ok := ratelimit.Match(rules, path)
+		if !ok || !strings.HasPrefix(path, "/api") {
+			limited = append(limited, group)
+			continue
+		}
+		limit := ratelimit.Middleware(store, rule, forwardedFor)
+		group.Routes = slices.Clone(group.Routes)
+		for i, route := range group.Routes {
+			group.Routes[i].Handler = limit(route.Handler).ServeHTTP
+		}
+		limited = append(limited, group)
+	}
+	return limited
+}
+
+// limitAddresses throttles the requests under /api of every address to
+// perAddress before their authentication, which a client sending a new token
+// with every request would otherwise go through without limit. An empty
+// perAddress lets them all through.
+func limitAddresses(store ratelimit.Store, perAddress string, forwardedFor bool) (mux.MiddlewareFunc, error) {
+	if perAddress == "" {
+		return func(next http.Handler) http.Handler { return next }, nil
+	}
+	limit, err := ratelimit.ParseLimit(perAddress)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: Not a path, so no rule shares its buckets
+	rule := ratelimit.Rule{Prefix: "address", Limit: limit, Key: ratelimit.IP}
+	return func(next http.Handler) http.Handler {
+		limited := ratelimit.Middleware(store, rule, forwardedFor)(next)
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.URL.Path, "/api") {
+				limited.ServeHTTP(res, req)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}, nil
+}
+
+// Methods lists the methods answered by the routes of router, sorted.
+func Methods(router *mux.Router) []string {
+	var methods []string
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		routeMethods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range routeMethods {
+			if !slices.Contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+		return nil
+	})
+	slices.Sort(methods)
+	return methods
+}
+
+// attachRoutes serves endpoints on router, each request bounded by timeout
+// but those of the untimed routes and exports. Their queries are still bounded
+// by database.QueryTimeout.
+func attachRoutes(router *mux.Router, endpoints []ModelRoutes, timeout time.Duration) {
+	deadline := middleware.Timeout(timeout)
 	for _, endpoint := range endpoints {
 		for _, route := range endpoint.Routes {
 			fullPath := endpoint.BasePath + route.Path
-			router.HandleFunc(fullPath, route.Handler).Methods(route.Method)
+			var handler http.Handler = route.Handler
+			if !route.Untimed && !route.Export {
+				handler = deadline(handler)
+			}
+			router.Handle(fullPath, handler).Methods(route.Method)
 		}
 	}
 }
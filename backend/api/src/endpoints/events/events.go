@@ -1,48 +1,71 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
 
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/validation"
 )
 
 type Event struct {
-	Id               int
-	Name             string
-	Date             string
-	Max_Participants int
-	Location_X       string
-	Location_Y       string
-	Type             string
-	CreatedAt        time.Time
-	Employee_Id      int
+	Id               int       `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	Date             string    `json:"date" db:"date"`
+	Max_Participants int       `json:"max_participants" db:"max_participants"`
+	Location_X       string    `json:"location_x" db:"location_x"`
+	Location_Y       string    `json:"location_y" db:"location_y"`
+	Type             string    `json:"type" db:"type"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	Employee_Id      int       `json:"employee_id" db:"employee_id"`
+}
+
+// EventsRepository is the storage behind EventModel.
+type EventsRepository interface {
+	FindAll(context.Context) ([]Event, error)
+	StreamAll(context.Context, func(Event) error) error
+	FindByID(context.Context, int) (*Event, error)
+	FindByEmployeeIDs(context.Context, []int) ([]Event, error)
+	Add(context.Context, *AddEvent) (*Event, error)
+	Delete(context.Context, int) error
+	Patch(context.Context, int, *UpdateEvent) (*Event, error)
 }
 
 type EventModel struct {
-	Events interface {
-		FindAll() ([]Event, error)
-		FindByID(int) (*Event, error)
-		Add(*AddEvent) (*Event, error)
-		Delete(int) error
-		Patch(int, *UpdateEvent) (*Event, error)
-	}
+	Events  EventsRepository
+	Changes *changes.Bus
+	Audit   *audit.Log
 }
 
-func (model *EventModel) GetAllEvents(res http.ResponseWriter, _ *http.Request) {
-	events, err := model.Events.FindAll()
+func (model *EventModel) GetAllEvents(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "events", func(fn func(EventResponse) error) error {
+			return model.Events.StreamAll(req.Context(), func(item Event) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	events, err := model.Events.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(events); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(events, Event.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -50,46 +73,45 @@ func (model *EventModel) GetAllEvents(res http.ResponseWriter, _ *http.Request)
 func (model *EventModel) GetEventsById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "event_id")
 	if err != nil {
-		http.Error(res, "Invalid event ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid event ID format"))
 		return
 	}
 
-	event, err := model.Events.FindByID(id)
+	event, err := model.Events.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Event not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*event); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(event.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *EventModel) AddEvent(res http.ResponseWriter, req *http.Request) {
 	var nc AddEvent
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	event, err := model.Events.Add(&nc)
+	var event *Event
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if event, err = model.Events.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Events, audit.Created, event.Id, nil, event.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add event", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), event.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*event); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(event.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -97,21 +119,30 @@ func (model *EventModel) AddEvent(res http.ResponseWriter, req *http.Request) {
 func (model *EventModel) DeleteEvent(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "event_id")
 	if err != nil {
-		http.Error(res, "Invalid employee ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid employee ID format"))
 		return
 	}
 
-	err = model.Events.Delete(id)
+	previous, err := model.Events.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to delete event", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
 	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Events.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Events, audit.Deleted, id, previous.Response(), nil)
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Events, changes.Deleted, id, nil))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -119,30 +150,38 @@ func (model *EventModel) DeleteEvent(res http.ResponseWriter, req *http.Request)
 func (model *EventModel) PatchEvent(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "event_id")
 	if err != nil {
-		http.Error(res, "Invalid event ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid event ID format"))
 		return
 	}
 
 	var updates UpdateEvent
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Events.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedEvent, err := model.Events.Patch(id, &updates)
+	var updatedEvent *Event
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedEvent, err = model.Events.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Events, audit.Updated, id, previous.Response(), updatedEvent.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to update event", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), updatedEvent.change(changes.Updated))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedEvent); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(updatedEvent.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
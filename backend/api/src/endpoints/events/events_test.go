@@ -2,10 +2,13 @@ package events
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -16,23 +19,33 @@ type MockEventsDB struct {
 	Err    error
 }
 
-func (m *MockEventsDB) FindAll() ([]Event, error) {
+func (m *MockEventsDB) FindAll(ctx context.Context) ([]Event, error) {
 	if m.Events == nil {
 		return nil, errors.New("no events found")
 	}
 	return m.Events, nil
 }
 
-func (m *MockEventsDB) FindByID(id int) (*Event, error) {
+func (m *MockEventsDB) FindByID(ctx context.Context, id int) (*Event, error) {
 	for _, event := range m.Events {
 		if event.Id == id {
 			return &event, nil
 		}
 	}
-	return nil, errors.New("event not found")
+	return nil, sql.ErrNoRows
 }
 
-func (m *MockEventsDB) Add(event *AddEvent) (*Event, error) {
+func (m *MockEventsDB) FindByEmployeeIDs(ctx context.Context, ids []int) ([]Event, error) {
+	var e []Event
+	for _, event := range m.Events {
+		if slices.Contains(ids, event.Employee_Id) {
+			e = append(e, event)
+		}
+	}
+	return e, nil
+}
+
+func (m *MockEventsDB) Add(ctx context.Context, event *AddEvent) (*Event, error) {
 	newEvent := Event{
 		Id:               len(m.Events) + 1,
 		Name:             event.Name,
@@ -50,17 +63,17 @@ func (m *MockEventsDB) Add(event *AddEvent) (*Event, error) {
 	return &newEvent, nil
 }
 
-func (m *MockEventsDB) Delete(id int) error {
+func (m *MockEventsDB) Delete(ctx context.Context, id int) error {
 	for i, event := range m.Events {
 		if event.Id == id {
 			m.Events = append(m.Events[:i], m.Events[i+1:]...)
 			return nil
 		}
 	}
-	return errors.New("event not found")
+	return sql.ErrNoRows
 }
 
-func (m *MockEventsDB) Patch(id int, updates *UpdateEvent) (*Event, error) {
+func (m *MockEventsDB) Patch(ctx context.Context, id int, updates *UpdateEvent) (*Event, error) {
 	for i, event := range m.Events {
 		if event.Id == id {
 			if updates.Name != nil {
@@ -72,7 +85,20 @@ func (m *MockEventsDB) Patch(id int, updates *UpdateEvent) (*Event, error) {
 			return &m.Events[i], nil
 		}
 	}
-	return nil, errors.New("event not found")
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEventsDB) StreamAll(ctx context.Context, fn func(Event) error) error {
+	all, err := m.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range all {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func setupTestModel(mockDB *MockEventsDB) *EventModel {
@@ -111,14 +137,14 @@ func testAddEvent(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/events", bytes.NewBuffer([]byte(`{invalid json}`)))
 		rr := httptest.NewRecorder()
 		model.AddEvent(rr, req)
-		checkResponseCode(t, rr, http.StatusInternalServerError)
+		checkResponseCode(t, rr, http.StatusBadRequest)
 	})
 
 	t.Run("Error Add Method", func(t *testing.T) {
 		model := setupTestModel(&MockEventsDB{Err: errors.New("database error")})
 		req := createRequest(t, http.MethodPost, "/api/events", &AddEvent{
 			Name:             "Event 1",
-			Date:             "25-04-2023",
+			Date:             "2023-04-25",
 			Max_Participants: 100,
 			Location_X:       "123.45",
 			Location_Y:       "678.90",
@@ -127,13 +153,38 @@ func testAddEvent(t *testing.T) {
 		})
 		rr := httptest.NewRecorder()
 		model.AddEvent(rr, req)
+		checkResponseCode(t, rr, http.StatusInternalServerError)
+	})
+
+	t.Run("Invalid Event", func(t *testing.T) {
+		req := createRequest(t, http.MethodPost, "/api/events", &AddEvent{
+			Name:             "Event 1",
+			Date:             "25/04/2023",
+			Max_Participants: -5,
+			Location_X:       "123.45",
+			Location_Y:       "678.90",
+			Type:             "Conference",
+			Employee_Id:      1,
+		})
+		rr := httptest.NewRecorder()
+		model.AddEvent(rr, req)
 		checkResponseCode(t, rr, http.StatusBadRequest)
+
+		var body struct {
+			Error struct {
+				Details []struct{ Field, Message string }
+			}
+		}
+		decodeResponseBody(t, rr, &body)
+		if len(body.Error.Details) != 2 || body.Error.Details[0].Field != "date" || body.Error.Details[1].Field != "max_participants" {
+			t.Errorf("Expected date and max_participants errors, got %+v", body.Error.Details)
+		}
 	})
 
 	t.Run("Add Event", func(t *testing.T) {
 		req := createRequest(t, http.MethodPost, "/api/events", &AddEvent{
 			Name:             "New Event",
-			Date:             "25-04-2023",
+			Date:             "2023-04-25",
 			Max_Participants: 100,
 			Location_X:       "123.45",
 			Location_Y:       "678.90",
@@ -156,8 +207,8 @@ func testAddEvent(t *testing.T) {
 func testGetAllEvents(t *testing.T) {
 	t.Run("Get All Events", func(t *testing.T) {
 		model := setupTestModel(&MockEventsDB{})
-		model.Events.Add(&AddEvent{Name: "Event 1", Date: "25-04-2023", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
-		model.Events.Add(&AddEvent{Name: "Event 2", Date: "26-04-2023", Max_Participants: 150, Location_X: "123.46", Location_Y: "678.91", Type: "Seminar", Employee_Id: 2})
+		model.Events.Add(context.Background(), &AddEvent{Name: "Event 1", Date: "2023-04-25", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
+		model.Events.Add(context.Background(), &AddEvent{Name: "Event 2", Date: "2023-04-26", Max_Participants: 150, Location_X: "123.46", Location_Y: "678.91", Type: "Seminar", Employee_Id: 2})
 
 		req := createRequest(t, http.MethodGet, "/api/events", nil)
 		rr := httptest.NewRecorder()
@@ -186,7 +237,7 @@ func testGetAllEvents(t *testing.T) {
 func testGetEventById(t *testing.T) {
 	t.Run("Get Event by ID", func(t *testing.T) {
 		model := setupTestModel(&MockEventsDB{})
-		model.Events.Add(&AddEvent{Name: "Event 1", Date: "25-04-2023", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
+		model.Events.Add(context.Background(), &AddEvent{Name: "Event 1", Date: "2023-04-25", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
 
 		req := createRequest(t, http.MethodGet, "/api/events/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"event_id": "1"})
@@ -227,7 +278,7 @@ func testGetEventById(t *testing.T) {
 func testDeleteEvent(t *testing.T) {
 	t.Run("Delete Event", func(t *testing.T) {
 		model := setupTestModel(&MockEventsDB{})
-		model.Events.Add(&AddEvent{Name: "Event to delete", Date: "25-04-2023", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
+		model.Events.Add(context.Background(), &AddEvent{Name: "Event to delete", Date: "2023-04-25", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
 
 		req := createRequest(t, http.MethodDelete, "/api/events/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"event_id": "1"})
@@ -250,7 +301,7 @@ func testDeleteEvent(t *testing.T) {
 
 		model.DeleteEvent(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Deleting Event", func(t *testing.T) {
@@ -261,7 +312,7 @@ func testDeleteEvent(t *testing.T) {
 
 		model.DeleteEvent(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
@@ -269,7 +320,7 @@ func testPatchEvents(t *testing.T) {
 	newName := "Updated Event"
 	t.Run("Patch Event", func(t *testing.T) {
 		model := setupTestModel(&MockEventsDB{})
-		model.Events.Add(&AddEvent{Name: "Original Event", Date: "25-04-2023", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
+		model.Events.Add(context.Background(), &AddEvent{Name: "Original Event", Date: "2023-04-25", Max_Participants: 100, Location_X: "123.45", Location_Y: "678.90", Type: "Conference", Employee_Id: 1})
 
 		req := createRequest(t, http.MethodPatch, "/api/events/1", &UpdateEvent{Name: &newName})
 		req = mux.SetURLVars(req, map[string]string{"event_id": "1"})
@@ -295,7 +346,7 @@ func testPatchEvents(t *testing.T) {
 
 		model.PatchEvent(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Patching Event", func(t *testing.T) {
@@ -306,7 +357,7 @@ func testPatchEvents(t *testing.T) {
 
 		model.PatchEvent(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
@@ -1,10 +1,10 @@
 package events
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"reflect"
-	"strings"
+
+	"soul-connection.com/api/src/database"
 )
 
 type EventsDB struct {
@@ -12,125 +12,52 @@ type EventsDB struct {
 }
 
 type AddEvent struct {
-	Name             string
-	Date             string
-	Max_Participants int
-	Location_X       string
-	Location_Y       string
-	Type             string
-	Employee_Id      int
+	Name             string `json:"name" db:"name" validate:"required,max=255"`
+	Date             string `json:"date" db:"date" validate:"required,date"`
+	Max_Participants int    `json:"max_participants" db:"max_participants" validate:"required,min=1"`
+	Location_X       string `json:"location_x" db:"location_x" validate:"required,max=255"`
+	Location_Y       string `json:"location_y" db:"location_y" validate:"required,max=255"`
+	Type             string `json:"type" db:"type" validate:"required,max=255"`
+	Employee_Id      int    `json:"employee_id" db:"employee_id" validate:"required,min=1"`
 }
 
 type UpdateEvent struct {
-	Name             *string
-	Date             *string
-	Max_Participants *int
-	Location_X       *string
-	Location_Y       *string
-	Type             *string
+	Name             *string `json:"name" db:"name" validate:"required,max=255"`
+	Date             *string `json:"date" db:"date" validate:"required,date"`
+	Max_Participants *int    `json:"max_participants" db:"max_participants" validate:"required,min=1"`
+	Location_X       *string `json:"location_x" db:"location_x" validate:"required,max=255"`
+	Location_Y       *string `json:"location_y" db:"location_y" validate:"required,max=255"`
+	Type             *string `json:"type" db:"type" validate:"required,max=255"`
 }
 
-func (db EventsDB) FindAll() ([]Event, error) {
-	rows, err := db.DB.Query("SELECT * FROM event")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var events []Event
-
-	for rows.Next() {
-		var e Event
-		err := rows.Scan(&e.Id, &e.Name, &e.Date, &e.Max_Participants, &e.Location_X, &e.Location_Y, &e.Type, &e.CreatedAt, &e.Employee_Id)
-		if err != nil {
-			return nil, err
-		}
-		events = append(events, e)
-	}
-	err = rows.Err()
-
-	if err != nil {
-		return nil, err
-	}
-	return events, nil
+func repository(q database.Queryer) database.Repository[Event] {
+	return database.Repository[Event]{DB: q, Table: "event"}
 }
 
-func (db EventsDB) FindByID(id int) (*Event, error) {
-	query := "SELECT * FROM event WHERE id = $1"
-
-	row := db.DB.QueryRow(query, id)
-	var e Event
-
-	err := row.Scan(&e.Id, &e.Name, &e.Date, &e.Max_Participants, &e.Location_X, &e.Location_Y, &e.Type, &e.CreatedAt, &e.Employee_Id)
-	if err != nil {
-		return nil, err
-	}
-
-	return &e, nil
+func (db EventsDB) FindAll(ctx context.Context) ([]Event, error) {
+	return repository(db.DB).FindAll(ctx)
 }
 
-func (db EventsDB) Add(employee *AddEvent) (*Event, error) {
-	query := `
-		INSERT INTO event (name, date, max_participants, location_x, location_y, type, employee_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING *
-    `
-	row := db.DB.QueryRow(query, employee.Name, employee.Date, employee.Max_Participants, employee.Location_X, employee.Location_Y, employee.Type, employee.Employee_Id)
-	var e Event
-
-	err := row.Scan(&e.Id, &e.Name, &e.Date, &e.Max_Participants, &e.Location_X, &e.Location_Y, &e.Type, &e.CreatedAt, &e.Employee_Id)
-
-	if err != nil {
-		return nil, err
-	}
-	return &e, nil
+func (db EventsDB) StreamAll(ctx context.Context, fn func(Event) error) error {
+	return repository(db.DB).StreamAll(ctx, fn)
 }
 
-func (db EventsDB) Delete(id int) error {
-	query := "DELETE FROM event WHERE id = $1"
-
-	_, err := db.DB.Exec(query, id)
-	return err
+func (db EventsDB) FindByID(ctx context.Context, id int) (*Event, error) {
+	return repository(db.DB).FindByID(ctx, id)
 }
 
-func (db EventsDB) Patch(id int, updates *UpdateEvent) (*Event, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
-
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
-		}
-	}
-
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
-	}
-
-	query := fmt.Sprintf(
-		"UPDATE event SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
+func (db EventsDB) FindByEmployeeIDs(ctx context.Context, ids []int) ([]Event, error) {
+	return repository(db.DB).FindIn(ctx, "employee_id", ids)
+}
 
-	args = append(args, id)
+func (db EventsDB) Add(ctx context.Context, event *AddEvent) (*Event, error) {
+	return repository(db.DB).Add(ctx, event)
+}
 
-	row := db.DB.QueryRow(query, args...)
-	var e Event
-	err := row.Scan(&e.Id, &e.Name, &e.Date, &e.Max_Participants, &e.Location_X, &e.Location_Y, &e.Type, &e.CreatedAt, &e.Employee_Id)
-	if err != nil {
-		return nil, err
-	}
+func (db EventsDB) Delete(ctx context.Context, id int) error {
+	return repository(db.DB).Delete(ctx, id)
+}
 
-	return &e, nil
+func (db EventsDB) Patch(ctx context.Context, id int, updates *UpdateEvent) (*Event, error) {
+	return repository(db.DB).Patch(ctx, id, updates)
 }
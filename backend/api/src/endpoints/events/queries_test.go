@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"database/sql"
 	"reflect"
 	"testing"
@@ -23,6 +24,7 @@ func setupTestDB() (*sql.DB, error) {
 		location_x TEXT NOT NULL,
 		location_y TEXT NOT NULL,
 		type TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		employee_id INTEGER,
 		UNIQUE (name, date, location_x, location_y)
 	);
@@ -66,7 +68,7 @@ func TestEventQueries(t *testing.T) {
 			Employee_Id:      employee_ID,
 		}
 
-		addedEvent, err := eventsDB.Add(newEvent)
+		addedEvent, err := eventsDB.Add(context.Background(), newEvent)
 		if err != nil {
 			t.Errorf("Failed to add event: %v", err)
 			return
@@ -78,7 +80,7 @@ func TestEventQueries(t *testing.T) {
 	})
 
 	t.Run("Find All Events", func(t *testing.T) {
-		_, err := eventsDB.Add(&AddEvent{
+		_, err := eventsDB.Add(context.Background(), &AddEvent{
 			Name:             "Another Event",
 			Date:             "26-04-2023",
 			Max_Participants: 50,
@@ -91,7 +93,7 @@ func TestEventQueries(t *testing.T) {
 			t.Fatalf("Failed to add event: %v", err)
 		}
 
-		events, err := eventsDB.FindAll()
+		events, err := eventsDB.FindAll(context.Background())
 		if err != nil {
 			t.Errorf("Failed to find all events: %v", err)
 			return
@@ -103,7 +105,7 @@ func TestEventQueries(t *testing.T) {
 	})
 
 	t.Run("Find Event by ID", func(t *testing.T) {
-		_, err := eventsDB.Add(&AddEvent{
+		_, err := eventsDB.Add(context.Background(), &AddEvent{
 			Name:             "Another Event",
 			Date:             "26-04-2023",
 			Max_Participants: 50,
@@ -116,7 +118,7 @@ func TestEventQueries(t *testing.T) {
 			t.Fatalf("Failed to add event: %v", err)
 		}
 
-		event, err := eventsDB.FindByID(1)
+		event, err := eventsDB.FindByID(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to find event by ID: %v", err)
 			return
@@ -128,7 +130,7 @@ func TestEventQueries(t *testing.T) {
 	})
 
 	t.Run("Patch Event", func(t *testing.T) {
-		_, err := eventsDB.Add(&AddEvent{
+		_, err := eventsDB.Add(context.Background(), &AddEvent{
 			Name:             "Test Event",
 			Date:             "25-04-2023",
 			Max_Participants: 100,
@@ -144,7 +146,7 @@ func TestEventQueries(t *testing.T) {
 		newDate := "25-05-2023"
 		updates := &UpdateEvent{Date: &newDate}
 
-		updatedEvent, err := eventsDB.Patch(1, updates)
+		updatedEvent, err := eventsDB.Patch(context.Background(), 1, updates)
 		if err != nil {
 			t.Errorf("Failed to update event: %v", err)
 			return
@@ -156,7 +158,7 @@ func TestEventQueries(t *testing.T) {
 	})
 
 	t.Run("Delete Event", func(t *testing.T) {
-		_, err := eventsDB.Add(&AddEvent{
+		_, err := eventsDB.Add(context.Background(), &AddEvent{
 			Name:             "Test Event",
 			Date:             "25-04-2023",
 			Max_Participants: 100,
@@ -169,13 +171,13 @@ func TestEventQueries(t *testing.T) {
 			t.Fatalf("Failed to add event: %v", err)
 		}
 
-		err = eventsDB.Delete(1)
+		err = eventsDB.Delete(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to delete event: %v", err)
 			return
 		}
 
-		_, err = eventsDB.FindByID(1)
+		_, err = eventsDB.FindByID(context.Background(), 1)
 		if err == nil {
 			t.Errorf("Expected error when finding deleted event, got nil")
 		}
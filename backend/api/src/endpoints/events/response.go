@@ -0,0 +1,38 @@
+package events
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// EventResponse is the JSON of an Event.
+type EventResponse struct {
+	Id               int       `json:"id"`
+	Name             string    `json:"name"`
+	Date             string    `json:"date"`
+	Max_Participants int       `json:"max_participants"`
+	Location_X       string    `json:"location_x"`
+	Location_Y       string    `json:"location_y"`
+	Type             string    `json:"type"`
+	CreatedAt        time.Time `json:"created_at"`
+	Employee_Id      int       `json:"employee_id"`
+}
+
+func (e Event) Response() EventResponse {
+	return EventResponse{
+		Id:               e.Id,
+		Name:             e.Name,
+		Date:             e.Date,
+		Max_Participants: e.Max_Participants,
+		Location_X:       e.Location_X,
+		Location_Y:       e.Location_Y,
+		Type:             e.Type,
+		CreatedAt:        e.CreatedAt,
+		Employee_Id:      e.Employee_Id,
+	}
+}
+
+func (e Event) change(action changes.Action) changes.Change {
+	return changes.New(changes.Events, action, e.Id, e.Response())
+}
@@ -0,0 +1,111 @@
+package imports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/importer"
+	"soul-connection.com/api/src/middleware"
+)
+
+const maxFileSize = 32 << 20
+
+type ImportsModel struct {
+	Importer interface {
+		Import(context.Context, string, io.Reader, importer.Options) (*importer.Report, error)
+	}
+	Audit *audit.Log
+}
+
+// Import accepts a csv file either as the raw request body or as the "file"
+// field of a multipart form. ?dry_run=true validates and reports without
+// saving anything and ?on_duplicate=skip|upsert decides what happens to rows
+// whose email is already taken. Only managers can import.
+func (model *ImportsModel) Import(res http.ResponseWriter, req *http.Request) {
+	if err := middleware.RequireManager(req, "Only managers can import files"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	entity := mux.Vars(req)["entity"]
+
+	opts := importer.Options{OnDuplicate: req.URL.Query().Get("on_duplicate")}
+	if dryRun := req.URL.Query().Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			apierror.Write(res, req, apierror.BadRequest("Invalid dry_run value, expected true or false"))
+			return
+		}
+		opts.DryRun = value
+	}
+
+	req.Body = http.MaxBytesReader(res, req.Body, maxFileSize)
+	file, err := csvFile(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid request body, expected a csv file"))
+		return
+	}
+	defer file.Close()
+
+	var report *importer.Report
+	if opts.DryRun {
+		report, err = model.Importer.Import(req.Context(), entity, file, opts)
+	} else {
+		err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+			if report, err = model.Importer.Import(ctx, entity, file, opts); err != nil {
+				return err
+			}
+			// NOTE: An import is recorded once with its counts, not once per row
+			return model.Audit.Record(ctx, changes.Entity(report.Entity), audit.Imported, 0, nil, map[string]int{
+				"total":    report.Total,
+				"inserted": report.Inserted,
+				"updated":  report.Updated,
+				"skipped":  report.Skipped,
+				"failed":   report.Failed,
+			})
+		})
+	}
+	var parseErr *csv.ParseError
+	switch {
+	case errors.Is(err, importer.ErrUnknownEntity):
+		apierror.Write(res, req, apierror.NotFound(err.Error()))
+		return
+	case errors.Is(err, importer.ErrUnknownPolicy), errors.Is(err, importer.ErrEmptyFile), errors.Is(err, importer.ErrInvalidHeader), errors.As(err, &parseErr):
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	case err != nil:
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(*report); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func csvFile(req *http.Request) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return req.Body, nil
+	}
+
+	if err := req.ParseMultipartForm(maxFileSize); err != nil {
+		return nil, err
+	}
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
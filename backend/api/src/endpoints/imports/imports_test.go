@@ -0,0 +1,125 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"soul-connection.com/api/src/importer"
+	"soul-connection.com/api/src/middleware"
+)
+
+type MockImporter struct {
+	File string
+	Opts importer.Options
+}
+
+func (m *MockImporter) Import(ctx context.Context, entity string, r io.Reader, opts importer.Options) (*importer.Report, error) {
+	if entity != "customers" {
+		return nil, importer.ErrUnknownEntity
+	}
+	if opts.OnDuplicate == "merge" {
+		return nil, importer.ErrUnknownPolicy
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	m.File, m.Opts = string(content), opts
+	return &importer.Report{Entity: entity, DryRun: opts.DryRun, Total: 1, Inserted: 1}, nil
+}
+
+func asManager(req *http.Request) *http.Request {
+	return req.WithContext(middleware.WithUser(req.Context(), &middleware.User{Id: 1, Work: "Manager"}))
+}
+
+func TestImport(t *testing.T) {
+	mock := &MockImporter{}
+	model := &ImportsModel{Importer: mock}
+	file := "email,name,surname\nzoe@test.com,Zoé,Durand\n"
+
+	t.Run("Raw Body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/import/customers?dry_run=true&on_duplicate=upsert", strings.NewReader(file))
+		req.Header.Set("Content-Type", "text/csv")
+		req = asManager(mux.SetURLVars(req, map[string]string{"entity": "customers"}))
+		rr := httptest.NewRecorder()
+		model.Import(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		var report importer.Report
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		if !report.DryRun || mock.Opts.OnDuplicate != importer.Upsert || mock.File != file {
+			t.Errorf("Unexpected import %+v with %+v", report, mock.Opts)
+		}
+	})
+
+	t.Run("Multipart Form", func(t *testing.T) {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "customers.csv")
+		part.Write([]byte(file))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/import/customers", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req = asManager(mux.SetURLVars(req, map[string]string{"entity": "customers"}))
+		rr := httptest.NewRecorder()
+		model.Import(rr, req)
+
+		if rr.Code != http.StatusOK || mock.File != file || mock.Opts.DryRun {
+			t.Errorf("Unexpected response %d, file %q", rr.Code, mock.File)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		url          string
+		entity       string
+		expectedCode int
+	}{
+		{"Unknown Entity", "/api/import/tips", "tips", http.StatusNotFound},
+		{"Unknown Policy", "/api/import/customers?on_duplicate=merge", "customers", http.StatusBadRequest},
+		{"Invalid Dry Run", "/api/import/customers?dry_run=maybe", "customers", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(file))
+			req = asManager(mux.SetURLVars(req, map[string]string{"entity": tt.entity}))
+			rr := httptest.NewRecorder()
+			model.Import(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, rr.Code)
+			}
+		})
+	}
+
+	t.Run("Managers Only", func(t *testing.T) {
+		mock.File = ""
+		for user, expectedCode := range map[*middleware.User]int{nil: http.StatusUnauthorized, {Id: 2, Work: "Coach"}: http.StatusForbidden} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/import/customers", strings.NewReader(file))
+			req = mux.SetURLVars(req, map[string]string{"entity": "customers"})
+			if user != nil {
+				req = req.WithContext(middleware.WithUser(req.Context(), user))
+			}
+			rr := httptest.NewRecorder()
+			model.Import(rr, req)
+
+			if rr.Code != expectedCode || mock.File != "" {
+				t.Errorf("Expected status %d without import, got %d", expectedCode, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var marshalerType = reflect.TypeFor[json.Marshaler]()
+
+// legacyJSON keeps the deprecated routes answering the JSON they did before
+// the contract of /api/v1, keyed by the names of the Go fields: request bodies
+// are renamed to the snake_case fields of route.Request and JSON responses are
+// decoded as route.Response and written back with the Go names.
+func legacyJSON(route Endpoint, next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if route.Request != nil && req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				next(res, req)
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(renameKeys(body, reflect.TypeOf(route.Request))))
+		}
+		if route.Response == nil {
+			next(res, req)
+			return
+		}
+
+		w := &legacyWriter{ResponseWriter: res}
+		next(w, req)
+		if !w.buffered {
+			return
+		}
+
+		content := w.buffer.Bytes()
+		if w.status < http.StatusBadRequest {
+			value := reflect.New(reflect.TypeOf(route.Response))
+			if err := json.Unmarshal(content, value.Interface()); err == nil {
+				if legacy, err := json.Marshal(goNames(value.Elem())); err == nil {
+					content = append(legacy, '\n')
+				}
+			}
+		}
+		res.Header().Del("Content-Length")
+		res.WriteHeader(w.status)
+		res.Write(content)
+	}
+}
+
+// renameKeys renames the keys of a JSON object matching the name of a field of
+// t, regardless of case, to the JSON name of that field. Anything else is left
+// for the handler to reject.
+func renameKeys(body []byte, t reflect.Type) []byte {
+	var object map[string]json.RawMessage
+	if t.Kind() != reflect.Struct || json.Unmarshal(body, &object) != nil {
+		return body
+	}
+
+	names := map[string]string{}
+	for _, field := range reflect.VisibleFields(t) {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.IsExported() && name != "" && name != "-" {
+			names[strings.ToLower(field.Name)] = name
+		}
+	}
+	renamed := make(map[string]json.RawMessage, len(object))
+	for key, value := range object {
+		if name, ok := names[strings.ToLower(key)]; ok {
+			key = name
+		}
+		renamed[key] = value
+	}
+	content, err := json.Marshal(renamed)
+	if err != nil {
+		return body
+	}
+	return content
+}
+
+// goNames turns v into maps keyed by the names of the Go fields, values with
+// their own MarshalJSON like time.Time or an Amount are kept as they are.
+func goNames(v reflect.Value) any {
+	if v.Type().Implements(marshalerType) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return goNames(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = goNames(v.Index(i))
+		}
+		return list
+	case reflect.Struct:
+		object := map[string]any{}
+		for _, field := range reflect.VisibleFields(v.Type()) {
+			if !field.IsExported() || field.Anonymous || field.Tag.Get("json") == "-" {
+				continue
+			}
+			object[field.Name] = goNames(v.FieldByIndex(field.Index))
+		}
+		return object
+	}
+	return v.Interface()
+}
+
+// legacyWriter holds back JSON responses so they can be rewritten, anything
+// else, like an image or a csv export, goes straight through.
+type legacyWriter struct {
+	http.ResponseWriter
+	status   int
+	decided  bool
+	buffered bool
+	buffer   bytes.Buffer
+}
+
+func (w *legacyWriter) WriteHeader(status int) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	w.status = status
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.buffered = mediaType == "application/json"
+	if !w.buffered {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *legacyWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffered {
+		return w.buffer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *legacyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
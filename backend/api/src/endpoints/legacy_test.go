@@ -0,0 +1,67 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/health"
+)
+
+type MockPaymentsDB struct {
+	payments.PaymentsRepository
+}
+
+func (m *MockPaymentsDB) Add(ctx context.Context, payment *payments.AddPayment) (*payments.Payment, error) {
+	return &payments.Payment{Id: 1, Date: payment.Date, PaymentMethod: payment.PaymentMethod, Amount: payment.Amount, CustomerId: payment.CustomerId}, nil
+}
+
+func TestLegacyJSON(t *testing.T) {
+	router, err := NewRouter(&config.Config{}, Models{Payments: payments.PaymentModel{Payments: &MockPaymentsDB{}}}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	post := func(path string, body string) map[string]any {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if res.Code != http.StatusOK {
+			t.Fatalf("Expected 200 from %s, got %d: %s", path, res.Code, res.Body.String())
+		}
+		var object map[string]any
+		if err := json.Unmarshal(res.Body.Bytes(), &object); err != nil {
+			t.Fatalf("Expected a JSON object: %v", err)
+		}
+		return object
+	}
+
+	t.Run("Versioned", func(t *testing.T) {
+		payment := post("/api/v1/payments", `{"date": "2024-05-01", "payment_method": "PayPal", "amount": 12.5, "customer_id": 3}`)
+		if payment["payment_method"] != "PayPal" || payment["customer_id"] != 3.0 || payment["amount"] != 12.5 {
+			t.Errorf("Expected snake_case fields, got %v", payment)
+		}
+	})
+
+	t.Run("Deprecated", func(t *testing.T) {
+		payment := post("/api/payments", `{"Date": "2024-05-01", "PaymentMethod": "PayPal", "Amount": 12.5, "CustomerId": 3}`)
+		if payment["PaymentMethod"] != "PayPal" || payment["CustomerId"] != 3.0 || payment["Amount"] != 12.5 || payment["CreatedAt"] == nil {
+			t.Errorf("Expected the Go names of the fields, got %v", payment)
+		}
+		if _, ok := payment["payment_method"]; ok {
+			t.Errorf("Expected no snake_case field, got %v", payment)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/api/payments", strings.NewReader(`{"Date": "2024-05-01"}`)))
+		if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), `"validation_failed"`) {
+			t.Errorf("Expected the error envelope untouched, got %d %s", res.Code, res.Body.String())
+		}
+	})
+}
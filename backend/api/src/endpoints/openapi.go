@@ -0,0 +1,184 @@
+package endpoints
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"unicode"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/openapi"
+)
+
+var (
+	pathParameter = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+	binary = &openapi.Schema{Type: "string", Format: "binary"}
+
+	formatQuery         = openapi.Query("format", "Export format, the Accept header is used when missing", &openapi.Schema{Type: "string", Enum: []string{"json", "csv", "xlsx"}})
+	fromDateQuery       = openapi.Query("from", "First day included, YYYY-MM-DD", &openapi.Schema{Type: "string", Format: "date"})
+	toDateQuery         = openapi.Query("to", "Last day included, YYYY-MM-DD", &openapi.Schema{Type: "string", Format: "date"})
+	fromMonthQuery      = openapi.Query("from", "First month included, YYYY-MM", &openapi.Schema{Type: "string"})
+	toMonthQuery        = openapi.Query("to", "Last month included, YYYY-MM", &openapi.Schema{Type: "string"})
+	storeQuery          = openapi.Query("store", "Keep a copy of the generated document", &openapi.Schema{Type: "boolean"})
+	documentTypeQuery   = openapi.Query("type", "Kind of document, receipt by default", &openapi.Schema{Type: "string", Enum: []string{"receipt", "invoice"}})
+	dryRunQuery         = openapi.Query("dry_run", "Validate and report without saving anything", &openapi.Schema{Type: "boolean"})
+	onDuplicateQuery    = openapi.Query("on_duplicate", "What happens to rows whose email is already taken", &openapi.Schema{Type: "string", Enum: []string{"skip", "upsert"}})
+	entitiesQuery       = openapi.Query("entities", "Entities followed, comma separated like customers,payments, every one when missing", &openapi.Schema{Type: "string"})
+	auditEntityQuery    = openapi.Query("entity", "Entity written, like customers", &openapi.Schema{Type: "string"})
+	auditEntityIdQuery  = openapi.Query("entity_id", "Id of the row written", &openapi.Schema{Type: "integer"})
+	auditActorQuery     = openapi.Query("actor_id", "Upstream id of the employee who wrote", &openapi.Schema{Type: "integer"})
+	auditActionQuery    = openapi.Query("action", "What was done", &openapi.Schema{Type: "string", Enum: []string{"created", "updated", "deleted", "imported", "restored", "purged", "erased"}})
+	phoneNumberQuery    = openapi.Query("phone_number", "Only the customers with exactly this phone number", &openapi.Schema{Type: "string"})
+	includeDeletedQuery = openapi.Query("include_deleted", "Include the deleted rows, managers only", &openapi.Schema{Type: "boolean"})
+	beforeIdQuery       = openapi.Query("before_id", "Only entries older than this one, to page through the log", &openapi.Schema{Type: "integer"})
+	limitQuery          = openapi.Query("limit", "Most entries answered, 100 by default and 1000 at most", &openapi.Schema{Type: "integer"})
+
+	// deleted is the body answered by every DELETE route
+	deleted = struct {
+		Status string `json:"status"`
+	}{}
+)
+
+// document describes routes as an OpenAPI document, each base path being a
+// tag and the schemas coming from the Request and Response of the endpoints.
+func document(routes []ModelRoutes) *openapi.Document {
+	schemas := openapi.NewSchemas()
+	failure := openapi.Response{
+		Description: "Error",
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: schemas.For(apierror.Envelope{})}},
+	}
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Soul Connection API",
+			Description: "Field names are written exactly as in the schemas below.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]openapi.PathItem{},
+	}
+	for _, group := range routes {
+		tag := strings.TrimPrefix(strings.TrimPrefix(group.BasePath, versionPrefix), "/api")
+		tag = strings.TrimPrefix(tag, "/")
+		if tag == "" {
+			tag = "system"
+		}
+		if !slices.ContainsFunc(doc.Tags, func(t openapi.Tag) bool { return t.Name == tag }) {
+			doc.Tags = append(doc.Tags, openapi.Tag{Name: tag})
+		}
+
+		for _, route := range group.Routes {
+			path := group.BasePath + route.Path
+			operation := &openapi.Operation{
+				OperationId: operationId(route.Method, path, group.Deprecated),
+				Summary:     route.Summary,
+				Tags:        []string{tag},
+				Deprecated:  group.Deprecated,
+				Parameters:  parameters(path),
+				Responses:   map[string]openapi.Response{"200": response(schemas, route), "default": failure},
+			}
+			if operation.Summary == "" {
+				operation.Summary = summary(route.Handler)
+			}
+			if group.Deprecated {
+				operation.Description = "Use " + versionPrefix + strings.TrimPrefix(path, "/api") + " instead, the bodies of this route are keyed by the Go names of the fields, like CreatedAt for created_at."
+			}
+			if route.Export {
+				operation.Parameters = append(operation.Parameters, formatQuery)
+			}
+			operation.Parameters = append(operation.Parameters, route.Query...)
+
+			switch {
+			case route.Consumes != "":
+				operation.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{route.Consumes: {Schema: binary}}}
+			case route.Request != nil:
+				operation.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{"application/json": {Schema: schemas.For(route.Request)}}}
+			}
+
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = openapi.PathItem{}
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = operation
+		}
+	}
+	doc.Components.Schemas = schemas.Components
+	return doc
+}
+
+func response(schemas *openapi.Schemas, route Endpoint) openapi.Response {
+	content := map[string]openapi.MediaType{}
+	switch {
+	case route.Produces != "":
+		content[route.Produces] = openapi.MediaType{Schema: binary}
+	case route.Response != nil:
+		content["application/json"] = openapi.MediaType{Schema: schemas.For(route.Response)}
+	}
+	if route.Export {
+		content["text/csv"] = openapi.MediaType{Schema: binary}
+		content["application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"] = openapi.MediaType{Schema: binary}
+	}
+	if len(content) == 0 {
+		return openapi.Response{Description: "Success"}
+	}
+	return openapi.Response{Description: "Success", Content: content}
+}
+
+// parameters lists the variables of a mux path template, ids are integers.
+func parameters(path string) []openapi.Parameter {
+	var result []openapi.Parameter
+	for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+		schema := &openapi.Schema{Type: "string"}
+		if strings.HasSuffix(match[1], "_id") {
+			schema = &openapi.Schema{Type: "integer", Format: "int32"}
+		}
+		result = append(result, openapi.Parameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	return result
+}
+
+// operationId names a route after its method and path, which makes it
+// unique: GET /api/v1/customers/{customer_id}/image is
+// getCustomersByCustomerIdImage, the deprecated /api/customers/{customer_id}/image
+// is getCustomersByCustomerIdImageDeprecated.
+func operationId(method string, path string, deprecated bool) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	path = strings.TrimPrefix(path, versionPrefix)
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		if match := pathParameter.FindStringSubmatch(segment); match != nil {
+			b.WriteString("By")
+			segment = match[1]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if deprecated {
+		b.WriteString("Deprecated")
+	}
+	return b.String()
+}
+
+// summary turns the name of a method value like GetAllCustomers into
+// "Get all customers", other handlers have none.
+func summary(handler http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	if !strings.HasSuffix(name, "-fm") {
+		return ""
+	}
+	name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune(' ')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package endpoints
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/health"
+	"soul-connection.com/api/src/openapi"
+)
+
+func setupRouter(t *testing.T) http.Handler {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// NOTE: The client connects lazily, nothing here talks to a server
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatalf("Failed to create mongo client: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	router, err := CreateRouter(&config.Config{}, db, client.Database("test"), changes.NewBus(), &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	return router
+}
+
+func TestOpenAPI(t *testing.T) {
+	router := setupRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil)
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", res.Code)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(res.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a JSON document: %v", err)
+	}
+
+	t.Run("Every Route", func(t *testing.T) {
+		patch := doc.Paths["/api/v1/customers/{customer_id}"]["patch"]
+		if doc.OpenAPI != openapi.Version || patch == nil || patch.OperationId != "patchCustomersByCustomerId" || patch.Summary != "Patch customer" {
+			t.Fatalf("Expected the PATCH route of customers, got %+v", patch)
+		}
+		if len(patch.Parameters) != 1 || patch.Parameters[0].Name != "customer_id" || patch.Parameters[0].Schema.Type != "integer" {
+			t.Errorf("Expected an integer customer_id parameter, got %+v", patch.Parameters)
+		}
+		if ref := patch.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/UpdateCustomer" {
+			t.Errorf("Expected an UpdateCustomer body, got %q", ref)
+		}
+		if _, ok := doc.Paths["/api/v1/payments/{payment_id}/document"]["get"].Responses["200"].Content["application/pdf"]; !ok {
+			t.Errorf("Expected a pdf response")
+		}
+	})
+
+	t.Run("Deprecated Aliases", func(t *testing.T) {
+		legacy := doc.Paths["/api/customers/{customer_id}"]["patch"]
+		if legacy == nil || !legacy.Deprecated || legacy.OperationId != "patchCustomersByCustomerIdDeprecated" || legacy.Summary != "Patch customer" {
+			t.Fatalf("Expected the deprecated PATCH route of customers, got %+v", legacy)
+		}
+		if doc.Paths["/api/v1/customers/{customer_id}"]["patch"].Deprecated {
+			t.Errorf("Expected the versioned route not to be deprecated")
+		}
+		if erase := doc.Paths["/api/customers/{customer_id}/erase"]["post"]; erase == nil || !erase.Deprecated {
+			t.Errorf("Expected the authenticated routes to have aliases as well, got %+v", erase)
+		}
+		if _, ok := doc.Paths["/api/v1/customers/{customer_id}/export"]["get"].Responses["200"].Content["application/zip"]; !ok {
+			t.Errorf("Expected a zip response")
+		}
+	})
+
+	t.Run("Field Casing", func(t *testing.T) {
+		customer := doc.Components.Schemas["CustomerResponse"]
+		if customer == nil || customer.Properties["soul_connection_id"] == nil || !customer.Properties["soul_connection_id"].Nullable {
+			t.Fatalf("Expected a nullable soul_connection_id, got %+v", customer)
+		}
+		if customer.Properties["created_at"].Format != "date-time" {
+			t.Errorf("Expected created_at as a date-time, got %+v", customer.Properties["created_at"])
+		}
+		if customer.Properties["image_id"] != nil || customer.Properties["has_image"] == nil {
+			t.Errorf("Expected has_image instead of image_id, got %+v", customer.Properties)
+		}
+		if employee := doc.Components.Schemas["EmployeeResponse"]; employee.Properties["password"] != nil {
+			t.Errorf("Expected the password to be hidden, got %+v", employee.Properties)
+		}
+		add := doc.Components.Schemas["AddCustomer"]
+		if strings.Join(add.Required, ",") == "" || add.Properties["email"].Format != "email" || len(add.Properties["gender"].Enum) != 3 {
+			t.Errorf("Expected the validation rules on AddCustomer, got %+v", add)
+		}
+		if amount := doc.Components.Schemas["PaymentResponse"].Properties["amount"]; amount.Type != "number" {
+			t.Errorf("Expected amounts as numbers, got %+v", amount)
+		}
+	})
+
+	t.Run("Error Envelope", func(t *testing.T) {
+		envelope := doc.Components.Schemas["Envelope"]
+		if envelope == nil || envelope.Properties["error"] == nil || doc.Components.Schemas["Error"].Properties["request_id"] == nil {
+			t.Errorf("Expected the error envelope, got %+v", envelope)
+		}
+	})
+}
+
+func TestDeprecatedRoutes(t *testing.T) {
+	router := setupRouter(t)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res
+	}
+
+	// NOTE: Both fail on the bad id before touching the database
+	legacy := serve("/api/customers/abc")
+	if legacy.Header().Get("Deprecation") == "" || legacy.Header().Get("Link") != `</api/v1/customers/abc>; rel="successor-version"` {
+		t.Errorf("Expected the deprecation headers, got %v", legacy.Header())
+	}
+	if legacy.Code != http.StatusBadRequest {
+		t.Errorf("Expected the alias to reach the same handler, got %d", legacy.Code)
+	}
+	if versioned := serve("/api/v1/customers/abc"); versioned.Header().Get("Deprecation") != "" || versioned.Code != http.StatusBadRequest {
+		t.Errorf("Expected the versioned route without deprecation, got %d %v", versioned.Code, versioned.Header())
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	router := setupRouter(t)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+		return res
+	}
+
+	if res := serve("/api/docs"); res.Code != http.StatusMovedPermanently || res.Header().Get("Location") != "/api/docs/" {
+		t.Errorf("Expected a redirect to /api/docs/, got %d %v", res.Code, res.Header())
+	}
+	if res := serve("/api/docs/"); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "swagger-ui") {
+		t.Errorf("Expected the Swagger UI page, got %d", res.Code)
+	}
+	if res := serve("/api/docs/swagger-initializer.js"); !strings.Contains(res.Body.String(), "../openapi.json") {
+		t.Errorf("Expected the initializer to load the api document, got %q", res.Body.String())
+	}
+	if res := serve("/api/docs/swagger-ui-bundle.js"); res.Code != http.StatusOK {
+		t.Errorf("Expected the bundle, got %d", res.Code)
+	}
+}
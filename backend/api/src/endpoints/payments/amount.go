@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"soul-connection.com/api/src/openapi"
+)
+
+// Amount is a monetary value stored as an exact number of cents. It is read
+// and written in JSON as a decimal number (12.34) so clients keep the same
+// format they had with floats, without the rounding errors.
+type Amount int64
+
+func AmountFromFloat(f float64) Amount {
+	return Amount(math.Round(f * 100))
+}
+
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (a Amount) OpenAPISchema() *openapi.Schema {
+	return &openapi.Schema{Type: "number", Format: "decimal", Description: "Exact amount with at most two decimals"}
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var literal json.Number
+	if err := json.Unmarshal(data, &literal); err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(literal.String()))
+	if !ok {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	cents := value.Mul(value, big.NewRat(100, 1))
+	if !cents.IsInt() {
+		return fmt.Errorf("amount %s has more than two decimals", data)
+	}
+	if !cents.Num().IsInt64() {
+		return fmt.Errorf("amount %s is out of range", data)
+	}
+	*a = Amount(cents.Num().Int64())
+	return nil
+}
+
+// RoundAmount reads a decimal literal of any precision, rounding it half to
+// even to the cent. It is meant for the amounts of the upstream api, which
+// UnmarshalJSON would refuse past two decimals.
+func RoundAmount(literal string) (Amount, error) {
+	cents, ok := new(big.Rat).SetString(strings.TrimSpace(literal))
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %s", literal)
+	}
+	cents.Mul(cents, big.NewRat(100, 1))
+
+	// NOTE: DivMod floors, the remainder is then compared to half a cent
+	rounded, remainder := new(big.Int).DivMod(cents.Num(), cents.Denom(), new(big.Int))
+	switch remainder.Lsh(remainder, 1).Cmp(cents.Denom()) {
+	case 1:
+		rounded.Add(rounded, big.NewInt(1))
+	case 0:
+		if rounded.Bit(0) == 1 {
+			rounded.Add(rounded, big.NewInt(1))
+		}
+	}
+	if !rounded.IsInt64() {
+		return 0, fmt.Errorf("amount %s is out of range", literal)
+	}
+	return Amount(rounded.Int64()), nil
+}
@@ -1,48 +1,113 @@
 package payments
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/validation"
 )
 
 type Payment struct {
-	Id                 int
-	Soul_Connection_Id *int
-	Date               string
-	PaymentMethod      string
-	Amount             float64
-	Comment            string
-	CreatedAt          time.Time
-	CustomerId         int
+	Id                 int       `json:"id" db:"id"`
+	Soul_Connection_Id *int      `json:"soul_connection_id" db:"soul_connection_id"`
+	Date               string    `json:"date" db:"date"`
+	PaymentMethod      string    `json:"payment_method" db:"payment_method"`
+	Amount             Amount    `json:"amount" db:"amount"`
+	Comment            string    `json:"comment" db:"comment"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	CustomerId         int       `json:"customer_id" db:"customer_id"`
+}
+
+type CustomerRevenue struct {
+	Customer_Id    *int   `json:"customer_id"`
+	Payments       int    `json:"payments"`
+	Gross          Amount `json:"gross"`
+	Refunds        Amount `json:"refunds"`
+	Lifetime_Value Amount `json:"lifetime_value"`
+	First_Payment  string `json:"first_payment"`
+	Last_Payment   string `json:"last_payment"`
+}
+
+type EmployeeRevenue struct {
+	Employee_Id *int   `json:"employee_id"`
+	Customers   int    `json:"customers"`
+	Payments    int    `json:"payments"`
+	Gross       Amount `json:"gross"`
+	Refunds     Amount `json:"refunds"`
+	Net         Amount `json:"net"`
+}
+
+type MonthlyRevenue struct {
+	Month    string `json:"month"`
+	Payments int    `json:"payments"`
+	Gross    Amount `json:"gross"`
+	Refunds  Amount `json:"refunds"`
+	Net      Amount `json:"net"`
+}
+
+type MethodRevenue struct {
+	Payment_Method string `json:"payment_method"`
+	Payments       int    `json:"payments"`
+	Gross          Amount `json:"gross"`
+	Refunds        Amount `json:"refunds"`
+	Net            Amount `json:"net"`
+}
+
+// PaymentsRepository is the storage behind PaymentModel.
+type PaymentsRepository interface {
+	FindAll(context.Context) ([]Payment, error)
+	StreamAll(context.Context, func(Payment) error) error
+	FindByID(context.Context, int) (*Payment, error)
+	FindByCustomerID(context.Context, int) ([]Payment, error)
+	FindByCustomerIDs(context.Context, []int) ([]Payment, error)
+	StreamByCustomerID(context.Context, int, func(Payment) error) error
+	Add(context.Context, *AddPayment) (*Payment, error)
+	Delete(context.Context, int) error
+	Patch(context.Context, int, *UpdatePayment) (*Payment, error)
+	RevenueByCustomer(context.Context) ([]CustomerRevenue, error)
+	RevenueForCustomer(context.Context, int) (*CustomerRevenue, error)
+	RevenueByEmployee(context.Context) ([]EmployeeRevenue, error)
+	RevenueByMonth(context.Context, string, string) ([]MonthlyRevenue, error)
+	RevenueByMethod(context.Context) ([]MethodRevenue, error)
 }
 
 type PaymentModel struct {
-	Payments interface {
-		FindAll() ([]Payment, error)
-		FindByID(int) (*Payment, error)
-		FindByCustomerID(int) ([]Payment, error)
-		Add(*AddPayment) (*Payment, error)
-		Delete(int) error
-		Patch(int, *UpdatePayment) (*Payment, error)
-	}
+	Payments PaymentsRepository
+	Changes  *changes.Bus
+	Audit    *audit.Log
 }
 
-func (model *PaymentModel) GetAllPayments(res http.ResponseWriter, _ *http.Request) {
-	payment, err := model.Payments.FindAll()
+func (model *PaymentModel) GetAllPayments(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "payments", func(fn func(PaymentResponse) error) error {
+			return model.Payments.StreamAll(req.Context(), func(item Payment) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	payment, err := model.Payments.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(payment); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(payment, Payment.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -50,22 +115,19 @@ func (model *PaymentModel) GetAllPayments(res http.ResponseWriter, _ *http.Reque
 func (model *PaymentModel) GetPaymentsById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "payment_id")
 	if err != nil {
-		http.Error(res, "Invalid payment ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid payment ID format"))
 		return
 	}
 
-	payment, err := model.Payments.FindByID(id)
+	payment, err := model.Payments.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Payment not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*payment); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(payment.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -73,46 +135,57 @@ func (model *PaymentModel) GetPaymentsById(res http.ResponseWriter, req *http.Re
 func (model *PaymentModel) GetPaymentsByCustomerId(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "customer_id")
 	if err != nil {
-		http.Error(res, "Invalid customer ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, fmt.Sprintf("customer_%d_payments", id), func(fn func(PaymentResponse) error) error {
+			return model.Payments.StreamByCustomerID(req.Context(), id, func(item Payment) error { return fn(item.Response()) })
+		})
 		return
 	}
 
-	payment, err := model.Payments.FindByCustomerID(id)
+	payment, err := model.Payments.FindByCustomerID(req.Context(), id)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(payment); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(payment, Payment.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *PaymentModel) AddPayment(res http.ResponseWriter, req *http.Request) {
 	var nc AddPayment
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	event, err := model.Payments.Add(&nc)
+	var event *Payment
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if event, err = model.Payments.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Payments, audit.Created, event.Id, nil, event.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add event", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), event.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*event); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(event.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -120,21 +193,31 @@ func (model *PaymentModel) AddPayment(res http.ResponseWriter, req *http.Request
 func (model *PaymentModel) DeletePayment(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "payment_id")
 	if err != nil {
-		http.Error(res, "Invalid payment ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid payment ID format"))
 		return
 	}
 
-	err = model.Payments.Delete(id)
+	// NOTE: The row is read first, it goes in the audit and tells which coach may hear of it
+	payment, err := model.Payments.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to delete payment", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
 	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Payments.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Payments, audit.Deleted, id, payment.Response(), nil)
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Payments, changes.Deleted, id, nil).ForCustomer(payment.CustomerId))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -142,30 +225,129 @@ func (model *PaymentModel) DeletePayment(res http.ResponseWriter, req *http.Requ
 func (model *PaymentModel) PatchPayment(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "payment_id")
 	if err != nil {
-		http.Error(res, "Invalid payment ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid payment ID format"))
 		return
 	}
 
 	var updates UpdatePayment
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Payments.FindByID(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var updatedPayment *Payment
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedPayment, err = model.Payments.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Payments, audit.Updated, id, previous.Response(), updatedPayment.Response())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), updatedPayment.change(changes.Updated))
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(updatedPayment.Response()); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *PaymentModel) GetRevenueByCustomer(res http.ResponseWriter, req *http.Request) {
+	revenue, err := model.Payments.RevenueByCustomer(req.Context())
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(revenue); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *PaymentModel) GetRevenueForCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+
+	revenue, err := model.Payments.RevenueForCustomer(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(*revenue); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *PaymentModel) GetRevenueByEmployee(res http.ResponseWriter, req *http.Request) {
+	revenue, err := model.Payments.RevenueByEmployee(req.Context())
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(revenue); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *PaymentModel) GetRevenueByMonth(res http.ResponseWriter, req *http.Request) {
+	from := req.URL.Query().Get("from")
+	to := req.URL.Query().Get("to")
+	if !isMonth(from) || !isMonth(to) {
+		apierror.Write(res, req, apierror.BadRequest("Invalid month format, expected YYYY-MM"))
+		return
+	}
+
+	revenue, err := model.Payments.RevenueByMonth(req.Context(), from, to)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedPayment, err := model.Payments.Patch(id, &updates)
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(revenue); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
+
+func (model *PaymentModel) GetRevenueByMethod(res http.ResponseWriter, req *http.Request) {
+	revenue, err := model.Payments.RevenueByMethod(req.Context())
 	if err != nil {
-		http.Error(res, "Unable to update payment", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedPayment); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(revenue); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
+
+func isMonth(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01", value)
+	return err == nil
+}
@@ -2,10 +2,13 @@ package payments
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -16,33 +19,47 @@ type MockPaymentsDB struct {
 	Err      error
 }
 
-func (m *MockPaymentsDB) FindAll() ([]Payment, error) {
+func (m *MockPaymentsDB) FindAll(ctx context.Context) ([]Payment, error) {
 	if m.Payments == nil {
 		return nil, errors.New("no payments found")
 	}
 	return m.Payments, nil
 }
 
-func (m *MockPaymentsDB) FindByID(id int) (*Payment, error) {
+func (m *MockPaymentsDB) FindByID(ctx context.Context, id int) (*Payment, error) {
 	for _, payment := range m.Payments {
 		if payment.Id == id {
 			return &payment, nil
 		}
 	}
-	return nil, errors.New("payment not found")
+	return nil, sql.ErrNoRows
 }
 
-func (m *MockPaymentsDB) FindByCustomerID(id int) ([]Payment, error) {
+func (m *MockPaymentsDB) FindByCustomerID(ctx context.Context, id int) ([]Payment, error) {
 	var p []Payment
 	for _, payment := range m.Payments {
 		if payment.CustomerId == id {
 			p = append(p, payment)
 		}
 	}
+	// NOTE: The mock has no customers, one without payments is unknown
+	if p == nil {
+		return nil, sql.ErrNoRows
+	}
 	return p, nil
 }
 
-func (m *MockPaymentsDB) Add(payment *AddPayment) (*Payment, error) {
+func (m *MockPaymentsDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]Payment, error) {
+	var p []Payment
+	for _, payment := range m.Payments {
+		if slices.Contains(ids, payment.CustomerId) {
+			p = append(p, payment)
+		}
+	}
+	return p, nil
+}
+
+func (m *MockPaymentsDB) Add(ctx context.Context, payment *AddPayment) (*Payment, error) {
 	newPayment := Payment{
 		Id:                 len(m.Payments) + 1,
 		Soul_Connection_Id: payment.Soul_Connection_Id,
@@ -59,17 +76,17 @@ func (m *MockPaymentsDB) Add(payment *AddPayment) (*Payment, error) {
 	return &newPayment, nil
 }
 
-func (m *MockPaymentsDB) Delete(id int) error {
+func (m *MockPaymentsDB) Delete(ctx context.Context, id int) error {
 	for i, payment := range m.Payments {
 		if payment.Id == id {
 			m.Payments = append(m.Payments[:i], m.Payments[i+1:]...)
 			return nil
 		}
 	}
-	return errors.New("payment not found")
+	return sql.ErrNoRows
 }
 
-func (m *MockPaymentsDB) Patch(id int, updates *UpdatePayment) (*Payment, error) {
+func (m *MockPaymentsDB) Patch(ctx context.Context, id int, updates *UpdatePayment) (*Payment, error) {
 	for i, payment := range m.Payments {
 		if payment.Id == id {
 			if updates.Payment_Method != nil {
@@ -81,7 +98,81 @@ func (m *MockPaymentsDB) Patch(id int, updates *UpdatePayment) (*Payment, error)
 			return &m.Payments[i], nil
 		}
 	}
-	return nil, errors.New("payment not found")
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockPaymentsDB) RevenueByCustomer(ctx context.Context) ([]CustomerRevenue, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	byCustomer := make(map[int]*CustomerRevenue)
+	var revenue []CustomerRevenue
+	for _, payment := range m.Payments {
+		r, ok := byCustomer[payment.CustomerId]
+		if !ok {
+			id := payment.CustomerId
+			r = &CustomerRevenue{Customer_Id: &id}
+			byCustomer[id] = r
+		}
+		r.Payments++
+		r.Lifetime_Value += payment.Amount
+	}
+	for _, r := range byCustomer {
+		revenue = append(revenue, *r)
+	}
+	return revenue, nil
+}
+
+func (m *MockPaymentsDB) RevenueForCustomer(ctx context.Context, id int) (*CustomerRevenue, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	r := CustomerRevenue{Customer_Id: &id}
+	for _, payment := range m.Payments {
+		if payment.CustomerId == id {
+			r.Payments++
+			r.Lifetime_Value += payment.Amount
+		}
+	}
+	return &r, nil
+}
+
+func (m *MockPaymentsDB) RevenueByEmployee(ctx context.Context) ([]EmployeeRevenue, error) {
+	return nil, m.Err
+}
+
+func (m *MockPaymentsDB) RevenueByMonth(ctx context.Context, from string, to string) ([]MonthlyRevenue, error) {
+	return nil, m.Err
+}
+
+func (m *MockPaymentsDB) RevenueByMethod(ctx context.Context) ([]MethodRevenue, error) {
+	return nil, m.Err
+}
+
+func (m *MockPaymentsDB) StreamAll(ctx context.Context, fn func(Payment) error) error {
+	all, err := m.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range all {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockPaymentsDB) StreamByCustomerID(ctx context.Context, id int, fn func(Payment) error) error {
+	found, err := m.FindByCustomerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, v := range found {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func setupTestModel(mockDB *MockPaymentsDB) *PaymentModel {
@@ -120,27 +211,44 @@ func testAddPayment(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/payments", bytes.NewBuffer([]byte(`{invalid json}`)))
 		rr := httptest.NewRecorder()
 		model.AddPayment(rr, req)
-		checkResponseCode(t, rr, http.StatusInternalServerError)
+		checkResponseCode(t, rr, http.StatusBadRequest)
 	})
 
 	t.Run("Error Add Method", func(t *testing.T) {
 		model := setupTestModel(&MockPaymentsDB{Err: errors.New("database error")})
 		req := createRequest(t, http.MethodPost, "/api/payments", &AddPayment{
-			Date:          "25-04-2023",
-			PaymentMethod: "cash",
+			Date:          "2023-04-25",
+			PaymentMethod: "Credit Card",
 			Amount:        1.0,
 			Comment:       "This should cause an error",
 			CustomerId:    1,
 		})
 		rr := httptest.NewRecorder()
 		model.AddPayment(rr, req)
+		checkResponseCode(t, rr, http.StatusInternalServerError)
+	})
+
+	t.Run("Invalid Payment", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/payments", bytes.NewBufferString(`{"date": "2023-04-25", "payment_method": "Cheque", "amount": 12.5, "customer_id": "one"}`))
+		rr := httptest.NewRecorder()
+		model.AddPayment(rr, req)
 		checkResponseCode(t, rr, http.StatusBadRequest)
+
+		var body struct {
+			Error struct {
+				Details []struct{ Field, Message string }
+			}
+		}
+		decodeResponseBody(t, rr, &body)
+		if len(body.Error.Details) != 1 || body.Error.Details[0].Field != "customer_id" {
+			t.Errorf("Expected a customer_id type error, got %+v", body.Error.Details)
+		}
 	})
 
 	t.Run("Add Payment", func(t *testing.T) {
 		req := createRequest(t, http.MethodPost, "/api/payments", &AddPayment{
-			Date:          "25-04-2023",
-			PaymentMethod: "cash",
+			Date:          "2023-04-25",
+			PaymentMethod: "Credit Card",
 			Amount:        1.0,
 			Comment:       "This is a new payment",
 			CustomerId:    1,
@@ -161,8 +269,8 @@ func testAddPayment(t *testing.T) {
 func testGetAllPayments(t *testing.T) {
 	t.Run("Get All Payments", func(t *testing.T) {
 		model := setupTestModel(&MockPaymentsDB{})
-		model.Payments.Add(&AddPayment{Date: "25-04-2023", PaymentMethod: "cash", Amount: 1.0, Comment: "Payment 1", CustomerId: 1})
-		model.Payments.Add(&AddPayment{Date: "26-04-2023", PaymentMethod: "card", Amount: 2.0, Comment: "Payment 2", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-25", PaymentMethod: "Credit Card", Amount: 1.0, Comment: "Payment 1", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-26", PaymentMethod: "PayPal", Amount: 2.0, Comment: "Payment 2", CustomerId: 1})
 
 		req := createRequest(t, http.MethodGet, "/api/payments", nil)
 		rr := httptest.NewRecorder()
@@ -191,7 +299,7 @@ func testGetAllPayments(t *testing.T) {
 func testGetPaymentById(t *testing.T) {
 	t.Run("Get Payment by ID", func(t *testing.T) {
 		model := setupTestModel(&MockPaymentsDB{})
-		model.Payments.Add(&AddPayment{Date: "25-04-2023", PaymentMethod: "cash", Amount: 1.0, Comment: "Payment 1", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-25", PaymentMethod: "Credit Card", Amount: 1.0, Comment: "Payment 1", CustomerId: 1})
 
 		req := createRequest(t, http.MethodGet, "/api/payments/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"payment_id": "1"})
@@ -247,7 +355,7 @@ func testGetPaymentByCustomerId(t *testing.T) {
 func testDeletePayment(t *testing.T) {
 	t.Run("Delete Payment", func(t *testing.T) {
 		model := setupTestModel(&MockPaymentsDB{})
-		model.Payments.Add(&AddPayment{Date: "25-04-2023", PaymentMethod: "cash", Amount: 1.0, Comment: "Payment to delete", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-25", PaymentMethod: "Credit Card", Amount: 1.0, Comment: "Payment to delete", CustomerId: 1})
 
 		req := createRequest(t, http.MethodDelete, "/api/payments/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"payment_id": "1"})
@@ -270,7 +378,7 @@ func testDeletePayment(t *testing.T) {
 
 		model.DeletePayment(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Deleting Payment", func(t *testing.T) {
@@ -281,15 +389,15 @@ func testDeletePayment(t *testing.T) {
 
 		model.DeletePayment(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
 func testPatchPayments(t *testing.T) {
-	newMethod := "credit"
+	newMethod := "Bank Transfer"
 	t.Run("Patch Payment", func(t *testing.T) {
 		model := setupTestModel(&MockPaymentsDB{})
-		model.Payments.Add(&AddPayment{Date: "25-04-2023", PaymentMethod: "cash", Amount: 1.0, Comment: "Original Payment", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-25", PaymentMethod: "Credit Card", Amount: 1.0, Comment: "Original Payment", CustomerId: 1})
 
 		req := createRequest(t, http.MethodPatch, "/api/payments/1", &UpdatePayment{Payment_Method: &newMethod})
 		req = mux.SetURLVars(req, map[string]string{"payment_id": "1"})
@@ -315,7 +423,7 @@ func testPatchPayments(t *testing.T) {
 
 		model.PatchPayment(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Patching Payment", func(t *testing.T) {
@@ -326,8 +434,104 @@ func testPatchPayments(t *testing.T) {
 
 		model.PatchPayment(rr, req)
 
+		checkResponseCode(t, rr, http.StatusNotFound)
+	})
+}
+
+func testRevenue(t *testing.T) {
+	t.Run("Get Revenue For Customer", func(t *testing.T) {
+		model := setupTestModel(&MockPaymentsDB{})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-25", PaymentMethod: "Credit Card", Amount: 1050, Comment: "Payment", CustomerId: 1})
+		model.Payments.Add(context.Background(), &AddPayment{Date: "2023-04-26", PaymentMethod: "Credit Card", Amount: -50, Comment: "Refund", CustomerId: 1})
+
+		req := createRequest(t, http.MethodGet, "/api/payments/revenue/customers/1", nil)
+		req = mux.SetURLVars(req, map[string]string{"customer_id": "1"})
+		rr := httptest.NewRecorder()
+		model.GetRevenueForCustomer(rr, req)
+
+		checkResponseCode(t, rr, http.StatusOK)
+
+		var revenue map[string]interface{}
+		decodeResponseBody(t, rr, &revenue)
+
+		if revenue["lifetime_value"] != 10.0 {
+			t.Errorf("Expected lifetime value 10.00, got %v", revenue["lifetime_value"])
+		}
+	})
+
+	t.Run("Invalid Month Range", func(t *testing.T) {
+		model := setupTestModel(&MockPaymentsDB{})
+		req := createRequest(t, http.MethodGet, "/api/payments/revenue/months?from=2023-13", nil)
+		rr := httptest.NewRecorder()
+		model.GetRevenueByMonth(rr, req)
+
 		checkResponseCode(t, rr, http.StatusBadRequest)
 	})
+
+	t.Run("Error Fetching Revenue", func(t *testing.T) {
+		model := setupTestModel(&MockPaymentsDB{Err: errors.New("database error")})
+		req := createRequest(t, http.MethodGet, "/api/payments/revenue/employees", nil)
+		rr := httptest.NewRecorder()
+		model.GetRevenueByEmployee(rr, req)
+
+		checkResponseCode(t, rr, http.StatusInternalServerError)
+	})
+}
+
+func TestAmountJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+		valid    bool
+	}{
+		{`12.34`, 1234, true},
+		{`"12.30"`, 1230, true},
+		{`-0.5`, -50, true},
+		{`1e2`, 10000, true},
+		{`0.1`, 10, true},
+		{`1.005`, 0, false},
+		{`"abc"`, 0, false},
+	}
+
+	for _, tt := range tests {
+		var a Amount
+		err := json.Unmarshal([]byte(tt.input), &a)
+		if tt.valid && (err != nil || a != tt.expected) {
+			t.Errorf("Unmarshal(%s) = %d, %v; want %d", tt.input, a, err, tt.expected)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("Unmarshal(%s) expected an error, got %d", tt.input, a)
+		}
+	}
+
+	data, err := json.Marshal(Amount(-1205))
+	if err != nil || string(data) != "-12.05" {
+		t.Errorf("Marshal(-1205) = %s, %v; want -12.05", data, err)
+	}
+}
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+	}{
+		{"12.34", 1234},
+		{"1.005", 100},
+		{"1.015", 102},
+		{"1.0051", 101},
+		{"-0.125", -12},
+		{"-0.135", -14},
+		{"19.999", 2000},
+	}
+
+	for _, tt := range tests {
+		if a, err := RoundAmount(tt.input); err != nil || a != tt.expected {
+			t.Errorf("RoundAmount(%s) = %d, %v; want %d", tt.input, a, err, tt.expected)
+		}
+	}
+	if _, err := RoundAmount("abc"); err == nil {
+		t.Errorf("RoundAmount(abc) expected an error")
+	}
 }
 
 func TestPaymentsEndpoints(t *testing.T) {
@@ -337,4 +541,5 @@ func TestPaymentsEndpoints(t *testing.T) {
 	testGetPaymentByCustomerId(t)
 	testPatchPayments(t)
 	testDeletePayment(t)
+	testRevenue(t)
 }
@@ -1,10 +1,11 @@
 package payments
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"reflect"
-	"strings"
+
+	"soul-connection.com/api/src/database"
 )
 
 type PaymentsDB struct {
@@ -12,148 +13,258 @@ type PaymentsDB struct {
 }
 
 type AddPayment struct {
-	Soul_Connection_Id *int
-	Date               string
-	PaymentMethod      string
-	Amount             float64
-	Comment            string
-	CustomerId         int
+	Soul_Connection_Id *int   `json:"soul_connection_id" db:"soul_connection_id" validate:"min=1"`
+	Date               string `json:"date" db:"date" validate:"required,date"`
+	PaymentMethod      string `json:"payment_method" db:"payment_method" validate:"required,oneof=Credit Card|PayPal|Bank Transfer"`
+	Amount             Amount `json:"amount" db:"amount" validate:"required"`
+	Comment            string `json:"comment" db:"comment" validate:"max=255"`
+	CustomerId         int    `json:"customer_id" db:"customer_id" validate:"required,min=1"`
 }
 
 type UpdatePayment struct {
-	Date           *string
-	Payment_Method *string
-	Amount         *float64
-	Comment        *string
+	Date           *string `json:"date" db:"date" validate:"required,date"`
+	Payment_Method *string `json:"payment_method" db:"payment_method" validate:"required,oneof=Credit Card|PayPal|Bank Transfer"`
+	Amount         *Amount `json:"amount" db:"amount" validate:"required"`
+	Comment        *string `json:"comment" db:"comment" validate:"max=255"`
+}
+
+func repository(q database.Queryer) database.Repository[Payment] {
+	return database.Repository[Payment]{DB: q, Table: "payment"}
+}
+
+func (db PaymentsDB) FindAll(ctx context.Context) ([]Payment, error) {
+	return repository(db.DB).FindAll(ctx)
+}
+
+func (db PaymentsDB) StreamAll(ctx context.Context, fn func(Payment) error) error {
+	return repository(db.DB).StreamAll(ctx, fn)
+}
+
+func (db PaymentsDB) FindByID(ctx context.Context, id int) (*Payment, error) {
+	return repository(db.DB).FindByID(ctx, id)
+}
+
+// FindByCustomerID answers sql.ErrNoRows when the customer does not exist, a
+// customer without payments gets an empty list.
+func (db PaymentsDB) FindByCustomerID(ctx context.Context, id int) ([]Payment, error) {
+	payments, err := repository(db.DB).FindBy(ctx, "customer_id", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(payments) == 0 {
+		var exists bool
+		if err := db.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM customer WHERE id = $1)", id).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, sql.ErrNoRows
+		}
+	}
+	return payments, nil
+}
+
+// FindByCustomerIDs answers the payments of every customer of ids at once,
+// customers that do not exist simply have none.
+func (db PaymentsDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]Payment, error) {
+	return repository(db.DB).FindIn(ctx, "customer_id", ids)
+}
+
+func (db PaymentsDB) StreamByCustomerID(ctx context.Context, id int, fn func(Payment) error) error {
+	return repository(db.DB).StreamBy(ctx, "customer_id", id, fn)
+}
+
+func (db PaymentsDB) Add(ctx context.Context, payment *AddPayment) (*Payment, error) {
+	return db.Insert(ctx, db.DB, payment)
+}
+
+func (db PaymentsDB) Insert(ctx context.Context, q database.Queryer, payment *AddPayment) (*Payment, error) {
+	return repository(q).Add(ctx, payment)
+}
+
+func (db PaymentsDB) Delete(ctx context.Context, id int) error {
+	return repository(db.DB).Delete(ctx, id)
+}
+
+func (db PaymentsDB) Patch(ctx context.Context, id int, updates *UpdatePayment) (*Payment, error) {
+	return repository(db.DB).Patch(ctx, id, updates)
 }
 
-func (db PaymentsDB) FindAll() ([]Payment, error) {
-	rows, err := db.DB.Query("SELECT * FROM payment")
+// NOTE: Refunds are stored as payments with a negative amount. Gross only sums
+// the positive amounts, Refunds the negative ones and Net (the lifetime value
+// for a customer) is what was actually kept. Revenues only count the payments
+// still stored, those of purged customers are gone with them.
+const revenueColumns = `
+	COUNT(p.id),
+	COALESCE(SUM(CASE WHEN p.amount > 0 THEN p.amount ELSE 0 END), 0),
+	COALESCE(SUM(CASE WHEN p.amount < 0 THEN -p.amount ELSE 0 END), 0),
+	COALESCE(SUM(p.amount), 0)
+`
+
+func (db PaymentsDB) RevenueByCustomer(ctx context.Context) ([]CustomerRevenue, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT p.customer_id, %s, MIN(p.date), MAX(p.date)
+		FROM payment p
+		GROUP BY p.customer_id
+		ORDER BY SUM(p.amount) DESC
+	`, revenueColumns)
+
+	rows, err := db.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var payments []Payment
+	revenue := []CustomerRevenue{}
 
 	for rows.Next() {
-		var p Payment
-		err := rows.Scan(&p.Id, &p.Soul_Connection_Id, &p.Date, &p.PaymentMethod, &p.Amount, &p.Comment, &p.CreatedAt, &p.CustomerId)
+		var r CustomerRevenue
+		err := rows.Scan(&r.Customer_Id, &r.Payments, &r.Gross, &r.Refunds, &r.Lifetime_Value, &r.First_Payment, &r.Last_Payment)
 		if err != nil {
 			return nil, err
 		}
-		payments = append(payments, p)
+		revenue = append(revenue, r)
 	}
 	err = rows.Err()
 
 	if err != nil {
 		return nil, err
 	}
-	return payments, nil
+	return revenue, nil
 }
 
-func (db PaymentsDB) FindByID(id int) (*Payment, error) {
-	query := "SELECT * FROM payment WHERE id = $1"
+// RevenueForCustomer answers sql.ErrNoRows for a customer that does not
+// exist or was deleted, zeros for one without payments.
+func (db PaymentsDB) RevenueForCustomer(ctx context.Context, id int) (*CustomerRevenue, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT %s, COALESCE(MIN(p.date), ''), COALESCE(MAX(p.date), '')
+		FROM customer c
+		LEFT JOIN payment p ON p.customer_id = c.id
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+		GROUP BY c.id
+	`, revenueColumns)
 
-	row := db.DB.QueryRow(query, id)
-	var p Payment
+	row := db.DB.QueryRowContext(ctx, query, id)
+	r := CustomerRevenue{Customer_Id: &id}
 
-	err := row.Scan(&p.Id, &p.Soul_Connection_Id, &p.Date, &p.PaymentMethod, &p.Amount, &p.Comment, &p.CreatedAt, &p.CustomerId)
+	err := row.Scan(&r.Payments, &r.Gross, &r.Refunds, &r.Lifetime_Value, &r.First_Payment, &r.Last_Payment)
 	if err != nil {
 		return nil, err
 	}
-
-	return &p, nil
+	return &r, nil
 }
 
-func (db PaymentsDB) FindByCustomerID(id int) ([]Payment, error) {
-	query := "SELECT * FROM payment WHERE customer_id = $1"
+// RevenueByEmployee credits every payment to the coach the customer has now,
+// not the one it had on the day of the payment: the assignment history does
+// not go back to the customers assigned when they were created or imported.
+// A customer moved to another coach takes its whole revenue along.
+func (db PaymentsDB) RevenueByEmployee(ctx context.Context) ([]EmployeeRevenue, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+		SELECT c.employee_id, COUNT(DISTINCT p.customer_id), %s
+		FROM payment p
+		LEFT JOIN customer c ON c.id = p.customer_id
+		GROUP BY c.employee_id
+		ORDER BY SUM(p.amount) DESC
+	`, revenueColumns)
 
-	rows, err := db.DB.Query(query, id)
+	rows, err := db.DB.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	revenue := []EmployeeRevenue{}
 
-	var payments []Payment
 	for rows.Next() {
-		var p Payment
-		err := rows.Scan(&p.Id, &p.Soul_Connection_Id, &p.Date, &p.PaymentMethod, &p.Amount, &p.Comment, &p.CreatedAt, &p.CustomerId)
+		var r EmployeeRevenue
+		err := rows.Scan(&r.Employee_Id, &r.Customers, &r.Payments, &r.Gross, &r.Refunds, &r.Net)
 		if err != nil {
 			return nil, err
 		}
-		payments = append(payments, p)
+		revenue = append(revenue, r)
 	}
 	err = rows.Err()
+
 	if err != nil {
 		return nil, err
 	}
-
-	return payments, nil
+	return revenue, nil
 }
 
-func (db PaymentsDB) Add(payment *AddPayment) (*Payment, error) {
-	query := `
-		INSERT INTO payment (soul_connection_id, date, payment_method, amount, comment, customer_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING *
-    `
-	row := db.DB.QueryRow(query, payment.Soul_Connection_Id, payment.Date, payment.PaymentMethod, payment.Amount, payment.Comment, payment.CustomerId)
-	var p Payment
+// NOTE: Payment dates are stored as YYYY-MM-DD strings so the month is their
+// first seven characters. Empty bounds are ignored.
+func (db PaymentsDB) RevenueByMonth(ctx context.Context, from string, to string) ([]MonthlyRevenue, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
 
-	err := row.Scan(&p.Id, &p.Soul_Connection_Id, &p.Date, &p.PaymentMethod, &p.Amount, &p.Comment, &p.CreatedAt, &p.CustomerId)
+	query := fmt.Sprintf(`
+		SELECT SUBSTR(p.date, 1, 7) AS month, %s
+		FROM payment p
+		WHERE ($1 = '' OR SUBSTR(p.date, 1, 7) >= $1)
+		AND ($2 = '' OR SUBSTR(p.date, 1, 7) <= $2)
+		GROUP BY month
+		ORDER BY month
+	`, revenueColumns)
 
+	rows, err := db.DB.QueryContext(ctx, query, from, to)
 	if err != nil {
 		return nil, err
 	}
-	return &p, nil
-}
+	defer rows.Close()
+	revenue := []MonthlyRevenue{}
 
-func (db PaymentsDB) Delete(id int) error {
-	query := "DELETE FROM payment WHERE id = $1"
+	for rows.Next() {
+		var r MonthlyRevenue
+		err := rows.Scan(&r.Month, &r.Payments, &r.Gross, &r.Refunds, &r.Net)
+		if err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
+	}
+	err = rows.Err()
 
-	_, err := db.DB.Exec(query, id)
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return revenue, nil
 }
 
-func (db PaymentsDB) Patch(id int, updates *UpdatePayment) (*Payment, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
+func (db PaymentsDB) RevenueByMethod(ctx context.Context) ([]MethodRevenue, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
 
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
+	query := fmt.Sprintf(`
+		SELECT p.payment_method, %s
+		FROM payment p
+		GROUP BY p.payment_method
+		ORDER BY SUM(p.amount) DESC
+	`, revenueColumns)
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
-		}
+	rows, err := db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+	revenue := []MethodRevenue{}
 
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
+	for rows.Next() {
+		var r MethodRevenue
+		err := rows.Scan(&r.Payment_Method, &r.Payments, &r.Gross, &r.Refunds, &r.Net)
+		if err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
 	}
+	err = rows.Err()
 
-	query := fmt.Sprintf(
-		"UPDATE payment SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
-
-	args = append(args, id)
-
-	row := db.DB.QueryRow(query, args...)
-	var p Payment
-	err := row.Scan(&p.Id, &p.Soul_Connection_Id, &p.Date, &p.PaymentMethod, &p.Amount, &p.Comment, &p.CreatedAt, &p.CustomerId)
 	if err != nil {
 		return nil, err
 	}
-
-	return &p, nil
+	return revenue, nil
 }
@@ -1,7 +1,9 @@
 package payments
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -20,10 +22,16 @@ func setupTestDB() (*sql.DB, error) {
 		soul_connection_id INTEGER,
 		date TEXT NOT NULL,
 		payment_method TEXT NOT NULL,
-		amount REAL NOT NULL,
+		amount INTEGER NOT NULL,
 		comment TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		customer_id INTEGER NOT NULL
 	);
+	CREATE TABLE customer (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		employee_id INTEGER,
+		deleted_at DATETIME
+	);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
@@ -62,7 +70,7 @@ func TestPaymentQueries(t *testing.T) {
 			CustomerId:         1,
 		}
 
-		addedPayment, err := paymentsDB.Add(newPayment)
+		addedPayment, err := paymentsDB.Add(context.Background(), newPayment)
 		if err != nil {
 			t.Errorf("Failed to add payment: %v", err)
 			return
@@ -74,7 +82,7 @@ func TestPaymentQueries(t *testing.T) {
 	})
 
 	t.Run("Find All Payments", func(t *testing.T) {
-		_, err := paymentsDB.Add(&AddPayment{
+		_, err := paymentsDB.Add(context.Background(), &AddPayment{
 			Soul_Connection_Id: &num,
 			Date:               "25-04-2023",
 			PaymentMethod:      "cash",
@@ -86,7 +94,7 @@ func TestPaymentQueries(t *testing.T) {
 			t.Fatalf("Failed to add payment: %v", err)
 		}
 
-		payments, err := paymentsDB.FindAll()
+		payments, err := paymentsDB.FindAll(context.Background())
 		if err != nil {
 			t.Errorf("Failed to find all payments: %v", err)
 			return
@@ -98,7 +106,7 @@ func TestPaymentQueries(t *testing.T) {
 	})
 
 	t.Run("Find Payment by ID", func(t *testing.T) {
-		_, err := paymentsDB.Add(&AddPayment{
+		_, err := paymentsDB.Add(context.Background(), &AddPayment{
 			Date:          "25-04-2023",
 			PaymentMethod: "cash",
 			Amount:        1.0,
@@ -109,7 +117,7 @@ func TestPaymentQueries(t *testing.T) {
 			t.Fatalf("Failed to add payment: %v", err)
 		}
 
-		payment, err := paymentsDB.FindByID(1)
+		payment, err := paymentsDB.FindByID(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to find payment by ID: %v", err)
 			return
@@ -121,7 +129,7 @@ func TestPaymentQueries(t *testing.T) {
 	})
 
 	t.Run("Patch Payment", func(t *testing.T) {
-		_, err := paymentsDB.Add(&AddPayment{
+		_, err := paymentsDB.Add(context.Background(), &AddPayment{
 			Date:          "25-04-2023",
 			PaymentMethod: "cash",
 			Amount:        1.0,
@@ -135,7 +143,7 @@ func TestPaymentQueries(t *testing.T) {
 		newDate := "25-05-2023"
 		updates := &UpdatePayment{Date: &newDate}
 
-		updatedPayment, err := paymentsDB.Patch(1, updates)
+		updatedPayment, err := paymentsDB.Patch(context.Background(), 1, updates)
 		if err != nil {
 			t.Errorf("Failed to update payment: %v", err)
 			return
@@ -147,7 +155,7 @@ func TestPaymentQueries(t *testing.T) {
 	})
 
 	t.Run("Delete Payment", func(t *testing.T) {
-		_, err := paymentsDB.Add(&AddPayment{
+		_, err := paymentsDB.Add(context.Background(), &AddPayment{
 			Date:          "25-04-2023",
 			PaymentMethod: "cash",
 			Amount:        1.0,
@@ -158,15 +166,162 @@ func TestPaymentQueries(t *testing.T) {
 			t.Fatalf("Failed to add payment: %v", err)
 		}
 
-		err = paymentsDB.Delete(1)
+		err = paymentsDB.Delete(context.Background(), 1)
 		if err != nil {
 			t.Errorf("Failed to delete payment: %v", err)
 			return
 		}
 
-		_, err = paymentsDB.FindByID(1)
+		_, err = paymentsDB.FindByID(context.Background(), 1)
 		if err == nil {
 			t.Errorf("Expected error when finding deleted payment, got nil")
 		}
+
+		err = paymentsDB.Delete(context.Background(), 1)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows when deleting a missing payment, got %v", err)
+		}
+	})
+
+	t.Run("Find Payments by Customer ID", func(t *testing.T) {
+		if _, err := db.Exec("INSERT INTO customer (id) VALUES (1), (2)"); err != nil {
+			t.Fatalf("Failed to add customers: %v", err)
+		}
+
+		payments, err := paymentsDB.FindByCustomerID(context.Background(), 1)
+		if err != nil || len(payments) != 4 {
+			t.Errorf("Expected 4 payments, got %d (%v)", len(payments), err)
+		}
+
+		payments, err = paymentsDB.FindByCustomerID(context.Background(), 2)
+		if err != nil || payments == nil || len(payments) != 0 {
+			t.Errorf("Expected no payments, got %+v (%v)", payments, err)
+		}
+
+		_, err = paymentsDB.FindByCustomerID(context.Background(), 99)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows for a missing customer, got %v", err)
+		}
+	})
+}
+
+func TestRevenueQueries(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+
+	paymentsDB := PaymentsDB{DB: db}
+	if _, err := db.Exec("INSERT INTO customer (id, employee_id, deleted_at) VALUES (1, 10, NULL), (2, 10, NULL), (3, NULL, NULL), (4, 10, NULL), (5, 10, CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("Failed to add customers: %v", err)
+	}
+	for _, p := range []AddPayment{
+		{Date: "2023-04-25", PaymentMethod: "cash", Amount: 10000, Comment: "first", CustomerId: 1},
+		{Date: "2023-05-02", PaymentMethod: "card", Amount: 2550, Comment: "second", CustomerId: 1},
+		{Date: "2023-05-10", PaymentMethod: "card", Amount: -2550, Comment: "refund", CustomerId: 1},
+		{Date: "2023-05-11", PaymentMethod: "paypal", Amount: 4000, Comment: "other", CustomerId: 2},
+		{Date: "2023-06-01", PaymentMethod: "cash", Amount: 1000, Comment: "unassigned", CustomerId: 3},
+	} {
+		if _, err := paymentsDB.Add(context.Background(), &p); err != nil {
+			t.Fatalf("Failed to add payment: %v", err)
+		}
+	}
+
+	t.Run("Revenue By Customer", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueByCustomer(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get revenue by customer: %v", err)
+		}
+		if len(revenue) != 3 {
+			t.Fatalf("Expected 3 customers, got %d", len(revenue))
+		}
+
+		top := revenue[0]
+		if *top.Customer_Id != 1 || top.Payments != 3 || top.Gross != 12550 || top.Refunds != 2550 || top.Lifetime_Value != 10000 {
+			t.Errorf("Unexpected revenue for customer 1: %+v", top)
+		}
+		if top.First_Payment != "2023-04-25" || top.Last_Payment != "2023-05-10" {
+			t.Errorf("Unexpected payment dates for customer 1: %+v", top)
+		}
+	})
+
+	t.Run("Revenue For Customer", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueForCustomer(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Failed to get revenue for customer: %v", err)
+		}
+		if revenue.Payments != 3 || revenue.Lifetime_Value != 10000 || revenue.Last_Payment != "2023-05-10" {
+			t.Errorf("Unexpected revenue for customer 1: %+v", revenue)
+		}
+	})
+
+	t.Run("Revenue For Customer Without Payments", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueForCustomer(context.Background(), 4)
+		if err != nil {
+			t.Fatalf("Failed to get revenue for customer: %v", err)
+		}
+		if revenue.Payments != 0 || revenue.Lifetime_Value != 0 {
+			t.Errorf("Expected empty revenue, got %+v", revenue)
+		}
+	})
+
+	t.Run("Revenue For Unknown Customer", func(t *testing.T) {
+		for _, id := range []int{5, 42} {
+			if _, err := paymentsDB.RevenueForCustomer(context.Background(), id); err != sql.ErrNoRows {
+				t.Errorf("Expected sql.ErrNoRows for customer %d, got %v", id, err)
+			}
+		}
+	})
+
+	t.Run("Revenue By Employee", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueByEmployee(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get revenue by employee: %v", err)
+		}
+		if len(revenue) != 2 {
+			t.Fatalf("Expected 2 groups, got %d", len(revenue))
+		}
+		if *revenue[0].Employee_Id != 10 || revenue[0].Customers != 2 || revenue[0].Net != 14000 {
+			t.Errorf("Unexpected revenue for employee 10: %+v", revenue[0])
+		}
+		if revenue[1].Employee_Id != nil || revenue[1].Net != 1000 {
+			t.Errorf("Unexpected revenue for unassigned customers: %+v", revenue[1])
+		}
+	})
+
+	t.Run("Revenue Without Payments", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueByMonth(context.Background(), "2030-01", "")
+		if err != nil {
+			t.Fatalf("Failed to get revenue by month: %v", err)
+		}
+		if revenue == nil || len(revenue) != 0 {
+			t.Errorf("Expected an empty list, got %#v", revenue)
+		}
+	})
+
+	t.Run("Revenue By Month", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueByMonth(context.Background(), "2023-05", "")
+		if err != nil {
+			t.Fatalf("Failed to get revenue by month: %v", err)
+		}
+		if len(revenue) != 2 {
+			t.Fatalf("Expected 2 months, got %d", len(revenue))
+		}
+		if revenue[0].Month != "2023-05" || revenue[0].Gross != 6550 || revenue[0].Refunds != 2550 || revenue[0].Net != 4000 {
+			t.Errorf("Unexpected revenue for 2023-05: %+v", revenue[0])
+		}
+	})
+
+	t.Run("Revenue By Method", func(t *testing.T) {
+		revenue, err := paymentsDB.RevenueByMethod(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get revenue by method: %v", err)
+		}
+		for _, r := range revenue {
+			if r.Payment_Method == "card" && (r.Payments != 2 || r.Net != 0) {
+				t.Errorf("Unexpected revenue for card: %+v", r)
+			}
+		}
 	})
 }
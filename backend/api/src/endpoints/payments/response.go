@@ -0,0 +1,43 @@
+package payments
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// PaymentResponse is the JSON of a Payment, see Amount for how the amount is
+// written.
+type PaymentResponse struct {
+	Id                 int       `json:"id"`
+	Soul_Connection_Id *int      `json:"soul_connection_id"`
+	Date               string    `json:"date"`
+	PaymentMethod      string    `json:"payment_method"`
+	Amount             Amount    `json:"amount"`
+	Comment            string    `json:"comment"`
+	CreatedAt          time.Time `json:"created_at"`
+	CustomerId         int       `json:"customer_id"`
+}
+
+func (p Payment) Response() PaymentResponse {
+	return PaymentResponse{
+		Id:                 p.Id,
+		Soul_Connection_Id: p.Soul_Connection_Id,
+		Date:               p.Date,
+		PaymentMethod:      p.PaymentMethod,
+		Amount:             p.Amount,
+		Comment:            p.Comment,
+		CreatedAt:          p.CreatedAt,
+		CustomerId:         p.CustomerId,
+	}
+}
+
+// PersonalFields are left out of the audit log, the comment may tell who
+// the customer is.
+func (PaymentResponse) PersonalFields() []string {
+	return []string{"comment"}
+}
+
+func (p Payment) change(action changes.Action) changes.Change {
+	return changes.New(changes.Payments, action, p.Id, p.Response()).ForCustomer(p.CustomerId)
+}
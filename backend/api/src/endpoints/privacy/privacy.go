@@ -0,0 +1,286 @@
+// Package privacy answers the requests customers make about their personal
+// data: a copy of everything the api holds about them, or its erasure.
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/middleware"
+)
+
+// ErasuresRepository is the storage behind EraseCustomer.
+type ErasuresRepository interface {
+	Erase(context.Context, int) (*Erasure, error)
+}
+
+// PrivacyModel reads the customers deleted but not purged yet as well, their
+// data is still held until then.
+type PrivacyModel struct {
+	Customers interface {
+		FindByID(context.Context, int) (*customers.Customer, error)
+		GetFile(context.Context, primitive.ObjectID) ([]byte, error)
+	}
+	Payments interface {
+		FindByCustomerID(context.Context, int) ([]payments.Payment, error)
+	}
+	Encounters interface {
+		FindByCustomerID(context.Context, int) ([]encounters.Encounter, error)
+	}
+	Clothes interface {
+		FindByCustomerID(context.Context, int) ([]clothes.Clothe, error)
+		GetFile(context.Context, primitive.ObjectID) ([]byte, error)
+	}
+	Erasures ErasuresRepository
+	Changes  *changes.Bus
+	Audit    *audit.Log
+}
+
+// Manifest is the first file of an export, it lists the others.
+type Manifest struct {
+	Customer_Id  int            `json:"customer_id"`
+	Generated_At time.Time      `json:"generated_at"`
+	Files        []ManifestFile `json:"files"`
+}
+
+// ManifestFile describes a file of an export, Records counts the rows of the
+// JSON lists.
+type ManifestFile struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Content_Type string `json:"content_type"`
+	Size         int    `json:"size"`
+	Sha256       string `json:"sha256"`
+	Records      *int   `json:"records,omitempty"`
+}
+
+type exportFile struct {
+	ManifestFile
+	content []byte
+}
+
+// ExportCustomer answers a ZIP of everything held about a customer, for
+// managers only: its record, payments, encounters and clothes as JSON, its
+// images, and manifest.json listing them.
+func (model *PrivacyModel) ExportCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+	if err := middleware.RequireManager(req, "Only managers can export the data of a customer"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	files, err := model.exportFiles(req.Context(), id)
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	manifest := Manifest{Customer_Id: id, Generated_At: time.Now().UTC(), Files: make([]ManifestFile, len(files))}
+	for i, file := range files {
+		manifest.Files[i] = file.ManifestFile
+	}
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	for _, file := range append([]exportFile{{ManifestFile: ManifestFile{Name: "manifest.json"}, content: content}}, files...) {
+		w, err := writer.CreateHeader(&zip.FileHeader{Name: file.Name, Method: zip.Deflate, Modified: manifest.Generated_At})
+		if err == nil {
+			_, err = w.Write(file.content)
+		}
+		if err != nil {
+			apierror.Write(res, req, err)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/zip")
+	res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("customer-%d.zip", id)))
+	res.Header().Set("Content-Length", fmt.Sprintf("%d", archive.Len()))
+	if _, err := archive.WriteTo(res); err != nil {
+		logger.FromContext(req.Context()).Error("could not send export", "error", err)
+	}
+}
+
+// exportFiles gathers the files of the export of the customer id, but its
+// manifest.
+func (model *PrivacyModel) exportFiles(ctx context.Context, id int) ([]exportFile, error) {
+	customer, err := model.Customers.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	customerPayments, err := model.Payments.FindByCustomerID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	customerEncounters, err := model.Encounters.FindByCustomerID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	customerClothes, err := model.Clothes.FindByCustomerID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := []struct {
+		name        string
+		description string
+		value       any
+		records     *int
+	}{
+		{"customer.json", "The record of the customer", customer.Response(), nil},
+		{"payments.json", "The payments of the customer", lib.Map(customerPayments, payments.Payment.Response), count(customerPayments)},
+		{"encounters.json", "The encounters of the customer", lib.Map(customerEncounters, encounters.Encounter.Response), count(customerEncounters)},
+		{"clothes.json", "The clothes of the customer, their pictures are in images/clothes", lib.Map(customerClothes, clothes.Clothe.Response), count(customerClothes)},
+		// NOTE: Events only know the employee hosting them, customers do not register to them yet
+		{"event_registrations.json", "The events the customer registered to, none are recorded", []any{}, count([]any{})},
+	}
+	var files []exportFile
+	for _, list := range lists {
+		content, err := json.MarshalIndent(list.value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		file := newFile(list.name, list.description, "application/json", content)
+		file.Records = list.records
+		files = append(files, file)
+	}
+
+	if customer.Image_Id != nil {
+		file, err := imageFile(ctx, model.Customers.GetFile, *customer.Image_Id, "images/customer", "The picture of the customer")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	for _, clothe := range customerClothes {
+		if clothe.Image_Id == nil {
+			continue
+		}
+		file, err := imageFile(ctx, model.Clothes.GetFile, *clothe.Image_Id, fmt.Sprintf("images/clothes/%d", clothe.Id), fmt.Sprintf("The picture of the clothe %d", clothe.Id))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func count[T any](rows []T) *int {
+	n := len(rows)
+	return &n
+}
+
+// imageExtensions name the images of an export after their detected type.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/bmp":  ".bmp",
+}
+
+func imageFile(ctx context.Context, download func(context.Context, primitive.ObjectID) ([]byte, error), imageId string, name string, description string) (exportFile, error) {
+	fileId, err := primitive.ObjectIDFromHex(imageId)
+	if err != nil {
+		return exportFile{}, err
+	}
+	content, err := download(ctx, fileId)
+	if err != nil {
+		return exportFile{}, err
+	}
+	contentType := http.DetectContentType(content)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		extension = ".bin"
+	}
+	return newFile(name+extension, description, contentType, content), nil
+}
+
+func newFile(name string, description string, contentType string, content []byte) exportFile {
+	sum := sha256.Sum256(content)
+	return exportFile{
+		ManifestFile: ManifestFile{
+			Name:         name,
+			Description:  description,
+			Content_Type: contentType,
+			Size:         len(content),
+			Sha256:       hex.EncodeToString(sum[:]),
+		},
+		content: content,
+	}
+}
+
+// EraseCustomer anonymizes a customer for managers only, see Erasure for what
+// is kept. The erasure is recorded with its counts only, the audit log holds
+// nothing personal to erase, see audit.Personal.
+func (model *PrivacyModel) EraseCustomer(res http.ResponseWriter, req *http.Request) {
+	id, err := lib.GetIdFromRequest(req, "customer_id")
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest("Invalid customer ID format"))
+		return
+	}
+	if err := middleware.RequireManager(req, "Only managers can erase a customer"); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	var erasure *Erasure
+	var response ErasureResponse
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if erasure, err = model.Erasures.Erase(ctx, id); err != nil {
+			return err
+		}
+		response = erasure.Response()
+		return model.Audit.Record(ctx, changes.Customers, audit.Erased, id, nil, response.counts())
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	customer := erasure.Customer.Response()
+	model.Changes.Publish(req.Context(), changes.New(changes.Customers, changes.Updated, id, customer).ForCustomer(id).WithCoach(customer.Employee_Id))
+	for _, p := range erasure.Payments {
+		model.Changes.Publish(req.Context(), changes.New(changes.Payments, changes.Updated, p.Id, p.Response()).ForCustomer(id))
+	}
+	for _, e := range erasure.Encounters {
+		model.Changes.Publish(req.Context(), changes.New(changes.Encounters, changes.Updated, e.Id, e.Response()).ForCustomer(id))
+	}
+	for _, c := range erasure.Clothes {
+		model.Changes.Publish(req.Context(), changes.New(changes.Clothes, changes.Deleted, c.Id, nil).ForCustomer(id))
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(res).Encode(response); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+}
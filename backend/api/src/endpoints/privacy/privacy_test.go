@@ -0,0 +1,276 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/middleware"
+)
+
+// png is the smallest content http.DetectContentType takes for an image.
+var png = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+type MockFiles map[primitive.ObjectID][]byte
+
+func (m MockFiles) GetFile(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	content, ok := m[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return content, nil
+}
+
+type MockCustomersDB struct {
+	MockFiles
+	Customers []customers.Customer
+}
+
+func (m *MockCustomersDB) FindByID(ctx context.Context, id int) (*customers.Customer, error) {
+	for _, customer := range m.Customers {
+		if customer.Id == id {
+			return &customer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type MockPaymentsDB struct {
+	Payments []payments.Payment
+}
+
+func (m *MockPaymentsDB) FindByCustomerID(ctx context.Context, id int) ([]payments.Payment, error) {
+	p := []payments.Payment{}
+	for _, payment := range m.Payments {
+		if payment.CustomerId == id {
+			p = append(p, payment)
+		}
+	}
+	return p, nil
+}
+
+type MockEncountersDB struct{}
+
+func (m *MockEncountersDB) FindByCustomerID(ctx context.Context, id int) ([]encounters.Encounter, error) {
+	return []encounters.Encounter{{Id: 1, Date: "2024-01-02", Rating: 4, Comment: "Met at the cinema", Customer_Id: id}}, nil
+}
+
+type MockClothesDB struct {
+	MockFiles
+	Clothes []clothes.Clothe
+}
+
+func (m *MockClothesDB) FindByCustomerID(ctx context.Context, id int) ([]clothes.Clothe, error) {
+	return m.Clothes, nil
+}
+
+type MockErasuresDB struct {
+	Erased []int
+}
+
+func (m *MockErasuresDB) Erase(ctx context.Context, id int) (*Erasure, error) {
+	if id != 1 {
+		return nil, sql.ErrNoRows
+	}
+	m.Erased = append(m.Erased, id)
+	return &Erasure{
+		Customer: customers.Customer{Id: id, Email: "erased-1@erased.invalid"},
+		Payments: []payments.Payment{{Id: 1, Amount: 4500, CustomerId: id}},
+		Clothes:  []clothes.Clothe{{Id: 3, CustomerId: id}},
+	}, nil
+}
+
+type MockEntriesDB struct {
+	Entries []audit.AddEntry
+}
+
+func (m *MockEntriesDB) Add(ctx context.Context, entry *audit.AddEntry) (*audit.Entry, error) {
+	m.Entries = append(m.Entries, *entry)
+	return &audit.Entry{}, nil
+}
+
+func (m *MockEntriesDB) Find(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	return nil, nil
+}
+
+func setupTestModel() (*PrivacyModel, *MockErasuresDB, *MockEntriesDB) {
+	customerImage, clotheImage := primitive.NewObjectID(), primitive.NewObjectID()
+	customerImageId, clotheImageId := customerImage.Hex(), clotheImage.Hex()
+	erasures, entries := &MockErasuresDB{}, &MockEntriesDB{}
+	return &PrivacyModel{
+		Customers: &MockCustomersDB{
+			MockFiles: MockFiles{customerImage: png},
+			Customers: []customers.Customer{{Id: 1, Name: "Zoé", Email: "zoe@test.com", Address: "1 rue de Paris", Image_Id: &customerImageId}},
+		},
+		Payments: &MockPaymentsDB{Payments: []payments.Payment{
+			{Id: 1, Amount: 4500, CustomerId: 1},
+			{Id: 2, Amount: 1500, CustomerId: 2},
+		}},
+		Encounters: &MockEncountersDB{},
+		Clothes: &MockClothesDB{
+			MockFiles: MockFiles{clotheImage: []byte("not an image")},
+			Clothes:   []clothes.Clothe{{Id: 3, Type: "hat", Image_Id: &clotheImageId, CustomerId: 1}, {Id: 4, Type: "shoes", CustomerId: 1}},
+		},
+		Erasures: erasures,
+		Audit:    &audit.Log{Entries: entries},
+	}, erasures, entries
+}
+
+func serve(handler http.HandlerFunc, method string, path string, user *middleware.User) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if user != nil {
+		req = req.WithContext(middleware.WithUser(req.Context(), user))
+	}
+	res := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/customers/{customer_id}/export", handler).Methods(http.MethodGet)
+	router.HandleFunc("/api/customers/{customer_id}/erase", handler).Methods(http.MethodPost)
+	router.ServeHTTP(res, req)
+	return res
+}
+
+var (
+	manager = &middleware.User{Id: 10, Email: "manager@test.com", Work: "Manager"}
+	coach   = &middleware.User{Id: 11, Email: "coach@test.com", Work: middleware.CoachWork}
+)
+
+func TestExportCustomer(t *testing.T) {
+	model, _, _ := setupTestModel()
+
+	t.Run("Archive", func(t *testing.T) {
+		res := serve(model.ExportCustomer, http.MethodGet, "/api/customers/1/export", manager)
+		if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("Expected a zip, got %d %s: %s", res.Code, res.Header().Get("Content-Type"), res.Body.String())
+		}
+		archive, err := zip.NewReader(bytes.NewReader(res.Body.Bytes()), int64(res.Body.Len()))
+		if err != nil {
+			t.Fatalf("Expected a valid zip: %v", err)
+		}
+		files := map[string][]byte{}
+		for _, file := range archive.File {
+			r, err := file.Open()
+			if err != nil {
+				t.Fatalf("Failed to open %s: %v", file.Name, err)
+			}
+			files[file.Name], _ = io.ReadAll(r)
+			r.Close()
+		}
+		if archive.File[0].Name != "manifest.json" {
+			t.Errorf("Expected the manifest first, got %s", archive.File[0].Name)
+		}
+
+		var manifest Manifest
+		if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil || manifest.Customer_Id != 1 {
+			t.Fatalf("Unexpected manifest %+v (%v)", manifest, err)
+		}
+		records := map[string]int{}
+		for _, file := range manifest.Files {
+			content, ok := files[file.Name]
+			if !ok || len(content) != file.Size {
+				t.Errorf("Expected %s in the archive with %d bytes", file.Name, file.Size)
+			}
+			if file.Records != nil {
+				records[file.Name] = *file.Records
+			}
+		}
+		expected := map[string]int{"payments.json": 1, "encounters.json": 1, "clothes.json": 2, "event_registrations.json": 0}
+		for name, n := range expected {
+			if records[name] != n {
+				t.Errorf("Expected %d records in %s, got %d", n, name, records[name])
+			}
+		}
+		if !bytes.Equal(files["images/customer.png"], png) || files["images/clothes/3.bin"] == nil || len(files) != len(manifest.Files)+1 {
+			t.Errorf("Expected the images of the customer and its clothes, got %d files", len(files))
+		}
+
+		var customer customers.CustomerResponse
+		if err := json.Unmarshal(files["customer.json"], &customer); err != nil || customer.Address != "1 rue de Paris" {
+			t.Errorf("Unexpected customer %+v (%v)", customer, err)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		user   *middleware.User
+		status int
+	}{
+		{"Anonymous", "/api/customers/1/export", nil, http.StatusUnauthorized},
+		{"Coach", "/api/customers/1/export", coach, http.StatusForbidden},
+		{"Not Found", "/api/customers/404/export", manager, http.StatusNotFound},
+		{"Invalid ID", "/api/customers/abc/export", manager, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := serve(model.ExportCustomer, http.MethodGet, tc.path, tc.user); res.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, res.Code)
+			}
+		})
+	}
+}
+
+func TestEraseCustomer(t *testing.T) {
+	model, erasures, entries := setupTestModel()
+
+	t.Run("Erase", func(t *testing.T) {
+		res := serve(model.EraseCustomer, http.MethodPost, "/api/customers/1/erase", manager)
+		if res.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", res.Code, res.Body.String())
+		}
+		var response ErasureResponse
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Customer.Email != "erased-1@erased.invalid" || response.Payments != 1 || response.Clothes != 1 {
+			t.Errorf("Unexpected erasure %+v", response)
+		}
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		if len(entries.Entries) != 1 {
+			t.Fatalf("Expected one audit entry, got %+v", entries.Entries)
+		}
+		entry := entries.Entries[0]
+		if entry.Action != audit.Erased || entry.Entity_Id == nil || *entry.Entity_Id != 1 || *entry.Actor_Id != manager.Id {
+			t.Errorf("Unexpected entry %+v", entry)
+		}
+		if _, ok := entry.Diff["customer"]; ok || string(entry.Diff["payments"].After) != "1" {
+			t.Errorf("Expected the counts of the erasure only, got %+v", entry.Diff)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		user   *middleware.User
+		status int
+	}{
+		{"Anonymous", "/api/customers/1/erase", nil, http.StatusUnauthorized},
+		{"Coach", "/api/customers/1/erase", coach, http.StatusForbidden},
+		{"Not Found", "/api/customers/404/erase", manager, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if res := serve(model.EraseCustomer, http.MethodPost, tc.path, tc.user); res.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, res.Code)
+			}
+		})
+	}
+	if len(erasures.Erased) != 1 {
+		t.Errorf("Expected a single erasure, got %v", erasures.Erased)
+	}
+}
@@ -0,0 +1,162 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/payments"
+	filestorage "soul-connection.com/api/src/file-storage"
+	"soul-connection.com/api/src/logger"
+)
+
+// Erasure is what erasing a customer changed. The customer keeps its row,
+// coach and upstream id, the migration would import it again otherwise, but
+// nothing that tells who it was. Payments keep their date, method and amount
+// so revenues do not change, encounters their date, rating and source.
+type Erasure struct {
+	Customer customers.Customer
+	// Payments and Encounters had their comment erased
+	Payments   []payments.Payment
+	Encounters []encounters.Encounter
+	// Clothes were removed with their pictures
+	Clothes []clothes.Clothe
+}
+
+// ErasureResponse is the JSON of an Erasure.
+type ErasureResponse struct {
+	Customer   customers.CustomerResponse `json:"customer"`
+	Payments   int                        `json:"payments"`
+	Encounters int                        `json:"encounters"`
+	Clothes    int                        `json:"clothes"`
+}
+
+func (e Erasure) Response() ErasureResponse {
+	return ErasureResponse{
+		Customer:   e.Customer.Response(),
+		Payments:   len(e.Payments),
+		Encounters: len(e.Encounters),
+		Clothes:    len(e.Clothes),
+	}
+}
+
+// counts is what the audit log keeps of an erasure, nothing personal.
+func (r ErasureResponse) counts() map[string]int {
+	return map[string]int{
+		"payments":   r.Payments,
+		"encounters": r.Encounters,
+		"clothes":    r.Clothes,
+	}
+}
+
+// ErasureDB erases customers in one transaction, then removes their pictures
+// from the buckets.
+type ErasureDB struct {
+	DB        *sql.DB
+	Customers *gridfs.Bucket
+	Clothes   *gridfs.Bucket
+
+	// remove deletes an image, tests replace filestorage.Delete
+	remove func(context.Context, *gridfs.Bucket, primitive.ObjectID) error
+}
+
+// Erase anonymizes the customer id, deleted or not, sql.ErrNoRows when there
+// is none. Erasing a customer again changes nothing more.
+func (db ErasureDB) Erase(ctx context.Context, id int) (*Erasure, error) {
+	tx, err := database.Begin(ctx, db.DB)
+	if err != nil {
+		return nil, err
+	}
+	erasure, imageId, err := erase(ctx, tx.Tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	db.removeImage(ctx, db.Customers, imageId)
+	for _, clothe := range erasure.Clothes {
+		db.removeImage(ctx, db.Clothes, clothe.Image_Id)
+	}
+	return erasure, nil
+}
+
+// erase runs the statements of Erase within tx and answers the former image
+// of the customer.
+func erase(ctx context.Context, tx *sql.Tx, id int) (*Erasure, *string, error) {
+	imageId, err := formerImage(ctx, tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	customerRepo := database.Repository[customers.Customer]{DB: tx, Table: "customer"}
+	customer, err := customerRepo.QueryRow(ctx, `UPDATE customer SET email = $1, name = '', surname = '', birth_date = '', gender = '',
+		description = '', astrological_sign = '', phone_number = '', phone_number_index = '', address = '', image_id = NULL
+		WHERE id = $2 `+customerRepo.Returning(), fmt.Sprintf("erased-%d@erased.invalid", id), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	paymentRepo := database.Repository[payments.Payment]{DB: tx, Table: "payment"}
+	customerPayments, err := paymentRepo.Query(ctx, "UPDATE payment SET comment = '' WHERE customer_id = $1 AND comment <> '' "+paymentRepo.Returning(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// NOTE: Encounters are unique by date, comment and source, each gets its
+	// own placeholder so that blanking them cannot collide
+	encounterRepo := database.Repository[encounters.Encounter]{DB: tx, Table: "encounter"}
+	customerEncounters, err := encounterRepo.Query(ctx, "UPDATE encounter SET comment = '[erased ' || id || ']' WHERE customer_id = $1 AND comment <> '[erased ' || id || ']' "+encounterRepo.Returning(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clotheRepo := database.Repository[clothes.Clothe]{DB: tx, Table: "clothe"}
+	customerClothes, err := clotheRepo.Query(ctx, "DELETE FROM clothe WHERE customer_id = $1 "+clotheRepo.Returning(), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Erasure{
+		Customer:   *customer,
+		Payments:   customerPayments,
+		Encounters: customerEncounters,
+		Clothes:    customerClothes,
+	}, imageId, nil
+}
+
+func formerImage(ctx context.Context, tx *sql.Tx, id int) (*string, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+	var imageId *string
+	err := tx.QueryRowContext(ctx, "SELECT image_id FROM customer WHERE id = $1", id).Scan(&imageId)
+	return imageId, err
+}
+
+// removeImage deletes an erased picture, one that could not be is logged as
+// its row no longer points to it either way.
+func (db ErasureDB) removeImage(ctx context.Context, bucket *gridfs.Bucket, imageId *string) {
+	if imageId == nil {
+		return
+	}
+	remove := db.remove
+	if remove == nil {
+		remove = filestorage.Delete
+	}
+	fileId, err := primitive.ObjectIDFromHex(*imageId)
+	if err == nil {
+		err = remove(ctx, bucket, fileId)
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error("Could not remove the picture of an erased customer", "image_id", *imageId, "error", err)
+	}
+}
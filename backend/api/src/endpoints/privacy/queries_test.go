@@ -0,0 +1,178 @@
+package privacy
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema := `
+	CREATE TABLE customer (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL,
+		surname TEXT NOT NULL,
+		birth_date TEXT NOT NULL,
+		gender TEXT NOT NULL,
+		description TEXT NOT NULL,
+		astrological_sign TEXT NOT NULL,
+		phone_number TEXT NOT NULL,
+		phone_number_index TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL,
+		image_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		employee_id INTEGER
+	);
+	CREATE UNIQUE INDEX customer_email ON customer (email) WHERE deleted_at IS NULL;
+	CREATE TABLE payment (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE,
+		date TEXT NOT NULL,
+		payment_method TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		comment TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		customer_id INTEGER
+	);
+	CREATE TABLE encounter (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date TEXT NOT NULL,
+		rating INTEGER NOT NULL,
+		comment TEXT NOT NULL,
+		source TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		customer_id INTEGER,
+		UNIQUE (date, comment, source, customer_id)
+	);
+	CREATE TABLE clothe (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE,
+		type TEXT NOT NULL,
+		image_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		customer_id INTEGER
+	);
+	CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor_id INTEGER,
+		actor_email TEXT,
+		entity TEXT NOT NULL,
+		entity_id INTEGER,
+		action TEXT NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		diff TEXT NOT NULL DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	return db
+}
+
+func TestErasureDB(t *testing.T) {
+	db := setupTestDB(t)
+	customerImage, clotheImage := primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{`INSERT INTO customer (id, soul_connection_id, email, name, surname, birth_date, gender, description, astrological_sign, phone_number, address, image_id, employee_id)
+			VALUES (1, 42, 'zoe@test.com', 'Zoé', 'Durand', '1990-01-01', 'Female', 'Likes cats', 'Leo', '0600000000', '1 rue de Paris', $1, 3)`, []any{customerImage}},
+		{`INSERT INTO customer (id, email, name, surname, birth_date, gender, description, astrological_sign, phone_number, address)
+			VALUES (2, 'max@test.com', 'Max', 'Martin', '1985-05-05', 'Male', '', 'Aries', '0611111111', '2 rue de Lyon')`, nil},
+		{"INSERT INTO payment (id, date, payment_method, amount, comment, customer_id) VALUES (1, '2024-01-01', 'card', 4500, 'Paid by Zoé', 1)", nil},
+		{"INSERT INTO payment (id, date, payment_method, amount, comment, customer_id) VALUES (2, '2024-01-01', 'card', 1500, 'Paid by Max', 2)", nil},
+		{"INSERT INTO encounter (id, date, rating, comment, source, customer_id) VALUES (1, '2024-01-02', 4, 'Cinema', 'app', 1)", nil},
+		{"INSERT INTO encounter (id, date, rating, comment, source, customer_id) VALUES (2, '2024-01-02', 2, 'Restaurant', 'app', 1)", nil},
+		{"INSERT INTO clothe (id, type, image_id, customer_id) VALUES (1, 'hat', $1, 1)", []any{clotheImage}},
+		{"INSERT INTO clothe (id, type, customer_id) VALUES (2, 'shoes', 2)", nil},
+		// NOTE: The entries tell which personal fields changed, never to what
+		{`INSERT INTO audit_log (entity, entity_id, action, diff) VALUES ('customers', 1, 'created', '{"name":{"after":"redacted"}}')`, nil},
+		{`INSERT INTO audit_log (entity, entity_id, action, diff) VALUES ('payments', 1, 'updated', '{"comment":{"before":"redacted","after":"redacted"}}')`, nil},
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement.query, statement.args...); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	var removed []string
+	erasures := ErasureDB{DB: db, remove: func(ctx context.Context, bucket *gridfs.Bucket, id primitive.ObjectID) error {
+		removed = append(removed, id.Hex())
+		return nil
+	}}
+
+	t.Run("Erase", func(t *testing.T) {
+		erasure, err := erasures.Erase(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Failed to erase: %v", err)
+		}
+		c := erasure.Customer
+		if c.Email != "erased-1@erased.invalid" || c.Name != "" || c.Birth_Date != "" || c.Address != "" || c.Phone_Number != "" || c.Astrological_Sign != "" || c.Description != "" || c.Image_Id != nil {
+			t.Errorf("Expected the personal data to be erased, got %+v", c)
+		}
+		if c.Soul_Connection_Id == nil || *c.Soul_Connection_Id != 42 || c.Employee_Id == nil || *c.Employee_Id != 3 {
+			t.Errorf("Expected the upstream id and coach to be kept, got %+v", c)
+		}
+		if len(erasure.Payments) != 1 || erasure.Payments[0].Comment != "" || erasure.Payments[0].Amount != 4500 {
+			t.Errorf("Expected the comment of the payment only to be erased, got %+v", erasure.Payments)
+		}
+		if len(erasure.Encounters) != 2 || erasure.Encounters[0].Comment == "Cinema" || erasure.Encounters[0].Rating != 4 {
+			t.Errorf("Expected the comments of the encounters to be erased, got %+v", erasure.Encounters)
+		}
+		if len(erasure.Clothes) != 1 || erasure.Clothes[0].Id != 1 {
+			t.Errorf("Expected the clothes to be removed, got %+v", erasure.Clothes)
+		}
+		slices.Sort(removed)
+		expected := []string{customerImage, clotheImage}
+		slices.Sort(expected)
+		if !slices.Equal(removed, expected) {
+			t.Errorf("Expected the images %v to be removed, got %v", expected, removed)
+		}
+	})
+
+	t.Run("Others Kept", func(t *testing.T) {
+		var blanked int
+		if err := db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE diff = '{}'").Scan(&blanked); err != nil || blanked != 0 {
+			t.Errorf("Expected the audit log to be left as it is, got %d blank diffs (%v)", blanked, err)
+		}
+		var comment string
+		if err := db.QueryRow("SELECT comment FROM payment WHERE id = 2").Scan(&comment); err != nil || comment != "Paid by Max" {
+			t.Errorf("Expected the other customers untouched, got %q (%v)", comment, err)
+		}
+	})
+
+	t.Run("Again", func(t *testing.T) {
+		removed = nil
+		erasure, err := erasures.Erase(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("Failed to erase again: %v", err)
+		}
+		if len(erasure.Payments)+len(erasure.Encounters)+len(erasure.Clothes) != 0 || len(removed) != 0 {
+			t.Errorf("Expected nothing more to erase, got %+v", erasure)
+		}
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		if _, err := erasures.Erase(context.Background(), 404); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Expected sql.ErrNoRows, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/health"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.Config{RateLimit: config.RateLimit{Rules: []string{"default=100/1m", "/api/v1/payments=2/1m"}}}
+	router, err := NewRouter(cfg, Models{Payments: payments.PaymentModel{Payments: &MockPaymentsDB{}}}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	post := func(path string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"date": "2024-05-01", "payment_method": "PayPal", "amount": 12.5, "customer_id": 3}`)))
+		return res
+	}
+
+	first := post("/api/v1/payments")
+	if first.Code != http.StatusOK || first.Header().Get("RateLimit-Limit") != "2" || first.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("Expected the rule of the group, got %d %v", first.Code, first.Header())
+	}
+	// NOTE: The deprecated alias counts against the same bucket
+	if legacy := post("/api/payments"); legacy.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected the alias to share the bucket, got %v", legacy.Header())
+	}
+	refused := post("/api/v1/payments")
+	if refused.Code != http.StatusTooManyRequests || refused.Header().Get("Retry-After") == "" || !strings.Contains(refused.Body.String(), `"too_many_requests"`) {
+		t.Errorf("Expected 429, got %d %v %s", refused.Code, refused.Header(), refused.Body.String())
+	}
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/customers/abc", nil))
+	if res.Header().Get("RateLimit-Limit") != "100" {
+		t.Errorf("Expected the default rule for the other groups, got %v", res.Header())
+	}
+}
+
+func TestRateLimitPerAddress(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		res.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		RateLimit: config.RateLimit{Rules: []string{"default=100/1m:employee"}, PerAddress: "2/1m"},
+		Upstream:  config.Upstream{BaseUri: upstream.URL},
+	}
+	router, err := NewRouter(cfg, Models{}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	var res *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/import/customers", nil)
+		req.Header.Set("Authorization", "Bearer forged-"+strconv.Itoa(i))
+		res = httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+	}
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("RateLimit-Limit") != "2" {
+		t.Errorf("Expected the address to be throttled whatever its tokens, got %d %v", res.Code, res.Header())
+	}
+	if calls != 2 {
+		t.Errorf("Expected the throttled request not to reach the upstream api, it was called %d times", calls)
+	}
+
+	// NOTE: The probes are not under /api
+	for i := 0; i < 3; i++ {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if res.Code != http.StatusOK {
+			t.Errorf("Expected the probes not to be throttled, got %d", res.Code)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/health"
+)
+
+func TestRestoreManagersOnly(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// NOTE: The work of the employee is the bearer, "Bearer Coach" is a coach
+		work := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		fmt.Fprintf(res, `{"id": 2, "email": "employee@example.com", "work": %q}`, strings.TrimSuffix(work, "-"))
+	}))
+	defer upstream.Close()
+	cfg := &config.Config{}
+	cfg.Upstream.BaseUri = upstream.URL
+	router, err := NewRouter(cfg, Models{}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	for _, path := range []string{"/api/v1/employees/1/restore", "/api/v1/customers/1/restore", "/api/employees/1/restore"} {
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, path, nil))
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 without a token on %s, got %d", path, res.Code)
+		}
+
+		// NOTE: Only the works listed as managers pass, "-" stands for no work at all
+		for _, work := range []string{"Coach", "coach", "manager", "Intern", "-"} {
+			res = httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			req.Header.Set("Authorization", "Bearer "+work)
+			router.ServeHTTP(res, req)
+			if res.Code != http.StatusForbidden {
+				t.Errorf("Expected 403 for the work %q on %s, got %d", work, path, res.Code)
+			}
+		}
+	}
+}
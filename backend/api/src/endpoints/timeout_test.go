@@ -0,0 +1,33 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAttachRoutesTimeout(t *testing.T) {
+	deadlines := map[string]bool{}
+	handler := func(res http.ResponseWriter, req *http.Request) {
+		_, deadlines[req.URL.Path] = req.Context().Deadline()
+	}
+	router := mux.NewRouter()
+	attachRoutes(router, []ModelRoutes{{
+		BasePath: "/api/v1/customers",
+		Routes: []Endpoint{
+			{Path: "", Handler: handler, Method: http.MethodGet, Export: true},
+			{Path: "/{customer_id}", Handler: handler, Method: http.MethodGet},
+			{Path: "/{customer_id}/export", Handler: handler, Method: http.MethodGet, Untimed: true},
+		},
+	}}, time.Minute)
+
+	for path, expected := range map[string]bool{"/api/v1/customers": false, "/api/v1/customers/1": true, "/api/v1/customers/1/export": false} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if deadlines[path] != expected {
+			t.Errorf("Expected a deadline on %s to be %t", path, expected)
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package tips
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"reflect"
-	"strings"
+
+	"soul-connection.com/api/src/database"
 )
 
 type TipsDB struct {
@@ -12,116 +12,39 @@ type TipsDB struct {
 }
 
 type AddTip struct {
-	Title string
-	Tip   string
+	Title string `json:"title" db:"title" validate:"required,max=255"`
+	Tip   string `json:"tip" db:"tip" validate:"required,max=255"`
 }
 
 type UpdateTip struct {
-	Title *string
-	Tip   *string
+	Title *string `json:"title" db:"title" validate:"required,max=255"`
+	Tip   *string `json:"tip" db:"tip" validate:"required,max=255"`
 }
 
-func (db TipsDB) FindAll() ([]Tip, error) {
-	rows, err := db.DB.Query("SELECT * FROM tip")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var tip []Tip
-
-	for rows.Next() {
-		var t Tip
-		err := rows.Scan(&t.Id, &t.Title, &t.Tip, &t.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		tip = append(tip, t)
-	}
-	err = rows.Err()
-
-	if err != nil {
-		return nil, err
-	}
-	return tip, nil
+func repository(q database.Queryer) database.Repository[Tip] {
+	return database.Repository[Tip]{DB: q, Table: "tip"}
 }
 
-func (db TipsDB) FindByID(id int) (*Tip, error) {
-	query := "SELECT * FROM tip WHERE id = $1"
-
-	row := db.DB.QueryRow(query, id)
-	var t Tip
-
-	err := row.Scan(&t.Id, &t.Title, &t.Tip, &t.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-
-	return &t, nil
+func (db TipsDB) FindAll(ctx context.Context) ([]Tip, error) {
+	return repository(db.DB).FindAll(ctx)
 }
 
-func (db TipsDB) Add(tip *AddTip) (*Tip, error) {
-	query := `
-		INSERT INTO tip (title, tip)
-		VALUES ($1, $2)
-		RETURNING *
-    `
-	row := db.DB.QueryRow(query, tip.Title, tip.Tip)
-	var t Tip
-
-	err := row.Scan(&t.Id, &t.Title, &t.Tip, &t.CreatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-	return &t, nil
+func (db TipsDB) StreamAll(ctx context.Context, fn func(Tip) error) error {
+	return repository(db.DB).StreamAll(ctx, fn)
 }
 
-func (db TipsDB) Delete(id int) error {
-	query := "DELETE FROM tip WHERE id = $1"
-
-	_, err := db.DB.Exec(query, id)
-	return err
+func (db TipsDB) FindByID(ctx context.Context, id int) (*Tip, error) {
+	return repository(db.DB).FindByID(ctx, id)
 }
 
-func (db TipsDB) Patch(id int, updates *UpdateTip) (*Tip, error) {
-	v := reflect.ValueOf(updates).Elem()
-	t := reflect.TypeOf(*updates)
-
-	var setClauses []string
-	var args []interface{}
-	argIndex := 1
-
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.IsNil() {
-			fieldName := t.Field(i).Tag.Get("db")
-			if fieldName == "" {
-				fieldName = strings.ToLower(strings.Replace(t.Field(i).Name, "_", "", -1))
-			}
-			setClauses = append(setClauses, fmt.Sprintf("%s = $%d", fieldName, argIndex))
-			args = append(args, field.Interface())
-			argIndex++
-		}
-	}
-
-	if len(setClauses) == 0 {
-		return nil, fmt.Errorf("no fields to update")
-	}
-
-	query := fmt.Sprintf(
-		"UPDATE tip SET %s WHERE id = $%d RETURNING *",
-		strings.Join(setClauses, ", "),
-		argIndex,
-	)
-
-	args = append(args, id)
+func (db TipsDB) Add(ctx context.Context, tip *AddTip) (*Tip, error) {
+	return repository(db.DB).Add(ctx, tip)
+}
 
-	row := db.DB.QueryRow(query, args...)
-	var tip Tip
-	err := row.Scan(&tip.Id, &tip.Title, &tip.Tip, &tip.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
+func (db TipsDB) Delete(ctx context.Context, id int) error {
+	return repository(db.DB).Delete(ctx, id)
+}
 
-	return &tip, nil
+func (db TipsDB) Patch(ctx context.Context, id int, updates *UpdateTip) (*Tip, error) {
+	return repository(db.DB).Patch(ctx, id, updates)
 }
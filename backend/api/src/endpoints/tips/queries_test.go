@@ -1,6 +1,7 @@
 package tips_test
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -46,7 +47,7 @@ func testAdd(t *testing.T) {
 			Tip:   "This is a test tip.",
 		}
 
-		addedTip, err := tipsDB.Add(newTip)
+		addedTip, err := tipsDB.Add(context.Background(), newTip)
 		if err != nil {
 			t.Errorf("Failed to add tip: %v", err)
 		}
@@ -68,12 +69,12 @@ func testFindAll(t *testing.T) {
 		tipsDB := tips.TipsDB{DB: db}
 		newTip := &tips.AddTip{Title: "Test Title", Tip: "This is a test tip."}
 
-		_, err = tipsDB.Add(newTip)
+		_, err = tipsDB.Add(context.Background(), newTip)
 		if err != nil {
 			t.Errorf("Failed to add tip: %v", err)
 		}
 
-		tips, err := tipsDB.FindAll()
+		tips, err := tipsDB.FindAll(context.Background())
 		if err != nil {
 			t.Errorf("Failed to find all tips: %v", err)
 		}
@@ -99,12 +100,12 @@ func testFindByID(t *testing.T) {
 		tipsDB := tips.TipsDB{DB: db}
 		newTip := &tips.AddTip{Title: "Test Title", Tip: "This is a test tip."}
 
-		addedTip, err := tipsDB.Add(newTip)
+		addedTip, err := tipsDB.Add(context.Background(), newTip)
 		if err != nil {
 			t.Errorf("Failed to add tip: %v", err)
 		}
 
-		tip, err := tipsDB.FindByID(addedTip.Id)
+		tip, err := tipsDB.FindByID(context.Background(), addedTip.Id)
 		if err != nil {
 			t.Errorf("Failed to find tip by ID: %v", err)
 		}
@@ -126,7 +127,7 @@ func testPatch(t *testing.T) {
 		tipsDB := tips.TipsDB{DB: db}
 		newTip := &tips.AddTip{Title: "Test Title", Tip: "This is a test tip."}
 
-		addedTip, err := tipsDB.Add(newTip)
+		addedTip, err := tipsDB.Add(context.Background(), newTip)
 		if err != nil {
 			t.Errorf("Failed to add tip: %v", err)
 		}
@@ -138,7 +139,7 @@ func testPatch(t *testing.T) {
 			Tip:   &updatedTipDescription,
 		}
 
-		patchedTip, err := tipsDB.Patch(addedTip.Id, updatedTip)
+		patchedTip, err := tipsDB.Patch(context.Background(), addedTip.Id, updatedTip)
 		if err != nil {
 			t.Errorf("Failed to update tip: %v", err)
 		}
@@ -160,17 +161,17 @@ func testDelete(t *testing.T) {
 		tipsDB := tips.TipsDB{DB: db}
 		newTip := &tips.AddTip{Title: "Test Title", Tip: "This is a test tip."}
 
-		addedTip, err := tipsDB.Add(newTip)
+		addedTip, err := tipsDB.Add(context.Background(), newTip)
 		if err != nil {
 			t.Errorf("Failed to add tip: %v", err)
 		}
 
-		err = tipsDB.Delete(addedTip.Id)
+		err = tipsDB.Delete(context.Background(), addedTip.Id)
 		if err != nil {
 			t.Errorf("Failed to delete tip: %v", err)
 		}
 
-		tip, err := tipsDB.FindByID(addedTip.Id)
+		tip, err := tipsDB.FindByID(context.Background(), addedTip.Id)
 		if err == nil || tip != nil {
 			t.Errorf("Expected error when finding deleted tip, got nil")
 		}
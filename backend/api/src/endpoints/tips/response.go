@@ -0,0 +1,28 @@
+package tips
+
+import (
+	"time"
+
+	"soul-connection.com/api/src/changes"
+)
+
+// TipResponse is the JSON of a Tip.
+type TipResponse struct {
+	Id        int       `json:"id"`
+	Title     string    `json:"title"`
+	Tip       string    `json:"tip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (t Tip) Response() TipResponse {
+	return TipResponse{
+		Id:        t.Id,
+		Title:     t.Title,
+		Tip:       t.Tip,
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func (t Tip) change(action changes.Action) changes.Change {
+	return changes.New(changes.Tips, action, t.Id, t.Response())
+}
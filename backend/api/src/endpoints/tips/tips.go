@@ -1,43 +1,65 @@
 package tips
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
 
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/export"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/validation"
 )
 
 type Tip struct {
-	Id        int
-	Title     string
-	Tip       string
-	CreatedAt time.Time
+	Id        int       `json:"id" db:"id"`
+	Title     string    `json:"title" db:"title"`
+	Tip       string    `json:"tip" db:"tip"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// TipsRepository is the storage behind TipModel.
+type TipsRepository interface {
+	FindAll(context.Context) ([]Tip, error)
+	StreamAll(context.Context, func(Tip) error) error
+	FindByID(context.Context, int) (*Tip, error)
+	Add(context.Context, *AddTip) (*Tip, error)
+	Delete(context.Context, int) error
+	Patch(context.Context, int, *UpdateTip) (*Tip, error)
 }
 
 type TipModel struct {
-	Tips interface {
-		FindAll() ([]Tip, error)
-		FindByID(int) (*Tip, error)
-		Add(*AddTip) (*Tip, error)
-		Delete(int) error
-		Patch(int, *UpdateTip) (*Tip, error)
-	}
+	Tips    TipsRepository
+	Changes *changes.Bus
+	Audit   *audit.Log
 }
 
-func (model *TipModel) GetAllTips(res http.ResponseWriter, _ *http.Request) {
-	tips, err := model.Tips.FindAll()
+func (model *TipModel) GetAllTips(res http.ResponseWriter, req *http.Request) {
+	format, err := export.Format(req)
+	if err != nil {
+		apierror.Write(res, req, apierror.BadRequest(err.Error()))
+		return
+	}
+	if format != export.JSON {
+		export.Write(res, req, format, "tips", func(fn func(TipResponse) error) error {
+			return model.Tips.StreamAll(req.Context(), func(item Tip) error { return fn(item.Response()) })
+		})
+		return
+	}
+
+	tips, err := model.Tips.FindAll(req.Context())
 
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(tips); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(lib.Map(tips, Tip.Response)); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -45,46 +67,45 @@ func (model *TipModel) GetAllTips(res http.ResponseWriter, _ *http.Request) {
 func (model *TipModel) GetTipById(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "tip_id")
 	if err != nil {
-		http.Error(res, "Invalid tip ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid tip ID format"))
 		return
 	}
 
-	tip, err := model.Tips.FindByID(id)
+	tip, err := model.Tips.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Tips not found", http.StatusNotFound)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*tip); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(tip.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
 
 func (model *TipModel) AddTip(res http.ResponseWriter, req *http.Request) {
 	var nc AddTip
-	err := json.NewDecoder(req.Body).Decode(&nc)
-
-	if err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := validation.Decode(req.Body, &nc); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
-	tip, err := model.Tips.Add(&nc)
+	var tip *Tip
+	err := model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if tip, err = model.Tips.Add(ctx, &nc); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Tips, audit.Created, tip.Id, nil, tip.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to add tip", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), tip.change(changes.Created))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(*tip); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(tip.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -92,21 +113,30 @@ func (model *TipModel) AddTip(res http.ResponseWriter, req *http.Request) {
 func (model *TipModel) DeleteTip(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "tip_id")
 	if err != nil {
-		http.Error(res, "Invalid tip ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid tip ID format"))
 		return
 	}
 
-	err = model.Tips.Delete(id)
+	previous, err := model.Tips.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Unable to delete tip", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
+		return
 	}
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) error {
+		if err := model.Tips.Delete(ctx, id); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Tips, audit.Deleted, id, previous.Response(), nil)
+	})
+	if err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+	model.Changes.Publish(req.Context(), changes.New(changes.Tips, changes.Deleted, id, nil))
 
 	res.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(res).Encode(map[string]string{"status": "ok"}); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 }
@@ -114,30 +144,38 @@ func (model *TipModel) DeleteTip(res http.ResponseWriter, req *http.Request) {
 func (model *TipModel) PatchTips(res http.ResponseWriter, req *http.Request) {
 	id, err := lib.GetIdFromRequest(req, "tip_id")
 	if err != nil {
-		http.Error(res, "Invalid tip ID format", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, apierror.BadRequest("Invalid tip ID format"))
 		return
 	}
 
 	var updates UpdateTip
-	err = json.NewDecoder(req.Body).Decode(&updates)
+	if err := validation.Decode(req.Body, &updates); err != nil {
+		apierror.Write(res, req, err)
+		return
+	}
+
+	previous, err := model.Tips.FindByID(req.Context(), id)
 	if err != nil {
-		http.Error(res, "Invalid request body", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
 
-	updatedTip, err := model.Tips.Patch(id, &updates)
+	var updatedTip *Tip
+	err = model.Audit.Write(req.Context(), func(ctx context.Context) (err error) {
+		if updatedTip, err = model.Tips.Patch(ctx, id, &updates); err != nil {
+			return err
+		}
+		return model.Audit.Record(ctx, changes.Tips, audit.Updated, id, previous.Response(), updatedTip.Response())
+	})
 	if err != nil {
-		http.Error(res, "Unable to update tip", http.StatusBadRequest)
-		lib.ServerLog("ERROR", err)
+		apierror.Write(res, req, err)
 		return
 	}
+	model.Changes.Publish(req.Context(), updatedTip.change(changes.Updated))
 
 	res.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(res).Encode(updatedTip); err != nil {
-		http.Error(res, "Internal server error", http.StatusInternalServerError)
-		lib.ServerLog("ERROR", err)
+	if err := json.NewEncoder(res).Encode(updatedTip.Response()); err != nil {
+		apierror.Write(res, req, err)
 		return
 	}
 }
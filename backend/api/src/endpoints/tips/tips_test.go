@@ -2,6 +2,8 @@ package tips_test
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -17,39 +19,39 @@ type MockTipsDB struct {
 	Tips []tips.Tip
 }
 
-func (m *MockTipsDB) FindAll() ([]tips.Tip, error) {
+func (m *MockTipsDB) FindAll(ctx context.Context) ([]tips.Tip, error) {
 	if m.Tips == nil {
 		return nil, errors.New("no tips found")
 	}
 	return m.Tips, nil
 }
 
-func (m *MockTipsDB) FindByID(id int) (*tips.Tip, error) {
+func (m *MockTipsDB) FindByID(ctx context.Context, id int) (*tips.Tip, error) {
 	for _, tip := range m.Tips {
 		if tip.Id == id {
 			return &tip, nil
 		}
 	}
-	return nil, errors.New("tip not found")
+	return nil, sql.ErrNoRows
 }
 
-func (m *MockTipsDB) Add(tip *tips.AddTip) (*tips.Tip, error) {
+func (m *MockTipsDB) Add(ctx context.Context, tip *tips.AddTip) (*tips.Tip, error) {
 	newTip := tips.Tip{Id: len(m.Tips) + 1, Title: tip.Title, Tip: tip.Tip}
 	m.Tips = append(m.Tips, newTip)
 	return &newTip, nil
 }
 
-func (m *MockTipsDB) Delete(id int) error {
+func (m *MockTipsDB) Delete(ctx context.Context, id int) error {
 	for i, tip := range m.Tips {
 		if tip.Id == id {
 			m.Tips = append(m.Tips[:i], m.Tips[i+1:]...)
 			return nil
 		}
 	}
-	return errors.New("tip not found")
+	return sql.ErrNoRows
 }
 
-func (m *MockTipsDB) Patch(id int, updates *tips.UpdateTip) (*tips.Tip, error) {
+func (m *MockTipsDB) Patch(ctx context.Context, id int, updates *tips.UpdateTip) (*tips.Tip, error) {
 	for i, tip := range m.Tips {
 		if tip.Id != id {
 			continue
@@ -72,7 +74,20 @@ func (m *MockTipsDB) Patch(id int, updates *tips.UpdateTip) (*tips.Tip, error) {
 
 		return &m.Tips[i], nil
 	}
-	return nil, errors.New("tip not found")
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockTipsDB) StreamAll(ctx context.Context, fn func(tips.Tip) error) error {
+	all, err := m.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range all {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func setupTestModel(mockDB *MockTipsDB) *tips.TipModel {
@@ -111,7 +126,7 @@ func testAddTip(t *testing.T) {
 		req := httptest.NewRequest(http.MethodPost, "/api/tips", bytes.NewBuffer([]byte(`{invalid json}`)))
 		rr := httptest.NewRecorder()
 		model.AddTip(rr, req)
-		checkResponseCode(t, rr, http.StatusInternalServerError)
+		checkResponseCode(t, rr, http.StatusBadRequest)
 	})
 
 	t.Run("Add Tip", func(t *testing.T) {
@@ -132,8 +147,8 @@ func testAddTip(t *testing.T) {
 func testGetAllTips(t *testing.T) {
 	t.Run("Get All Tips", func(t *testing.T) {
 		model := setupTestModel(&MockTipsDB{})
-		model.Tips.Add(&tips.AddTip{Title: "Tip 1", Tip: "This is tip 1"})
-		model.Tips.Add(&tips.AddTip{Title: "Tip 2", Tip: "This is tip 2"})
+		model.Tips.Add(context.Background(), &tips.AddTip{Title: "Tip 1", Tip: "This is tip 1"})
+		model.Tips.Add(context.Background(), &tips.AddTip{Title: "Tip 2", Tip: "This is tip 2"})
 
 		req := createRequest(t, http.MethodGet, "/api/tips", nil)
 		rr := httptest.NewRecorder()
@@ -162,7 +177,7 @@ func testGetAllTips(t *testing.T) {
 func testGetTipById(t *testing.T) {
 	t.Run("Get Tip by ID", func(t *testing.T) {
 		model := setupTestModel(&MockTipsDB{})
-		model.Tips.Add(&tips.AddTip{Title: "Tip 1", Tip: "This is tip 1"})
+		model.Tips.Add(context.Background(), &tips.AddTip{Title: "Tip 1", Tip: "This is tip 1"})
 
 		req := createRequest(t, http.MethodGet, "/api/tips/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"tip_id": "1"})
@@ -203,7 +218,7 @@ func testGetTipById(t *testing.T) {
 func testDeleteTip(t *testing.T) {
 	t.Run("Delete Tip", func(t *testing.T) {
 		model := setupTestModel(&MockTipsDB{})
-		model.Tips.Add(&tips.AddTip{Title: "Tip to delete", Tip: "This tip will be deleted"})
+		model.Tips.Add(context.Background(), &tips.AddTip{Title: "Tip to delete", Tip: "This tip will be deleted"})
 
 		req := createRequest(t, http.MethodDelete, "/api/tips/1", nil)
 		req = mux.SetURLVars(req, map[string]string{"tip_id": "1"})
@@ -226,7 +241,7 @@ func testDeleteTip(t *testing.T) {
 
 		model.DeleteTip(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Deleting Tip", func(t *testing.T) {
@@ -237,7 +252,7 @@ func testDeleteTip(t *testing.T) {
 
 		model.DeleteTip(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
@@ -245,7 +260,7 @@ func testPatchTips(t *testing.T) {
 	newTitle := "Updated Title"
 	t.Run("Patch Tip", func(t *testing.T) {
 		model := setupTestModel(&MockTipsDB{})
-		model.Tips.Add(&tips.AddTip{Title: "Original Title", Tip: "Original Tip"})
+		model.Tips.Add(context.Background(), &tips.AddTip{Title: "Original Title", Tip: "Original Tip"})
 		newTitle := "Updated Title"
 
 		req := createRequest(t, http.MethodPatch, "/api/tips/1", &tips.UpdateTip{Title: &newTitle})
@@ -272,7 +287,7 @@ func testPatchTips(t *testing.T) {
 
 		model.PatchTips(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 
 	t.Run("Error Patching Tip", func(t *testing.T) {
@@ -283,7 +298,7 @@ func testPatchTips(t *testing.T) {
 
 		model.PatchTips(rr, req)
 
-		checkResponseCode(t, rr, http.StatusBadRequest)
+		checkResponseCode(t, rr, http.StatusNotFound)
 	})
 }
 
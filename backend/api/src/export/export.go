@@ -0,0 +1,181 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/logger"
+)
+
+const (
+	JSON = "json"
+	CSV  = "csv"
+	XLSX = "xlsx"
+
+	csvMimeType  = "text/csv"
+	xlsxMimeType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format, expected json, csv or xlsx")
+
+type cell struct {
+	value   string
+	numeric bool
+}
+
+type rowWriter interface {
+	writeRow([]cell) error
+	close() error
+}
+
+// Format returns the format asked by the client, ?format takes precedence over
+// the Accept header and JSON is the default.
+func Format(req *http.Request) (string, error) {
+	switch format := strings.ToLower(req.URL.Query().Get("format")); format {
+	case "":
+	case JSON, CSV, XLSX:
+		return format, nil
+	default:
+		return "", ErrUnknownFormat
+	}
+
+	accept := req.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, csvMimeType):
+		return CSV, nil
+	case strings.Contains(accept, xlsxMimeType):
+		return XLSX, nil
+	}
+	return JSON, nil
+}
+
+// Write streams every row produced by stream as a csv or xlsx attachment, one
+// column per exported field of T. Nothing is sent until the first row so an
+// error raised before it is still answered with a 500.
+func Write[T any](res http.ResponseWriter, req *http.Request, format string, name string, stream func(func(T) error) error) {
+	header := columns(reflect.TypeFor[T]())
+	started := false
+	var w rowWriter
+
+	start := func() error {
+		started = true
+		switch format {
+		case CSV:
+			res.Header().Set("Content-Type", csvMimeType)
+		case XLSX:
+			res.Header().Set("Content-Type", xlsxMimeType)
+		}
+		res.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s.%s", name, format)))
+
+		var err error
+		if format == XLSX {
+			w, err = newXlsxWriter(res, name)
+		} else {
+			w = newCsvWriter(res)
+		}
+		if err != nil {
+			return err
+		}
+		return w.writeRow(header)
+	}
+
+	err := stream(func(v T) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return w.writeRow(values(reflect.ValueOf(v)))
+	})
+	if err != nil {
+		if !started {
+			apierror.Write(res, req, err)
+			return
+		}
+		// NOTE: The status is already sent, the client gets a truncated file
+		logger.FromContext(req.Context()).Error("export failed", "error", err)
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			logger.FromContext(req.Context()).Error("export failed", "error", err)
+			return
+		}
+	}
+	if err := w.close(); err != nil {
+		logger.FromContext(req.Context()).Error("export failed", "error", err)
+	}
+}
+
+func exported(field reflect.StructField) bool {
+	return field.IsExported() && field.Tag.Get("export") != "-"
+}
+
+func columns(t reflect.Type) []cell {
+	var header []cell
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !exported(field) {
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			name = tag
+		}
+		header = append(header, cell{value: name})
+	}
+	return header
+}
+
+func values(v reflect.Value) []cell {
+	var row []cell
+	for i := 0; i < v.NumField(); i++ {
+		if !exported(v.Type().Field(i)) {
+			continue
+		}
+		row = append(row, formatCell(v.Field(i)))
+	}
+	return row
+}
+
+func formatCell(v reflect.Value) cell {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return cell{}
+		}
+		v = v.Elem()
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return cell{}
+		}
+		return cell{value: value.Format(time.RFC3339)}
+	case fmt.Stringer:
+		return cell{value: value.String(), numeric: isNumber(v.Kind())}
+	}
+
+	switch {
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		return cell{value: strconv.FormatInt(v.Int(), 10), numeric: true}
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		return cell{value: strconv.FormatUint(v.Uint(), 10), numeric: true}
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		return cell{value: strconv.FormatFloat(v.Float(), 'f', -1, 64), numeric: true}
+	case v.Kind() == reflect.Bool:
+		return cell{value: strconv.FormatBool(v.Bool())}
+	}
+	return cell{value: fmt.Sprint(v.Interface())}
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
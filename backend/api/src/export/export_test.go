@@ -0,0 +1,173 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type row struct {
+	Id        int
+	Name      string
+	Score     *int
+	Password  string `export:"-"`
+	CreatedAt time.Time
+}
+
+func streamRows(rows []row) func(func(row) error) error {
+	return func(fn func(row) error) error {
+		for _, r := range rows {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		expected string
+		err      error
+	}{
+		{"Default", "/api/customers", "", JSON, nil},
+		{"Query CSV", "/api/customers?format=csv", "", CSV, nil},
+		{"Query XLSX", "/api/customers?format=XLSX", "", XLSX, nil},
+		{"Query Overrides Accept", "/api/customers?format=json", "text/csv", JSON, nil},
+		{"Accept CSV", "/api/customers", "text/csv", CSV, nil},
+		{"Accept XLSX", "/api/customers", xlsxMimeType, XLSX, nil},
+		{"Unknown", "/api/customers?format=pdf", "", "", ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept", tt.accept)
+
+			format, err := Format(req)
+			if err != tt.err || format != tt.expected {
+				t.Errorf("Format() = %q, %v; want %q, %v", format, err, tt.expected, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	score := 12
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest("GET", "/", nil), CSV, "rows", streamRows([]row{
+		{Id: 1, Name: "Zoé", Score: &score, Password: "secret", CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Id: 2, Name: "=HYPERLINK(\"x\")"},
+	}))
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != csvMimeType {
+		t.Errorf("Expected %s, got %s", csvMimeType, contentType)
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); !strings.Contains(disposition, "rows.csv") {
+		t.Errorf("Expected rows.csv attachment, got %s", disposition)
+	}
+
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read csv: %v", err)
+	}
+	expected := [][]string{
+		{"Id", "Name", "Score", "CreatedAt"},
+		{"1", "Zoé", "12", "2024-01-02T03:04:05Z"},
+		{"2", "'=HYPERLINK(\"x\")", "", ""},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d", len(expected), len(records))
+	}
+	for i := range expected {
+		if strings.Join(records[i], "|") != strings.Join(expected[i], "|") {
+			t.Errorf("Record %d = %v; want %v", i, records[i], expected[i])
+		}
+	}
+}
+
+// unwrapper hides the Flusher of its writer like the middlewares that only
+// Unwrap.
+type unwrapper struct {
+	http.ResponseWriter
+}
+
+func (w unwrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestWriteCSVFlushes(t *testing.T) {
+	rows := make([]row, flushEvery)
+	rr := httptest.NewRecorder()
+	Write(unwrapper{rr}, httptest.NewRequest("GET", "/", nil), CSV, "rows", streamRows(rows))
+
+	if !rr.Flushed {
+		t.Errorf("Expected the rows to be flushed through the wrapper")
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rr.Code)
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Write(rr, httptest.NewRequest("GET", "/", nil), XLSX, "rows", streamRows([]row{{Id: 1, Name: "A & B"}}))
+
+	body := rr.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Failed to open xlsx: %v", err)
+	}
+
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Failed to open sheet: %v", err)
+		}
+		content, _ := io.ReadAll(r)
+		sheet = string(content)
+	}
+
+	if !strings.Contains(sheet, "<c><v>1</v></c>") || !strings.Contains(sheet, "A &amp; B") {
+		t.Errorf("Unexpected sheet content: %s", sheet)
+	}
+	if !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Errorf("Sheet is not closed: %s", sheet)
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	t.Run("Error Before First Row", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		Write(rr, httptest.NewRequest("GET", "/", nil), CSV, "rows", func(fn func(row) error) error {
+			return errors.New("database error")
+		})
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("Empty Export Has Header", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		Write(rr, httptest.NewRequest("GET", "/", nil), CSV, "rows", streamRows(nil))
+
+		if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "Id,Name,Score,CreatedAt" {
+			t.Errorf("Unexpected response %d: %q", rr.Code, rr.Body.String())
+		}
+	})
+}
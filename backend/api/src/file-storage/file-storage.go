@@ -3,52 +3,82 @@ package filestorage
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
-	"os"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"soul-connection.com/api/src/metrics"
+	"soul-connection.com/api/src/tracing"
 )
 
-func Open(connectionString string) (*mongo.Client, context.Context, error) {
-	ctx := context.TODO()
+// OperationTimeout bounds every upload, download and delete on top of the
+// deadline of the context they run with, zero disables it.
+var OperationTimeout = 30 * time.Second
+
+func Open(ctx context.Context, connectionString string) (*mongo.Client, error) {
 	filestorage, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionString))
 
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	if err := filestorage.Ping(ctx, nil); err != nil {
-		return nil, nil, err
+		filestorage.Disconnect(context.Background())
+		return nil, err
 	}
-	return filestorage, ctx, nil
+	return filestorage, nil
 }
 
-func ConnectionString() string {
-	mongo_user := os.Getenv("MONGO_USER")
-	mongo_password := os.Getenv("MONGO_PASSWORD")
-	mongo_host := os.Getenv("MONGO_HOST")
-	mongo_port := os.Getenv("MONGO_PORT")
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if OperationTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, OperationTimeout)
+}
 
-	return fmt.Sprintf("mongodb://%s:%s@%s:%s/?authSource=admin", mongo_user, mongo_password, mongo_host, mongo_port)
+// NOTE: GridFS streams only know about deadlines, contextReader makes them
+// stop as soon as ctx is cancelled as well.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
 }
 
-func Upload(bucket *gridfs.Bucket, r io.Reader, filename string) (*primitive.ObjectID, error) {
-	fileBytes, err := io.ReadAll(r)
-	if err != nil {
-		return nil, err
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.r.Read(p)
+}
+
+func Upload(ctx context.Context, bucket *gridfs.Bucket, r io.Reader, filename string) (_ *primitive.ObjectID, err error) {
+	ctx, span := startSpan(ctx, "gridfs.upload", bucket, attribute.String("gridfs.filename", filename))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	uploadStream, err := bucket.OpenUploadStream(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer uploadStream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		uploadStream.SetWriteDeadline(deadline)
+	}
 
-	_, err = uploadStream.Write(fileBytes)
+	written, err := io.Copy(uploadStream, contextReader{ctx: ctx, r: r})
+	metrics.StorageUploadedBytes.WithLabelValues(bucketName(bucket)).Add(float64(written))
+	span.SetAttributes(attribute.Int64("gridfs.bytes", written))
 	if err != nil {
+		uploadStream.Abort()
+		return nil, err
+	}
+	if err := uploadStream.Close(); err != nil {
 		return nil, err
 	}
 
@@ -59,38 +89,74 @@ func Upload(bucket *gridfs.Bucket, r io.Reader, filename string) (*primitive.Obj
 	return &fileObjectId, nil
 }
 
-func DownloadByName(bucket *gridfs.Bucket, filename string) ([]byte, error) {
+func DownloadByName(ctx context.Context, bucket *gridfs.Bucket, filename string) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "gridfs.download", bucket, attribute.String("gridfs.filename", filename))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	downloadStream, err := bucket.OpenDownloadStreamByName(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer downloadStream.Close()
 
-	fileContent, err := io.ReadAll(downloadStream)
-	if err != nil {
-		return nil, err
-	}
-	return fileContent, nil
+	return download(ctx, bucket, downloadStream)
 }
 
-func DownloadById(bucket *gridfs.Bucket, fileId primitive.ObjectID) ([]byte, error) {
+func DownloadById(ctx context.Context, bucket *gridfs.Bucket, fileId primitive.ObjectID) (_ []byte, err error) {
+	ctx, span := startSpan(ctx, "gridfs.download", bucket, attribute.String("gridfs.file_id", fileId.Hex()))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	downloadStream, err := bucket.OpenDownloadStream(fileId)
 	if err != nil {
 		return nil, err
 	}
 	defer downloadStream.Close()
 
-	fileContent, err := io.ReadAll(downloadStream)
+	return download(ctx, bucket, downloadStream)
+}
+
+func download(ctx context.Context, bucket *gridfs.Bucket, downloadStream *gridfs.DownloadStream) ([]byte, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		downloadStream.SetReadDeadline(deadline)
+	}
+
+	fileContent, err := io.ReadAll(contextReader{ctx: ctx, r: downloadStream})
+	metrics.StorageDownloadedBytes.WithLabelValues(bucketName(bucket)).Add(float64(len(fileContent)))
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("gridfs.bytes", len(fileContent)))
 	if err != nil {
 		return nil, err
 	}
 	return fileContent, nil
 }
 
-func Delete(bucket *gridfs.Bucket, fileId primitive.ObjectID) error {
-	err := bucket.Delete(fileId)
-	if err != nil {
-		return err
-	}
-	return nil
+func Delete(ctx context.Context, bucket *gridfs.Bucket, fileId primitive.ObjectID) (err error) {
+	ctx, span := startSpan(ctx, "gridfs.delete", bucket, attribute.String("gridfs.file_id", fileId.Hex()))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return bucket.DeleteContext(ctx, fileId)
+}
+
+// bucketName answers the name the bucket was created with, GridFS only keeps
+// the name of its files collection.
+func bucketName(bucket *gridfs.Bucket) string {
+	return strings.TrimSuffix(bucket.GetFilesCollection().Name(), ".files")
+}
+
+func startSpan(ctx context.Context, name string, bucket *gridfs.Bucket, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	attributes = append(attributes, attribute.String("gridfs.bucket", bucketName(bucket)))
+	return tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+func endSpan(span trace.Span, err error) {
+	tracing.Fail(span, err)
+	span.End()
 }
@@ -0,0 +1,101 @@
+// Package graph serves the entities of the api and their relations as a
+// GraphQL schema, read from the same repositories as the REST routes.
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/middleware"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxDepth bounds how far a query may follow relations, customer { coach {
+// customers { payments } } } is already four levels deep.
+const maxDepth = 8
+
+// Repositories are the storage the schema reads from.
+type Repositories struct {
+	Employees  employees.EmployeesRepository
+	Customers  customers.CustomersRepository
+	Events     events.EventsRepository
+	Payments   payments.PaymentsRepository
+	Encounters encounters.EncountersRepository
+	Clothes    clothes.ClothesRepository
+	Tips       tips.TipsRepository
+}
+
+// Request is the body of a POST to the GraphQL endpoint.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Response is what every query answers, with a 200 even when some fields
+// failed: Data holds what could be read and Errors what could not.
+type Response = graphql.Response
+
+// Handler answers GraphQL queries over repos. Every request gets its own
+// loaders so nothing read for one user is ever served to another.
+func Handler(repos Repositories) (http.HandlerFunc, error) {
+	parsed, err := graphql.ParseSchema(schema, &query{repos: repos}, graphql.MaxDepth(maxDepth))
+	if err != nil {
+		return nil, err
+	}
+	return func(res http.ResponseWriter, req *http.Request) {
+		var request Request
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			apierror.Write(res, req, apierror.BadRequest("Invalid request body"))
+			return
+		}
+
+		user, _ := middleware.UserFrom(req.Context())
+		ctx := withSession(req.Context(), newSession(user, repos))
+		response := parsed.Exec(ctx, request.Query, request.OperationName, request.Variables)
+
+		res.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(res).Encode(response); err != nil {
+			logger.FromContext(req.Context()).Error("could not write graphql response", "error", err)
+		}
+	}, nil
+}
+
+// resolverError is an error of a field, sent with the code of the REST error
+// it maps to and never with its cause.
+type resolverError struct {
+	err *apierror.Error
+}
+
+func (e resolverError) Error() string {
+	return e.err.Message
+}
+
+func (e resolverError) Extensions() map[string]any {
+	return map[string]any{"code": e.err.Code}
+}
+
+// fail maps err like apierror.Write does, server errors are logged since
+// they only reach the client as an opaque message.
+func fail(ctx context.Context, err error) error {
+	apiErr := apierror.From(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		logger.FromContext(ctx).Error("graphql field failed", "error", err)
+	}
+	return resolverError{err: apiErr}
+}
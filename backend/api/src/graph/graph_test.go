@@ -0,0 +1,366 @@
+package graph_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/endpoints"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/graph"
+	"soul-connection.com/api/src/health"
+	"soul-connection.com/api/src/middleware"
+)
+
+// The mocks count the batched queries, the embedded interfaces panic on
+// anything the schema should not call.
+
+type MockEmployeesDB struct {
+	employees.EmployeesRepository
+	Employees []employees.Employee
+	Batches   atomic.Int32
+}
+
+func (m *MockEmployeesDB) FindByOldID(ctx context.Context, id int) (*employees.Employee, error) {
+	for _, employee := range m.Employees {
+		if employee.Soul_Connection_Id != nil && *employee.Soul_Connection_Id == id {
+			return &employee, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEmployeesDB) FindByID(ctx context.Context, id int) (*employees.Employee, error) {
+	for _, employee := range m.Employees {
+		if employee.Id == id {
+			return &employee, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockEmployeesDB) FindByIDs(ctx context.Context, ids []int) ([]employees.Employee, error) {
+	m.Batches.Add(1)
+	var result []employees.Employee
+	for _, employee := range m.Employees {
+		if slices.Contains(ids, employee.Id) {
+			result = append(result, employee)
+		}
+	}
+	return result, nil
+}
+
+type MockCustomersDB struct {
+	customers.CustomersRepository
+	Customers []customers.Customer
+}
+
+func (m *MockCustomersDB) FindAll(ctx context.Context) ([]customers.Customer, error) {
+	return m.Customers, nil
+}
+
+func (m *MockCustomersDB) FindByID(ctx context.Context, id int) (*customers.Customer, error) {
+	for _, customer := range m.Customers {
+		if customer.Id == id {
+			return &customer, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockCustomersDB) FindByIDs(ctx context.Context, ids []int) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if slices.Contains(ids, customer.Id) {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockCustomersDB) FindByEmployeeID(ctx context.Context, id int) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if customer.Employee_Id != nil && *customer.Employee_Id == id {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+func (m *MockCustomersDB) FindByEmployeeIDs(ctx context.Context, ids []int) ([]customers.Customer, error) {
+	var result []customers.Customer
+	for _, customer := range m.Customers {
+		if customer.Employee_Id != nil && slices.Contains(ids, *customer.Employee_Id) {
+			result = append(result, customer)
+		}
+	}
+	return result, nil
+}
+
+type MockPaymentsDB struct {
+	payments.PaymentsRepository
+	Payments []payments.Payment
+	Batches  atomic.Int32
+}
+
+func (m *MockPaymentsDB) FindAll(ctx context.Context) ([]payments.Payment, error) {
+	return m.Payments, nil
+}
+
+func (m *MockPaymentsDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]payments.Payment, error) {
+	m.Batches.Add(1)
+	var result []payments.Payment
+	for _, payment := range m.Payments {
+		if slices.Contains(ids, payment.CustomerId) {
+			result = append(result, payment)
+		}
+	}
+	return result, nil
+}
+
+type MockEncountersDB struct {
+	encounters.EncountersRepository
+	Batches atomic.Int32
+}
+
+func (m *MockEncountersDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]encounters.Encounter, error) {
+	m.Batches.Add(1)
+	return nil, nil
+}
+
+type MockClothesDB struct {
+	clothes.ClothesRepository
+	Batches atomic.Int32
+}
+
+func (m *MockClothesDB) FindByCustomerIDs(ctx context.Context, ids []int) ([]clothes.Clothe, error) {
+	m.Batches.Add(1)
+	return []clothes.Clothe{{Id: 1, Type: "hat/cap", CustomerId: 1}}, nil
+}
+
+type mocks struct {
+	employees  *MockEmployeesDB
+	payments   *MockPaymentsDB
+	encounters *MockEncountersDB
+	clothes    *MockClothesDB
+}
+
+func setupRepositories(t *testing.T) (graph.Repositories, *mocks) {
+	coachId, otherId := 7, 8
+	coachOldId, managerOldId := 70, 90
+	m := &mocks{
+		employees: &MockEmployeesDB{Employees: []employees.Employee{
+			{Id: coachId, Soul_Connection_Id: &coachOldId, Email: "coach@example.com", Name: "Carla", Work: "Coach"},
+			{Id: otherId, Email: "other@example.com", Name: "Oscar", Work: "Coach"},
+			{Id: 9, Soul_Connection_Id: &managerOldId, Email: "manager@example.com", Name: "Mona", Work: "Manager"},
+		}},
+		payments: &MockPaymentsDB{Payments: []payments.Payment{
+			{Id: 1, PaymentMethod: "PayPal", Amount: 1250, CustomerId: 1},
+			{Id: 2, PaymentMethod: "Credit Card", Amount: -300, CustomerId: 2},
+			{Id: 3, PaymentMethod: "PayPal", Amount: 1000, CustomerId: 3},
+		}},
+		encounters: &MockEncountersDB{},
+		clothes:    &MockClothesDB{},
+	}
+	repos := graph.Repositories{
+		Employees: m.employees,
+		Customers: &MockCustomersDB{Customers: []customers.Customer{
+			{Id: 1, Name: "Jane", Employee_Id: &coachId},
+			{Id: 2, Name: "John", Employee_Id: &coachId},
+			{Id: 3, Name: "Ada", Employee_Id: &otherId},
+		}},
+		Events:     events.EventsDB{},
+		Payments:   m.payments,
+		Encounters: m.encounters,
+		Clothes:    m.clothes,
+		Tips:       tips.TipsDB{},
+	}
+	return repos, m
+}
+
+func setupHandler(t *testing.T) (http.HandlerFunc, *mocks) {
+	repos, m := setupRepositories(t)
+	handler, err := graph.Handler(repos)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	return handler, m
+}
+
+type response struct {
+	Data   map[string]json.RawMessage
+	Errors []struct {
+		Message    string
+		Path       []any
+		Extensions map[string]any
+	}
+}
+
+func execute(t *testing.T, handler http.HandlerFunc, user *middleware.User, query string) response {
+	body, _ := json.Marshal(graph.Request{Query: query})
+	req := httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(string(body)))
+	if user != nil {
+		req = req.WithContext(middleware.WithUser(req.Context(), user))
+	}
+	res := httptest.NewRecorder()
+	handler(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", res.Code, res.Body.String())
+	}
+	var result response
+	if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Expected a GraphQL response: %v", err)
+	}
+	return result
+}
+
+func TestGraph(t *testing.T) {
+	manager := &middleware.User{Id: 90, Work: "Manager"}
+	coach := &middleware.User{Id: 70, Work: middleware.CoachWork}
+
+	t.Run("Relations Are Batched", func(t *testing.T) {
+		handler, m := setupHandler(t)
+		result := execute(t, handler, manager, `{ customers { name coach { name } payments { amount payment_method } encounters { id } clothes { type } } }`)
+		if len(result.Errors) != 0 {
+			t.Fatalf("Unexpected errors %+v", result.Errors)
+		}
+
+		var list []struct {
+			Name     string
+			Coach    *struct{ Name string }
+			Payments []struct {
+				Amount         json.Number
+				Payment_Method string
+			}
+			Clothes []struct{ Type string }
+		}
+		if err := json.Unmarshal(result.Data["customers"], &list); err != nil || len(list) != 3 {
+			t.Fatalf("Expected the 3 customers, got %s (%v)", result.Data["customers"], err)
+		}
+		if list[0].Coach == nil || list[0].Coach.Name != "Carla" || list[2].Coach.Name != "Oscar" {
+			t.Errorf("Expected the coach of each customer, got %+v", list)
+		}
+		if len(list[0].Payments) != 1 || list[0].Payments[0].Amount != "12.50" || list[1].Payments[0].Amount != "-3.00" {
+			t.Errorf("Expected the exact amounts, got %+v", list)
+		}
+		if len(list[0].Clothes) != 1 || len(list[1].Clothes) != 0 {
+			t.Errorf("Expected the clothes of customer 1 only, got %+v", list)
+		}
+		for name, batches := range map[string]int32{"employees": m.employees.Batches.Load(), "payments": m.payments.Batches.Load(), "encounters": m.encounters.Batches.Load(), "clothes": m.clothes.Batches.Load()} {
+			if batches != 1 {
+				t.Errorf("Expected the %s of every customer in 1 query, got %d", name, batches)
+			}
+		}
+	})
+
+	t.Run("Coach", func(t *testing.T) {
+		handler, _ := setupHandler(t)
+
+		result := execute(t, handler, coach, `{ customers { id } payments { id } }`)
+		if string(result.Data["customers"]) != `[{"id":1},{"id":2}]` || string(result.Data["payments"]) != `[{"id":1},{"id":2}]` {
+			t.Errorf("Expected only the customers of the coach, got %s", result.Data)
+		}
+
+		result = execute(t, handler, coach, `{ customer(id: 3) { name } }`)
+		if string(result.Data["customer"]) != "null" || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "forbidden" {
+			t.Errorf("Expected the customer of another coach to be forbidden, got %s %+v", result.Data, result.Errors)
+		}
+	})
+
+	t.Run("Per Field", func(t *testing.T) {
+		handler, _ := setupHandler(t)
+
+		result := execute(t, handler, coach, `{ me { name customers { id } } payments { customer { name } } }`)
+		if len(result.Errors) != 0 || string(result.Data["me"]) != `{"name":"Carla","customers":[{"id":1},{"id":2}]}` {
+			t.Fatalf("Expected the coach to read their own customers, got %s %+v", result.Data, result.Errors)
+		}
+
+		// NOTE: Another coach is readable, their customers are not
+		result = execute(t, handler, coach, `{ employee(id: 8) { name customers { id } } mine: customer(id: 1) { name } other: customer(id: 3) { name } }`)
+		if string(result.Data["employee"]) != `{"name":"Oscar","customers":null}` || string(result.Data["mine"]) != `{"name":"Jane"}` || string(result.Data["other"]) != "null" {
+			t.Errorf("Expected only the fields of another coach to be null, got %s", result.Data)
+		}
+		if len(result.Errors) != 2 || result.Errors[0].Extensions["code"] != "forbidden" || result.Errors[1].Extensions["code"] != "forbidden" {
+			t.Errorf("Expected 2 forbidden fields, got %+v", result.Errors)
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		handler, _ := setupHandler(t)
+
+		result := execute(t, handler, nil, `{ customers { id } }`)
+		if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "unauthorized" {
+			t.Errorf("Expected an unauthorized error, got %+v", result.Errors)
+		}
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		handler, _ := setupHandler(t)
+
+		result := execute(t, handler, manager, `{ customers { coach { customers { coach { customers { coach { customers { coach { id } } } } } } } } }`)
+		if len(result.Errors) == 0 || result.Data != nil {
+			t.Errorf("Expected the query to be rejected, got %s", result.Data)
+		}
+	})
+}
+
+func TestRoute(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/employees/me" || req.Header.Get("Authorization") != "Bearer valid" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(res).Encode(map[string]any{"id": 90, "email": "manager@example.com", "work": "Manager"})
+	}))
+	defer upstream.Close()
+
+	repos, _ := setupRepositories(t)
+	cfg := &config.Config{}
+	cfg.Upstream.BaseUri = upstream.URL
+	router, err := endpoints.NewRouter(cfg, endpoints.Models{
+		Employees:  employees.EmployeesModel{Employees: repos.Employees},
+		Customers:  customers.CustomersModel{Customers: repos.Customers},
+		Events:     events.EventModel{Events: repos.Events},
+		Payments:   payments.PaymentModel{Payments: repos.Payments},
+		Encounters: encounters.EncounterModel{Encounters: repos.Encounters},
+		Clothes:    clothes.ClothesModel{Clothes: repos.Clothes},
+		Tips:       tips.TipModel{Tips: repos.Tips},
+	}, &health.Probe{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(`{"query": "{ customer(id: 3) { name } }"}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := post(""); res.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", res.Code)
+	}
+	if res := post("expired"); res.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a rejected token, got %d", res.Code)
+	}
+	if res := post("valid"); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), `"Ada"`) {
+		t.Errorf("Expected the manager to read the customer, got %d %s", res.Code, res.Body.String())
+	}
+}
@@ -0,0 +1,210 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/lib"
+)
+
+// query resolves the fields of Query, the entry points of every read.
+type query struct {
+	repos Repositories
+}
+
+type idArgs struct {
+	Id int32
+}
+
+func (q *query) Me(ctx context.Context) (*employeeResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	employee, err := q.repos.Employees.FindByOldID(ctx, s.user.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEmployee(*employee), nil
+}
+
+func (q *query) Employees(ctx context.Context) ([]*employeeResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := q.repos.Employees.FindAll(ctx)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEmployees(ctx, list), nil
+}
+
+func (q *query) Employee(ctx context.Context, args idArgs) (*employeeResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	employee, err := q.repos.Employees.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEmployee(*employee), nil
+}
+
+func (q *query) Customers(ctx context.Context) ([]*customerResolver, error) {
+	id, coach, err := sessionFrom(ctx).coachOf(ctx)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	var list []customers.Customer
+	if coach {
+		list, err = q.repos.Customers.FindByEmployeeID(ctx, id)
+	} else {
+		list, err = q.repos.Customers.FindAll(ctx)
+	}
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newCustomers(ctx, list), nil
+}
+
+func (q *query) Customer(ctx context.Context, args idArgs) (*customerResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	customer, err := q.repos.Customers.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if err := sessionFrom(ctx).canSeeCustomersOf(ctx, customer.Employee_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newCustomer(*customer), nil
+}
+
+func (q *query) Payments(ctx context.Context) ([]*paymentResolver, error) {
+	list, err := ofVisibleCustomers(ctx, q.repos.Payments.FindAll, q.repos.Payments.FindByCustomerIDs)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newPayments(ctx, list), nil
+}
+
+func (q *query) Payment(ctx context.Context, args idArgs) (*paymentResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	payment, err := q.repos.Payments.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if err := sessionFrom(ctx).canSeeCustomer(ctx, payment.CustomerId); err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newPayment(*payment), nil
+}
+
+func (q *query) Encounters(ctx context.Context) ([]*encounterResolver, error) {
+	list, err := ofVisibleCustomers(ctx, q.repos.Encounters.FindAll, q.repos.Encounters.FindByCustomerIDs)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEncounters(ctx, list), nil
+}
+
+func (q *query) Encounter(ctx context.Context, args idArgs) (*encounterResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	encounter, err := q.repos.Encounters.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if err := sessionFrom(ctx).canSeeCustomer(ctx, encounter.Customer_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEncounter(*encounter), nil
+}
+
+func (q *query) Clothes(ctx context.Context) ([]*clotheResolver, error) {
+	list, err := ofVisibleCustomers(ctx, q.repos.Clothes.FindAll, q.repos.Clothes.FindByCustomerIDs)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newClothes(ctx, list), nil
+}
+
+func (q *query) Clothe(ctx context.Context, args idArgs) (*clotheResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	clothe, err := q.repos.Clothes.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if err := sessionFrom(ctx).canSeeCustomer(ctx, clothe.CustomerId); err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newClothe(*clothe), nil
+}
+
+func (q *query) Events(ctx context.Context) ([]*eventResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := q.repos.Events.FindAll(ctx)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEvents(ctx, list), nil
+}
+
+func (q *query) Event(ctx context.Context, args idArgs) (*eventResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	event, err := q.repos.Events.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEvent(*event), nil
+}
+
+func (q *query) Tips(ctx context.Context) ([]*tipResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := q.repos.Tips.FindAll(ctx)
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return lib.Map(list, newTip), nil
+}
+
+func (q *query) Tip(ctx context.Context, args idArgs) (*tipResolver, error) {
+	if err := sessionFrom(ctx).authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	tip, err := q.repos.Tips.FindByID(ctx, int(args.Id))
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newTip(*tip), nil
+}
+
+// ofVisibleCustomers lists every row for managers and, for a coach, only the
+// rows of their customers.
+func ofVisibleCustomers[T any](ctx context.Context, all func(context.Context) ([]T, error), byCustomers func(context.Context, []int) ([]T, error)) ([]T, error) {
+	ids, every, err := sessionFrom(ctx).visibleCustomers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if every {
+		return all(ctx)
+	}
+	return byCustomers(ctx, ids)
+}
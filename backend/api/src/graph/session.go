@@ -0,0 +1,211 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/graph-gophers/graphql-go"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/middleware"
+)
+
+// batchWait is how long a loader collects keys before running its query. The
+// lists prime their relations up front, see prime, so it only has to cover
+// sibling lists resolved concurrently.
+const batchWait = 2 * time.Millisecond
+
+// session holds what a single request may read and the loaders batching the
+// relations it follows: the coach of a hundred customers is one query.
+type session struct {
+	user  *middleware.User
+	repos Repositories
+
+	once    sync.Once
+	coachId int
+	coach   bool
+	err     error
+
+	employees            *dataloader.Loader[int, *employees.Employee]
+	customers            *dataloader.Loader[int, *customers.Customer]
+	customersByEmployee  *dataloader.Loader[int, []customers.Customer]
+	eventsByEmployee     *dataloader.Loader[int, []events.Event]
+	paymentsByCustomer   *dataloader.Loader[int, []payments.Payment]
+	encountersByCustomer *dataloader.Loader[int, []encounters.Encounter]
+	clothesByCustomer    *dataloader.Loader[int, []clothes.Clothe]
+}
+
+func newSession(user *middleware.User, repos Repositories) *session {
+	// NOTE: The repositories are only called once a query needs them
+	return &session{
+		user:  user,
+		repos: repos,
+		employees: byID(func(ctx context.Context, ids []int) ([]employees.Employee, error) {
+			return repos.Employees.FindByIDs(ctx, ids)
+		}, func(e employees.Employee) int { return e.Id }),
+		customers: byID(func(ctx context.Context, ids []int) ([]customers.Customer, error) {
+			return repos.Customers.FindByIDs(ctx, ids)
+		}, func(c customers.Customer) int { return c.Id }),
+		customersByEmployee: groupedBy(func(ctx context.Context, ids []int) ([]customers.Customer, error) {
+			return repos.Customers.FindByEmployeeIDs(ctx, ids)
+		}, func(c customers.Customer) *int { return c.Employee_Id }),
+		eventsByEmployee: groupedBy(func(ctx context.Context, ids []int) ([]events.Event, error) {
+			return repos.Events.FindByEmployeeIDs(ctx, ids)
+		}, func(e events.Event) *int { return &e.Employee_Id }),
+		paymentsByCustomer: groupedBy(func(ctx context.Context, ids []int) ([]payments.Payment, error) {
+			return repos.Payments.FindByCustomerIDs(ctx, ids)
+		}, func(p payments.Payment) *int { return &p.CustomerId }),
+		encountersByCustomer: groupedBy(func(ctx context.Context, ids []int) ([]encounters.Encounter, error) {
+			return repos.Encounters.FindByCustomerIDs(ctx, ids)
+		}, func(e encounters.Encounter) *int { return &e.Customer_Id }),
+		clothesByCustomer: groupedBy(func(ctx context.Context, ids []int) ([]clothes.Clothe, error) {
+			return repos.Clothes.FindByCustomerIDs(ctx, ids)
+		}, func(c clothes.Clothe) *int { return &c.CustomerId }),
+	}
+}
+
+type sessionKey struct{}
+
+func withSession(ctx context.Context, s *session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+func sessionFrom(ctx context.Context) *session {
+	return ctx.Value(sessionKey{}).(*session)
+}
+
+// authenticated fails every field read without a user, the route is meant to
+// sit behind middleware.AuthProvider.Auth.
+func (s *session) authenticated() error {
+	if s.user == nil {
+		return apierror.Unauthorized("Authentication required")
+	}
+	return nil
+}
+
+// coachOf answers the local id of the employee the user is when they are not
+// a manager, held to their own customers like a coach. A coach without a
+// local record follows nobody and gets 0.
+func (s *session) coachOf(ctx context.Context) (id int, coach bool, err error) {
+	if err := s.authenticated(); err != nil {
+		return 0, false, err
+	}
+	s.once.Do(func() {
+		if s.user.IsManager() {
+			return
+		}
+		s.coach = true
+		employee, err := s.repos.Employees.FindByOldID(ctx, s.user.Id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.coachId = employee.Id
+	})
+	return s.coachId, s.coach, s.err
+}
+
+// canSeeCustomer tells whether the customer of id, and what belongs to
+// them, may be read: managers read every customer and coaches their own.
+func (s *session) canSeeCustomer(ctx context.Context, id int) error {
+	if _, coach, err := s.coachOf(ctx); err != nil || !coach {
+		return err
+	}
+	customer, err := s.customers.Load(ctx, id)()
+	if err != nil {
+		return err
+	}
+	if customer == nil {
+		return apierror.Forbidden("Coaches can only read their own customers")
+	}
+	return s.canSeeCustomersOf(ctx, customer.Employee_Id)
+}
+
+// canSeeCustomersOf tells whether the customers followed by employeeId, or
+// the unassigned ones for nil, may be read.
+func (s *session) canSeeCustomersOf(ctx context.Context, employeeId *int) error {
+	id, coach, err := s.coachOf(ctx)
+	if err != nil {
+		return err
+	}
+	if coach && (employeeId == nil || *employeeId != id) {
+		return apierror.Forbidden("Coaches can only read their own customers")
+	}
+	return nil
+}
+
+// visibleCustomers answers the ids of the customers of a coach, all is set
+// instead for managers who read every customer.
+func (s *session) visibleCustomers(ctx context.Context) (ids []int, all bool, err error) {
+	id, coach, err := s.coachOf(ctx)
+	if err != nil || !coach {
+		return nil, err == nil, err
+	}
+	followed, err := s.repos.Customers.FindByEmployeeID(ctx, id)
+	if err != nil {
+		return nil, false, err
+	}
+	return lib.Map(followed, func(c customers.Customer) int { return c.Id }), false, nil
+}
+
+// byID batches the lookups of single rows by id, missing rows resolve to nil.
+func byID[T any](find func(context.Context, []int) ([]T, error), id func(T) int) *dataloader.Loader[int, *T] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []int) []*dataloader.Result[*T] {
+		rows, err := find(ctx, keys)
+		found := make(map[int]*T, len(rows))
+		for i := range rows {
+			found[id(rows[i])] = &rows[i]
+		}
+		results := make([]*dataloader.Result[*T], len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result[*T]{Data: found[key], Error: err}
+		}
+		return results
+	}, dataloader.WithWait[int, *T](batchWait))
+}
+
+// groupedBy batches the lookups of the rows pointing at a parent, like the
+// payments of customers, a parent without any resolves to an empty list.
+func groupedBy[T any](find func(context.Context, []int) ([]T, error), parent func(T) *int) *dataloader.Loader[int, []T] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []int) []*dataloader.Result[[]T] {
+		rows, err := find(ctx, keys)
+		groups := make(map[int][]T, len(keys))
+		for _, row := range rows {
+			if id := parent(row); id != nil {
+				groups[*id] = append(groups[*id], row)
+			}
+		}
+		results := make([]*dataloader.Result[[]T], len(keys))
+		for i, key := range keys {
+			results[i] = &dataloader.Result[[]T]{Data: groups[key], Error: err}
+		}
+		return results
+	}, dataloader.WithWait[int, []T](batchWait))
+}
+
+// prime queues the loads of field for every item of a list before any item
+// resolves, when the query selects it, so they all end up in one batch.
+func prime[T any, V any](ctx context.Context, field string, loader *dataloader.Loader[int, V], items []T, key func(T) *int) {
+	if !graphql.HasSelectedField(ctx, field) {
+		return
+	}
+	for _, item := range items {
+		if id := key(item); id != nil {
+			loader.Load(ctx, *id)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/graph-gophers/graphql-go"
+
+	"soul-connection.com/api/src/endpoints/clothes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/encounters"
+	"soul-connection.com/api/src/endpoints/events"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/endpoints/tips"
+	"soul-connection.com/api/src/lib"
+)
+
+// NOTE: Resolvers wrap the responses of the REST routes so both expose the
+// same fields, GraphQL integers are int32.
+
+func newEmployees(ctx context.Context, list []employees.Employee) []*employeeResolver {
+	s := sessionFrom(ctx)
+	id := func(e employees.Employee) *int { return &e.Id }
+	prime(ctx, "customers", s.customersByEmployee, list, id)
+	prime(ctx, "events", s.eventsByEmployee, list, id)
+	return lib.Map(list, newEmployee)
+}
+
+type employeeResolver struct {
+	e employees.EmployeeResponse
+}
+
+func newEmployee(e employees.Employee) *employeeResolver {
+	return &employeeResolver{e: e.Response()}
+}
+
+func (r *employeeResolver) Id() int32                  { return int32(r.e.Id) }
+func (r *employeeResolver) Soul_Connection_Id() *int32 { return int32Ptr(r.e.Soul_Connection_Id) }
+func (r *employeeResolver) Email() string              { return r.e.Email }
+func (r *employeeResolver) Name() string               { return r.e.Name }
+func (r *employeeResolver) Surname() string            { return r.e.Surname }
+func (r *employeeResolver) Birth_Date() string         { return r.e.Birth_Date }
+func (r *employeeResolver) Gender() string             { return r.e.Gender }
+func (r *employeeResolver) Work() string               { return r.e.Work }
+func (r *employeeResolver) Has_Image() bool            { return r.e.Has_Image }
+func (r *employeeResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: r.e.CreatedAt} }
+
+// Customers is nullable so a coach reading another coach only loses this
+// field rather than the whole employee.
+func (r *employeeResolver) Customers(ctx context.Context) (*[]*customerResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomersOf(ctx, &r.e.Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := s.customersByEmployee.Load(ctx, r.e.Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	resolvers := newCustomers(ctx, list)
+	return &resolvers, nil
+}
+
+func (r *employeeResolver) Events(ctx context.Context) ([]*eventResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := s.eventsByEmployee.Load(ctx, r.e.Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEvents(ctx, list), nil
+}
+
+func newCustomers(ctx context.Context, list []customers.Customer) []*customerResolver {
+	s := sessionFrom(ctx)
+	id := func(c customers.Customer) *int { return &c.Id }
+	prime(ctx, "coach", s.employees, list, func(c customers.Customer) *int { return c.Employee_Id })
+	prime(ctx, "payments", s.paymentsByCustomer, list, id)
+	prime(ctx, "encounters", s.encountersByCustomer, list, id)
+	prime(ctx, "clothes", s.clothesByCustomer, list, id)
+	return lib.Map(list, newCustomer)
+}
+
+type customerResolver struct {
+	c customers.CustomerResponse
+}
+
+func newCustomer(c customers.Customer) *customerResolver {
+	return &customerResolver{c: c.Response()}
+}
+
+func (r *customerResolver) Id() int32                  { return int32(r.c.Id) }
+func (r *customerResolver) Soul_Connection_Id() *int32 { return int32Ptr(r.c.Soul_Connection_Id) }
+func (r *customerResolver) Email() string              { return r.c.Email }
+func (r *customerResolver) Name() string               { return r.c.Name }
+func (r *customerResolver) Surname() string            { return r.c.Surname }
+func (r *customerResolver) Birth_Date() string         { return r.c.Birth_Date }
+func (r *customerResolver) Gender() string             { return r.c.Gender }
+func (r *customerResolver) Description() string        { return r.c.Description }
+func (r *customerResolver) Astrological_Sign() string  { return r.c.Astrological_Sign }
+func (r *customerResolver) Phone_Number() string       { return r.c.Phone_Number }
+func (r *customerResolver) Address() string            { return r.c.Address }
+func (r *customerResolver) Has_Image() bool            { return r.c.Has_Image }
+func (r *customerResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: r.c.CreatedAt} }
+func (r *customerResolver) Employee_Id() *int32        { return int32Ptr(r.c.Employee_Id) }
+
+func (r *customerResolver) Coach(ctx context.Context) (*employeeResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomersOf(ctx, r.c.Employee_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	if r.c.Employee_Id == nil {
+		return nil, nil
+	}
+	employee, err := s.employees.Load(ctx, *r.c.Employee_Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if employee == nil {
+		return nil, nil
+	}
+	return newEmployee(*employee), nil
+}
+
+func (r *customerResolver) Payments(ctx context.Context) ([]*paymentResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomersOf(ctx, r.c.Employee_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := s.paymentsByCustomer.Load(ctx, r.c.Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newPayments(ctx, list), nil
+}
+
+func (r *customerResolver) Encounters(ctx context.Context) ([]*encounterResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomersOf(ctx, r.c.Employee_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := s.encountersByCustomer.Load(ctx, r.c.Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newEncounters(ctx, list), nil
+}
+
+func (r *customerResolver) Clothes(ctx context.Context) ([]*clotheResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomersOf(ctx, r.c.Employee_Id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	list, err := s.clothesByCustomer.Load(ctx, r.c.Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	return newClothes(ctx, list), nil
+}
+
+func newPayments(ctx context.Context, list []payments.Payment) []*paymentResolver {
+	prime(ctx, "customer", sessionFrom(ctx).customers, list, func(p payments.Payment) *int { return &p.CustomerId })
+	return lib.Map(list, newPayment)
+}
+
+type paymentResolver struct {
+	p payments.PaymentResponse
+}
+
+func newPayment(p payments.Payment) *paymentResolver {
+	return &paymentResolver{p: p.Response()}
+}
+
+func (r *paymentResolver) Id() int32                  { return int32(r.p.Id) }
+func (r *paymentResolver) Soul_Connection_Id() *int32 { return int32Ptr(r.p.Soul_Connection_Id) }
+func (r *paymentResolver) Date() string               { return r.p.Date }
+func (r *paymentResolver) PaymentMethod() string      { return r.p.PaymentMethod }
+func (r *paymentResolver) Amount() amount             { return amount(r.p.Amount) }
+func (r *paymentResolver) Comment() string            { return r.p.Comment }
+func (r *paymentResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: r.p.CreatedAt} }
+func (r *paymentResolver) CustomerId() int32          { return int32(r.p.CustomerId) }
+
+func (r *paymentResolver) Customer(ctx context.Context) (*customerResolver, error) {
+	return customerOf(ctx, r.p.CustomerId)
+}
+
+func newEncounters(ctx context.Context, list []encounters.Encounter) []*encounterResolver {
+	prime(ctx, "customer", sessionFrom(ctx).customers, list, func(e encounters.Encounter) *int { return &e.Customer_Id })
+	return lib.Map(list, newEncounter)
+}
+
+type encounterResolver struct {
+	e encounters.EncounterResponse
+}
+
+func newEncounter(e encounters.Encounter) *encounterResolver {
+	return &encounterResolver{e: e.Response()}
+}
+
+func (r *encounterResolver) Id() int32               { return int32(r.e.Id) }
+func (r *encounterResolver) Date() string            { return r.e.Date }
+func (r *encounterResolver) Rating() int32           { return int32(r.e.Rating) }
+func (r *encounterResolver) Comment() string         { return r.e.Comment }
+func (r *encounterResolver) Source() string          { return r.e.Source }
+func (r *encounterResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.e.CreatedAt} }
+func (r *encounterResolver) Customer_Id() int32      { return int32(r.e.Customer_Id) }
+
+func (r *encounterResolver) Customer(ctx context.Context) (*customerResolver, error) {
+	return customerOf(ctx, r.e.Customer_Id)
+}
+
+func newClothes(ctx context.Context, list []clothes.Clothe) []*clotheResolver {
+	prime(ctx, "customer", sessionFrom(ctx).customers, list, func(c clothes.Clothe) *int { return &c.CustomerId })
+	return lib.Map(list, newClothe)
+}
+
+type clotheResolver struct {
+	c clothes.ClotheResponse
+}
+
+func newClothe(c clothes.Clothe) *clotheResolver {
+	return &clotheResolver{c: c.Response()}
+}
+
+func (r *clotheResolver) Id() int32                  { return int32(r.c.Id) }
+func (r *clotheResolver) Soul_Connection_Id() *int32 { return int32Ptr(r.c.Soul_Connection_Id) }
+func (r *clotheResolver) Type() string               { return r.c.Type }
+func (r *clotheResolver) Has_Image() bool            { return r.c.Has_Image }
+func (r *clotheResolver) CreatedAt() graphql.Time    { return graphql.Time{Time: r.c.CreatedAt} }
+func (r *clotheResolver) CustomerId() int32          { return int32(r.c.CustomerId) }
+
+func (r *clotheResolver) Customer(ctx context.Context) (*customerResolver, error) {
+	return customerOf(ctx, r.c.CustomerId)
+}
+
+func newEvents(ctx context.Context, list []events.Event) []*eventResolver {
+	prime(ctx, "employee", sessionFrom(ctx).employees, list, func(e events.Event) *int { return &e.Employee_Id })
+	return lib.Map(list, newEvent)
+}
+
+type eventResolver struct {
+	e events.EventResponse
+}
+
+func newEvent(e events.Event) *eventResolver {
+	return &eventResolver{e: e.Response()}
+}
+
+func (r *eventResolver) Id() int32               { return int32(r.e.Id) }
+func (r *eventResolver) Name() string            { return r.e.Name }
+func (r *eventResolver) Date() string            { return r.e.Date }
+func (r *eventResolver) Max_Participants() int32 { return int32(r.e.Max_Participants) }
+func (r *eventResolver) Location_X() string      { return r.e.Location_X }
+func (r *eventResolver) Location_Y() string      { return r.e.Location_Y }
+func (r *eventResolver) Type() string            { return r.e.Type }
+func (r *eventResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.e.CreatedAt} }
+func (r *eventResolver) Employee_Id() int32      { return int32(r.e.Employee_Id) }
+
+func (r *eventResolver) Employee(ctx context.Context) (*employeeResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.authenticated(); err != nil {
+		return nil, fail(ctx, err)
+	}
+	employee, err := s.employees.Load(ctx, r.e.Employee_Id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if employee == nil {
+		return nil, nil
+	}
+	return newEmployee(*employee), nil
+}
+
+type tipResolver struct {
+	t tips.TipResponse
+}
+
+func newTip(t tips.Tip) *tipResolver {
+	return &tipResolver{t: t.Response()}
+}
+
+func (r *tipResolver) Id() int32               { return int32(r.t.Id) }
+func (r *tipResolver) Title() string           { return r.t.Title }
+func (r *tipResolver) Tip() string             { return r.t.Tip }
+func (r *tipResolver) CreatedAt() graphql.Time { return graphql.Time{Time: r.t.CreatedAt} }
+
+// customerOf resolves the customer a row belongs to, through the loader so
+// the customers of a whole list are read at once.
+func customerOf(ctx context.Context, id int) (*customerResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.canSeeCustomer(ctx, id); err != nil {
+		return nil, fail(ctx, err)
+	}
+	customer, err := s.customers.Load(ctx, id)()
+	if err != nil {
+		return nil, fail(ctx, err)
+	}
+	if customer == nil {
+		return nil, nil
+	}
+	return newCustomer(*customer), nil
+}
+
+// amount is the Amount scalar, written like the amounts of the REST routes.
+type amount payments.Amount
+
+func (amount) ImplementsGraphQLType(name string) bool {
+	return name == "Amount"
+}
+
+func (a *amount) UnmarshalGraphQL(input any) error {
+	switch value := input.(type) {
+	case float64:
+		*a = amount(payments.AmountFromFloat(value))
+	case int32:
+		*a = amount(payments.AmountFromFloat(float64(value)))
+	default:
+		return fmt.Errorf("invalid amount %v", input)
+	}
+	return nil
+}
+
+func (a amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(payments.Amount(a))
+}
+
+func int32Ptr(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	i := int32(*v)
+	return &i
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"soul-connection.com/api/src/logger"
+)
+
+// CheckTimeout bounds the checks of a single readiness probe.
+var CheckTimeout = 2 * time.Second
+
+// Probe answers the liveness and readiness probes of the API.
+type Probe struct {
+	// Checks are run by Ready, every one of them must pass for the API to be
+	// ready, like pinging the databases it depends on.
+	Checks   map[string]func(context.Context) error
+	draining atomic.Bool
+}
+
+type status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live answers 200 as long as the process is able to serve requests.
+func (p *Probe) Live(res http.ResponseWriter, req *http.Request) {
+	write(res, req, http.StatusOK, status{Status: "ok"})
+}
+
+// Ready answers 200 when every check passes and 503 otherwise, or as soon as
+// the API is draining.
+func (p *Probe) Ready(res http.ResponseWriter, req *http.Request) {
+	if p.draining.Load() {
+		write(res, req, http.StatusServiceUnavailable, status{Status: "draining"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), CheckTimeout)
+	defer cancel()
+
+	code := http.StatusOK
+	result := status{Status: "ok", Checks: map[string]string{}}
+	for name, check := range p.Checks {
+		if err := check(ctx); err != nil {
+			// NOTE: Only the logs get the error, the probe is public
+			logger.FromContext(ctx).Warn("not ready", "check", name, "error", err)
+			code = http.StatusServiceUnavailable
+			result.Status = "unavailable"
+			result.Checks[name] = "unavailable"
+			continue
+		}
+		result.Checks[name] = "ok"
+	}
+	write(res, req, code, result)
+}
+
+// Drain makes Ready fail from now on so no new traffic is sent to the API
+// while its in-flight requests complete.
+func (p *Probe) Drain() {
+	p.draining.Store(true)
+}
+
+func write(res http.ResponseWriter, req *http.Request, code int, body status) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(code)
+	if err := json.NewEncoder(res).Encode(body); err != nil {
+		logger.FromContext(req.Context()).Error("could not write probe response", "error", err)
+	}
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, status) {
+	res := httptest.NewRecorder()
+	handler(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body status
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return res.Code, body
+}
+
+func TestProbe(t *testing.T) {
+	var mongoErr error
+	p := &Probe{Checks: map[string]func(context.Context) error{
+		"postgres": func(ctx context.Context) error { return nil },
+		"mongo":    func(ctx context.Context) error { return mongoErr },
+	}}
+
+	t.Run("Live", func(t *testing.T) {
+		if code, body := probe(t, p.Live); code != http.StatusOK || body.Status != "ok" {
+			t.Errorf("Expected 200 ok, got %d %+v", code, body)
+		}
+	})
+
+	t.Run("Ready", func(t *testing.T) {
+		code, body := probe(t, p.Ready)
+		if code != http.StatusOK || body.Checks["postgres"] != "ok" || body.Checks["mongo"] != "ok" {
+			t.Errorf("Expected 200 with every check ok, got %d %+v", code, body)
+		}
+	})
+
+	t.Run("Failing Check", func(t *testing.T) {
+		mongoErr = errors.New("connection refused")
+		defer func() { mongoErr = nil }()
+
+		code, body := probe(t, p.Ready)
+		if code != http.StatusServiceUnavailable || body.Checks["postgres"] != "ok" || body.Checks["mongo"] != "unavailable" {
+			t.Errorf("Expected 503 with mongo unavailable, got %d %+v", code, body)
+		}
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		p.Drain()
+
+		if code, body := probe(t, p.Ready); code != http.StatusServiceUnavailable || body.Status != "draining" {
+			t.Errorf("Expected 503 draining, got %d %+v", code, body)
+		}
+		if code, _ := probe(t, p.Live); code != http.StatusOK {
+			t.Errorf("Expected a draining API to stay live, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"context"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/endpoints/payments"
+)
+
+// customerRows seals the sensitive values of the customers with encrypted.
+func customerRows(encrypted *encryption.Fields) rows[customers.AddCustomer] {
+	db := customers.CustomersDB{Encryption: encrypted}
+	return rows[customers.AddCustomer]{
+		email: func(c *customers.AddCustomer) string { return c.Email },
+		insert: func(ctx context.Context, q database.Queryer, c *customers.AddCustomer) (int, error) {
+			customer, err := db.Insert(ctx, q, c)
+			if err != nil {
+				return 0, err
+			}
+			return customer.Id, nil
+		},
+		findID: db.FindIDByEmail,
+		merge: func(ctx context.Context, q database.Queryer, c *customers.AddCustomer) (int, error) {
+			customer, err := db.MergeByEmail(ctx, q, c)
+			if err != nil {
+				return 0, err
+			}
+			return customer.Id, nil
+		},
+	}
+}
+
+var employeeRows = rows[employees.AddEmployee]{
+	email: func(e *employees.AddEmployee) string { return e.Email },
+	insert: func(ctx context.Context, q database.Queryer, e *employees.AddEmployee) (int, error) {
+		employee, err := employees.EmployeesDB{}.Insert(ctx, q, e)
+		if err != nil {
+			return 0, err
+		}
+		return employee.Id, nil
+	},
+	findID: employees.EmployeesDB{}.FindIDByEmail,
+	merge: func(ctx context.Context, q database.Queryer, e *employees.AddEmployee) (int, error) {
+		employee, err := employees.EmployeesDB{}.MergeByEmail(ctx, q, e)
+		if err != nil {
+			return 0, err
+		}
+		return employee.Id, nil
+	},
+}
+
+var paymentRows = rows[payments.AddPayment]{
+	insert: func(ctx context.Context, q database.Queryer, p *payments.AddPayment) (int, error) {
+		payment, err := payments.PaymentsDB{}.Insert(ctx, q, p)
+		if err != nil {
+			return 0, err
+		}
+		return payment.Id, nil
+	},
+}
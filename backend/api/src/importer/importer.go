@@ -0,0 +1,289 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
+	"soul-connection.com/api/src/validation"
+)
+
+const (
+	Skip   = "skip"
+	Upsert = "upsert"
+
+	Inserted = "inserted"
+	Updated  = "updated"
+	Skipped  = "skipped"
+	Invalid  = "invalid"
+	Failed   = "failed"
+)
+
+var (
+	ErrUnknownEntity = errors.New("unknown import entity, expected customers, employees or payments")
+	ErrUnknownPolicy = errors.New("unknown duplicate policy, expected skip or upsert")
+	ErrEmptyFile     = errors.New("csv file has no header")
+	ErrInvalidHeader = errors.New("invalid csv header")
+)
+
+type Options struct {
+	DryRun      bool   `json:"dry_run"`
+	OnDuplicate string `json:"on_duplicate"`
+}
+
+type RowResult struct {
+	// Line is the line of the row in the csv file, the header being line 1
+	Line   int                     `json:"line"`
+	Status string                  `json:"status"`
+	Id     *int                    `json:"id"`
+	Errors []validation.FieldError `json:"errors"`
+}
+
+type Report struct {
+	Entity   string      `json:"entity"`
+	DryRun   bool        `json:"dry_run"`
+	Total    int         `json:"total"`
+	Inserted int         `json:"inserted"`
+	Updated  int         `json:"updated"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Rows     []RowResult `json:"rows"`
+}
+
+type Importer struct {
+	DB *sql.DB
+	// Encryption seals the sensitive values of the customers imported
+	Encryption *encryption.Fields
+}
+
+// Import reads a csv file of entity rows and adds the valid ones in a single
+// transaction, the one of ctx when it carries one, a row failing in the
+// database is rolled back on its own and reported without aborting the others.
+// A dry run goes through the exact same steps but rolls the whole transaction
+// back at the end.
+func (importer Importer) Import(ctx context.Context, entity string, r io.Reader, opts Options) (*Report, error) {
+	switch opts.OnDuplicate {
+	case "":
+		opts.OnDuplicate = Skip
+	case Skip, Upsert:
+	default:
+		return nil, ErrUnknownPolicy
+	}
+
+	switch entity {
+	case "customers":
+		return run(ctx, importer.DB, entity, r, opts, customerRows(importer.Encryption))
+	case "employees":
+		return run(ctx, importer.DB, entity, r, opts, employeeRows)
+	case "payments":
+		return run(ctx, importer.DB, entity, r, opts, paymentRows)
+	}
+	return nil, ErrUnknownEntity
+}
+
+// rows describes how the records of one entity are checked and stored.
+type rows[T any] struct {
+	// email is nil for entities without a unique email, every row is inserted
+	email  func(*T) string
+	insert func(context.Context, database.Queryer, *T) (int, error)
+	findID func(context.Context, database.Queryer, string) (int, error)
+	merge  func(context.Context, database.Queryer, *T) (int, error)
+}
+
+func run[T any](ctx context.Context, db *sql.DB, entity string, r io.Reader, opts Options, entityRows rows[T]) (*Report, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	fields, err := mapColumns(reflect.TypeFor[T](), header)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := database.Begin(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := Report{Entity: entity, DryRun: opts.DryRun}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		result := RowResult{Line: line}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+			result.Errors = []validation.FieldError{{Message: fmt.Sprintf("expected %d columns, got %d", len(header), len(record))}}
+		} else if err != nil {
+			return nil, err
+		} else {
+			result = importRow(ctx, tx.Tx, record, fields, opts, entityRows, result)
+		}
+		if result.Status == "" {
+			result.Status = Invalid
+		}
+
+		report.add(result)
+	}
+
+	if opts.DryRun {
+		return &report, tx.Rollback()
+	}
+	return &report, tx.Commit()
+}
+
+func importRow[T any](ctx context.Context, tx *sql.Tx, record []string, fields []*reflect.StructField, opts Options, entityRows rows[T], result RowResult) RowResult {
+	var row T
+	result.Errors = parseRecord(&row, record, fields)
+	if len(result.Errors) > 0 {
+		return result
+	}
+	var errs validation.Errors
+	if errors.As(validation.Struct(&row), &errs) {
+		result.Errors = errs
+		return result
+	}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+		result.Status = Failed
+		result.Errors = []validation.FieldError{{Message: err.Error()}}
+		return result
+	}
+	id, status, err := store(ctx, tx, &row, opts, entityRows)
+	if err != nil {
+		tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_row")
+		result.Status = Failed
+		result.Errors = []validation.FieldError{{Message: err.Error()}}
+		return result
+	}
+	tx.ExecContext(ctx, "RELEASE SAVEPOINT import_row")
+
+	result.Status = status
+	if status != Skipped {
+		result.Id = &id
+	}
+	return result
+}
+
+func store[T any](ctx context.Context, tx *sql.Tx, row *T, opts Options, entityRows rows[T]) (int, string, error) {
+	if entityRows.email == nil {
+		id, err := entityRows.insert(ctx, tx, row)
+		return id, Inserted, err
+	}
+
+	id, err := entityRows.findID(ctx, tx, entityRows.email(row))
+	if err == sql.ErrNoRows {
+		id, err := entityRows.insert(ctx, tx, row)
+		return id, Inserted, err
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if opts.OnDuplicate == Skip {
+		return id, Skipped, nil
+	}
+	id, err = entityRows.merge(ctx, tx, row)
+	return id, Updated, err
+}
+
+func (report *Report) add(result RowResult) {
+	report.Total++
+	switch result.Status {
+	case Inserted:
+		report.Inserted++
+	case Updated:
+		report.Updated++
+	case Skipped:
+		report.Skipped++
+	default:
+		report.Failed++
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+// NOTE: Columns match fields regardless of case, underscores, dashes or
+// spaces so "Birth Date", "birth_date" and "Birth_Date" are all accepted.
+func normalize(name string) string {
+	return strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+}
+
+func mapColumns(t reflect.Type, header []string) ([]*reflect.StructField, error) {
+	byName := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		byName[normalize(t.Field(i).Name)] = t.Field(i)
+	}
+
+	fields := make([]*reflect.StructField, len(header))
+	seen := map[string]bool{}
+	for i, column := range header {
+		// NOTE: Spreadsheets often save csv files with a byte order mark
+		name := normalize(strings.TrimPrefix(column, "\uFEFF"))
+		field, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidHeader, column)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidHeader, column)
+		}
+		seen[name] = true
+		fields[i] = &field
+	}
+	return fields, nil
+}
+
+func parseRecord(row any, record []string, fields []*reflect.StructField) []validation.FieldError {
+	v := reflect.ValueOf(row).Elem()
+	var errs []validation.FieldError
+
+	for i, field := range fields {
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+		if err := parseValue(v.FieldByIndex(field.Index), value); err != nil {
+			errs = append(errs, validation.FieldError{Field: validation.FieldName(*field), Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+func parseValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if unmarshaler, ok := v.Addr().Interface().(json.Unmarshaler); ok {
+		return unmarshaler.UnmarshalJSON([]byte(strconv.Quote(value)))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		v.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func setupTestDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: Every connection to :memory: opens a new empty database
+	db.SetMaxOpenConns(1)
+
+	schema := `
+	PRAGMA foreign_keys = ON;
+	CREATE TABLE customer (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER UNIQUE,
+		email TEXT NOT NULL,
+		name TEXT NOT NULL,
+		surname TEXT NOT NULL,
+		birth_date TEXT NOT NULL,
+		gender TEXT NOT NULL,
+		description TEXT NOT NULL,
+		astrological_sign TEXT NOT NULL,
+		phone_number TEXT NOT NULL,
+		phone_number_index TEXT NOT NULL DEFAULT '',
+		address TEXT NOT NULL,
+		image_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		employee_id INTEGER
+	);
+	CREATE UNIQUE INDEX customer_email ON customer (email) WHERE deleted_at IS NULL;
+	CREATE TABLE payment (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER,
+		date TEXT NOT NULL,
+		payment_method TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		comment TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		customer_id INTEGER NOT NULL REFERENCES customer (id)
+	);
+	`
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("Failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+func statuses(report *Report) string {
+	var s []string
+	for _, row := range report.Rows {
+		s = append(s, row.Status)
+	}
+	return strings.Join(s, ",")
+}
+
+const customersFile = `Email,Name,Surname,Birth Date,phone_number
+zoe@test.com,Zoé,Durand,1990-04-01,0600000000
+not-an-email,John,Doe,,
+marc@test.com,,Martin,01/02/1985,
+ines@test.com,Inès,Petit,,0611111111
+`
+
+func TestImportCustomers(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	importer := Importer{DB: db}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		report, err := importer.Import(context.Background(), "customers", strings.NewReader(customersFile), Options{DryRun: true})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if statuses(report) != "inserted,invalid,invalid,inserted" {
+			t.Errorf("Unexpected statuses %s", statuses(report))
+		}
+		if report.Inserted != 2 || report.Failed != 2 || !report.DryRun {
+			t.Errorf("Unexpected report %+v", report)
+		}
+		if n := count(t, db, "customer"); n != 0 {
+			t.Errorf("Dry run saved %d customers", n)
+		}
+	})
+
+	t.Run("Row Errors", func(t *testing.T) {
+		report, err := importer.Import(context.Background(), "customers", strings.NewReader(customersFile), Options{})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		invalid := report.Rows[1]
+		if invalid.Line != 3 || len(invalid.Errors) != 1 || invalid.Errors[0].Field != "email" {
+			t.Errorf("Unexpected result for line 3: %+v", invalid)
+		}
+		invalid = report.Rows[2]
+		if len(invalid.Errors) != 2 || invalid.Errors[0].Field != "name" || invalid.Errors[1].Field != "birth_date" {
+			t.Errorf("Expected name and birth date errors, got %+v", invalid)
+		}
+		if n := count(t, db, "customer"); n != 2 {
+			t.Errorf("Expected 2 customers, got %d", n)
+		}
+	})
+
+	t.Run("Skip Duplicates", func(t *testing.T) {
+		file := "email,name,surname\nzoe@test.com,Zoe,Dupont\nnew@test.com,New,Customer\n"
+		report, err := importer.Import(context.Background(), "customers", strings.NewReader(file), Options{OnDuplicate: Skip})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if statuses(report) != "skipped,inserted" || report.Rows[0].Id != nil {
+			t.Errorf("Unexpected report %+v", report.Rows)
+		}
+
+		var surname string
+		db.QueryRow("SELECT surname FROM customer WHERE email = 'zoe@test.com'").Scan(&surname)
+		if surname != "Durand" {
+			t.Errorf("Skipped customer was updated to %s", surname)
+		}
+	})
+
+	t.Run("Upsert Duplicates", func(t *testing.T) {
+		file := "email,name,surname,phone_number\nzoe@test.com,Zoe,Dupont,\n"
+		report, err := importer.Import(context.Background(), "customers", strings.NewReader(file), Options{OnDuplicate: Upsert})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if statuses(report) != "updated" || report.Rows[0].Id == nil {
+			t.Errorf("Unexpected report %+v", report.Rows)
+		}
+
+		var surname, phone string
+		db.QueryRow("SELECT surname, phone_number FROM customer WHERE email = 'zoe@test.com'").Scan(&surname, &phone)
+		if surname != "Dupont" || phone != "0600000000" {
+			t.Errorf("Expected surname Dupont and phone kept, got %s %s", surname, phone)
+		}
+	})
+
+	t.Run("Deleted Duplicates", func(t *testing.T) {
+		if _, err := db.Exec("UPDATE customer SET deleted_at = CURRENT_TIMESTAMP WHERE email = 'zoe@test.com'"); err != nil {
+			t.Fatalf("Failed to delete the customer: %v", err)
+		}
+		file := "email,name,surname\nzoe@test.com,Zoe,Martin\n"
+		report, err := importer.Import(context.Background(), "customers", strings.NewReader(file), Options{OnDuplicate: Upsert})
+		if err != nil {
+			t.Fatalf("Import failed: %v", err)
+		}
+		if statuses(report) != "inserted" {
+			t.Errorf("Expected a new customer in place of the deleted one, got %+v", report.Rows)
+		}
+
+		var surname string
+		db.QueryRow("SELECT surname FROM customer WHERE email = 'zoe@test.com' AND deleted_at IS NOT NULL").Scan(&surname)
+		if surname != "Dupont" {
+			t.Errorf("Deleted customer was updated to %s", surname)
+		}
+	})
+
+	t.Run("Invalid Files", func(t *testing.T) {
+		tests := []struct {
+			name string
+			file string
+			opts Options
+			err  error
+		}{
+			{"Empty File", "", Options{}, ErrEmptyFile},
+			{"Unknown Column", "email,nickname\n", Options{}, ErrInvalidHeader},
+			{"Duplicate Column", "email,Email\n", Options{}, ErrInvalidHeader},
+			{"Unknown Policy", "email\n", Options{OnDuplicate: "merge"}, ErrUnknownPolicy},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := importer.Import(context.Background(), "customers", strings.NewReader(tt.file), tt.opts)
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v, got %v", tt.err, err)
+				}
+			})
+		}
+	})
+
+	t.Run("Unknown Entity", func(t *testing.T) {
+		_, err := importer.Import(context.Background(), "tips", strings.NewReader("title\n"), Options{})
+		if err != ErrUnknownEntity {
+			t.Errorf("Expected %v, got %v", ErrUnknownEntity, err)
+		}
+	})
+}
+
+func TestImportPayments(t *testing.T) {
+	db, err := setupTestDB()
+	if err != nil {
+		t.Fatalf("Failed to set up test database: %v", err)
+	}
+	defer db.Close()
+	importer := Importer{DB: db}
+
+	_, err = db.Exec(`INSERT INTO customer (email, name, surname, birth_date, gender, description, astrological_sign, phone_number, address)
+		VALUES ('zoe@test.com', 'Zoé', 'Durand', '', '', '', '', '', '')`)
+	if err != nil {
+		t.Fatalf("Failed to add customer: %v", err)
+	}
+
+	file := `date,payment_method,amount,comment,customer_id
+2024-01-05,Credit Card,45.50,Séance,1
+2024-01-06,Credit Card,12.345,,1
+2024-01-07,PayPal,10,,99
+2024-01-08,PayPal,-5,Refund,1
+2024-01-09,PayPal,10
+`
+	report, err := importer.Import(context.Background(), "payments", strings.NewReader(file), Options{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	if statuses(report) != "inserted,invalid,failed,inserted,invalid" {
+		t.Errorf("Unexpected statuses %s", statuses(report))
+	}
+	if report.Rows[1].Errors[0].Field != "amount" {
+		t.Errorf("Expected an amount error, got %+v", report.Rows[1].Errors)
+	}
+	if n := count(t, db, "payment"); n != 2 {
+		t.Errorf("Expected 2 payments, got %d", n)
+	}
+
+	var amount int64
+	db.QueryRow("SELECT amount FROM payment WHERE comment = 'Séance'").Scan(&amount)
+	if amount != 4550 {
+		t.Errorf("Expected 4550 cents, got %d", amount)
+	}
+}
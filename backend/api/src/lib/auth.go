@@ -1,22 +1,30 @@
 package lib
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 )
 
+// ErrRejected is wrapped by the errors of Auth when the upstream api refused
+// the credentials, rather than failing to check them.
+var ErrRejected = errors.New("credentials rejected")
+
+// LoginCredentials log in to the upstream api found at BaseUri.
 type LoginCredentials struct {
+	BaseUri              string
 	XGroupAuthentication string
 	AuthEmail            string
 	AuthPassword         string
 }
 
-func Auth(credentials LoginCredentials) (string, error) {
+func Auth(ctx context.Context, credentials LoginCredentials) (string, error) {
 	resp, err := Fetch(&http.Client{}, FetchRequest{
+		Context: ctx,
 		Method:  "POST",
-		Url:     fmt.Sprintf("%s/api/employees/login", ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/employees/login", credentials.BaseUri),
 		Body:    map[string]string{"email": credentials.AuthEmail, "password": credentials.AuthPassword},
 		Headers: map[string]string{"Content-Type": "application/json", "X-Group-Authorization": credentials.XGroupAuthentication},
 	})
@@ -33,7 +41,10 @@ func Auth(credentials LoginCredentials) (string, error) {
 	if resp.StatusCode != 200 {
 		detail, ok := loginResponse["detail"]
 		if !ok {
-			return "", errors.New("could not authenticate to api")
+			detail = "could not authenticate to api"
+		}
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return "", fmt.Errorf("%s: %w", detail, ErrRejected)
 		}
 		return "", errors.New(detail)
 	}
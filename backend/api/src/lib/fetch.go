@@ -2,12 +2,17 @@ package lib
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type FetchRequest struct {
+	// Context cancels the request, it defaults to context.Background()
+	Context context.Context
 	Method  string
 	Url     string
 	Body    map[string]string
@@ -25,7 +30,11 @@ func Fetch(client *http.Client, request FetchRequest) (*http.Response, error) {
 	} else {
 		body = nil
 	}
-	req, err := http.NewRequest(request.Method, request.Url, body)
+	ctx := request.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.Url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +42,10 @@ func Fetch(client *http.Client, request FetchRequest) (*http.Response, error) {
 		req.Header.Set(k, v)
 	}
 
-	resp, err := client.Do(req)
+	// NOTE: The transport traces the call and propagates the trace context
+	traced := *client
+	traced.Transport = otelhttp.NewTransport(client.Transport)
+	resp, err := traced.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestFetch(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(previous)
+
+	var traceparent, group string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		group = req.Header.Get("X-Group-Authorization")
+	}))
+	defer server.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+
+	resp, err := Fetch(&http.Client{}, FetchRequest{
+		Context: ctx,
+		Method:  "GET",
+		Url:     server.URL,
+		Headers: map[string]string{"X-Group-Authorization": "key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch: %v", err)
+	}
+	resp.Body.Close()
+
+	if group != "key" {
+		t.Errorf("Expected the headers to be sent, got %q", group)
+	}
+	if traceId := span.SpanContext().TraceID().String(); !strings.Contains(traceparent, traceId) {
+		t.Errorf("Expected traceparent to carry trace %s, got %q", traceId, traceparent)
+	}
+}
@@ -6,9 +6,9 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
-)
 
-const ApiBaseUri string = "https://soul-connection.fr"
+	"soul-connection.com/api/src/logger"
+)
 
 func GetIdFromRequest(req *http.Request, key string) (int, error) {
 	vars := mux.Vars(req)
@@ -20,9 +20,19 @@ func GetIdFromRequest(req *http.Request, key string) (int, error) {
 
 	value, err := strconv.Atoi(stringValue)
 	if err != nil {
-		ServerLog("ERROR", err)
+		logger.FromContext(req.Context()).Error("invalid id", "key", key, "error", err)
 		return 0, err
 	}
 
 	return value, nil
 }
+
+// Map applies fn to every item, a nil slice gives an empty one so lists are
+// always written as JSON arrays.
+func Map[T any, R any](items []T, fn func(T) R) []R {
+	result := make([]R, len(items))
+	for i, item := range items {
+		result[i] = fn(item)
+	}
+	return result
+}
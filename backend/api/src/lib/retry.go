@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"soul-connection.com/api/src/logger"
+)
+
+// RetryDelay is the wait after the first failed attempt of Retry, it doubles
+// after every other one up to RetryMaxDelay.
+var (
+	RetryDelay    = 500 * time.Millisecond
+	RetryMaxDelay = 10 * time.Second
+)
+
+// Retry calls fn until it succeeds or ctx is done, in which case the error of
+// the last attempt is returned. name identifies fn in the logs.
+func Retry(ctx context.Context, name string, fn func(context.Context) error) error {
+	delay := RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: giving up after %d attempts: %w", name, attempt, err)
+		}
+		logger.FromContext(ctx).Warn("attempt failed", "name", name, "attempt", attempt, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s: giving up after %d attempts: %w", name, attempt, err)
+		}
+		delay = min(delay*2, RetryMaxDelay)
+	}
+}
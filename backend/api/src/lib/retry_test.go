@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soul-connection.com/api/src/logger"
+)
+
+func TestRetry(t *testing.T) {
+	RetryDelay, RetryMaxDelay = time.Millisecond, 2*time.Millisecond
+
+	t.Run("Succeeds Eventually", func(t *testing.T) {
+		attempts := 0
+		err := Retry(logger.WithContext(context.Background(), logger.Discard()), "test", func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("Expected success on the third attempt, got %d attempts (%v)", attempts, err)
+		}
+	})
+
+	t.Run("Gives Up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(logger.WithContext(context.Background(), logger.Discard()), 20*time.Millisecond)
+		defer cancel()
+
+		refused := errors.New("connection refused")
+		err := Retry(ctx, "test", func(ctx context.Context) error { return refused })
+		if !errors.Is(err, refused) {
+			t.Errorf("Expected the last error, got %v", err)
+		}
+	})
+}
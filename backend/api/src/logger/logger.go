@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	JSON   = "json"
+	Pretty = "pretty"
+)
+
+// New creates a logger writing to w, as one JSON object per line for
+// production or as colored lines meant for a terminal in development.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case JSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case Pretty:
+		return slog.New(newPrettyHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, expected %s or %s", format, JSON, Pretty)
+}
+
+func ParseLevel(value string) (slog.Level, error) {
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// Setup creates a logger on stdout with format, json or pretty, and level,
+// one of debug, info, warn or error, and makes it the default one. Only main
+// functions should call it.
+func Setup(format string, level string) (*slog.Logger, error) {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	log, err := New(os.Stdout, format, parsed)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(log)
+	// NOTE: Bars would be interleaved with the JSON lines
+	showProgress = format == Pretty
+	return log, nil
+}
+
+// Discard drops everything, it is meant for tests.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+type contextKey struct{}
+
+// WithContext makes log the logger of everything done on behalf of ctx, like
+// the request it belongs to.
+func WithContext(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, log)
+}
+
+// FromContext answers the logger given to WithContext, or the default one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if log, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return log
+		}
+	}
+	return slog.Default()
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		log, err := New(&out, JSON, slog.LevelInfo)
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		log.Debug("hidden")
+		log.With("request_id", "abc").Warn("slow query", "duration", "2s")
+
+		var line map[string]any
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("Expected a single JSON line, got %q", out.String())
+		}
+		if line["level"] != "WARN" || line["msg"] != "slow query" || line["request_id"] != "abc" || line["duration"] != "2s" {
+			t.Errorf("Unexpected line %v", line)
+		}
+	})
+
+	t.Run("Pretty", func(t *testing.T) {
+		var out bytes.Buffer
+		log, err := New(&out, Pretty, slog.LevelDebug)
+		if err != nil {
+			t.Fatalf("Failed to create logger: %v", err)
+		}
+		log.With("request_id", "abc").WithGroup("http").Error("request failed", "status", 500)
+
+		line := out.String()
+		for _, expected := range []string{"ERROR", "request failed", " request_id=abc", " http.status=500"} {
+			if !strings.Contains(line, expected) {
+				t.Errorf("Expected %q in %q", expected, line)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if _, err := New(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+			t.Error("Expected an error for an unknown format")
+		}
+		if _, err := ParseLevel("verbose"); err == nil {
+			t.Error("Expected an error for an unknown level")
+		}
+		if level, err := ParseLevel("debug"); err != nil || level != slog.LevelDebug {
+			t.Errorf("Expected debug, got %v (%v)", level, err)
+		}
+	})
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("Expected the default logger without one in the context")
+	}
+	log := Discard()
+	if FromContext(WithContext(context.Background(), log)) != log {
+		t.Error("Expected the logger of the context")
+	}
+}
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+const (
+	reset   = "\033[0m"
+	red     = "\033[31m"
+	green   = "\033[32m"
+	yellow  = "\033[33m"
+	magenta = "\033[35m"
+)
+
+// prettyHandler prints records as "2006-01-02 15:04:05 [INFO] message
+// key=value", with the level colored.
+type prettyHandler struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	options *slog.HandlerOptions
+	attrs   string
+	group   string
+}
+
+func newPrettyHandler(w io.Writer, options *slog.HandlerOptions) *prettyHandler {
+	return &prettyHandler{w: w, mu: &sync.Mutex{}, options: options}
+}
+
+func (h *prettyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.options.Level.Level()
+}
+
+func (h *prettyHandler) Handle(ctx context.Context, record slog.Record) error {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s [%s%s%s] %s", record.Time.Format("2006-01-02 15:04:05"), color(record.Level), record.Level, reset, record.Message)
+	line.WriteString(h.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		writeAttr(&line, h.group, attr)
+		return true
+	})
+	line.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var line strings.Builder
+	line.WriteString(h.attrs)
+	for _, attr := range attrs {
+		writeAttr(&line, h.group, attr)
+	}
+	clone := *h
+	clone.attrs = line.String()
+	return &clone
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = h.group + name + "."
+	return &clone
+}
+
+func writeAttr(line *strings.Builder, group string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, member := range attr.Value.Group() {
+			writeAttr(line, group+attr.Key+".", member)
+		}
+		return
+	}
+	fmt.Fprintf(line, " %s%s=%v", group, attr.Key, attr.Value)
+}
+
+func color(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return red
+	case level >= slog.LevelWarn:
+		return yellow
+	case level >= slog.LevelInfo:
+		return green
+	}
+	return magenta
+}
@@ -0,0 +1,51 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+var progress = mpb.New(mpb.WithWidth(64))
+
+// showProgress is set by Setup, bars are only drawn with the pretty output.
+var showProgress = false
+
+// Progress follows a long running task, like syncing every customer, as a
+// terminal progress bar. Its start and end are logged either way.
+type Progress struct {
+	log   *slog.Logger
+	name  string
+	done  int
+	total int
+	bar   *mpb.Bar
+}
+
+func StartProgress(ctx context.Context, name string, total int) *Progress {
+	p := &Progress{log: FromContext(ctx), name: name, total: total}
+	p.log.Info(name, "total", total)
+	if showProgress {
+		p.bar = progress.AddBar(int64(total),
+			mpb.BarRemoveOnComplete(),
+			mpb.PrependDecorators(decor.CountersNoUnit("%d / %d")),
+			mpb.AppendDecorators(decor.Percentage(decor.WC{W: 5, C: decor.DSyncSpace})),
+		)
+	}
+	return p
+}
+
+func (p *Progress) Increment() {
+	p.done++
+	if p.bar != nil {
+		p.bar.Increment()
+	}
+}
+
+func (p *Progress) Complete() {
+	if p.bar != nil {
+		p.bar.SetTotal(0, true)
+	}
+	p.log.Info(p.name+" complete", "done", p.done, "total", p.total)
+}
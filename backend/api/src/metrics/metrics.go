@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NOTE: Routes are labelled with their mux path template, like
+// /api/customers/{customer_id}, so ids never create new series.
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time spent serving HTTP requests by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	RequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	ChangeStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "change_streams_open",
+		Help: "Clients currently following /api/stream.",
+	})
+
+	AuditFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "audit_failures_total",
+		Help: "Writes that could not be recorded in the audit log.",
+	})
+
+	RetentionPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "retention_purged_total",
+		Help: "Deleted rows removed for good once their retention period was over.",
+	}, []string{"entity"})
+
+	StorageUploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gridfs_uploaded_bytes_total",
+		Help: "Bytes uploaded to GridFS by bucket.",
+	}, []string{"bucket"})
+
+	StorageDownloadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gridfs_downloaded_bytes_total",
+		Help: "Bytes downloaded from GridFS by bucket.",
+	}, []string{"bucket"})
+)
+
+// RegisterDB exposes the connection pool statistics of database, labelled
+// with name.
+func RegisterDB(database *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(database, name))
+}
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
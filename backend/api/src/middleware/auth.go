@@ -1,38 +1,161 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 
+	"soul-connection.com/api/src/apierror"
 	"soul-connection.com/api/src/lib"
 )
 
+// CoachWork is the Work of the employees that only follow their own
+// customers.
+const CoachWork = "Coach"
+
+// ManagerWorks are the Work of the employees that manage the whole agency,
+// matched exactly. Anyone else, a coach as well as an empty or unknown Work,
+// is held to what a coach may do.
+var ManagerWorks = []string{"Manager"}
+
+// User is the employee behind a request, as the upstream api knows them: Id
+// is their upstream id, the Soul_Connection_Id of the local employee.
+type User struct {
+	Id      int    `json:"id"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Surname string `json:"surname"`
+	Work    string `json:"work"`
+}
+
+// IsManager is the single place telling managers apart, every route
+// restricted to them goes through it.
+func (u *User) IsManager() bool {
+	return slices.Contains(ManagerWorks, u.Work)
+}
+
+type userKey struct{}
+
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom answers the user authenticated by AuthProvider.Auth, if any.
+func UserFrom(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey{}).(*User)
+	return user, ok && user != nil
+}
+
 type AuthProvider struct {
-	ApiKey string
+	ApiKey  string
+	BaseUri string
 }
 
+// Auth rejects the requests of anyone the upstream api does not know, the
+// others reach next along with their User.
 func (p *AuthProvider) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-		bearer, ok := req.Header["Authorization"]
-		if !ok {
-			http.Error(res, "Missing Authorization header", http.StatusUnauthorized)
+		bearer := req.Header.Get("Authorization")
+		if bearer == "" {
+			apierror.Write(res, req, apierror.Unauthorized("Missing Authorization header"))
 			return
 		}
-		resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
-			Method:  "GET",
-			Url:     fmt.Sprintf("%s/api/employees/me", lib.ApiBaseUri),
-			Body:    nil,
-			Headers: map[string]string{"Authorization": bearer[0], "X-Group-Authorization": p.ApiKey},
-		})
+		user, err := p.user(req, bearer)
 		if err != nil {
-			http.Error(res, "Internal server error", http.StatusInternalServerError)
-			lib.ServerLog("ERROR", err)
+			apierror.Write(res, req, err)
 			return
 		}
-		if resp.StatusCode != 200 {
-			http.Error(res, "Unauthorized", http.StatusUnauthorized)
+		next.ServeHTTP(res, req.WithContext(WithUser(req.Context(), user)))
+	})
+}
+
+// Identify lets anonymous requests through but attributes writes sent with
+// a token to their User, rejecting the tokens the upstream api does not know.
+// Reads are only checked when they ask for deleted rows, see IncludeDeleted,
+// so the others cost no call upstream.
+func (p *AuthProvider) Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		bearer := req.Header.Get("Authorization")
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !req.URL.Query().Has(includeDeletedQuery) {
+				bearer = ""
+			}
+		}
+		if bearer == "" {
+			next.ServeHTTP(res, req)
 			return
 		}
-		next.ServeHTTP(res, req)
+		user, err := p.user(req, bearer)
+		if err != nil {
+			apierror.Write(res, req, err)
+			return
+		}
+		next.ServeHTTP(res, req.WithContext(WithUser(req.Context(), user)))
+	})
+}
+
+const includeDeletedQuery = "include_deleted"
+
+// IncludeDeleted tells whether req asks for the deleted rows as well with
+// include_deleted=true, which only managers may do.
+func IncludeDeleted(req *http.Request) (bool, error) {
+	value := req.URL.Query().Get(includeDeletedQuery)
+	if value == "" {
+		return false, nil
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, apierror.BadRequest("Invalid include_deleted, expected true or false")
+	}
+	if !include {
+		return false, nil
+	}
+	user, ok := UserFrom(req.Context())
+	if !ok {
+		return false, apierror.Unauthorized("Authentication required to include deleted rows")
+	}
+	if !user.IsManager() {
+		return false, apierror.Forbidden("Only managers can include deleted rows")
+	}
+	return true, nil
+}
+
+// RequireManager refuses the requests of anyone but a manager, message tells
+// coaches what they may not do.
+func RequireManager(req *http.Request, message string) error {
+	user, ok := UserFrom(req.Context())
+	if !ok {
+		return apierror.Unauthorized("Authentication required")
+	}
+	if !user.IsManager() {
+		return apierror.Forbidden(message)
+	}
+	return nil
+}
+
+// user asks the upstream api who bearer belongs to.
+func (p *AuthProvider) user(req *http.Request, bearer string) (*User, error) {
+	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: req.Context(),
+		Method:  "GET",
+		Url:     fmt.Sprintf("%s/api/employees/me", p.BaseUri),
+		Body:    nil,
+		Headers: map[string]string{"Authorization": bearer, "X-Group-Authorization": p.ApiKey},
 	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, apierror.Unauthorized("Unauthorized")
+	}
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"soul-connection.com/api/src/apierror"
+)
+
+func TestIdentify(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.Header.Get("Authorization") != "Bearer good" {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		res.Write([]byte(`{"id": 90, "email": "manager@soul-connection.fr", "work": "Manager"}`))
+	}))
+	defer upstream.Close()
+
+	provider := &AuthProvider{BaseUri: upstream.URL}
+	handler := provider.Identify(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if user, ok := UserFrom(req.Context()); ok {
+			res.Header().Set("X-User", user.Email)
+		}
+	}))
+	serve := func(method string, target string, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", bearer)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		if res := serve(http.MethodPost, "/api/v1/tips", ""); res.Code != http.StatusOK || res.Header().Get("X-User") != "" {
+			t.Errorf("Expected an anonymous request, got %d %q", res.Code, res.Header().Get("X-User"))
+		}
+	})
+
+	t.Run("Writes", func(t *testing.T) {
+		if res := serve(http.MethodDelete, "/api/v1/tips/1", "Bearer good"); res.Header().Get("X-User") != "manager@soul-connection.fr" {
+			t.Errorf("Expected the write to be attributed, got %q", res.Header().Get("X-User"))
+		}
+		if res := serve(http.MethodDelete, "/api/v1/tips/1", "Bearer bad"); res.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for an unknown token, got %d", res.Code)
+		}
+	})
+
+	t.Run("Reads", func(t *testing.T) {
+		calls = 0
+		if res := serve(http.MethodGet, "/api/v1/customers", "Bearer good"); res.Header().Get("X-User") != "" || calls != 0 {
+			t.Errorf("Expected reads not to be checked upstream, got %d calls", calls)
+		}
+		if res := serve(http.MethodGet, "/api/v1/customers?include_deleted=true", "Bearer good"); res.Header().Get("X-User") == "" {
+			t.Error("Expected reads of deleted rows to be identified")
+		}
+	})
+}
+
+func TestIncludeDeleted(t *testing.T) {
+	check := func(query string, user *User) (bool, error) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/customers"+query, nil)
+		if user != nil {
+			req = req.WithContext(WithUser(req.Context(), user))
+		}
+		return IncludeDeleted(req)
+	}
+	manager := &User{Work: "Manager"}
+
+	if include, err := check("", nil); include || err != nil {
+		t.Errorf("Expected deleted rows to be left out by default, got %v (%v)", include, err)
+	}
+	if include, err := check("?include_deleted=false", nil); include || err != nil {
+		t.Errorf("Expected deleted rows to be left out, got %v (%v)", include, err)
+	}
+	if include, err := check("?include_deleted=true", manager); !include || err != nil {
+		t.Errorf("Expected a manager to include deleted rows, got %v (%v)", include, err)
+	}
+	for name, tc := range map[string]struct {
+		query string
+		user  *User
+	}{
+		"Invalid":   {"?include_deleted=maybe", manager},
+		"Anonymous": {"?include_deleted=true", nil},
+		"Coach":     {"?include_deleted=true", &User{Work: CoachWork}},
+		"No Work":   {"?include_deleted=true", &User{Work: ""}},
+	} {
+		if include, err := check(tc.query, tc.user); include || err == nil {
+			t.Errorf("%s: expected an error, got %v", name, include)
+		}
+	}
+}
+
+func TestRequireManager(t *testing.T) {
+	defer func(works []string) { ManagerWorks = works }(ManagerWorks)
+	ManagerWorks = []string{"Manager", "CEO"}
+
+	tests := []struct {
+		name     string
+		user     *User
+		expected int
+	}{
+		{"Manager", &User{Work: "Manager"}, 0},
+		{"Other Manager Work", &User{Work: "CEO"}, 0},
+		{"Anonymous", nil, http.StatusUnauthorized},
+		{"Coach", &User{Work: CoachWork}, http.StatusForbidden},
+		{"No Work", &User{Work: ""}, http.StatusForbidden},
+		{"Other Case", &User{Work: "manager"}, http.StatusForbidden},
+		{"Lower Case Coach", &User{Work: "coach"}, http.StatusForbidden},
+		{"Unknown Work", &User{Work: "Intern"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/customers/1/restore", nil)
+			if tt.user != nil {
+				req = req.WithContext(WithUser(req.Context(), tt.user))
+			}
+			err := RequireManager(req, "Only managers")
+			var apiErr *apierror.Error
+			switch {
+			case tt.expected == 0 && err != nil:
+				t.Errorf("Expected a manager to pass, got %v", err)
+			case tt.expected != 0 && (!errors.As(err, &apiErr) || apiErr.Status != tt.expected):
+				t.Errorf("Expected %d, got %v", tt.expected, err)
+			}
+		})
+	}
+}
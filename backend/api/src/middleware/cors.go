@@ -1,18 +1,38 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+	"time"
 
-func CorsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	"github.com/rs/cors"
+)
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+// CorsOptions is the single cross-origin policy of the api.
+type CorsOptions struct {
+	// Origins are exact origins, patterns with one wildcard like
+	// https://*.example.com or * for any origin.
+	Origins []string
+	// Methods are the ones the routes answer, OPTIONS is added for preflights.
+	Methods          []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
 
-		next.ServeHTTP(w, r)
-	})
+// Cors answers preflight requests and tags the responses to allowed origins.
+// It must wrap the whole router, mux skips the middlewares of a route when
+// the method does not match, and a preflight is always an OPTIONS request.
+func Cors(options CorsOptions) func(http.Handler) http.Handler {
+	methods := options.Methods
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(slices.Clone(methods), http.MethodOptions)
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins:   options.Origins,
+		AllowedMethods:   methods,
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+		AllowCredentials: options.AllowCredentials && !slices.Contains(options.Origins, "*"),
+		MaxAge:           int(options.MaxAge.Seconds()),
+	}).Handler
 }
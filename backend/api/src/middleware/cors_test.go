@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCors(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/tips/{tip_id}", func(res http.ResponseWriter, req *http.Request) {}).Methods(http.MethodPatch)
+
+	serve := func(options CorsOptions, method string, origin string) *httptest.ResponseRecorder {
+		root := mux.NewRouter()
+		root.Use(Cors(options))
+		root.PathPrefix("/").Handler(router)
+
+		req := httptest.NewRequest(method, "/api/tips/1", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+		}
+		res := httptest.NewRecorder()
+		root.ServeHTTP(res, req)
+		return res
+	}
+	options := CorsOptions{
+		Origins:          []string{"https://app.soul-connection.fr", "https://*.preview.soul-connection.fr"},
+		Methods:          []string{http.MethodGet, http.MethodPatch},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	t.Run("Patch Preflight", func(t *testing.T) {
+		res := serve(options, http.MethodOptions, "https://app.soul-connection.fr")
+		if res.Code != http.StatusNoContent || res.Header().Get("Access-Control-Allow-Methods") != http.MethodPatch {
+			t.Errorf("Expected PATCH to be allowed, got %d %v", res.Code, res.Header())
+		}
+		if res.Header().Get("Access-Control-Allow-Credentials") != "true" || res.Header().Get("Access-Control-Max-Age") != "600" {
+			t.Errorf("Expected credentials and a max age, got %v", res.Header())
+		}
+	})
+
+	t.Run("Single Allow Origin", func(t *testing.T) {
+		res := serve(options, http.MethodPatch, "https://app.soul-connection.fr")
+		if values := res.Header().Values("Access-Control-Allow-Origin"); len(values) != 1 || values[0] != "https://app.soul-connection.fr" {
+			t.Errorf("Expected the origin once, got %v", values)
+		}
+	})
+
+	t.Run("Pattern", func(t *testing.T) {
+		res := serve(options, http.MethodPatch, "https://pr-12.preview.soul-connection.fr")
+		if res.Header().Get("Access-Control-Allow-Origin") != "https://pr-12.preview.soul-connection.fr" {
+			t.Errorf("Expected the pattern to match, got %v", res.Header())
+		}
+	})
+
+	t.Run("Other Origin", func(t *testing.T) {
+		res := serve(options, http.MethodPatch, "https://evil.example.com")
+		if res.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers, got %v", res.Header())
+		}
+	})
+
+	t.Run("Wildcard Without Credentials", func(t *testing.T) {
+		res := serve(CorsOptions{Origins: []string{"*"}, Methods: []string{http.MethodPatch}, AllowCredentials: true}, http.MethodPatch, "https://evil.example.com")
+		if res.Header().Get("Access-Control-Allow-Origin") != "*" || res.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Errorf("Expected a wildcard without credentials, got %v", res.Header())
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deprecated tags the responses of routes kept for old clients with the
+// Deprecation header of RFC 9745 and a Link to the route replacing them,
+// found by swapping prefix for successor in the path.
+func Deprecated(since time.Time, prefix string, successor string) func(http.Handler) http.Handler {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Deprecation", deprecation)
+			if path, ok := strings.CutPrefix(req.URL.Path, prefix); ok {
+				res.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, path))
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
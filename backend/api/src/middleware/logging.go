@@ -1,11 +1,10 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"time"
 
-	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
 type wrappedWriter struct {
@@ -22,6 +21,15 @@ func (w *wrappedWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// Flush lets streamed exports reach the client through the wrapper.
+func (w *wrappedWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *wrappedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -33,6 +41,6 @@ func Logging(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		lib.ServerLog("INFO", fmt.Sprintf("%s [%d] %s %s", r.Method, wrapped.statusCode, r.URL.Path, time.Since(start)))
+		logger.FromContext(r.Context()).Info("request", "method", r.Method, "status", wrapped.statusCode, "path", r.URL.Path, "duration", time.Since(start))
 	})
 }
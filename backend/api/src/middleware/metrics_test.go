@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"soul-connection.com/api/src/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Metrics)
+	router.HandleFunc("/api/tips/{tip_id}", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+
+	for _, id := range []string{"1", "2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/tips/"+id, nil))
+	}
+
+	count := testutil.ToFloat64(metrics.RequestsTotal.WithLabelValues(http.MethodGet, "/api/tips/{tip_id}", "404"))
+	if count != 2 {
+		t.Errorf("Expected 2 requests counted under the route template, got %v", count)
+	}
+	if inFlight := testutil.ToFloat64(metrics.RequestsInFlight); inFlight != 0 {
+		t.Errorf("Expected no request in flight, got %v", inFlight)
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/logger"
+)
+
+const maxRequestIdLength = 128
+
+// RequestId tags the request with the id sent in X-Request-ID, or a new one
+// when there is none, echoes it in the response and adds it to every line
+// logged on behalf of the request, along with its trace id when it is traced.
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(apierror.RequestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+			r.Header.Set(apierror.RequestIdHeader, id)
+		}
+		w.Header().Set(apierror.RequestIdHeader, id)
+
+		log := logger.FromContext(r.Context()).With("request_id", id)
+		if span := trace.SpanContextFromContext(r.Context()); span.HasTraceID() {
+			log = log.With("trace_id", span.TraceID().String())
+		}
+		ctx := context.WithValue(logger.WithContext(r.Context(), log), requestIdKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type requestIdKey struct{}
+
+// RequestIdFrom answers the id RequestId gave the request, empty outside of it.
+func RequestIdFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// validRequestId rejects ids that would not fit on a single log line.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"soul-connection.com/api/src/logger"
+)
+
+func TestRequestId(t *testing.T) {
+	var out bytes.Buffer
+	log, _ := logger.New(&out, logger.JSON, slog.LevelInfo)
+
+	handler := RequestId(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		logger.FromContext(req.Context()).Info("handled", "context_id", RequestIdFrom(req.Context()))
+	}))
+
+	serve := func(id string) (string, map[string]any) {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(logger.WithContext(context.Background(), log))
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		var line map[string]any
+		if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+			t.Fatalf("Expected a JSON log line, got %q", out.String())
+		}
+		return res.Header().Get("X-Request-ID"), line
+	}
+
+	t.Run("Echoes The Client Id", func(t *testing.T) {
+		id, line := serve("client-id-1")
+		if id != "client-id-1" || line["request_id"] != "client-id-1" || line["context_id"] != "client-id-1" {
+			t.Errorf("Expected client-id-1 in the response and logs, got %q and %v", id, line["request_id"])
+		}
+	})
+
+	t.Run("Generates An Id", func(t *testing.T) {
+		id, line := serve("")
+		if len(id) != 32 || line["request_id"] != id {
+			t.Errorf("Expected a generated id in the response and logs, got %q and %v", id, line["request_id"])
+		}
+	})
+
+	t.Run("Replaces An Invalid Id", func(t *testing.T) {
+		if id, _ := serve("bad\nid"); id == "bad\nid" || len(id) != 32 {
+			t.Errorf("Expected a generated id, got %q", id)
+		}
+	})
+}
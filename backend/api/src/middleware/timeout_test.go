@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	var deadline bool
+	serve := func(timeout time.Duration) bool {
+		handler := Timeout(timeout)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			_, deadline = req.Context().Deadline()
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/customers", nil))
+		return deadline
+	}
+
+	if !serve(time.Minute) {
+		t.Errorf("Expected a deadline on the requests")
+	}
+	if serve(0) {
+		t.Errorf("Expected no deadline once disabled")
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// probes are polled constantly and would drown the traces that matter.
+var probes = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// Tracing starts a span for every request, named after its route, continuing
+// the trace of the caller when it sent a traceparent header. Like Metrics it
+// must be used on a mux router.
+var Tracing = otelhttp.NewMiddleware("http.server",
+	otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+		return r.Method + " " + routeTemplate(r)
+	}),
+	otelhttp.WithFilter(func(r *http.Request) bool {
+		return !probes[r.URL.Path]
+	}),
+)
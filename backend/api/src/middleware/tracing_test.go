@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(previous)
+
+	router := mux.NewRouter()
+	router.Use(Tracing)
+	handler := func(res http.ResponseWriter, req *http.Request) {}
+	router.HandleFunc("/api/tips/{tip_id}", handler).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", handler).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tips/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected a single span, probes are not traced, got %d", len(spans))
+	}
+	if spans[0].Name != "GET /api/tips/{tip_id}" {
+		t.Errorf("Expected the span to be named after the route, got %q", spans[0].Name)
+	}
+	if traceId := spans[0].SpanContext.TraceID().String(); traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace of the caller to continue, got %s", traceId)
+	}
+}
@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType    = reflect.TypeFor[time.Time]()
+	schemerType = reflect.TypeFor[Schemer]()
+	rawType     = reflect.TypeFor[json.RawMessage]()
+)
+
+// Schemas derives schemas from Go types the way encoding/json writes them:
+// named structs become components referenced by $ref, field names follow the
+// `json` tags and the `validate` rules of the validation package become
+// constraints.
+type Schemas struct {
+	Components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func NewSchemas() *Schemas {
+	return &Schemas{Components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// For answers the schema of the type of v, nil for a nil v.
+func (s *Schemas) For(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *Schemas) schema(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Pointer && t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).OpenAPISchema()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			// NOTE: Siblings of $ref are ignored in OpenAPI 3.0
+			return schema
+		}
+		nullable := *schema
+		nullable.Nullable = true
+		return &nullable
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + s.component(t)}
+	}
+	// NOTE: Interfaces and anything else can hold any JSON value
+	return &Schema{}
+}
+
+// component registers t once, types of different packages sharing a name
+// are told apart by their package.
+func (s *Schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.Components[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	s.names[t] = name
+	// NOTE: Reserved before building it so recursive types end in a $ref
+	s.Components[name] = &Schema{}
+	*s.Components[name] = *s.object(t)
+	return name
+}
+
+func (s *Schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, schema)
+	return schema
+}
+
+func (s *Schemas) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, omitEmpty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(embedded, schema)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schema(field.Type)
+		rules := field.Tag.Get("validate")
+		if rules != "" && property.Ref == "" {
+			constrained := *property
+			constrain(&constrained, rules)
+			property = &constrained
+		}
+		schema.Properties[name] = property
+		if hasRule(rules, "required") && !omitEmpty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	return name, strings.Contains(options, "omitempty"), false
+}
+
+func hasRule(rules string, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name || strings.HasPrefix(rule, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// constrain translates the rules of a `validate` tag to schema keywords.
+func constrain(schema *Schema, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			schema.Format = "email"
+		case "date":
+			schema.Format = "date"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Split(param, "|")
+		case "min":
+			if limit, err := strconv.ParseFloat(param, 64); err == nil {
+				schema.Minimum = &limit
+			}
+		case "max":
+			if schema.Type == "string" {
+				if limit, err := strconv.Atoi(param); err == nil {
+					schema.MaxLength = &limit
+				}
+			} else if limit, err := strconv.ParseFloat(param, 64); err == nil {
+				schema.Maximum = &limit
+			}
+		}
+	}
+}
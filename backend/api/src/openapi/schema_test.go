@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+type cents int64
+
+func (c cents) OpenAPISchema() *Schema {
+	return &Schema{Type: "number"}
+}
+
+type base struct {
+	Id int
+}
+
+type node struct {
+	base
+	Name     string `json:"name" validate:"required,max=20"`
+	Parent   *node  `json:"parent,omitempty"`
+	Price    *cents `json:"price"`
+	Children []node `json:"children"`
+	Tags     map[string]string
+	Hidden   string `json:"-"`
+	internal string
+}
+
+func TestSchemas(t *testing.T) {
+	schemas := NewSchemas()
+	schema := schemas.For([]node{})
+
+	if schema.Type != "array" || schema.Items.Ref != "#/components/schemas/node" {
+		t.Fatalf("Expected an array of $ref, got %+v", schema)
+	}
+	n := schemas.Components["node"]
+	var names []string
+	for name := range n.Properties {
+		names = append(names, name)
+	}
+	if len(names) != 6 || n.Properties["Id"] == nil || n.Properties["Hidden"] != nil || n.Properties["internal"] != nil {
+		t.Errorf("Expected the json names of the exported fields, got %v", names)
+	}
+	if n.Properties["parent"].Ref != "#/components/schemas/node" || n.Properties["children"].Items.Ref != "#/components/schemas/node" {
+		t.Errorf("Expected recursive references, got %+v", n.Properties)
+	}
+	if price := n.Properties["price"]; price.Type != "number" || !price.Nullable {
+		t.Errorf("Expected a nullable number from OpenAPISchema, got %+v", price)
+	}
+	if name := n.Properties["name"]; *name.MaxLength != 20 || !reflect.DeepEqual(n.Required, []string{"name"}) {
+		t.Errorf("Expected the validation rules, got %+v %v", name, n.Required)
+	}
+	if tags := n.Properties["Tags"]; tags.Type != "object" || tags.AdditionalProperties.Type != "string" {
+		t.Errorf("Expected a map of strings, got %+v", tags)
+	}
+}
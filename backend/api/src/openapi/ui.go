@@ -0,0 +1,33 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// NOTE: Replaces the initializer of the bundle, which loads the petstore
+//
+//go:embed swagger-initializer.js
+var initializer []byte
+
+// SwaggerUI serves the Swagger UI under prefix, like /api/docs/, showing the
+// document served next to it at ../openapi.json.
+func SwaggerUI(prefix string) http.HandlerFunc {
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	return func(res http.ResponseWriter, req *http.Request) {
+		switch {
+		case !strings.HasPrefix(req.URL.Path, prefix):
+			// NOTE: Relative links of the page only resolve with the trailing slash
+			http.Redirect(res, req, prefix, http.StatusMovedPermanently)
+		case strings.TrimPrefix(req.URL.Path, prefix) == "swagger-initializer.js":
+			res.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			res.Write(initializer)
+		default:
+			files.ServeHTTP(res, req)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Lockout refuses the logins to an account from an address for Period once
+// MaxAttempts of them failed within Window. A nil Lockout never locks
+// anything.
+//
+// The failures are counted per account and address, so that anyone guessing
+// the email of an employee cannot lock them out from elsewhere: the address
+// limiter in front of the api slows down whoever spreads their guesses over
+// many accounts.
+type Lockout struct {
+	Store       Store
+	MaxAttempts int
+	Window      time.Duration
+	Period      time.Duration
+}
+
+// NewLockout answers nil when maxAttempts is 0, accounts are then never
+// locked.
+func NewLockout(store Store, maxAttempts int, window time.Duration, period time.Duration) *Lockout {
+	if maxAttempts <= 0 {
+		return nil
+	}
+	return &Lockout{Store: store, MaxAttempts: maxAttempts, Window: window, Period: period}
+}
+
+// Locked is how long the account stays locked to address, zero when it is
+// not.
+func (l *Lockout) Locked(ctx context.Context, account string, address string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return l.Store.Expiry(ctx, lockoutKey("locked", account, address))
+}
+
+// Failed counts a failed login to the account from address and locks it to
+// address once there were too many, answering how long for.
+func (l *Lockout) Failed(ctx context.Context, account string, address string) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	failures, err := l.Store.Increment(ctx, lockoutKey("failures", account, address), l.Window)
+	if err != nil || failures < l.MaxAttempts {
+		return 0, err
+	}
+	if _, err := l.Store.Increment(ctx, lockoutKey("locked", account, address), l.Period); err != nil {
+		return 0, err
+	}
+	// NOTE: The attempts are counted from scratch once the lock expires
+	return l.Period, l.Store.Delete(ctx, lockoutKey("failures", account, address))
+}
+
+// Succeeded forgets the failed logins to the account from address.
+func (l *Lockout) Succeeded(ctx context.Context, account string, address string) error {
+	if l == nil {
+		return nil
+	}
+	return l.Store.Delete(ctx, lockoutKey("failures", account, address))
+}
+
+// lockoutKey ends with the account, the only part written by the client.
+func lockoutKey(kind string, account string, address string) string {
+	return "lockout:" + kind + ":" + address + ":" + strings.ToLower(strings.TrimSpace(account))
+}
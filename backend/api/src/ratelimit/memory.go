@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore forgets the full buckets and the
+// expired counters.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+	period time.Duration
+}
+
+type counter struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryStore keeps the buckets of a single instance.
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]bucket
+	counters map[string]counter
+	swept    time.Time
+	now      func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]bucket{}, counters: map[string]counter{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	tokens := float64(limit.Requests)
+	if b, ok := s.buckets[key]; ok {
+		tokens = limit.refill(b.tokens, now.Sub(b.at))
+	}
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	s.buckets[key] = bucket{tokens: tokens, at: now, period: limit.Period}
+	return limit.result(allowed, tokens), nil
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = counter{expires: now.Add(ttl)}
+	}
+	c.count++
+	s.counters[key] = c
+	return c.count, nil
+}
+
+func (s *MemoryStore) Expiry(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.counters[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(c.expires.Sub(s.now()), 0), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets, key)
+	delete(s.counters, key)
+	return nil
+}
+
+// sweep drops what holds nothing more than a missing key would, at most once
+// every sweepInterval, so clients seen once do not pile up.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < sweepInterval {
+		return
+	}
+	s.swept = now
+	for key, b := range s.buckets {
+		if now.Sub(b.at) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expires) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package ratelimit throttles the clients of the api with token buckets kept
+// in memory or in a Redis-compatible server, and locks accounts out after
+// repeated failed logins.
+package ratelimit
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/middleware"
+)
+
+// Limit lets Requests through every Period, in bursts of up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Result is the state of a bucket once a token was asked for.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, when not Allowed
+	RetryAfter time.Duration
+}
+
+// refill answers the tokens of a bucket of limit that held tokens elapsed ago.
+func (l Limit) refill(tokens float64, elapsed time.Duration) float64 {
+	tokens += float64(elapsed) * float64(l.Requests) / float64(l.Period)
+	return math.Min(tokens, float64(l.Requests))
+}
+
+// result describes a bucket of limit left with tokens.
+func (l Limit) result(allowed bool, tokens float64) Result {
+	perToken := float64(l.Period) / float64(l.Requests)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     l.Requests,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(l.Requests) - tokens) * perToken),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return r
+}
+
+// Store keeps the buckets and counters of every instance sharing it.
+type Store interface {
+	// Take removes a token from the bucket of key if there is one left.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Increment counts one more for key, the count is forgotten ttl after
+	// the first one.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int, error)
+	// Expiry is how long until the count of key is forgotten, zero when
+	// there is none.
+	Expiry(ctx context.Context, key string) (time.Duration, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore connects to the Redis-compatible server at redisUrl, an empty
+// url keeps the buckets in memory, each instance counting on its own.
+func NewStore(redisUrl string) (Store, error) {
+	if redisUrl == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewRedisStore(redisUrl)
+}
+
+// Keys of the clients a rule counts the requests of.
+const (
+	// IP counts the requests of each address
+	IP = "ip"
+	// ApiKey counts the requests of each Authorization header the upstream
+	// api accepted, the address for the others
+	ApiKey = "api_key"
+	// Employee counts the requests of each authenticated employee, the
+	// address for the others
+	Employee = "employee"
+)
+
+// DefaultRule is the prefix of the rule applying to the paths no other rule
+// matches.
+const DefaultRule = "default"
+
+// Rule limits the requests to the paths starting with Prefix, every client
+// identified by Key having a bucket of its own.
+type Rule struct {
+	Prefix string
+	Limit  Limit
+	Key    string
+}
+
+// ParseRules reads rules written prefix=requests/period:key, like
+// /api/v1/import=10/1m:employee. The key is IP when left out and the prefix
+// default matches every path.
+func ParseRules(values []string) ([]Rule, error) {
+	var rules []Rule
+	for _, value := range values {
+		prefix, spec, ok := strings.Cut(strings.TrimSpace(value), "=")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("ratelimit: rules must be written prefix=requests/period:key, got %q", value)
+		}
+		spec, key, _ := strings.Cut(spec, ":")
+		key = strings.ToLower(cmp.Or(key, IP))
+		if !slices.Contains([]string{IP, ApiKey, Employee}, key) {
+			return nil, fmt.Errorf("ratelimit: unknown key %q in %q, expected ip, api_key or employee", key, value)
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == DefaultRule {
+			prefix = ""
+		}
+		if slices.ContainsFunc(rules, func(r Rule) bool { return r.Prefix == prefix }) {
+			return nil, fmt.Errorf("ratelimit: %q has more than one rule", cmp.Or(prefix, DefaultRule))
+		}
+		rules = append(rules, Rule{Prefix: prefix, Limit: limit, Key: key})
+	}
+	return rules, nil
+}
+
+// ParseLimit reads a limit written requests/period, like 300/1m.
+func ParseLimit(value string) (Limit, error) {
+	requests, period, _ := strings.Cut(strings.TrimSpace(value), "/")
+	limit := Limit{}
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests < 1 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid number of requests in %q", value)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("ratelimit: invalid period in %q, expected a duration like 1m", value)
+	}
+	return limit, nil
+}
+
+// Match answers the rule with the longest prefix of path, made of whole
+// segments: /api/v1/customers matches /api/v1/customers/reassign but not
+// /api/v1/customersXYZ.
+func Match(rules []Rule, path string) (Rule, bool) {
+	var match Rule
+	found := false
+	for _, rule := range rules {
+		rest, ok := strings.CutPrefix(path, rule.Prefix)
+		if !ok || (rest != "" && rule.Prefix != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		if !found || len(rule.Prefix) > len(match.Prefix) {
+			match, found = rule, true
+		}
+	}
+	return match, found
+}
+
+// Middleware takes a token from the bucket of the client in store for every
+// request, and answers 429 once there are none left. Every response carries
+// the RateLimit headers of the IETF draft, and Retry-After when refused. A
+// store that fails lets the requests through.
+//
+// The clients are told apart by rule.Key, the address of the request being
+// the last one of X-Forwarded-For when forwardedFor is set.
+func Middleware(store Store, rule Rule, forwardedFor bool) func(http.Handler) http.Handler {
+	policy := fmt.Sprintf("%d;w=%d", rule.Limit.Requests, seconds(rule.Limit.Period))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			key := "ratelimit:" + cmp.Or(rule.Prefix, DefaultRule) + ":" + clientKey(req, rule.Key, forwardedFor)
+			result, err := store.Take(req.Context(), key, rule.Limit)
+			if err != nil {
+				logger.FromContext(req.Context()).Warn("could not rate limit the request", "error", err)
+				next.ServeHTTP(res, req)
+				return
+			}
+			header := res.Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				apierror.Write(res, req, apierror.TooManyRequests("Too many requests, retry later"))
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// clientKey identifies the client of req by key. A token only tells clients
+// apart once the upstream api vouched for it, anyone can send a new one with
+// every request.
+func clientKey(req *http.Request, key string, forwardedFor bool) string {
+	if user, ok := middleware.UserFrom(req.Context()); ok {
+		switch key {
+		case Employee:
+			return "employee:" + strconv.Itoa(user.Id)
+		case ApiKey:
+			// NOTE: Hashed so the tokens never reach the store
+			sum := sha256.Sum256([]byte(req.Header.Get("Authorization")))
+			return "token:" + hex.EncodeToString(sum[:16])
+		}
+	}
+	return "ip:" + ClientIP(req, forwardedFor)
+}
+
+// ClientIP is the address req comes from, the last one of X-Forwarded-For
+// when forwardedFor is set, as the proxy in front of the api appends it.
+func ClientIP(req *http.Request, forwardedFor bool) string {
+	if forwardedFor {
+		addresses := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+		if address := strings.TrimSpace(addresses[len(addresses)-1]); address != "" {
+			return address
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// seconds rounds d up to whole seconds, as the headers count them.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,263 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"soul-connection.com/api/src/middleware"
+)
+
+// clock is a MemoryStore whose time only moves when told to.
+func clock() (*MemoryStore, func(time.Duration)) {
+	store := NewMemoryStore()
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]string{"default=300/1m", " /api/v1/import/=10/1m:employee", "/api/graphql=60/30s:API_KEY"})
+	if err != nil {
+		t.Fatalf("Failed to parse the rules: %v", err)
+	}
+	expected := []Rule{
+		{Prefix: "", Limit: Limit{Requests: 300, Period: time.Minute}, Key: IP},
+		{Prefix: "/api/v1/import", Limit: Limit{Requests: 10, Period: time.Minute}, Key: Employee},
+		{Prefix: "/api/graphql", Limit: Limit{Requests: 60, Period: 30 * time.Second}, Key: ApiKey},
+	}
+	for i, rule := range expected {
+		if rules[i] != rule {
+			t.Errorf("Expected %+v, got %+v", rule, rules[i])
+		}
+	}
+
+	for _, invalid := range []string{"300/1m", "default=0/1m", "default=ten/1m", "default=10/soon", "default=10/1m:session"} {
+		if _, err := ParseRules([]string{invalid}); err == nil {
+			t.Errorf("Expected %q to be refused", invalid)
+		}
+	}
+	if _, err := ParseRules([]string{"default=1/1s", "default=2/1s"}); err == nil {
+		t.Errorf("Expected two rules of the same prefix to be refused")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules, _ := ParseRules([]string{"default=300/1m", "/api/v1/customers=10/1m"})
+	tests := []struct {
+		path   string
+		prefix string
+	}{
+		{"/api/v1/customers", "/api/v1/customers"},
+		{"/api/v1/customers/reassign", "/api/v1/customers"},
+		{"/api/v1/customersXYZ", ""},
+		{"/api/v1/employees", ""},
+	}
+	for _, tc := range tests {
+		if rule, ok := Match(rules, tc.path); !ok || rule.Prefix != tc.prefix {
+			t.Errorf("Expected %s to match %q, got %q", tc.path, tc.prefix, rule.Prefix)
+		}
+	}
+	if _, ok := Match(rules[1:], "/api/v1/employees"); ok {
+		t.Errorf("Expected no rule without a default")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store, advance := clock()
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	t.Run("Bucket", func(t *testing.T) {
+		first, _ := store.Take(ctx, "a", limit)
+		second, _ := store.Take(ctx, "a", limit)
+		third, _ := store.Take(ctx, "a", limit)
+		if !first.Allowed || first.Remaining != 1 || !second.Allowed || second.Remaining != 0 {
+			t.Fatalf("Expected a burst of 2, got %+v and %+v", first, second)
+		}
+		if third.Allowed || third.RetryAfter != 30*time.Second || third.Reset != time.Minute {
+			t.Errorf("Expected a token back in 30s, got %+v", third)
+		}
+		if other, _ := store.Take(ctx, "b", limit); !other.Allowed {
+			t.Errorf("Expected every key to have its own bucket")
+		}
+
+		advance(30 * time.Second)
+		if refilled, _ := store.Take(ctx, "a", limit); !refilled.Allowed || refilled.Remaining != 0 {
+			t.Errorf("Expected a single token refilled, got %+v", refilled)
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		advance(2 * time.Minute)
+		store.Take(ctx, "c", limit)
+		if _, ok := store.buckets["a"]; ok || len(store.buckets) != 1 {
+			t.Errorf("Expected the full buckets to be forgotten, got %v", store.buckets)
+		}
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		for i := 1; i <= 2; i++ {
+			if count, _ := store.Increment(ctx, "failures", time.Minute); count != i {
+				t.Errorf("Expected %d, got %d", i, count)
+			}
+		}
+		advance(20 * time.Second)
+		if expiry, _ := store.Expiry(ctx, "failures"); expiry != 40*time.Second {
+			t.Errorf("Expected the count to expire a minute after the first, got %s", expiry)
+		}
+		advance(time.Minute)
+		if count, _ := store.Increment(ctx, "failures", time.Minute); count != 1 {
+			t.Errorf("Expected the count to start over once expired, got %d", count)
+		}
+		store.Delete(ctx, "failures")
+		if expiry, _ := store.Expiry(ctx, "failures"); expiry != 0 {
+			t.Errorf("Expected nothing once deleted, got %s", expiry)
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	store, _ := clock()
+	serve := func(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+	ok := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {})
+
+	t.Run("Headers", func(t *testing.T) {
+		handler := Middleware(store, Rule{Prefix: "/api/v1/customers", Limit: Limit{Requests: 2, Period: time.Minute}, Key: IP}, false)(ok)
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/customers", nil)
+		first := serve(handler, req)
+		if first.Code != http.StatusOK || first.Header().Get("RateLimit-Limit") != "2" || first.Header().Get("RateLimit-Remaining") != "1" || first.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("Expected the RateLimit headers, got %d %v", first.Code, first.Header())
+		}
+		serve(handler, req)
+		refused := serve(handler, req)
+		if refused.Code != http.StatusTooManyRequests || refused.Header().Get("Retry-After") != "30" || refused.Header().Get("RateLimit-Reset") != "60" {
+			t.Errorf("Expected 429 with Retry-After, got %d %v", refused.Code, refused.Header())
+		}
+		other := httptest.NewRequest(http.MethodGet, "/api/v1/customers", nil)
+		other.RemoteAddr = "192.0.2.2:1234"
+		if res := serve(handler, other); res.Code != http.StatusOK {
+			t.Errorf("Expected another address to have its own bucket, got %d", res.Code)
+		}
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			key          string
+			forwardedFor bool
+			prepare      func(*http.Request) *http.Request
+			expected     string
+		}{
+			{"IP", IP, false, func(r *http.Request) *http.Request { return r }, "ip:192.0.2.1"},
+			{"Forwarded For", IP, true, func(r *http.Request) *http.Request {
+				r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.3")
+				return r
+			}, "ip:198.51.100.3"},
+			{"Forwarded For Ignored", IP, false, func(r *http.Request) *http.Request {
+				r.Header.Set("X-Forwarded-For", "203.0.113.7")
+				return r
+			}, "ip:192.0.2.1"},
+			{"Api Key", ApiKey, false, func(r *http.Request) *http.Request {
+				r.Header.Set("Authorization", "Bearer secret")
+				return r.WithContext(middleware.WithUser(r.Context(), &middleware.User{Id: 42}))
+			}, "token:"},
+			{"Api Key Unverified", ApiKey, false, func(r *http.Request) *http.Request {
+				r.Header.Set("Authorization", "Bearer secret")
+				return r
+			}, "ip:192.0.2.1"},
+			{"Api Key Missing", ApiKey, false, func(r *http.Request) *http.Request { return r }, "ip:192.0.2.1"},
+			{"Employee", Employee, false, func(r *http.Request) *http.Request {
+				r.Header.Set("Authorization", "Bearer secret")
+				return r.WithContext(middleware.WithUser(r.Context(), &middleware.User{Id: 42}))
+			}, "employee:42"},
+			{"Employee Unknown", Employee, false, func(r *http.Request) *http.Request {
+				r.Header.Set("Authorization", "Bearer secret")
+				return r
+			}, "ip:192.0.2.1"},
+		}
+		for _, tc := range tests {
+			t.Run(tc.name, func(t *testing.T) {
+				key := clientKey(tc.prepare(httptest.NewRequest(http.MethodGet, "/", nil)), tc.key, tc.forwardedFor)
+				if len(key) < len(tc.expected) || key[:len(tc.expected)] != tc.expected {
+					t.Errorf("Expected %q, got %q", tc.expected, key)
+				}
+			})
+		}
+	})
+
+	t.Run("Rotated Header", func(t *testing.T) {
+		for _, key := range []string{ApiKey, Employee} {
+			handler := Middleware(store, Rule{Prefix: "/api/v1/" + key, Limit: Limit{Requests: 1, Period: time.Minute}, Key: key}, false)(ok)
+			var res *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/"+key, nil)
+				req.Header.Set("Authorization", "Bearer forged-"+strconv.Itoa(i))
+				res = serve(handler, req)
+			}
+			if res.Code != http.StatusTooManyRequests {
+				t.Errorf("Expected a new %s header not to get a new bucket, got %d", key, res.Code)
+			}
+		}
+	})
+
+	t.Run("Store Down", func(t *testing.T) {
+		down, _ := NewRedisStore("redis://127.0.0.1:1")
+		handler := Middleware(down, Rule{Limit: Limit{Requests: 1, Period: time.Minute}, Key: IP}, false)(ok)
+		if res := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil)); res.Code != http.StatusOK || res.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("Expected the request through without headers, got %d %v", res.Code, res.Header())
+		}
+	})
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	store, advance := clock()
+	lockout := NewLockout(store, 3, 10*time.Minute, 15*time.Minute)
+
+	for i := 1; i < 3; i++ {
+		if locked, _ := lockout.Failed(ctx, "Zoe@test.com", "192.0.2.1"); locked != 0 {
+			t.Fatalf("Expected failure %d not to lock, got %s", i, locked)
+		}
+	}
+	if locked, _ := lockout.Failed(ctx, " zoe@test.com", "192.0.2.1"); locked != 15*time.Minute {
+		t.Fatalf("Expected the third failure to lock the account, whatever its case, got %s", locked)
+	}
+	advance(5 * time.Minute)
+	if locked, _ := lockout.Locked(ctx, "zoe@test.com", "192.0.2.1"); locked != 10*time.Minute {
+		t.Errorf("Expected 10 minutes left, got %s", locked)
+	}
+	if locked, _ := lockout.Locked(ctx, "max@test.com", "192.0.2.1"); locked != 0 {
+		t.Errorf("Expected other accounts not to be locked, got %s", locked)
+	}
+	if locked, _ := lockout.Locked(ctx, "zoe@test.com", "198.51.100.7"); locked != 0 {
+		t.Errorf("Expected the account to be locked to the address of the failures only, got %s", locked)
+	}
+	advance(10 * time.Minute)
+	if locked, _ := lockout.Locked(ctx, "zoe@test.com", "192.0.2.1"); locked != 0 {
+		t.Errorf("Expected the lock to expire, got %s", locked)
+	}
+
+	t.Run("Succeeded", func(t *testing.T) {
+		lockout.Failed(ctx, "max@test.com", "192.0.2.1")
+		lockout.Failed(ctx, "max@test.com", "192.0.2.1")
+		lockout.Succeeded(ctx, "max@test.com", "192.0.2.1")
+		if locked, _ := lockout.Failed(ctx, "max@test.com", "192.0.2.1"); locked != 0 {
+			t.Errorf("Expected a successful login to forget the failures, got %s", locked)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var disabled *Lockout = NewLockout(store, 0, time.Minute, time.Minute)
+		if locked, err := disabled.Failed(ctx, "zoe@test.com", "192.0.2.1"); disabled != nil || locked != 0 || err != nil {
+			t.Errorf("Expected no lockout, got %s (%v)", locked, err)
+		}
+	})
+}
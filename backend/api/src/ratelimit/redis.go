@@ -0,0 +1,280 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takeScript refills and takes from the bucket of KEYS[1] atomically, by the
+// clock of the server so that every instance agrees. ARGV are the requests
+// and the period in milliseconds of the limit. The tokens are answered as a
+// string, Lua numbers become integers on the way out.
+const takeScript = `
+local requests = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = tonumber(bucket[1]) or requests
+local at = tonumber(bucket[2]) or now
+tokens = math.min(requests, tokens + math.max(now - at, 0) * requests / period)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`
+
+// incrementScript counts one more for KEYS[1], setting its ttl of ARGV[1]
+// milliseconds on the first one only.
+const incrementScript = `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`
+
+// redisTimeout bounds every command, a slow store must not hold the requests
+// it throttles.
+const redisTimeout = 2 * time.Second
+
+// maxIdle is how many connections RedisStore keeps open between commands.
+const maxIdle = 8
+
+// RedisStore keeps the buckets in a server speaking the Redis protocol, like
+// Redis, Valkey or KeyDB, shared by every instance of the api. Its scripts
+// need Redis 5 or a server compatible with it.
+type RedisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+// NewRedisStore reads redisUrl as redis://[user:password@]host:port[/db],
+// the connections are only opened once needed.
+func NewRedisStore(redisUrl string) (*RedisStore, error) {
+	u, err := url.Parse(redisUrl)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, errors.New("ratelimit: the redis url must be written redis://[user:password@]host:port[/db]")
+	}
+	s := &RedisStore{addr: u.Host, idle: make(chan *redisConn, maxIdle)}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("ratelimit: invalid redis database %q", db)
+		}
+	}
+	return s, nil
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := s.do(ctx, "EVAL", takeScript, "1", key, strconv.Itoa(limit.Requests), strconv.FormatInt(limit.Period.Milliseconds(), 10))
+	if err != nil {
+		return Result{}, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	return limit.result(allowed == 1, tokens), nil
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	reply, err := s.do(ctx, "EVAL", incrementScript, "1", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	return int(count), nil
+}
+
+func (s *RedisStore) Expiry(ctx context.Context, key string) (time.Duration, error) {
+	reply, err := s.do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+	ttl, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	// NOTE: -2 is a missing key and -1 a key without expiry, which none of ours is
+	return time.Duration(max(ttl, 0)) * time.Millisecond, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", key)
+	return err
+}
+
+// Close closes the idle connections.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case conn := <-s.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do sends a command on an idle connection, or a new one, and answers its
+// reply. A connection that failed is closed rather than reused.
+func (s *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+	dialer := net.Dialer{Timeout: redisTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := conn.do(ctx, args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error answered by the server, the connection is still
+// usable after it.
+type redisError string
+
+func (e redisError) Error() string {
+	return "ratelimit: redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *redisConn) do(ctx context.Context, args ...string) (any, error) {
+	deadline := time.Now().Add(redisTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, command.String()); err != nil {
+		return nil, err
+	}
+	return readReply(c.reader)
+}
+
+// readReply reads a RESP2 reply: simple strings and bulk strings are
+// answered as strings, integers as int64, arrays as []any and nil values as
+// nil.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("ratelimit: malformed redis reply")
+	}
+	switch kind, value := line[0], line[1:]; kind {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, redisError(value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		content := make([]byte, size+2)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, err
+		}
+		return string(content[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		values := make([]any, size)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				var redisErr redisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				values[i] = err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("ratelimit: unexpected redis reply %q", line)
+}
@@ -0,0 +1,166 @@
+// Package retention removes for good the employees and customers deleted
+// longer ago than the retention period, along with their images.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+
+	"soul-connection.com/api/src/audit"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/database"
+	filestorage "soul-connection.com/api/src/file-storage"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/metrics"
+)
+
+// Buckets hold the images of the purged rows.
+type Buckets struct {
+	Employees *gridfs.Bucket
+	Customers *gridfs.Bucket
+	Clothes   *gridfs.Bucket
+}
+
+// Purger purges the rows deleted more than Period ago. The payments,
+// encounters and clothes of a customer go with it, so the revenues shrink by
+// what it paid, and the events of an employee go with them while their
+// customers are left without coach.
+type Purger struct {
+	DB      *sql.DB
+	Buckets Buckets
+	// Period is how long deleted rows are kept, zero keeps them forever
+	Period time.Duration
+	Audit  *audit.Log
+
+	// remove deletes an image, tests replace filestorage.Delete
+	remove func(context.Context, *gridfs.Bucket, primitive.ObjectID) error
+}
+
+// Purged counts the rows a purge removed.
+type Purged struct {
+	Employees int
+	Customers int
+	Clothes   int
+}
+
+// Run purges now and then every interval until ctx is cancelled, it does
+// nothing when either Period or interval is zero.
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	if p.Period <= 0 || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := p.Purge(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.FromContext(ctx).Error("Could not purge deleted rows", "error", err)
+		case err == nil && purged != (Purged{}):
+			logger.FromContext(ctx).Info("Purged deleted rows", "employees", purged.Employees, "customers", purged.Customers, "clothes", purged.Clothes)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// row is a purged row and its image, if any.
+type row struct {
+	id      int
+	imageId *string
+}
+
+// Purge removes the rows deleted more than Period ago and records it in one
+// transaction, then removes their images. An image that could not be removed
+// is logged, the rows are gone either way.
+func (p *Purger) Purge(ctx context.Context) (Purged, error) {
+	before := time.Now().UTC().Add(-p.Period)
+
+	tx, err := p.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Purged{}, err
+	}
+	// NOTE: Clothes would go with their customer anyway, they are deleted
+	// first to know which images to remove
+	clothes, err := deleteReturning(ctx, tx, "DELETE FROM clothe WHERE customer_id IN (SELECT id FROM customer WHERE deleted_at < $1) RETURNING id, image_id", before)
+	if err != nil {
+		tx.Rollback()
+		return Purged{}, err
+	}
+	customers, err := deleteReturning(ctx, tx, "DELETE FROM customer WHERE deleted_at < $1 RETURNING id, image_id", before)
+	if err != nil {
+		tx.Rollback()
+		return Purged{}, err
+	}
+	employees, err := deleteReturning(ctx, tx, "DELETE FROM employee WHERE deleted_at < $1 RETURNING id, image_id", before)
+	if err != nil {
+		tx.Rollback()
+		return Purged{}, err
+	}
+	for entity, rows := range map[changes.Entity][]row{changes.Customers: customers, changes.Employees: employees} {
+		for _, r := range rows {
+			if err := p.Audit.Record(database.WithTx(ctx, tx), entity, audit.Purged, r.id, nil, nil); err != nil {
+				tx.Rollback()
+				return Purged{}, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Purged{}, err
+	}
+
+	p.removeImages(ctx, p.Buckets.Clothes, clothes)
+	p.removeImages(ctx, p.Buckets.Customers, customers)
+	p.removeImages(ctx, p.Buckets.Employees, employees)
+	metrics.RetentionPurged.WithLabelValues(string(changes.Clothes)).Add(float64(len(clothes)))
+	metrics.RetentionPurged.WithLabelValues(string(changes.Customers)).Add(float64(len(customers)))
+	metrics.RetentionPurged.WithLabelValues(string(changes.Employees)).Add(float64(len(employees)))
+
+	return Purged{Employees: len(employees), Customers: len(customers), Clothes: len(clothes)}, nil
+}
+
+func (p *Purger) removeImages(ctx context.Context, bucket *gridfs.Bucket, rows []row) {
+	remove := p.remove
+	if remove == nil {
+		remove = filestorage.Delete
+	}
+	for _, r := range rows {
+		if r.imageId == nil {
+			continue
+		}
+		fileId, err := primitive.ObjectIDFromHex(*r.imageId)
+		if err == nil {
+			err = remove(ctx, bucket, fileId)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Error("Could not remove the image of a purged row", "id", r.id, "image_id", *r.imageId, "error", err)
+		}
+	}
+}
+
+func deleteReturning(ctx context.Context, tx *sql.Tx, query string, before time.Time) ([]row, error) {
+	ctx, cancel := database.WithQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deleted []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.imageId); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, r)
+	}
+	return deleted, rows.Err()
+}
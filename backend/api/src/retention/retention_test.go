@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema := `
+	CREATE TABLE employee (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		image_id TEXT,
+		deleted_at DATETIME
+	);
+	CREATE TABLE customer (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		image_id TEXT,
+		deleted_at DATETIME
+	);
+	CREATE TABLE clothe (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		image_id TEXT,
+		customer_id INTEGER
+	);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	return db
+}
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("Failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestPurge(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now().UTC()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	images := []string{primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex(), primitive.NewObjectID().Hex()}
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{"INSERT INTO employee (id, image_id, deleted_at) VALUES (1, $1, $2)", []any{images[0], old}},
+		{"INSERT INTO employee (id, deleted_at) VALUES (2, $1)", []any{recent}},
+		{"INSERT INTO employee (id) VALUES (3)", nil},
+		{"INSERT INTO customer (id, image_id, deleted_at) VALUES (1, $1, $2)", []any{images[1], old}},
+		{"INSERT INTO customer (id, deleted_at) VALUES (2, $1)", []any{recent}},
+		{"INSERT INTO clothe (image_id, customer_id) VALUES ($1, 1)", []any{images[2]}},
+		{"INSERT INTO clothe (customer_id) VALUES (2)", nil},
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement.query, statement.args...); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	var removed []string
+	purger := &Purger{
+		DB:     db,
+		Period: 24 * time.Hour,
+		remove: func(ctx context.Context, bucket *gridfs.Bucket, fileId primitive.ObjectID) error {
+			removed = append(removed, fileId.Hex())
+			return nil
+		},
+	}
+
+	purged, err := purger.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+	if purged != (Purged{Employees: 1, Customers: 1, Clothes: 1}) {
+		t.Errorf("Expected a row of each, got %+v", purged)
+	}
+	if count(t, db, "employee") != 2 || count(t, db, "customer") != 1 || count(t, db, "clothe") != 1 {
+		t.Errorf("Expected the recently deleted and live rows to be kept")
+	}
+	slices.Sort(removed)
+	slices.Sort(images)
+	if !slices.Equal(removed, images) {
+		t.Errorf("Expected the images %v to be removed, got %v", images, removed)
+	}
+
+	purged, err = purger.Purge(context.Background())
+	if err != nil || purged != (Purged{}) {
+		t.Errorf("Expected nothing left to purge, got %+v (%v)", purged, err)
+	}
+}
+
+func TestRunDisabled(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		(&Purger{}).Run(context.Background(), time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected Run to return without a retention period")
+	}
+}
@@ -1,13 +1,14 @@
 package server
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-
-	"soul-connection.com/api/src/lib"
+	"time"
 )
 
 func Start(server *http.Server, running chan struct{}) {
@@ -17,15 +18,37 @@ func Start(server *http.Server, running chan struct{}) {
 	}
 }
 
-func Stop(server *http.Server) {
+// Stop waits for SIGINT or SIGTERM and shuts server down gracefully. drain is
+// called first, failing the readiness probe, and server keeps serving for
+// preStop so that the orchestrator sees it and stops routing traffic to it
+// before the listeners close.
+func Stop(server *http.Server, drain func(), preStop time.Duration, drainTimeout time.Duration) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
 
 	<-stop
-	lib.ServerLog("INFO", "Shutting down server...")
+	slog.Info("Shutting down server...", "pre_stop", preStop)
+	drain()
+	time.Sleep(preStop)
+
+	if err := Shutdown(server, drainTimeout); err != nil {
+		slog.Error("Server shutdown failed", "error", err)
+		return
+	}
+	slog.Info("Server gracefully stopped")
+}
+
+// Shutdown stops accepting connections and gives in-flight requests
+// drainTimeout to complete, the ones still running after it are cut off.
+// Functions registered with server.RegisterOnShutdown run when it starts.
+func Shutdown(server *http.Server, drainTimeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
 
-	if err := server.Close(); err != nil {
-		log.Fatal("Server shutdown failed:", err)
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return err
 	}
-	lib.ServerLog("INFO", "Server gracefully stopped")
+	return nil
 }
@@ -1,21 +1,145 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"syscall"
 	"testing"
+	"time"
+
+	"soul-connection.com/api/src/health"
 )
 
+func startServer(t *testing.T, handler http.Handler) (*http.Server, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	apiServer := &http.Server{Handler: handler}
+	go apiServer.Serve(listener)
+	return apiServer, fmt.Sprintf("http://%s", listener.Addr())
+}
+
 func TestStartAndStop(t *testing.T) {
 	apiServer := &http.Server{
-		Addr: fmt.Sprintf(":%d", 8080),
+		Addr: "127.0.0.1:0",
 	}
 	running := make(chan struct{})
 	go Start(apiServer, running)
 	<-running
+	if err := Shutdown(apiServer, time.Second); err != nil {
+		t.Errorf("Failed to shut down: %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-release
+		res.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("Drains In-Flight Requests", func(t *testing.T) {
+		apiServer, url := startServer(t, slow)
+
+		result := make(chan error, 1)
+		go func() {
+			resp, err := http.Get(url)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+				}
+			}
+			result <- err
+		}()
+		<-started
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+		}()
+		if err := Shutdown(apiServer, time.Second); err != nil {
+			t.Fatalf("Failed to shut down: %v", err)
+		}
+		if err := <-result; err != nil {
+			t.Errorf("Expected the in-flight request to complete, got %v", err)
+		}
+	})
+
+	t.Run("Drain Timeout", func(t *testing.T) {
+		started = make(chan struct{})
+		release = make(chan struct{})
+		defer close(release)
+
+		apiServer, url := startServer(t, slow)
+		go http.Get(url)
+		<-started
+
+		if err := Shutdown(apiServer, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestStop(t *testing.T) {
+	probe := &health.Probe{}
+	apiServer, url := startServer(t, http.HandlerFunc(probe.Ready))
+	ready := func() (int, error) {
+		resp, err := http.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	// NOTE: Keeps the signals from killing the test before Stop listens to them
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	stopped := make(chan struct{})
 	go func() {
-		defer Stop(apiServer)
-		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		Stop(apiServer, probe.Drain, 300*time.Millisecond, time.Second)
+		close(stopped)
 	}()
+
+	// NOTE: Signalled until Stop has received one, it may not listen yet
+	process, _ := os.FindProcess(os.Getpid())
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		process.Signal(syscall.SIGTERM)
+		if code, err := ready(); err != nil || code == http.StatusServiceUnavailable {
+			if err != nil {
+				t.Fatalf("Expected the server to keep serving while draining, got %v", err)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the readiness probe to fail once signalled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-stopped:
+		t.Fatalf("Expected the server to wait for the pre-stop delay")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if code, err := ready(); err != nil || code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 during the pre-stop delay, got %d %v", code, err)
+	}
+
+	<-stopped
+	if _, err := ready(); err == nil {
+		t.Errorf("Expected the server to be closed after the pre-stop delay")
+	}
 }
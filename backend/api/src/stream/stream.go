@@ -0,0 +1,180 @@
+// Package stream serves the changes of the bus to dashboards as server-sent
+// events, each user only receiving the changes of the rows they may read.
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"soul-connection.com/api/src/apierror"
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/metrics"
+	"soul-connection.com/api/src/middleware"
+)
+
+// heartbeat is how often an idle stream sends a comment so proxies do not
+// close it.
+var heartbeat = 25 * time.Second
+
+// retry is how long browsers wait before reconnecting a closed stream.
+const retry = 3 * time.Second
+
+// Repositories are read once per stream to find the customers of a coach.
+type Repositories struct {
+	Employees employees.EmployeesRepository
+	Customers customers.CustomersRepository
+}
+
+// Handler streams the changes published on bus as text/event-stream, one
+// JSON encoded changes.Change per event. The entities query parameter, like
+// customers,payments, only keeps the changes of those entities.
+//
+// Managers receive every change. Coaches, like anyone who is not a manager,
+// receive the employees, events and tips, and only the customers they follow
+// with their rows: a customer assigned to them is sent along with its
+// assignment, one taken away is sent one last time.
+func Handler(bus *changes.Bus, repos Repositories) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		user, ok := middleware.UserFrom(req.Context())
+		if !ok {
+			apierror.Write(res, req, apierror.Unauthorized("Authentication required"))
+			return
+		}
+		entities, err := entitiesOf(req)
+		if err != nil {
+			apierror.Write(res, req, err)
+			return
+		}
+
+		// NOTE: Subscribing before reading the customers of a coach keeps
+		// the assignments made in between
+		received, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+		audience, err := audienceOf(req.Context(), user, repos)
+		if err != nil {
+			apierror.Write(res, req, err)
+			return
+		}
+
+		metrics.ChangeStreams.Inc()
+		defer metrics.ChangeStreams.Dec()
+
+		controller := http.NewResponseController(res)
+		res.Header().Set("Content-Type", "text/event-stream")
+		res.Header().Set("Cache-Control", "no-cache")
+		res.Header().Set("X-Accel-Buffering", "no")
+		res.WriteHeader(http.StatusOK)
+		fmt.Fprintf(res, "retry: %d\n\n", retry.Milliseconds())
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-req.Context().Done():
+				return
+			case change, ok := <-received:
+				if !ok {
+					return
+				}
+				if !audience.allows(change) || !entities[change.Entity] {
+					continue
+				}
+				data, err := json.Marshal(change)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(res, "data: %s\n\n", data)
+			case <-ticker.C:
+				fmt.Fprint(res, ": ping\n\n")
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// entitiesOf answers the entities asked for, every one when none is.
+func entitiesOf(req *http.Request) (map[changes.Entity]bool, error) {
+	entities := map[changes.Entity]bool{}
+	query := req.URL.Query().Get("entities")
+	if query == "" {
+		for _, entity := range changes.Entities {
+			entities[entity] = true
+		}
+		return entities, nil
+	}
+	for _, name := range strings.Split(query, ",") {
+		entity := changes.Entity(strings.TrimSpace(name))
+		if !slices.Contains(changes.Entities, entity) {
+			return nil, apierror.BadRequest(fmt.Sprintf("Unknown entity %q", name))
+		}
+		entities[entity] = true
+	}
+	return entities, nil
+}
+
+// audience is what a single stream may receive. The customers a coach
+// follows are read once and kept up to date with the customer changes
+// going through the stream.
+type audience struct {
+	coach    bool
+	coachId  int
+	followed map[int]bool
+}
+
+func audienceOf(ctx context.Context, user *middleware.User, repos Repositories) (*audience, error) {
+	if user.IsManager() {
+		return &audience{}, nil
+	}
+	a := &audience{coach: true, followed: map[int]bool{}}
+	employee, err := repos.Employees.FindByOldID(ctx, user.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// NOTE: A coach without a local record follows nobody yet
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.coachId = employee.Id
+	followed, err := repos.Customers.FindByEmployeeID(ctx, employee.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, customer := range followed {
+		a.followed[customer.Id] = true
+	}
+	return a, nil
+}
+
+// allows tells whether change may be sent, it must see every change so the
+// customers of a coach stay current.
+func (a *audience) allows(change changes.Change) bool {
+	if !a.coach || change.CustomerId == nil {
+		return true
+	}
+	id := *change.CustomerId
+	before := a.followed[id]
+	if change.Entity != changes.Customers {
+		return before
+	}
+	after := change.Action != changes.Deleted && change.EmployeeId != nil && *change.EmployeeId == a.coachId
+	if after {
+		a.followed[id] = true
+	} else {
+		delete(a.followed, id)
+	}
+	return before || after
+}
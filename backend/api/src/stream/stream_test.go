@@ -0,0 +1,226 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"soul-connection.com/api/src/changes"
+	"soul-connection.com/api/src/endpoints/customers"
+	"soul-connection.com/api/src/endpoints/employees"
+	"soul-connection.com/api/src/middleware"
+)
+
+type MockEmployeesDB struct {
+	employees.EmployeesRepository
+	Employees []employees.Employee
+}
+
+func (m *MockEmployeesDB) FindByOldID(ctx context.Context, id int) (*employees.Employee, error) {
+	for _, employee := range m.Employees {
+		if employee.Soul_Connection_Id != nil && *employee.Soul_Connection_Id == id {
+			return &employee, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type MockCustomersDB struct {
+	customers.CustomersRepository
+	Customers []customers.Customer
+}
+
+func (m *MockCustomersDB) FindByEmployeeID(ctx context.Context, id int) ([]customers.Customer, error) {
+	var found []customers.Customer
+	for _, customer := range m.Customers {
+		if customer.Employee_Id != nil && *customer.Employee_Id == id {
+			found = append(found, customer)
+		}
+	}
+	return found, nil
+}
+
+func ptr(v int) *int {
+	return &v
+}
+
+// follow opens the stream as user and answers the changes it sends, once the
+// stream is established.
+func follow(t *testing.T, bus *changes.Bus, user *middleware.User, query string) <-chan changes.Change {
+	repos := Repositories{
+		Employees: &MockEmployeesDB{Employees: []employees.Employee{{Id: 2, Soul_Connection_Id: ptr(50)}}},
+		Customers: &MockCustomersDB{Customers: []customers.Customer{{Id: 3, Employee_Id: ptr(2)}, {Id: 9, Employee_Id: ptr(5)}}},
+	}
+	handler := Handler(bus, repos)
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		handler(res, req.WithContext(middleware.WithUser(req.Context(), user)))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+query, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open the stream: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected a 200 event stream, got %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), "retry: ") {
+		t.Fatalf("Expected the stream to start with its retry delay, got %q", scanner.Text())
+	}
+
+	received := make(chan changes.Change, 16)
+	go func() {
+		defer close(received)
+		defer res.Body.Close()
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var change changes.Change
+			if err := json.Unmarshal([]byte(data), &change); err != nil {
+				t.Errorf("Failed to decode %q: %v", data, err)
+				return
+			}
+			received <- change
+		}
+	}()
+	return received
+}
+
+// expect reads the next changes of received, written like "tips created 1".
+func expect(t *testing.T, received <-chan changes.Change, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case change := <-received:
+			if got := fmt.Sprintf("%s %s %d", change.Entity, change.Action, change.Id); got != w {
+				t.Errorf("Expected %q, got %q", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %q, got nothing", w)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Manager", func(t *testing.T) {
+		bus := changes.NewBus()
+		received := follow(t, bus, &middleware.User{Id: 90, Work: "Manager"}, "")
+		bus.Publish(ctx, changes.New(changes.Payments, changes.Created, 1, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Tips, changes.Updated, 4, nil))
+		expect(t, received, "payments created 1", "tips updated 4")
+	})
+
+	t.Run("Coach", func(t *testing.T) {
+		bus := changes.NewBus()
+		received := follow(t, bus, &middleware.User{Id: 50, Work: middleware.CoachWork}, "")
+
+		bus.Publish(ctx, changes.New(changes.Payments, changes.Created, 1, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Payments, changes.Created, 2, nil).ForCustomer(3))
+		// NOTE: Customer 9 is assigned to the coach, then customer 3 taken away
+		bus.Publish(ctx, changes.New(changes.Customers, changes.Updated, 9, nil).ForCustomer(9).WithCoach(ptr(2)))
+		bus.Publish(ctx, changes.New(changes.Encounters, changes.Created, 3, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Customers, changes.Updated, 3, nil).ForCustomer(3).WithCoach(ptr(5)))
+		bus.Publish(ctx, changes.New(changes.Clothes, changes.Deleted, 4, nil).ForCustomer(3))
+		bus.Publish(ctx, changes.New(changes.Customers, changes.Deleted, 9, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Encounters, changes.Updated, 3, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Events, changes.Created, 5, nil))
+
+		expect(t, received,
+			"payments created 2",
+			"customers updated 9",
+			"encounters created 3",
+			"customers updated 3",
+			"customers deleted 9",
+			"events created 5",
+		)
+	})
+
+	t.Run("Coach Without Record", func(t *testing.T) {
+		bus := changes.NewBus()
+		received := follow(t, bus, &middleware.User{Id: 51, Work: middleware.CoachWork}, "")
+		bus.Publish(ctx, changes.New(changes.Payments, changes.Created, 2, nil).ForCustomer(3))
+		bus.Publish(ctx, changes.New(changes.Tips, changes.Created, 1, nil))
+		expect(t, received, "tips created 1")
+	})
+
+	t.Run("Entities", func(t *testing.T) {
+		bus := changes.NewBus()
+		received := follow(t, bus, &middleware.User{Id: 90, Work: "Manager"}, "?entities=tips,%20events")
+		bus.Publish(ctx, changes.New(changes.Payments, changes.Created, 1, nil).ForCustomer(9))
+		bus.Publish(ctx, changes.New(changes.Events, changes.Created, 5, nil))
+		bus.Publish(ctx, changes.New(changes.Tips, changes.Created, 1, nil))
+		expect(t, received, "events created 5", "tips created 1")
+	})
+
+	t.Run("Bus Closed", func(t *testing.T) {
+		bus := changes.NewBus()
+		received := follow(t, bus, &middleware.User{Id: 90, Work: "Manager"}, "")
+		bus.Close()
+		select {
+		case _, ok := <-received:
+			if ok {
+				t.Error("Expected no change")
+			}
+		case <-time.After(time.Second):
+			t.Error("Expected the stream to end with the bus")
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		defer func(previous time.Duration) { heartbeat = previous }(heartbeat)
+		heartbeat = 10 * time.Millisecond
+
+		bus := changes.NewBus()
+		handler := Handler(bus, Repositories{})
+		server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			handler(res, req.WithContext(middleware.WithUser(req.Context(), &middleware.User{Work: "Manager"})))
+		}))
+		defer server.Close()
+
+		res, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Failed to open the stream: %v", err)
+		}
+		defer res.Body.Close()
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if scanner.Text() == ": ping" {
+				return
+			}
+		}
+		t.Error("Expected a ping")
+	})
+}
+
+func TestErrors(t *testing.T) {
+	handler := Handler(changes.NewBus(), Repositories{})
+
+	res := httptest.NewRecorder()
+	handler(res, httptest.NewRequest(http.MethodGet, "/api/stream", nil))
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a user, got %d", res.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stream?entities=tips,secrets", nil)
+	res = httptest.NewRecorder()
+	handler(res, req.WithContext(middleware.WithUser(req.Context(), &middleware.User{Work: "Manager"})))
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), "secrets") {
+		t.Errorf("Expected 400 naming the unknown entity, got %d %s", res.Code, res.Body.String())
+	}
+}
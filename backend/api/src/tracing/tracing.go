@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	None   = "none"
+	OTLP   = "otlp"
+	Stdout = "stdout"
+)
+
+const instrumentationName = "soul-connection.com/api"
+
+// Tracer starts the spans of the code of this repository, the libraries it
+// uses are instrumented on their own.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs exporter: none, otlp or stdout for local debugging. The OTLP
+// exporter sends to endpoint, or falls back to the standard
+// OTEL_EXPORTER_OTLP_* variables when it is empty. Trace context is propagated
+// either way, shutdown flushes the spans not exported yet.
+func Setup(ctx context.Context, exporterName string, endpoint string, serviceName string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch exporterName {
+	case "", None:
+		return func(context.Context) error { return nil }, nil
+	case OTLP:
+		var options []otlptracehttp.Option
+		if endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case Stdout:
+		// NOTE: stderr so spans are not mixed with the JSON logs
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q, expected %s, %s or %s", exporterName, None, OTLP, Stdout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: Later options win, serviceName overrides OTEL_RESOURCE_ATTRIBUTES
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+	)
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Fail marks span as failed with err, err is answered so it can be returned
+// right away: return tracing.Fail(span, err).
+func Fail(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
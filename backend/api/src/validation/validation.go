@@ -0,0 +1,241 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a request body, it is written back to
+// the client as is.
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = strings.TrimSpace(fmt.Sprintf("%s %s", err.Field, err.Message))
+	}
+	return strings.Join(messages, ", ")
+}
+
+// Struct checks v against the rules of its `validate` tags, separated by
+// commas:
+//
+//	required   the value is not empty (zero number, blank string)
+//	email      a bare email address
+//	date       a date formatted as YYYY-MM-DD
+//	url        an absolute http or https URL
+//	min=N      a number >= N
+//	max=N      a number <= N or a string of at most N characters
+//	oneof=a|b  one of the listed values
+//
+// Rules other than required are skipped for empty strings and nil pointers are
+// never checked, so the same rules work for Add* structs and partial Update*
+// ones. A zero number is a value like any other and is held to min and max.
+// It returns nil or Errors.
+func Struct(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	t := value.Type()
+	var errs Errors
+
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		field := value.Field(i)
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		if message := check(field, tag); message != "" {
+			errs = append(errs, FieldError{Field: FieldName(t.Field(i)), Message: message})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// FieldName is the name of field in JSON bodies, so errors name fields the
+// way clients wrote them.
+func FieldName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// Decode reads a JSON request body into v and validates it, malformed JSON and
+// values of the wrong type are reported as Errors too.
+func Decode(r io.Reader, v any) error {
+	return decode(json.NewDecoder(r), v)
+}
+
+// DecodeStrict is Decode for bodies whose every field matters, fields v does
+// not have are reported as Errors rather than ignored.
+func DecodeStrict(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	return decode(decoder, v)
+}
+
+func decode(decoder *json.Decoder, v any) error {
+	err := decoder.Decode(v)
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return Errors{{Field: typeErr.Field, Message: fmt.Sprintf("must be a %s", typeErr.Type)}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Errors{{Message: "request body is not valid JSON"}}
+	case err != nil && strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return Errors{{Field: field, Message: "is not a known field"}}
+	case err != nil:
+		// NOTE: Errors of custom unmarshalers, like an amount with too many decimals
+		return Errors{{Message: err.Error()}}
+	}
+	return Struct(v)
+}
+
+func check(v reflect.Value, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		if name == "required" {
+			if isEmpty(v) {
+				return "is required"
+			}
+			continue
+		}
+		if isEmpty(v) && !isNumber(v) {
+			return ""
+		}
+
+		var message string
+		switch name {
+		case "email":
+			message = checkEmail(v.String())
+		case "date":
+			message = checkDate(v.String())
+		case "url":
+			message = checkUrl(v.String())
+		case "min":
+			message = checkMin(v, param)
+		case "max":
+			message = checkMax(v, param)
+		case "oneof":
+			message = checkOneOf(v.String(), strings.Split(param, "|"))
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q", rule))
+		}
+		if message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+func isEmpty(v reflect.Value) bool {
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero()
+}
+
+func isNumber(v reflect.Value) bool {
+	return v.CanInt() || v.CanUint() || v.CanFloat()
+}
+
+func checkEmail(value string) string {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+func checkDate(value string) string {
+	if _, err := time.Parse(time.DateOnly, value); err != nil {
+		return "must be a date formatted as YYYY-MM-DD"
+	}
+	return ""
+}
+
+func checkUrl(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an absolute http or https URL"
+	}
+	return ""
+}
+
+func checkMin(v reflect.Value, param string) string {
+	limit := parseLimit(param)
+	if number(v) < limit {
+		return fmt.Sprintf("must be at least %s", param)
+	}
+	return ""
+}
+
+func checkMax(v reflect.Value, param string) string {
+	limit := parseLimit(param)
+	if v.Kind() == reflect.String {
+		if float64(utf8.RuneCountInString(v.String())) > limit {
+			return fmt.Sprintf("must be at most %s characters long", param)
+		}
+		return ""
+	}
+	if number(v) > limit {
+		return fmt.Sprintf("must be at most %s", param)
+	}
+	return ""
+}
+
+func checkOneOf(value string, allowed []string) string {
+	for _, a := range allowed {
+		if value == a {
+			return ""
+		}
+	}
+	return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+}
+
+func parseLimit(param string) float64 {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid limit %q", param))
+	}
+	return limit
+}
+
+func number(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	case v.CanFloat():
+		return v.Float()
+	}
+	panic(fmt.Sprintf("validation: %s is not a number", v.Type()))
+}
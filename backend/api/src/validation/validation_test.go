@@ -0,0 +1,100 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type addPerson struct {
+	Email   string  `validate:"required,email,max=20"`
+	Name    string  `validate:"required"`
+	Birth   string  `validate:"date"`
+	Age     int     `validate:"min=0,max=150"`
+	Gender  string  `validate:"oneof=Male|Female|Other"`
+	Website string  `validate:"url"`
+	Manager *int    `validate:"min=1"`
+	Note    *string `validate:"required,max=5"`
+	Comment string
+}
+
+func fields(err error) string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return ""
+	}
+	var names []string
+	for _, e := range errs {
+		names = append(names, e.Field)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestStruct(t *testing.T) {
+	zero, note, emptyNote := 0, "hello", " "
+
+	tests := []struct {
+		name     string
+		value    addPerson
+		expected string
+	}{
+		{"Valid", addPerson{Email: "zoe@test.com", Name: "Zoé", Birth: "1990-04-01", Age: 34, Gender: "Female", Website: "https://zoe.fr", Note: &note}, ""},
+		{"Optional Values Skipped", addPerson{Email: "zoe@test.com", Name: "Zoé"}, ""},
+		{"Required", addPerson{Name: "  "}, "Email,Name"},
+		{"Email", addPerson{Email: "Zoé <zoe@test.com>", Name: "Zoé"}, "Email"},
+		{"String Max", addPerson{Email: "a-very-long-address@test.com", Name: "Zoé"}, "Email"},
+		{"Date", addPerson{Email: "zoe@test.com", Name: "Zoé", Birth: "01/04/1990"}, "Birth"},
+		{"Url", addPerson{Email: "zoe@test.com", Name: "Zoé", Website: "zoe.fr"}, "Website"},
+		{"Range", addPerson{Email: "zoe@test.com", Name: "Zoé", Age: 420}, "Age"},
+		{"Enum", addPerson{Email: "zoe@test.com", Name: "Zoé", Gender: "male"}, "Gender"},
+		{"Pointer Min", addPerson{Email: "zoe@test.com", Name: "Zoé", Manager: &zero}, "Manager"},
+		{"Pointer Required", addPerson{Email: "zoe@test.com", Name: "Zoé", Note: &emptyNote}, "Note"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(&tt.value)
+			if fields(err) != tt.expected {
+				t.Errorf("Expected errors on %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"Valid", `{"Email": "zoe@test.com", "Name": "Zoé"}`, ""},
+		{"Invalid JSON", `{Email}`, ""},
+		{"Empty Body", ``, ""},
+		{"Wrong Type", `{"Email": "zoe@test.com", "Name": "Zoé", "Age": "old"}`, "Age"},
+		{"Invalid Values", `{"Email": "zoe", "Name": "Zoé"}`, "Email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var person addPerson
+			err := Decode(strings.NewReader(tt.body), &person)
+			if tt.name != "Valid" && err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if fields(err) != tt.expected {
+				t.Errorf("Expected errors on %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	var person addPerson
+	err := DecodeStrict(strings.NewReader(`{"Email": "zoe@test.com", "Name": "Zoé", "Nickname": "Zo"}`), &person)
+	if fields(err) != "Nickname" {
+		t.Errorf("Expected an error on Nickname, got %v", err)
+	}
+	if err := Decode(strings.NewReader(`{"Email": "zoe@test.com", "Name": "Zoé", "Nickname": "Zo"}`), &person); err != nil {
+		t.Errorf("Expected Decode to ignore unknown fields, got %v", err)
+	}
+}
@@ -1,7 +1,7 @@
 CREATE TABLE IF NOT EXISTS "employee" (
     id SERIAL PRIMARY KEY,
     soul_connection_id INT UNIQUE,
-    email VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
     password VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
     surname VARCHAR(255) NOT NULL,
@@ -9,9 +9,13 @@ CREATE TABLE IF NOT EXISTS "employee" (
     gender VARCHAR(255) NOT NULL,
     work VARCHAR(255) NOT NULL,
     image_id VARCHAR(255),
-    created_at TIMESTAMP DEFAULT NOW()
+    created_at TIMESTAMP DEFAULT NOW(),
+    deleted_at TIMESTAMP
 );
 
+-- Databases created before the soft delete are upgraded in place
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
 CREATE TABLE IF NOT EXISTS "event" (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
@@ -28,18 +32,60 @@ CREATE TABLE IF NOT EXISTS "event" (
 CREATE TABLE IF NOT EXISTS "customer" (
     id SERIAL PRIMARY KEY,
     soul_connection_id INT UNIQUE,
-    email VARCHAR(255) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
     name VARCHAR(255) NOT NULL,
     surname VARCHAR(255) NOT NULL,
-    birth_date VARCHAR(255) NOT NULL,
+    -- The sensitive columns are TEXT as they may hold values sealed with
+    -- ENCRYPTION_KEYS, longer than the 255 characters accepted in plaintext
+    birth_date TEXT NOT NULL,
     gender VARCHAR(255) NOT NULL,
-    description VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
     astrological_sign VARCHAR(255) NOT NULL,
-    phone_number VARCHAR(255) NOT NULL,
-    address VARCHAR(255) NOT NULL,
+    phone_number TEXT NOT NULL,
+    -- Blind index of the phone number, to look it up once sealed
+    phone_number_index VARCHAR(64) NOT NULL DEFAULT '',
+    address TEXT NOT NULL,
     image_id VARCHAR(255),
     created_at TIMESTAMP DEFAULT NOW(),
-    employee_id INT REFERENCES employee(id) ON DELETE CASCADE
+    deleted_at TIMESTAMP,
+    employee_id INT REFERENCES employee(id) ON DELETE SET NULL
+);
+
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+-- Purging an employee leaves their customers without a coach, it used to
+-- delete them along
+ALTER TABLE customer DROP CONSTRAINT IF EXISTS customer_employee_id_fkey;
+ALTER TABLE customer ADD CONSTRAINT customer_employee_id_fkey
+    FOREIGN KEY (employee_id) REFERENCES employee(id) ON DELETE SET NULL;
+-- Databases created before the encryption at rest get the blind index and
+-- room for the sealed values
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS phone_number_index VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE customer
+    ALTER COLUMN birth_date TYPE TEXT,
+    ALTER COLUMN description TYPE TEXT,
+    ALTER COLUMN phone_number TYPE TEXT,
+    ALTER COLUMN address TYPE TEXT;
+
+-- Deleted employees and customers are kept until the retention job purges them
+CREATE INDEX IF NOT EXISTS employee_deleted_at ON employee (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS customer_deleted_at ON customer (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- An email is unique among the rows that were not deleted, a deleted employee
+-- or customer can be created again
+ALTER TABLE employee DROP CONSTRAINT IF EXISTS employee_email_key;
+ALTER TABLE customer DROP CONSTRAINT IF EXISTS customer_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS employee_email ON employee (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS customer_email ON customer (email) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS customer_phone_number_index ON customer (phone_number_index) WHERE phone_number_index <> '';
+
+CREATE TABLE IF NOT EXISTS "customer_assignment" (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL REFERENCES customer(id) ON DELETE CASCADE,
+    previous_employee_id INT REFERENCES employee(id) ON DELETE SET NULL,
+    employee_id INT REFERENCES employee(id) ON DELETE SET NULL,
+    assigned_by INT REFERENCES employee(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW()
 );
 
 CREATE TABLE IF NOT EXISTS "payment" (
@@ -47,12 +93,23 @@ CREATE TABLE IF NOT EXISTS "payment" (
     soul_connection_id INT UNIQUE,
     date VARCHAR(255) NOT NULL,
     payment_method VARCHAR(255) NOT NULL,
-    amount FLOAT(25) NOT NULL,
+    amount BIGINT NOT NULL, -- in cents
     comment VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT NOW(),
     customer_id INT REFERENCES customer(id) ON DELETE CASCADE
 );
 
+-- Databases created before the amounts were stored in cents hold them as
+-- floats, they are converted once
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'payment' AND column_name = 'amount') <> 'bigint' THEN
+        ALTER TABLE payment ALTER COLUMN amount TYPE BIGINT USING round(amount::numeric * 100)::bigint;
+    END IF;
+END
+$$;
+
 CREATE TABLE IF NOT EXISTS "encounter" (
     id SERIAL PRIMARY KEY,
     date VARCHAR(255) NOT NULL,
@@ -81,3 +138,31 @@ CREATE TABLE IF NOT EXISTS "tip" (
     CONSTRAINT unique_tip UNIQUE (title, tip)
 );
 
+
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INT, -- upstream id of the employee, null for anonymous requests
+    actor_email VARCHAR(255),
+    entity VARCHAR(255) NOT NULL,
+    entity_id INT,
+    action VARCHAR(255) NOT NULL,
+    request_id VARCHAR(255) NOT NULL DEFAULT '',
+    diff JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity, entity_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor ON audit_log (actor_id);
+
+-- The log is append-only, rows can be added but never changed or removed. The
+-- diffs hold no personal data, erasing a customer has nothing to change there
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+CREATE OR REPLACE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -1,25 +1,62 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 
+	"soul-connection.com/api/src/config"
+	"soul-connection.com/api/src/database"
+	filestorage "soul-connection.com/api/src/file-storage"
+	"soul-connection.com/api/src/logger"
+	"soul-connection.com/api/src/metrics"
+	"soul-connection.com/api/src/tracing"
 	"soul-connection.com/migration/src/migration"
-	"soul-connection.com/migration/src/parser"
 )
 
 func main() {
-	params, err := parser.ParseArgs()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], config.PostgresSection, config.MongoSection, config.UpstreamSection)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if _, err := logger.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Configuration loaded", "config", cfg)
+
+	database.QueryTimeout = cfg.Timeouts.Query
+	filestorage.OperationTimeout = cfg.Timeouts.Storage
 
-	err = godotenv.Load(*params.EnvPath)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cmp.Or(cfg.Tracing.ServiceName, "soul-connection-migration"))
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer shutdownTracing(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.MetricsPort != 0 {
+		metricsServer := &http.Server{
+			Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+			Handler: metrics.Handler(),
+		}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Metrics server failed", "error", err)
+			}
+		}()
+		defer metricsServer.Close()
+	}
 
-	migration.Start()
+	migration.Start(ctx, cfg)
 }
@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -13,14 +14,15 @@ import (
 	"soul-connection.com/api/src/lib"
 )
 
-func migrateClothes(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, ids *Ids) error {
+func migrateClothes(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, ids *Ids) error {
 	var cs []struct {
 		Id   int
 		Type string
 	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/customers/%d/clothes", lib.ApiBaseUri, ids.old),
+		Url:     fmt.Sprintf("%s/api/customers/%d/clothes", credentials.BaseUri, ids.old),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -45,25 +47,29 @@ func migrateClothes(database *sql.DB, fileStorage *mongo.Database, credentials *
 	clothesDb := clothes.ClothesDB{DB: database, Bucket: bucket}
 
 	for _, c := range cs {
-		newClothe, err := clothesDb.Add(&clothes.AddClothe{
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		newClothe, err := clothesDb.Add(ctx, &clothes.AddClothe{
 			Soul_Connection_Id: &c.Id,
 			Type:               c.Type,
 			CustomerId:         ids.new,
 		})
-		if err != nil {
+		if record("clothes", err) != nil {
 			continue
 		}
 
-		migrateClotheImage(&clothesDb, credentials, &Ids{old: c.Id, new: newClothe.Id})
+		migrateClotheImage(ctx, &clothesDb, credentials, &Ids{old: c.Id, new: newClothe.Id})
 	}
 
 	return nil
 }
 
-func migrateClotheImage(db *clothes.ClothesDB, credentials *ApiCredentials, ids *Ids) error {
+func migrateClotheImage(ctx context.Context, db *clothes.ClothesDB, credentials *ApiCredentials, ids *Ids) error {
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/clothes/%d/image", lib.ApiBaseUri, ids.old),
+		Url:     fmt.Sprintf("%s/api/clothes/%d/image", credentials.BaseUri, ids.old),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -76,7 +82,7 @@ func migrateClotheImage(db *clothes.ClothesDB, credentials *ApiCredentials, ids
 		return fmt.Errorf("could not retrieve clothe image with id %d from api", ids.old)
 	}
 
-	_, err = db.UploadFile(ids.new, resp.Body, fmt.Sprintf("clothe_%d", ids.new))
+	_, err = db.UploadFile(ctx, ids.new, resp.Body, fmt.Sprintf("clothe_%d", ids.new))
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -11,15 +12,20 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"soul-connection.com/api/src/encryption"
 	"soul-connection.com/api/src/endpoints/customers"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
-func migrateCustomers(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
-	time.Sleep(2 * time.Second)
+func migrateCustomers(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, encrypted *encryption.Fields) error {
+	if err := sleep(ctx, 2*time.Second); err != nil {
+		return err
+	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/customers", lib.ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/customers", credentials.BaseUri),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -43,16 +49,19 @@ func migrateCustomers(database *sql.DB, fileStorage *mongo.Database, credentials
 		return err
 	}
 
-	lib.ServerLog("PROGRESS", fmt.Sprintf("Migrating customers, clothes and payments...:START:%d", len(customersResponse)))
+	progress := logger.StartProgress(ctx, "Migrating customers, clothes and payments...", len(customersResponse))
 	for _, e := range customersResponse {
-		lib.ServerLog("PROGRESS", "Migrating customers, clothes and payments...:INCREMENT")
-		migrateCustomer(database, fileStorage, credentials, e.Id)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.Increment()
+		record("customers", migrateCustomer(ctx, database, fileStorage, credentials, encrypted, e.Id))
 	}
-	lib.ServerLog("PROGRESS", "Migrating customers, clothes and payments...:COMPLETE")
+	progress.Complete()
 	return nil
 }
 
-func migrateCustomer(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, id int) error {
+func migrateCustomer(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, encrypted *encryption.Fields, id int) error {
 	var customer struct {
 		Id                int
 		Email             string
@@ -66,8 +75,9 @@ func migrateCustomer(database *sql.DB, fileStorage *mongo.Database, credentials
 		Address           string
 	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/customers/%d", lib.ApiBaseUri, id),
+		Url:     fmt.Sprintf("%s/api/customers/%d", credentials.BaseUri, id),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -90,9 +100,9 @@ func migrateCustomer(database *sql.DB, fileStorage *mongo.Database, credentials
 	if err != nil {
 		return err
 	}
-	customerDb := &customers.CustomersDB{DB: database, Bucket: bucket}
+	customerDb := &customers.CustomersDB{DB: database, Bucket: bucket, Encryption: encrypted}
 
-	newCustomer, err := customerDb.Add(&customers.AddCustomer{
+	newCustomer, err := customerDb.Add(ctx, &customers.AddCustomer{
 		Soul_Connection_Id: &customer.Id,
 		Email:              customer.Email,
 		Name:               customer.Name,
@@ -111,16 +121,17 @@ func migrateCustomer(database *sql.DB, fileStorage *mongo.Database, credentials
 		old: *newCustomer.Soul_Connection_Id,
 		new: newCustomer.Id,
 	}
-	migrateCustomerImage(customerDb, credentials, ids)
-	migrateClothes(database, fileStorage, credentials, ids)
-	migratePayments(database, fileStorage, credentials, ids)
+	migrateCustomerImage(ctx, customerDb, credentials, ids)
+	migrateClothes(ctx, database, fileStorage, credentials, ids)
+	migratePayments(ctx, database, fileStorage, credentials, ids)
 	return nil
 }
 
-func migrateCustomerImage(db *customers.CustomersDB, credentials *ApiCredentials, ids *Ids) error {
+func migrateCustomerImage(ctx context.Context, db *customers.CustomersDB, credentials *ApiCredentials, ids *Ids) error {
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/customers/%d/image", lib.ApiBaseUri, ids.old),
+		Url:     fmt.Sprintf("%s/api/customers/%d/image", credentials.BaseUri, ids.old),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -133,7 +144,7 @@ func migrateCustomerImage(db *customers.CustomersDB, credentials *ApiCredentials
 		return fmt.Errorf("could not retrieve user image with id %d from api", ids.old)
 	}
 
-	_, err = db.UploadFile(ids.new, resp.Body, fmt.Sprintf("customer_%d", ids.new))
+	_, err = db.UploadFile(ctx, ids.new, resp.Body, fmt.Sprintf("customer_%d", ids.new))
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -13,13 +14,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"soul-connection.com/api/src/endpoints/employees"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
-func migrateEmployees(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
-	time.Sleep(2 * time.Second)
+func migrateEmployees(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
+	if err := sleep(ctx, 2*time.Second); err != nil {
+		return err
+	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/employees", lib.ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/employees", credentials.BaseUri),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -48,16 +53,19 @@ func migrateEmployees(database *sql.DB, fileStorage *mongo.Database, credentials
 		return err
 	}
 	employeesDb := employees.EmployeesDB{DB: database, Bucket: bucket}
-	lib.ServerLog("PROGRESS", fmt.Sprintf("Migrating employees...:START:%d", len(employeesResponse)))
+	progress := logger.StartProgress(ctx, "Migrating employees...", len(employeesResponse))
 	for _, e := range employeesResponse {
-		lib.ServerLog("PROGRESS", "Migrating employees...:INCREMENT")
-		migrateEmployee(employeesDb, credentials, e.Id)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.Increment()
+		record("employees", migrateEmployee(ctx, employeesDb, credentials, e.Id))
 	}
-	lib.ServerLog("PROGRESS", "Migrating employees...:COMPLETE")
+	progress.Complete()
 	return nil
 }
 
-func migrateEmployee(db employees.EmployeesDB, credentials *ApiCredentials, id int) error {
+func migrateEmployee(ctx context.Context, db employees.EmployeesDB, credentials *ApiCredentials, id int) error {
 	var employeeResponse struct {
 		Id    int
 		Email string
@@ -69,8 +77,9 @@ func migrateEmployee(db employees.EmployeesDB, credentials *ApiCredentials, id i
 		Work       string
 	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/employees/%d", lib.ApiBaseUri, id),
+		Url:     fmt.Sprintf("%s/api/employees/%d", credentials.BaseUri, id),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -88,7 +97,7 @@ func migrateEmployee(db employees.EmployeesDB, credentials *ApiCredentials, id i
 		return err
 	}
 
-	employee, err := db.Add(&employees.AddEmployee{
+	employee, err := db.Add(ctx, &employees.AddEmployee{
 		Soul_Connection_Id: &employeeResponse.Id,
 		Email:              employeeResponse.Email,
 		Name:               employeeResponse.Name,
@@ -101,17 +110,18 @@ func migrateEmployee(db employees.EmployeesDB, credentials *ApiCredentials, id i
 		return err
 	}
 
-	err = migrateEmployeeImage(&db, credentials, &Ids{old: id, new: employee.Id})
+	err = migrateEmployeeImage(ctx, &db, credentials, &Ids{old: id, new: employee.Id})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func migrateEmployeeImage(db *employees.EmployeesDB, credentials *ApiCredentials, ids *Ids) error {
+func migrateEmployeeImage(ctx context.Context, db *employees.EmployeesDB, credentials *ApiCredentials, ids *Ids) error {
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/employees/%d/image", lib.ApiBaseUri, ids.old),
+		Url:     fmt.Sprintf("%s/api/employees/%d/image", credentials.BaseUri, ids.old),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -124,7 +134,7 @@ func migrateEmployeeImage(db *employees.EmployeesDB, credentials *ApiCredentials
 		return fmt.Errorf("could not retrieve user image with id %d from api", ids.old)
 	}
 
-	_, err = db.UploadFile(ids.new, resp.Body, fmt.Sprintf("employee_%d", ids.new))
+	_, err = db.UploadFile(ctx, ids.new, resp.Body, fmt.Sprintf("employee_%d", ids.new))
 	if err != nil {
 		return err
 	}
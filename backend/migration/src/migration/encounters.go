@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -9,16 +10,21 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"soul-connection.com/api/src/encryption"
 	"soul-connection.com/api/src/endpoints/customers"
 	"soul-connection.com/api/src/endpoints/encounters"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
-func migrateEncounters(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
-	time.Sleep(2 * time.Second)
+func migrateEncounters(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, encrypted *encryption.Fields) error {
+	if err := sleep(ctx, 2*time.Second); err != nil {
+		return err
+	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/encounters", lib.ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/encounters", credentials.BaseUri),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -42,20 +48,24 @@ func migrateEncounters(database *sql.DB, fileStorage *mongo.Database, credential
 		return err
 	}
 
-	lib.ServerLog("PROGRESS", fmt.Sprintf("Migrating encounters...:START:%d", len(encountersResponse)))
+	progress := logger.StartProgress(ctx, "Migrating encounters...", len(encountersResponse))
 	for _, e := range encountersResponse {
-		lib.ServerLog("PROGRESS", "Migrating encounters...:INCREMENT")
-		migrateEncounter(database, credentials, e.Id)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.Increment()
+		record("encounters", migrateEncounter(ctx, database, credentials, encrypted, e.Id))
 	}
-	lib.ServerLog("PROGRESS", "Migrating encounters...:COMPLETE")
+	progress.Complete()
 	return nil
 }
 
-func migrateEncounter(database *sql.DB, credentials *ApiCredentials, id int) error {
+func migrateEncounter(ctx context.Context, database *sql.DB, credentials *ApiCredentials, encrypted *encryption.Fields, id int) error {
 	var encounter encounters.AddEncounter
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/encounters/%d", lib.ApiBaseUri, id),
+		Url:     fmt.Sprintf("%s/api/encounters/%d", credentials.BaseUri, id),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -74,13 +84,13 @@ func migrateEncounter(database *sql.DB, credentials *ApiCredentials, id int) err
 	}
 
 	encounterDb := encounters.EncountersDB{DB: database}
-	customersDb := customers.CustomersDB{DB: database}
-	customer, err := customersDb.FindByOldID(encounter.Customer_Id)
+	customersDb := customers.CustomersDB{DB: database, Encryption: encrypted}
+	customer, err := customersDb.FindByOldID(ctx, encounter.Customer_Id)
 	if err != nil {
 		return err
 	}
 	encounter.Customer_Id = customer.Id
-	_, err = encounterDb.Add(&encounter)
+	_, err = encounterDb.Add(ctx, &encounter)
 	if err != nil {
 		return err
 	}
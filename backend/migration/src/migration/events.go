@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -12,13 +13,17 @@ import (
 	"soul-connection.com/api/src/endpoints/employees"
 	"soul-connection.com/api/src/endpoints/events"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
-func migrateEvents(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
-	time.Sleep(2 * time.Second)
+func migrateEvents(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
+	if err := sleep(ctx, 2*time.Second); err != nil {
+		return err
+	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/events", lib.ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/events", credentials.BaseUri),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -43,20 +48,24 @@ func migrateEvents(database *sql.DB, fileStorage *mongo.Database, credentials *A
 		return err
 	}
 
-	lib.ServerLog("PROGRESS", fmt.Sprintf("Migrating events...:START:%d", len(eventsResponse)))
+	progress := logger.StartProgress(ctx, "Migrating events...", len(eventsResponse))
 	for _, e := range eventsResponse {
-		lib.ServerLog("PROGRESS", "Migrating events...:INCREMENT")
-		migrateEvent(database, fileStorage, credentials, e.Id)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.Increment()
+		record("events", migrateEvent(ctx, database, fileStorage, credentials, e.Id))
 	}
-	lib.ServerLog("PROGRESS", "Migrating events...:COMPLETE")
+	progress.Complete()
 	return nil
 }
 
-func migrateEvent(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, id int) error {
+func migrateEvent(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, id int) error {
 	var event events.AddEvent
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/events/%d", lib.ApiBaseUri, id),
+		Url:     fmt.Sprintf("%s/api/events/%d", credentials.BaseUri, id),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -77,12 +86,12 @@ func migrateEvent(database *sql.DB, fileStorage *mongo.Database, credentials *Ap
 
 	eventsDb := events.EventsDB{DB: database}
 	employeeDb := employees.EmployeesDB{DB: database}
-	employee, err := employeeDb.FindByOldID(event.Employee_Id)
+	employee, err := employeeDb.FindByOldID(ctx, event.Employee_Id)
 	if err != nil {
 		return err
 	}
 	event.Employee_Id = employee.Id
-	_, err = eventsDb.Add(&event)
+	_, err = eventsDb.Add(ctx, &event)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,34 @@
+package migration
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	syncedRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "migration_records_total",
+		Help: "Records synced from the Soul Connection API by entity and result.",
+	}, []string{"entity", "result"})
+
+	lastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "migration_last_success_timestamp_seconds",
+		Help: "Unix time of the last sync of an entity that completed without error.",
+	}, []string{"entity"})
+)
+
+// record counts the sync of a single record of entity and answers err.
+func record(entity string, err error) error {
+	result := "synced"
+	if err != nil {
+		result = "failed"
+	}
+	syncedRecords.WithLabelValues(entity, result).Inc()
+	return err
+}
+
+func succeeded(entity string) {
+	lastSuccess.WithLabelValues(entity).Set(float64(time.Now().Unix()))
+}
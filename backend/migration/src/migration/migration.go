@@ -1,15 +1,21 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"log"
-	"os"
+	"log/slog"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
+	"soul-connection.com/api/src/config"
 	"soul-connection.com/api/src/database"
+	"soul-connection.com/api/src/encryption"
+	"soul-connection.com/api/src/endpoints/customers"
 	"soul-connection.com/api/src/file-storage"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/metrics"
+	"soul-connection.com/api/src/tracing"
 )
 
 type ApiCredentials struct {
@@ -22,58 +28,82 @@ type Ids struct {
 	new int
 }
 
-type MigrationFunc func(*sql.DB, *mongo.Database, *ApiCredentials) error
+type MigrationFunc func(context.Context, *sql.DB, *mongo.Database, *ApiCredentials) error
 
 const migrationRate int = 60 * 24
 
-func Start() {
+// Start runs the migration now and then every migrationRate minutes until
+// ctx is cancelled, a run in progress stops at its next request.
+func Start(ctx context.Context, cfg *config.Config) {
 	ticker := time.NewTicker(time.Duration(migrationRate) * time.Minute)
-	quit := make(chan struct{})
+	quit := make(chan struct{}, 1)
 	credentials := lib.LoginCredentials{
-		XGroupAuthentication: os.Getenv("API_KEY"),
-		AuthEmail:            os.Getenv("API_EMAIL"),
-		AuthPassword:         os.Getenv("API_PASSWORD"),
+		BaseUri:              cfg.Upstream.BaseUri,
+		XGroupAuthentication: cfg.Upstream.ApiKey,
+		AuthEmail:            cfg.Upstream.Email,
+		AuthPassword:         cfg.Upstream.Password,
 	}
-	database, err := database.Open(database.ConnectionString())
+	encrypted, err := customers.NewEncryption(cfg.Encryption.Keys, cfg.Encryption.IndexKey, cfg.Encryption.Fields)
 	if err != nil {
-		lib.ServerLog("ERROR", err)
+		slog.Error("Could not read the encryption keys", "error", err)
 		return
 	}
-	defer database.Close()
+	startupCtx, cancel := context.WithTimeout(ctx, cfg.Timeouts.Startup)
+	defer cancel()
 
-	mongoClient, ctx, err := filestorage.Open(filestorage.ConnectionString())
+	var db *sql.DB
+	err = lib.Retry(startupCtx, "postgres", func(ctx context.Context) (err error) {
+		db, err = database.Open(ctx, cfg.Postgres.ConnectionString())
+		return err
+	})
+	if err != nil {
+		slog.Error("Could not connect to postgres", "error", err)
+		return
+	}
+	defer db.Close()
+	metrics.RegisterDB(db, "postgres")
+
+	var mongoClient *mongo.Client
+	err = lib.Retry(startupCtx, "mongo", func(ctx context.Context) (err error) {
+		mongoClient, err = filestorage.Open(ctx, cfg.Mongo.ConnectionString())
+		return err
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer mongoClient.Disconnect(ctx)
+	defer mongoClient.Disconnect(context.Background())
 	fileStorage := mongoClient.Database("soul-connection-files")
 
-	lib.ServerLog("INFO", "Running migration")
-	run(database, fileStorage, credentials, quit)
-	lib.ServerLog("INFO", "Migration complete")
+	slog.Info("Running migration")
+	run(ctx, db, fileStorage, credentials, encrypted, quit)
+	slog.Info("Migration complete")
 
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				lib.ServerLog("INFO", "Running migration")
-				run(database, fileStorage, credentials, quit)
-				lib.ServerLog("INFO", "Migration complete")
+				slog.Info("Running migration")
+				run(ctx, db, fileStorage, credentials, encrypted, quit)
+				slog.Info("Migration complete")
 			case <-quit:
 				ticker.Stop()
 				return
+			case <-ctx.Done():
+				ticker.Stop()
+				return
 			}
 		}
 	}()
 
-	select {}
+	<-ctx.Done()
+	slog.Info("Migration stopped")
 }
 
-func run(database *sql.DB, fileStorage *mongo.Database, loginCredentials lib.LoginCredentials, quit chan struct{}) {
-	jwt, err := lib.Auth(loginCredentials)
+func run(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, loginCredentials lib.LoginCredentials, encrypted *encryption.Fields, quit chan struct{}) {
+	jwt, err := lib.Auth(ctx, loginCredentials)
 
 	if err != nil {
-		lib.ServerLog("ERROR", err)
+		slog.Error("Could not authenticate to the api", "error", err)
 		quit <- struct{}{}
 		return
 	}
@@ -82,18 +112,50 @@ func run(database *sql.DB, fileStorage *mongo.Database, loginCredentials lib.Log
 		Jwt:              jwt,
 	}
 
-	migrations := []MigrationFunc{
-		migrateEmployees,
-		migrateCustomers,
-		migrateEncounters,
-		migrateTips,
-		migrateEvents,
+	migrations := []struct {
+		entity  string
+		migrate MigrationFunc
+	}{
+		{"employees", migrateEmployees},
+		{"customers", withEncryption(migrateCustomers, encrypted)},
+		{"encounters", withEncryption(migrateEncounters, encrypted)},
+		{"tips", migrateTips},
+		{"events", migrateEvents},
 	}
 
 	for _, migration := range migrations {
-		err := migration(database, fileStorage, &apiCredentials)
+		ctx, span := tracing.Tracer().Start(ctx, "migration."+migration.entity)
+		err := migration.migrate(ctx, database, fileStorage, &apiCredentials)
+		tracing.Fail(span, err)
+		span.End()
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			lib.ServerLog("WARNING", err)
+			slog.Warn("Migration failed", "entity", migration.entity, "error", err)
+			continue
 		}
+		succeeded(migration.entity)
+	}
+}
+
+// withEncryption binds the migration of entities reading or writing the
+// sensitive values of the customers to the fields sealing them.
+func withEncryption(migrate func(context.Context, *sql.DB, *mongo.Database, *ApiCredentials, *encryption.Fields) error, encrypted *encryption.Fields) MigrationFunc {
+	return func(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
+		return migrate(ctx, database, fileStorage, credentials, encrypted)
+	}
+}
+
+// sleep pauses for d unless ctx is cancelled first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -1,9 +1,11 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,17 +13,18 @@ import (
 	"soul-connection.com/api/src/lib"
 )
 
-func migratePayments(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, ids *Ids) error {
+func migratePayments(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials, ids *Ids) error {
 	var ps []struct {
 		Id             int
 		Date           string
 		Payment_Method string
-		Amount         float64
+		Amount         json.Number
 		Comment        string
 	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/customers/%d/payments_history", lib.ApiBaseUri, ids.old),
+		Url:     fmt.Sprintf("%s/api/customers/%d/payments_history", credentials.BaseUri, ids.old),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -41,14 +44,26 @@ func migratePayments(database *sql.DB, fileStorage *mongo.Database, credentials
 
 	paymentsDb := payments.PaymentsDB{DB: database}
 	for _, e := range ps {
-		paymentsDb.Add(&payments.AddPayment{
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// NOTE: The upstream api does not limit the decimals, they are rounded
+		// rather than refusing the payment
+		amount, err := payments.RoundAmount(e.Amount.String())
+		if err != nil {
+			slog.Warn("Could not read the amount of a payment", "id", e.Id, "error", err)
+			record("payments", err)
+			continue
+		}
+		_, err = paymentsDb.Add(ctx, &payments.AddPayment{
 			Soul_Connection_Id: &e.Id,
 			Date:               e.Date,
 			PaymentMethod:      e.Payment_Method,
-			Amount:             e.Amount,
+			Amount:             amount,
 			Comment:            e.Comment,
 			CustomerId:         ids.new,
 		})
+		record("payments", err)
 	}
 
 	return nil
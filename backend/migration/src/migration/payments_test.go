@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"soul-connection.com/api/src/endpoints/payments"
+	"soul-connection.com/api/src/lib"
+)
+
+func TestMigratePayments(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/customers/7/payments_history" {
+			http.NotFound(res, req)
+			return
+		}
+		res.Write([]byte(`[
+			{"id": 1, "date": "2024-05-01", "payment_method": "PayPal", "amount": 12.5, "comment": "exact"},
+			{"id": 2, "date": "2024-05-02", "payment_method": "PayPal", "amount": 12.345, "comment": "half"},
+			{"id": 3, "date": "2024-05-03", "payment_method": "PayPal", "amount": 3.14159, "comment": "long"}
+		]`))
+	}))
+	defer upstream.Close()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+	CREATE TABLE payment (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		soul_connection_id INTEGER,
+		date TEXT NOT NULL,
+		payment_method TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		comment TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		customer_id INTEGER NOT NULL
+	);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create the schema: %v", err)
+	}
+
+	credentials := &ApiCredentials{LoginCredentials: lib.LoginCredentials{BaseUri: upstream.URL}}
+	if err := migratePayments(context.Background(), db, nil, credentials, &Ids{old: 7, new: 1}); err != nil {
+		t.Fatalf("Failed to migrate the payments: %v", err)
+	}
+
+	migrated, err := payments.PaymentsDB{DB: db}.FindByCustomerID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Failed to read the payments: %v", err)
+	}
+	expected := map[string]payments.Amount{"exact": 1250, "half": 1234, "long": 314}
+	if len(migrated) != len(expected) {
+		t.Fatalf("Expected every payment to be migrated, got %d", len(migrated))
+	}
+	for _, payment := range migrated {
+		if payment.Amount != expected[payment.Comment] {
+			t.Errorf("Expected %s to be rounded to %s, got %s", payment.Comment, expected[payment.Comment], payment.Amount)
+		}
+	}
+}
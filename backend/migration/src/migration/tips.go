@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -11,13 +12,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"soul-connection.com/api/src/endpoints/tips"
 	"soul-connection.com/api/src/lib"
+	"soul-connection.com/api/src/logger"
 )
 
-func migrateTips(database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
-	time.Sleep(2 * time.Second)
+func migrateTips(ctx context.Context, database *sql.DB, fileStorage *mongo.Database, credentials *ApiCredentials) error {
+	if err := sleep(ctx, 2*time.Second); err != nil {
+		return err
+	}
 	resp, err := lib.Fetch(&http.Client{}, lib.FetchRequest{
+		Context: ctx,
 		Method:  "GET",
-		Url:     fmt.Sprintf("%s/api/tips", lib.ApiBaseUri),
+		Url:     fmt.Sprintf("%s/api/tips", credentials.BaseUri),
 		Body:    nil,
 		Headers: map[string]string{"X-Group-Authorization": credentials.XGroupAuthentication, "Authorization": fmt.Sprintf("Bearer %s", credentials.Jwt)},
 	})
@@ -38,11 +43,15 @@ func migrateTips(database *sql.DB, fileStorage *mongo.Database, credentials *Api
 	}
 
 	tipsDb := tips.TipsDB{DB: database}
-	lib.ServerLog("PROGRESS", fmt.Sprintf("Migrating tips...:START:%d", len(tip)))
+	progress := logger.StartProgress(ctx, "Migrating tips...", len(tip))
 	for _, e := range tip {
-		lib.ServerLog("PROGRESS", "Migrating tips...:INCREMENT")
-		tipsDb.Add(&e)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		progress.Increment()
+		_, err := tipsDb.Add(ctx, &e)
+		record("tips", err)
 	}
-	lib.ServerLog("PROGRESS", "Migrating tips...:COMPLETE")
+	progress.Complete()
 	return nil
 }
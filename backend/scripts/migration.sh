@@ -2,7 +2,6 @@
 
 # This SCRIPT is ONLY to be used in the DOCKERFILE
 
-./scripts/wait-for-it.sh db:5432 5432 '-- echo "Postgres is ready"'
-./scripts/wait-for-it.sh file-storage:27017 27017 '-- echo "Mongo is ready"'
-./migration/migration -env-path .env
+# NOTE: exec so SIGTERM reaches the binary and it can shut down gracefully
+exec ./migration/migration -env-path .env
 
@@ -17,14 +17,6 @@ total_packages=0
 total_failed=0
 total_tests=0
 
-disable_logger() {
-    go run -exec "lib.DisableLogger()" > /dev/null 2>&1
-}
-
-enable_logger() {
-    go run -exec "lib.EnableLogger()" > /dev/null 2>&1
-}
-
 packages=$(go list ./... | grep -v /vendor/)
 
 run_tests() {
@@ -54,14 +46,10 @@ run_tests() {
     echo -e "${BOLD}${YELLOW}-------------------------------------------------------${RESET}"
 }
 
-disable_logger
-
 for package in $packages; do
     run_tests "$package"
 done
 
-enable_logger
-
 if [ "$total_packages" -gt 0 ]; then
     average_coverage=$(echo "scale=2; $total_coverage / $total_packages" | bc)
 else
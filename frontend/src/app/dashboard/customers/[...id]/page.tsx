@@ -28,7 +28,7 @@ const getImage = async (customerId: number): Promise<Blob | null> => {
 
   try {
     const response = await fetch(
-      `${process.env.NEXT_PUBLIC_API_URL}/api/customers/${customerId}/image`,
+      `${process.env.NEXT_PUBLIC_API_URL}/api/v1/customers/${customerId}/image`,
       {
         headers: {
           Authorization: `${session.token}`,
@@ -57,7 +57,7 @@ const WardrobePage = () => {
     const getImage = async (imageId: number) => {
         const session = await getSession();
         if (!session) return null;
-        const response = await fetch(`${process.env.NEXT_PUBLIC_API_URL}/api/clothes/${imageId}/image`, {
+        const response = await fetch(`${process.env.NEXT_PUBLIC_API_URL}/api/v1/clothes/${imageId}/image`, {
             headers: {
                 Authorization: `${session.token}`,
             },
@@ -13,7 +13,7 @@ export const employeeSchema = z.object({
   Soul_Connection_Id: z.number().nullable(),
   Name: z.string(),
   Email: z.string(),
-  Password: z.string(),
+  Password: z.string().optional(),
   Surname: z.string(),
   Birth_Date: z.string(),
   Gender: z.string(),